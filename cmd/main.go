@@ -23,6 +23,63 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:      "import",
+			Usage:     "import events from an iCalendar file",
+			ArgsUsage: "file.ics",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "user",
+					Usage:    "login of the user the events are imported for",
+					Required: true,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.Exit("exactly one iCalendar file has to be given", 1)
+				}
+
+				return serve.Import(ctx, os.Stdout, c.String("user"), c.Args().First())
+			},
+		},
+		{
+			Name:  "events",
+			Usage: "manage events",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "assign-unowned",
+					Usage: "hand events created before events had owners over to a user",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "user",
+							Usage:    "login of the user the events are assigned to",
+							Required: true,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return serve.AssignUnownedEvents(ctx, os.Stdout, c.String("user"))
+					},
+				},
+			},
+		},
+		{
+			Name:  "user",
+			Usage: "manage users",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "promote",
+					Usage:     "grant the administrator role to a user",
+					ArgsUsage: "login",
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 1 {
+							return cli.Exit("exactly one login has to be given", 1)
+						}
+
+						return serve.PromoteUser(ctx, os.Stdout, c.Args().First())
+					},
+				},
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
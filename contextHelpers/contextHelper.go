@@ -2,6 +2,8 @@ package contextHelpers
 
 import (
 	"context"
+
+	"github.com/bubo-py/McK/types"
 )
 
 type contextKey string
@@ -9,6 +11,9 @@ type contextKey string
 var (
 	loginKey    = contextKey("login")
 	timezoneKey = contextKey("timezone")
+	scopesKey   = contextKey("scopes")
+	roleKey     = contextKey("role")
+	clientIPKey = contextKey("clientIP")
 )
 
 func WriteLoginToContext(ctx context.Context, value string) context.Context {
@@ -21,6 +26,23 @@ func WriteTimezoneToContext(ctx context.Context, value string) context.Context {
 	return ctxWithData
 }
 
+// WriteScopesToContext limits the request to the scopes of the API token it was authenticated with
+func WriteScopesToContext(ctx context.Context, value []types.TokenScope) context.Context {
+	ctxWithData := context.WithValue(ctx, scopesKey, value)
+	return ctxWithData
+}
+
+func WriteRoleToContext(ctx context.Context, value types.Role) context.Context {
+	ctxWithData := context.WithValue(ctx, roleKey, value)
+	return ctxWithData
+}
+
+// WriteClientIPToContext keeps the address of the client, failed logins are counted for it
+func WriteClientIPToContext(ctx context.Context, value string) context.Context {
+	ctxWithData := context.WithValue(ctx, clientIPKey, value)
+	return ctxWithData
+}
+
 func RetrieveLoginFromContext(ctx context.Context) (string, bool) {
 	login, ok := ctx.Value(loginKey).(string)
 	return login, ok
@@ -30,3 +52,19 @@ func RetrieveTimezoneFromContext(ctx context.Context) (string, bool) {
 	timezone, ok := ctx.Value(timezoneKey).(string)
 	return timezone, ok
 }
+
+// RetrieveScopesFromContext returns the scopes of the request, it is not limited by scopes when ok is false
+func RetrieveScopesFromContext(ctx context.Context) ([]types.TokenScope, bool) {
+	scopes, ok := ctx.Value(scopesKey).([]types.TokenScope)
+	return scopes, ok
+}
+
+func RetrieveRoleFromContext(ctx context.Context) (types.Role, bool) {
+	role, ok := ctx.Value(roleKey).(types.Role)
+	return role, ok
+}
+
+func RetrieveClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey).(string)
+	return ip, ok
+}
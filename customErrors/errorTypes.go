@@ -7,6 +7,9 @@ import (
 type ReturnError struct {
 	ErrorType    string
 	ErrorMessage string
+
+	// Fields of a ValidationError
+	Fields []FieldError `json:",omitempty"`
 }
 
 type CustomError struct {
@@ -34,12 +37,24 @@ var ErrUnauthenticated = CustomError{
 	ErrorType: "Unauthenticated",
 }
 
+var ErrOTPRequired = CustomError{
+	Err: errors.New("a one-time password is required"),
+	// The password was correct but the user has two-factor authentication, 401
+	ErrorType: "OTPRequired",
+}
+
 var ErrUnauthorized = CustomError{
 	Err: errors.New("the server cannot process the request due to lack of client's access rights"),
 	// Authorization checks whether users have permission to access a resource, 403
 	ErrorType: "Unauthorized",
 }
 
+var ErrTooManyRequests = CustomError{
+	Err: errors.New("too many requests, try again later"),
+	// Rate limiting rejects requests until the client waits, 429
+	ErrorType: "TooManyRequests",
+}
+
 var ErrUnexpected = CustomError{
 	Err:       errors.New("an unexpected error occurred"),
 	ErrorType: "Unexpected",
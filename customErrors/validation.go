@@ -0,0 +1,40 @@
+package customErrors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError tells why a field of a request is invalid, Code is a stable identifier clients can rely on
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Codes of field errors
+const (
+	CodeRequired        = "required"
+	CodeTooLong         = "tooLong"
+	CodeEndBeforeStart  = "endBeforeStart"
+	CodeAlertAfterStart = "alertAfterStart"
+	CodeInvalid         = "invalid"
+)
+
+// ValidationError lists every invalid field of a request, it wraps ErrBadRequest
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e ValidationError) Error() string {
+	s := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		s[i] = fmt.Sprintf("%s: %s", f.Field, f.Message)
+	}
+
+	return fmt.Sprintf("%v: %s", ErrBadRequest, strings.Join(s, "; "))
+}
+
+func (e ValidationError) Unwrap() error {
+	return ErrBadRequest
+}
@@ -0,0 +1,112 @@
+package alerts
+
+import (
+	"context"
+	"time"
+
+	"github.com/bubo-py/McK/events/service"
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/worker"
+)
+
+// Config of the dispatcher, alerts which run out of attempts are marked as failed
+type Config struct {
+	worker.Config
+
+	// Lateness limits how long after their alert time missed alerts are still delivered, e.g. after a downtime
+	Lateness time.Duration
+}
+
+var DefaultConfig = Config{
+	Config: worker.Config{
+		Interval:    30 * time.Second,
+		Lease:       time.Minute,
+		BatchSize:   100,
+		MaxAttempts: 5,
+	},
+	Lateness: time.Hour,
+}
+
+// Dispatcher delivers alerts of events at their alert time. Alerts are claimed in the database,
+// so any number of replicas can run a dispatcher without delivering an alert twice.
+type Dispatcher struct {
+	bl       service.AlertsInterface
+	notifier Notifier
+	config   Config
+}
+
+func InitDispatcher(bl service.AlertsInterface, notifier Notifier, config Config) Dispatcher {
+	var d Dispatcher
+	d.bl = bl
+	d.notifier = notifier
+	d.config = config
+	return d
+}
+
+// Run polls for due alerts until the context is cancelled
+func (d Dispatcher) Run(ctx context.Context) {
+	worker.Run(ctx, "Alert dispatch", d.config.Interval, d.Dispatch)
+}
+
+// Dispatch plans alerts up to the next poll and delivers the ones due at now, it returns the number of delivered alerts
+func (d Dispatcher) Dispatch(ctx context.Context, now time.Time) (int, error) {
+	delivered := 0
+
+	err := d.bl.PlanAlerts(ctx, now.Add(-d.config.Lateness), now.Add(d.config.Interval))
+	if err != nil {
+		return delivered, err
+	}
+
+	for {
+		alerts, err := d.bl.ClaimAlerts(ctx, now, d.config.Lease, d.config.BatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		if len(alerts) == 0 {
+			return delivered, nil
+		}
+
+		for _, a := range alerts {
+			a = d.deliver(ctx, a, now)
+			if a.Status == types.AlertDelivered {
+				delivered++
+			}
+
+			err = d.bl.FinishAlert(ctx, a)
+			if err != nil {
+				return delivered, err
+			}
+		}
+	}
+}
+
+// deliver notifies about the alert and sets its state according to the outcome
+func (d Dispatcher) deliver(ctx context.Context, a types.Alert, now time.Time) types.Alert {
+	if now.Sub(a.AlertTime) > d.config.Lateness {
+		a.Status = types.AlertFailed
+		a.LastError = "alert is too late to be delivered"
+		return a
+	}
+
+	err := d.notifier.Notify(ctx, a)
+	if err == nil {
+		deliveredAt := now.UTC()
+		a.Status = types.AlertDelivered
+		a.LastError = ""
+		a.DeliveredAt = &deliveredAt
+		return a
+	}
+
+	a.LastError = err.Error()
+
+	if a.Attempts >= d.config.MaxAttempts {
+		a.Status = types.AlertFailed
+		return a
+	}
+
+	a.Status = types.AlertPending
+	a.NextAttempt = now.Add(worker.RetryDelay(d.config.Interval, a.Attempts))
+
+	return a
+}
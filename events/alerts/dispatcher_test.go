@@ -0,0 +1,134 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/events/repositories/memoryStorage"
+	"github.com/bubo-py/McK/events/service"
+	"github.com/bubo-py/McK/types"
+	"github.com/stretchr/testify/require"
+)
+
+type webhook struct {
+	mu       sync.Mutex
+	received []types.Alert
+	fail     bool
+}
+
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var a types.Alert
+	_ = json.NewDecoder(r.Body).Decode(&a)
+	h.received = append(h.received, a)
+}
+
+func TestDispatch(t *testing.T) {
+	ctx := context.Background()
+	userCtx := contextHelpers.WriteLoginToContext(ctx, "hello")
+	userCtx = contextHelpers.WriteTimezoneToContext(userCtx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	db.Timezones["hello"] = "Europe/Warsaw"
+	bl := service.InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	ti := time.Date(2023, 3, 24, 9, 0, 0, 0, loc)
+
+	err := bl.AddEvent(userCtx, types.Event{Name: "Review", StartTime: ti, EndTime: ti.Add(time.Hour),
+		AlertTime: ti.Add(-15 * time.Minute)})
+	require.Nil(t, err)
+
+	// Daily occurrences keep their local time across the DST change on 26 March
+	err = bl.AddEvent(userCtx, types.Event{Name: "Stand-up", StartTime: ti, EndTime: ti.Add(15 * time.Minute),
+		AlertTime: ti.Add(-5 * time.Minute), Recurrence: "FREQ=DAILY;COUNT=5"})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(userCtx, types.Event{Name: "No alert", StartTime: ti, EndTime: ti})
+	require.Nil(t, err)
+
+	hook := &webhook{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	config := DefaultConfig
+	config.Interval = time.Minute
+	d := InitDispatcher(bl, InitWebhookNotifier(server.URL), config)
+
+	delivered, err := d.Dispatch(ctx, ti.Add(-20*time.Minute))
+	require.Nil(t, err)
+	require.Equal(t, 0, delivered, "alerts should not be delivered before their time")
+
+	delivered, err = d.Dispatch(ctx, ti.Add(-5*time.Minute))
+	require.Nil(t, err)
+	require.Equal(t, 2, delivered)
+	require.Len(t, hook.received, 2)
+	require.Equal(t, "Review", hook.received[0].Name)
+	require.Equal(t, "Stand-up", hook.received[1].Name)
+	require.Equal(t, ti, hook.received[1].StartTime.In(loc))
+
+	delivered, err = d.Dispatch(ctx, ti.Add(-4*time.Minute))
+	require.Nil(t, err)
+	require.Equal(t, 0, delivered, "alerts should be delivered once")
+
+	sunday := time.Date(2023, 3, 26, 8, 55, 0, 0, loc)
+	delivered, err = d.Dispatch(ctx, sunday)
+	require.Nil(t, err)
+	require.Equal(t, 1, delivered)
+	require.Equal(t, time.Date(2023, 3, 26, 9, 0, 0, 0, loc), hook.received[2].StartTime.In(loc))
+
+	// A cancelled occurrence does not alert, even when its alert was planned before
+	monday := time.Date(2023, 3, 27, 8, 55, 0, 0, loc)
+	_, err = d.Dispatch(ctx, monday.Add(-30*time.Second))
+	require.Nil(t, err)
+
+	err = bl.DeleteOccurrence(userCtx, 2, time.Date(2023, 3, 27, 9, 0, 0, 0, loc), types.ScopeThis)
+	require.Nil(t, err)
+
+	delivered, err = d.Dispatch(ctx, monday)
+	require.Nil(t, err)
+	require.Equal(t, 0, delivered)
+	require.Equal(t, types.AlertCancelled, db.Alerts[len(db.Alerts)-1].Status)
+
+	// Failed deliveries are retried with a backoff
+	tuesday := time.Date(2023, 3, 28, 8, 55, 0, 0, loc)
+	hook.fail = true
+
+	delivered, err = d.Dispatch(ctx, tuesday)
+	require.Nil(t, err)
+	require.Equal(t, 0, delivered)
+
+	a := db.Alerts[len(db.Alerts)-1]
+	require.Equal(t, types.AlertPending, a.Status)
+	require.Equal(t, 1, a.Attempts)
+	require.Equal(t, "webhook responded with 503 Service Unavailable", a.LastError)
+	require.Equal(t, tuesday.Add(time.Minute), a.NextAttempt)
+
+	hook.fail = false
+
+	delivered, err = d.Dispatch(ctx, tuesday.Add(30*time.Second))
+	require.Nil(t, err)
+	require.Equal(t, 0, delivered, "retries should wait for the backoff")
+
+	delivered, err = d.Dispatch(ctx, tuesday.Add(time.Minute))
+	require.Nil(t, err)
+	require.Equal(t, 1, delivered)
+
+	a = db.Alerts[len(db.Alerts)-1]
+	require.Equal(t, types.AlertDelivered, a.Status)
+	require.Equal(t, 2, a.Attempts)
+	require.NotNil(t, a.DeliveredAt)
+}
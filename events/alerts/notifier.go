@@ -0,0 +1,66 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/worker"
+)
+
+// Notifier delivers an alert to its recipient, an error makes the dispatcher retry the delivery later
+type Notifier interface {
+	Notify(ctx context.Context, a types.Alert) error
+}
+
+// LogNotifier writes alerts to a logger, the standard one when Logger is nil
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func (n LogNotifier) Notify(ctx context.Context, a types.Alert) error {
+	logger := n.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	logger.Printf("Alert for %s: %q starts at %s", a.Login, a.Name, a.StartTime.Format(time.RFC3339))
+	return nil
+}
+
+// WebhookNotifier posts alerts as JSON to a URL, any response other than 2xx fails the delivery
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// webhookTimeout limits a single delivery when no client is configured
+const webhookTimeout = 10 * time.Second
+
+func InitWebhookNotifier(url string) WebhookNotifier {
+	return WebhookNotifier{URL: url, Client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, a types.Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+
+	return worker.Send(client, req)
+}
@@ -0,0 +1,50 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, time.UTC)
+	alert := types.Alert{ID: 1, EventID: 2, Login: "hello", Name: "Meeting", StartTime: ti, AlertTime: ti}
+
+	testCases := []struct {
+		name       string
+		statusCode int
+		expErr     bool
+	}{
+		{name: "ok", statusCode: http.StatusNoContent},
+		{name: "serverError", statusCode: http.StatusInternalServerError, expErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received types.Alert
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				require.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			err := InitWebhookNotifier(server.URL).Notify(context.Background(), alert)
+			if tc.expErr {
+				require.Error(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+
+			require.Equal(t, alert.Name, received.Name)
+			require.True(t, alert.StartTime.Equal(received.StartTime))
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/go-chi/chi"
+)
+
+func (h *Handler) GetCalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	calendars, err := h.bl.GetCalendars(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	if calendars == nil {
+		calendars = []types.Calendar{}
+	}
+
+	err = json.NewEncoder(w).Encode(calendars)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) GetCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	c, err := h.bl.GetCalendar(r.Context(), id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(c)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) AddCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var c types.Calendar
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	c, err = h.bl.AddCalendar(r.Context(), c)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(c)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) UpdateCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	var c types.Calendar
+	err = json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.UpdateCalendar(r.Context(), c, id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	c, err = h.bl.GetCalendar(r.Context(), id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(c)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// DeleteCalendarHandler deletes a calendar, the events parameter tells whether its events are deleted as well
+// or moved to the target calendar
+func (h *Handler) DeleteCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	id, target, action, err := deleteCalendarParams(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.DeleteCalendar(r.Context(), id, action, target)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteCalendarParams reads the ID of the calendar, the events parameter, which defaults to move,
+// and the optional target calendar
+func deleteCalendarParams(r *http.Request) (int64, int64, types.CalendarEvents, error) {
+	var target int64
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return id, target, "", err
+	}
+
+	query := r.URL.Query()
+
+	action := types.CalendarEvents(query.Get("events"))
+	if action == "" {
+		action = types.CalendarEventsMove
+	}
+
+	_, present := query["target"]
+	if present {
+		target, err = strconv.ParseInt(query.Get("target"), 10, 64)
+		if err != nil {
+			return id, target, action, err
+		}
+	}
+
+	return id, target, action, nil
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/bubo-py/McK/types"
+)
+
+// FreeBusyHandler returns busy intervals of the users in the posted query, in the order of their logins
+func (h *Handler) FreeBusyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var q types.FreeBusyQuery
+	err := json.NewDecoder(r.Body).Decode(&q)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	s, err := h.bl.GetFreeBusy(r.Context(), q)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(s)
+	if err != nil {
+		log.Println(err)
+	}
+}
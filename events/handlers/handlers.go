@@ -2,9 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/events/service"
@@ -13,6 +18,11 @@ import (
 	"github.com/pkg/errors"
 )
 
+// maxImportSize limits the size of an imported calendar
+const maxImportSize = 10 << 20
+
+var filterTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
 var badRequestReturn = customErrors.ReturnError{
 	ErrorType:    customErrors.ErrBadRequest.ErrorType,
 	ErrorMessage: customErrors.ErrBadRequest.Error(),
@@ -23,6 +33,11 @@ var notFoundReturn = customErrors.ReturnError{
 	ErrorMessage: customErrors.ErrNotFound.Error(),
 }
 
+var unauthorizedReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrUnauthorized.ErrorType,
+	ErrorMessage: customErrors.ErrUnauthorized.Error(),
+}
+
 var unexpectedReturn = customErrors.ReturnError{
 	ErrorType:    customErrors.ErrUnexpected.ErrorType,
 	ErrorMessage: customErrors.ErrUnexpected.Error(),
@@ -31,6 +46,9 @@ var unexpectedReturn = customErrors.ReturnError{
 type Handler struct {
 	bl  service.BusinessLogicInterface
 	Mux *chi.Mux
+
+	// CalendarsMux serves the calendars events are kept in
+	CalendarsMux *chi.Mux
 }
 
 func InitHandler(bl service.BusinessLogicInterface) Handler {
@@ -41,11 +59,28 @@ func InitHandler(bl service.BusinessLogicInterface) Handler {
 	r.Get("/", h.GetEventsHandler)
 	r.Get("/{id}", h.GetEventHandler)
 	r.Post("/", h.AddEventHandler)
+	r.Post("/import", h.ImportEventsHandler)
 	r.Put("/{id}", h.UpdateEventHandler)
 	r.Delete("/{id}", h.DeleteEventHandler)
+	r.Put("/{id}/occurrences/{originalStart}", h.UpdateOccurrenceHandler)
+	r.Delete("/{id}/occurrences/{originalStart}", h.DeleteOccurrenceHandler)
+	r.Post("/{id}/rsvp", h.RSVPHandler)
 
 	h.Mux = r
 
+	calendars := chi.NewRouter()
+
+	calendars.Get("/", h.GetCalendarsHandler)
+	calendars.Get("/{id}", h.GetCalendarHandler)
+	calendars.Post("/", h.AddCalendarHandler)
+	calendars.Put("/{id}", h.UpdateCalendarHandler)
+	calendars.Delete("/{id}", h.DeleteCalendarHandler)
+	calendars.Get("/{id}/shares", h.GetSharesHandler)
+	calendars.Post("/{id}/shares", h.SetShareHandler)
+	calendars.Delete("/{id}/shares/{login}", h.DeleteShareHandler)
+
+	h.CalendarsMux = calendars
+
 	h.bl = bl
 	return h
 }
@@ -53,49 +88,29 @@ func InitHandler(bl service.BusinessLogicInterface) Handler {
 func (h *Handler) GetEventsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var f types.Filters
-	query := r.URL.Query()
-
-	_, present := query["day"]
-	if present {
-		day, err := strconv.Atoi(query.Get("day"))
+	f, err := parseFilters(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			err = json.NewEncoder(w).Encode(badRequestReturn)
-			if err != nil {
-				log.Println(err)
-			}
-			return
+			log.Println(err)
 		}
-		f.Day = day
+		return
 	}
 
-	_, present = query["month"]
-	if present {
-		month, err := strconv.Atoi(query.Get("month"))
+	p, paged, err := parsePage(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			err = json.NewEncoder(w).Encode(badRequestReturn)
-			if err != nil {
-				log.Println(err)
-			}
-			return
+			log.Println(err)
 		}
-		f.Month = month
+		return
 	}
 
-	_, present = query["year"]
-	if present {
-		year, err := strconv.Atoi(query.Get("year"))
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			err = json.NewEncoder(w).Encode(badRequestReturn)
-			if err != nil {
-				log.Println(err)
-			}
-			return
-		}
-		f.Year = year
+	if paged {
+		h.getEventsPage(w, r, f, p)
+		return
 	}
 
 	events, err := h.bl.GetEvents(r.Context(), f)
@@ -110,6 +125,60 @@ func (h *Handler) GetEventsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getEventsPage writes a page of events, links to the neighbouring pages are given in the Link header
+func (h *Handler) getEventsPage(w http.ResponseWriter, r *http.Request, f types.Filters, p types.Page) {
+	page, err := h.bl.GetEventsPage(r.Context(), f, p)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	var links []string
+	if page.Next != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, page.Next)))
+	}
+	if page.Prev != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(r, page.Prev)))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	err = json.NewEncoder(w).Encode(page.Events)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// ExportEventsHandler serves events of the user as an iCalendar feed, it accepts the same filters as GetEventsHandler
+func (h *Handler) ExportEventsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	f, err := parseFilters(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	calendar, err := h.bl.ExportEvents(r.Context(), f)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="events.ics"`)
+
+	_, err = w.Write(calendar)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
 func (h *Handler) GetEventHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -161,6 +230,32 @@ func (h *Handler) AddEventHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ImportEventsHandler adds events from a text/calendar body and reports what happened to each of its VEVENTs
+func (h *Handler) ImportEventsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/calendar" {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	report, err := h.bl.ImportEvents(r.Context(), http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
 func (h *Handler) DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -191,6 +286,42 @@ func (h *Handler) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var u types.EventUpdate
+	err = json.NewDecoder(r.Body).Decode(&u)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.UpdateEvent(r.Context(), u, id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(u)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) UpdateOccurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, originalStart, scope, err := occurrenceParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
 	var e types.Event
 	err = json.NewDecoder(r.Body).Decode(&e)
 	if err != nil {
@@ -202,7 +333,7 @@ func (h *Handler) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.bl.UpdateEvent(r.Context(), e, id)
+	err = h.bl.UpdateOccurrence(r.Context(), e, id, originalStart, scope)
 	if err != nil {
 		errBasedReturn(w, err)
 		return
@@ -214,11 +345,185 @@ func (h *Handler) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *Handler) DeleteOccurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	id, originalStart, scope, err := occurrenceParams(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.DeleteOccurrence(r.Context(), id, originalStart, scope)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseFilters parses the day, month, year, from, to and calendar filters from the query
+func parseFilters(r *http.Request) (types.Filters, error) {
+	var f types.Filters
+	query := r.URL.Query()
+
+	_, present := query["day"]
+	if present {
+		day, err := strconv.Atoi(query.Get("day"))
+		if err != nil {
+			return f, err
+		}
+		f.Day = day
+	}
+
+	_, present = query["month"]
+	if present {
+		month, err := strconv.Atoi(query.Get("month"))
+		if err != nil {
+			return f, err
+		}
+		f.Month = month
+	}
+
+	_, present = query["year"]
+	if present {
+		year, err := strconv.Atoi(query.Get("year"))
+		if err != nil {
+			return f, err
+		}
+		f.Year = year
+	}
+
+	_, present = query["from"]
+	if present {
+		from, err := parseFilterTime(query.Get("from"))
+		if err != nil {
+			return f, err
+		}
+		f.From = from
+	}
+
+	_, present = query["to"]
+	if present {
+		to, err := parseFilterTime(query.Get("to"))
+		if err != nil {
+			return f, err
+		}
+		f.To = to
+	}
+
+	_, present = query["calendar"]
+	if present {
+		id, err := strconv.ParseInt(query.Get("calendar"), 10, 64)
+		if err != nil {
+			return f, err
+		}
+		f.CalendarID = id
+	}
+
+	return f, nil
+}
+
+// parsePage reads the limit, cursor and sort parameters, the listing is paginated when any of them is given.
+// Events are sorted by the given field in ascending order or, when it is prefixed with "-", in descending order.
+func parsePage(r *http.Request) (types.Page, bool, error) {
+	var p types.Page
+	query := r.URL.Query()
+
+	_, limit := query["limit"]
+	if limit {
+		n, err := strconv.Atoi(query.Get("limit"))
+		if err != nil {
+			return p, false, err
+		}
+		p.Limit = n
+	}
+
+	_, sort := query["sort"]
+	if sort {
+		field := query.Get("sort")
+		if strings.HasPrefix(field, "-") {
+			p.Desc = true
+			field = field[1:]
+		}
+		p.Sort = types.SortField(field)
+	}
+
+	_, cursor := query["cursor"]
+	p.Cursor = query.Get("cursor")
+
+	return p, limit || sort || cursor, nil
+}
+
+// pageURL returns the URL of the request with its cursor replaced
+func pageURL(r *http.Request, cursor string) string {
+	u := *r.URL
+
+	query := u.Query()
+	query.Set("cursor", cursor)
+	u.RawQuery = query.Encode()
+
+	return u.RequestURI()
+}
+
+// parseFilterTime accepts a date, a date with a time or an RFC 3339 timestamp,
+// they are interpreted as wall-clock times in the user's timezone by the business logic.
+func parseFilterTime(s string) (time.Time, error) {
+	var err error
+
+	for _, layout := range filterTimeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+// occurrenceParams parses the event ID and the original start of its occurrence from the path
+// together with the scope of the change, which defaults to the single occurrence.
+func occurrenceParams(r *http.Request) (int64, time.Time, types.OccurrenceScope, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+
+	param, err := url.PathUnescape(chi.URLParam(r, "originalStart"))
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+
+	originalStart, err := time.Parse(time.RFC3339, param)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+
+	scope := types.ScopeThis
+	if query := r.URL.Query(); query.Has("scope") {
+		scope = types.OccurrenceScope(query.Get("scope"))
+	}
+
+	return id, originalStart, scope, nil
+}
+
 func errBasedReturn(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, customErrors.ErrBadRequest):
+		ret := badRequestReturn
+
+		var validation customErrors.ValidationError
+		if errors.As(err, &validation) {
+			ret.Fields = validation.Fields
+		}
+
 		w.WriteHeader(http.StatusBadRequest)
-		err = json.NewEncoder(w).Encode(badRequestReturn)
+		err = json.NewEncoder(w).Encode(ret)
 		if err != nil {
 			log.Println(err)
 		}
@@ -228,6 +533,12 @@ func errBasedReturn(w http.ResponseWriter, err error) {
 		if err != nil {
 			log.Println(err)
 		}
+	case errors.Is(err, customErrors.ErrUnauthorized):
+		w.WriteHeader(http.StatusForbidden)
+		err = json.NewEncoder(w).Encode(unauthorizedReturn)
+		if err != nil {
+			log.Println(err)
+		}
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 		err = json.NewEncoder(w).Encode(unexpectedReturn)
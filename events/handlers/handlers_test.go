@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/bubo-py/McK/contextHelpers"
 	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/events"
+	"github.com/bubo-py/McK/events/repositories/memoryStorage"
+	"github.com/bubo-py/McK/events/service"
 	"github.com/bubo-py/McK/types"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -100,6 +104,30 @@ func TestGetEventsHandler(t *testing.T) {
 			expJSONReturn:     `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
 			expStatusCode:     400,
 		},
+		{
+			testName:      "GetEvents_with_range",
+			r:             httptest.NewRequest("GET", "/api/events?from=2023-01-02&to=2023-01-09T12:30", nil),
+			w:             httptest.NewRecorder(),
+			expFilters:    types.Filters{From: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 1, 9, 12, 30, 0, 0, time.UTC)},
+			expJSONReturn: "null\n",
+			expStatusCode: 200,
+		},
+		{
+			testName:      "GetEvents_with_range_RFC3339",
+			r:             httptest.NewRequest("GET", "/api/events?from=2023-01-02T14:00:00Z&to=2023-01-02T16:00:00Z", nil),
+			w:             httptest.NewRecorder(),
+			expFilters:    types.Filters{From: time.Date(2023, 1, 2, 14, 0, 0, 0, time.UTC), To: time.Date(2023, 1, 2, 16, 0, 0, 0, time.UTC)},
+			expJSONReturn: "null\n",
+			expStatusCode: 200,
+		},
+		{
+			testName:          "GetEvents_FromParseErr_BadRequest",
+			strConvErrPresent: true,
+			r:                 httptest.NewRequest("GET", "/api/events?from=yesterday&to=2023-01-09", nil),
+			w:                 httptest.NewRecorder(),
+			expJSONReturn:     `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode:     400,
+		},
 		{
 			testName:          "GetEvents_StrConvErr_BadRequest",
 			strConvErrPresent: true,
@@ -271,6 +299,21 @@ func TestAddEventHandler(t *testing.T) {
 			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
 			expStatusCode: 400,
 		},
+		{
+			testName: "AddEvent_ValidationError",
+			jsonStr:  `{"id":1,"name":"Meeting Name","startTime":"2022-09-14T09:00:00Z","endTime":"2022-09-14T08:00:00Z", "alertTime":"0001-01-01T00:00:00Z"}`,
+			eventToMock: types.Event{
+				ID:        1,
+				Name:      "Meeting Name",
+				StartTime: time.Date(2022, 9, 14, 9, 0, 0, 0, time.UTC),
+				EndTime:   time.Date(2022, 9, 14, 8, 0, 0, 0, time.UTC),
+			},
+			mockErrReturn: customErrors.ValidationError{Fields: []customErrors.FieldError{{Field: "endTime",
+				Code: customErrors.CodeEndBeforeStart, Message: "should not be before startTime"}}},
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request",` +
+				`"Fields":[{"field":"endTime","code":"endBeforeStart","message":"should not be before startTime"}]}`,
+			expStatusCode: 400,
+		},
 		{
 			testName: "AddEvent_Unexpected",
 			jsonStr:  `{"id":1,"name":"Meeting Name","startTime":"2022-09-14T09:00:00Z","endTime":"2022-09-14T09:00:00Z", "alertTime":"0001-01-01T00:00:00Z"}`,
@@ -425,6 +468,7 @@ func TestUpdateEventHandler(t *testing.T) {
 		decodeErrPresent bool
 		expID            int64
 		eventToMock      types.Event
+		allDayToMock     *bool
 		mockErrReturn    error
 		expJSONReturn    string
 		expStatusCode    int
@@ -443,6 +487,19 @@ func TestUpdateEventHandler(t *testing.T) {
 			expJSONReturn: `{"id":1,"name":"Onboarding Meeting","startTime":"2022-09-14T09:00:00Z","endTime":"2022-09-14T09:00:00Z", "alertTime":"0001-01-01T00:00:00Z"}`,
 			expStatusCode: 200,
 		},
+		{
+			testName: "UpdateEvent_AllDayOff",
+			r:        httptest.NewRequest("PUT", "/5", bytes.NewBuffer([]byte(`{"startTime":"2022-09-14T09:00:00Z","endTime":"2022-09-14T10:00:00Z","allDay":false}`))),
+			w:        httptest.NewRecorder(),
+			eventToMock: types.Event{
+				StartTime: time.Date(2022, 9, 14, 9, 0, 0, 0, time.UTC),
+				EndTime:   time.Date(2022, 9, 14, 10, 0, 0, 0, time.UTC),
+			},
+			allDayToMock:  new(bool),
+			expID:         5,
+			expJSONReturn: `{"id":0,"name":"","startTime":"2022-09-14T09:00:00Z","endTime":"2022-09-14T10:00:00Z", "alertTime":"0001-01-01T00:00:00Z","allDay":false}`,
+			expStatusCode: 200,
+		},
 		{
 			testName: "UpdateEvent_Unexpected",
 			r:        httptest.NewRequest("PUT", "/100", bytes.NewBuffer([]byte(`{"id":1,"name":"Supposedly too long Meeting Name","startTime":"2022-09-14T09:00:00Z","endTime":"2022-09-14T09:00:00Z", "alertTime":"0001-01-01T00:00:00Z"}`))),
@@ -531,7 +588,8 @@ func TestUpdateEventHandler(t *testing.T) {
 			mockBL := events.NewMockBusinessLogicInterface(mockCtrl)
 
 			if !tc.decodeErrPresent {
-				mockBL.EXPECT().UpdateEvent(gomock.Any(), tc.eventToMock, tc.expID).Return(tc.mockErrReturn)
+				u := types.EventUpdate{Event: tc.eventToMock, AllDay: tc.allDayToMock}
+				mockBL.EXPECT().UpdateEvent(gomock.Any(), u, tc.expID).Return(tc.mockErrReturn)
 			}
 
 			// create handler with mocks
@@ -552,3 +610,786 @@ func TestUpdateEventHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestEventsIsolation(t *testing.T) {
+	// real business logic backed by the memory storage, requests are made on behalf of two different users
+	handler := InitHandler(service.InitBusinessLogic(memoryStorage.InitDatabase()))
+
+	asUser := func(r *http.Request, login string) *http.Request {
+		ctx := contextHelpers.WriteLoginToContext(context.Background(), login)
+		ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/London")
+		return r.WithContext(ctx)
+	}
+
+	w := httptest.NewRecorder()
+	body := `{"name":"Onboarding Meeting","startTime":"2022-09-14T09:00:00Z","endTime":"2022-09-14T09:00:00Z"}`
+	handler.Mux.ServeHTTP(w, asUser(httptest.NewRequest("POST", "/", bytes.NewBufferString(body)), "owner"))
+	require.Equal(t, 200, w.Result().StatusCode, "Wrong status code returned")
+
+	testCases := []struct {
+		testName      string
+		r             *http.Request
+		expJSONReturn string
+		expStatusCode int
+	}{
+		{
+			testName:      "GetEvents_other_user_sees_nothing",
+			r:             asUser(httptest.NewRequest("GET", "/", nil), "other"),
+			expJSONReturn: "null\n",
+			expStatusCode: 200,
+		},
+		{
+			testName:      "GetEvent_other_user_NotFound",
+			r:             asUser(httptest.NewRequest("GET", "/1", nil), "other"),
+			expJSONReturn: `{"ErrorType":"NotFound","ErrorMessage":"the server cannot find the requested resource"}`,
+			expStatusCode: 404,
+		},
+		{
+			testName:      "UpdateEvent_other_user_NotFound",
+			r:             asUser(httptest.NewRequest("PUT", "/1", bytes.NewBufferString(`{"name":"Hijacked"}`)), "other"),
+			expJSONReturn: `{"ErrorType":"NotFound","ErrorMessage":"the server cannot find the requested resource"}`,
+			expStatusCode: 404,
+		},
+		{
+			testName:      "DeleteEvent_other_user_NotFound",
+			r:             asUser(httptest.NewRequest("DELETE", "/1", nil), "other"),
+			expJSONReturn: `{"ErrorType":"NotFound","ErrorMessage":"the server cannot find the requested resource"}`,
+			expStatusCode: 404,
+		},
+		{
+			testName:      "GetEvent_owner",
+			r:             asUser(httptest.NewRequest("GET", "/1", nil), "owner"),
+			expStatusCode: 200,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.Mux.ServeHTTP(w, tc.r)
+
+			resp := w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.expJSONReturn != "" {
+				require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should be equal")
+			}
+
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestDeleteOccurrenceHandler(t *testing.T) {
+	originalStart := time.Date(2023, 3, 21, 9, 0, 0, 0, time.FixedZone("", 3600))
+
+	testCases := []struct {
+		testName      string
+		r             *http.Request
+		w             *httptest.ResponseRecorder
+		expScope      types.OccurrenceScope
+		mockErrReturn error
+		expJSONReturn string
+		expStatusCode int
+	}{
+		{
+			testName:      "DeleteOccurrence_defaultScope",
+			r:             httptest.NewRequest("DELETE", "/5/occurrences/2023-03-21T09:00:00%2B01:00", nil),
+			w:             httptest.NewRecorder(),
+			expScope:      types.ScopeThis,
+			expStatusCode: 204,
+		},
+		{
+			testName:      "DeleteOccurrence_following",
+			r:             httptest.NewRequest("DELETE", "/5/occurrences/2023-03-21T09:00:00%2B01:00?scope=following", nil),
+			w:             httptest.NewRecorder(),
+			expScope:      types.ScopeFollowing,
+			expStatusCode: 204,
+		},
+		{
+			testName:      "DeleteOccurrence_invalidScope",
+			r:             httptest.NewRequest("DELETE", "/5/occurrences/2023-03-21T09:00:00%2B01:00?scope=some", nil),
+			w:             httptest.NewRecorder(),
+			expScope:      "some",
+			mockErrReturn: customErrors.ErrBadRequest,
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName:      "DeleteOccurrence_NotFound",
+			r:             httptest.NewRequest("DELETE", "/5/occurrences/2023-03-21T09:00:00%2B01:00", nil),
+			w:             httptest.NewRecorder(),
+			expScope:      types.ScopeThis,
+			mockErrReturn: customErrors.ErrNotFound,
+			expJSONReturn: `{"ErrorType":"NotFound","ErrorMessage":"the server cannot find the requested resource"}`,
+			expStatusCode: 404,
+		},
+		{
+			testName:      "DeleteOccurrence_invalidOriginalStart",
+			r:             httptest.NewRequest("DELETE", "/5/occurrences/tuesday", nil),
+			w:             httptest.NewRecorder(),
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := events.NewMockBusinessLogicInterface(mockCtrl)
+			if tc.expScope != "" {
+				mockBL.EXPECT().DeleteOccurrence(gomock.Any(), int64(5), originalStart, tc.expScope).
+					Return(tc.mockErrReturn)
+			}
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			handler.Mux.ServeHTTP(tc.w, tc.r)
+
+			resp := tc.w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.expJSONReturn != "" {
+				require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			}
+
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestDeleteCalendarHandler(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		r             *http.Request
+		w             *httptest.ResponseRecorder
+		expAction     types.CalendarEvents
+		expTarget     int64
+		mockErrReturn error
+		expJSONReturn string
+		expStatusCode int
+	}{
+		{
+			testName:      "DeleteCalendar_defaultAction",
+			r:             httptest.NewRequest("DELETE", "/5", nil),
+			w:             httptest.NewRecorder(),
+			expAction:     types.CalendarEventsMove,
+			expStatusCode: 204,
+		},
+		{
+			testName:      "DeleteCalendar_moveToTarget",
+			r:             httptest.NewRequest("DELETE", "/5?events=move&target=2", nil),
+			w:             httptest.NewRecorder(),
+			expAction:     types.CalendarEventsMove,
+			expTarget:     2,
+			expStatusCode: 204,
+		},
+		{
+			testName:      "DeleteCalendar_cascade",
+			r:             httptest.NewRequest("DELETE", "/5?events=delete", nil),
+			w:             httptest.NewRecorder(),
+			expAction:     types.CalendarEventsDelete,
+			expStatusCode: 204,
+		},
+		{
+			testName:      "DeleteCalendar_default",
+			r:             httptest.NewRequest("DELETE", "/5", nil),
+			w:             httptest.NewRecorder(),
+			expAction:     types.CalendarEventsMove,
+			mockErrReturn: customErrors.ErrBadRequest,
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName:      "DeleteCalendar_NotFound",
+			r:             httptest.NewRequest("DELETE", "/5?events=delete", nil),
+			w:             httptest.NewRecorder(),
+			expAction:     types.CalendarEventsDelete,
+			mockErrReturn: customErrors.ErrNotFound,
+			expJSONReturn: `{"ErrorType":"NotFound","ErrorMessage":"the server cannot find the requested resource"}`,
+			expStatusCode: 404,
+		},
+		{
+			testName:      "DeleteCalendar_invalidTarget",
+			r:             httptest.NewRequest("DELETE", "/5?target=work", nil),
+			w:             httptest.NewRecorder(),
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := events.NewMockBusinessLogicInterface(mockCtrl)
+			if tc.expAction != "" {
+				mockBL.EXPECT().DeleteCalendar(gomock.Any(), int64(5), tc.expAction, tc.expTarget).
+					Return(tc.mockErrReturn)
+			}
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			handler.CalendarsMux.ServeHTTP(tc.w, tc.r)
+
+			resp := tc.w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.expJSONReturn != "" {
+				require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			}
+
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestCalendarsHandlers(t *testing.T) {
+	ctx := contextHelpers.WriteLoginToContext(context.Background(), "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	handler := InitHandler(service.InitBusinessLogic(memoryStorage.InitDatabase()))
+
+	serve := func(mux http.Handler, method, target, body string) (int, string) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, bytes.NewBufferString(body)).WithContext(ctx))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := serve(handler.CalendarsMux, "POST", "/", `{"name":"Work","color":"#1E90FF"}`)
+	require.Equal(t, 200, code)
+	require.JSONEq(t, `{"id":1,"name":"Work","color":"#1E90FF","visibility":"freeBusy","default":false,`+
+		`"owner":"hello"}`, body)
+
+	code, body = serve(handler.CalendarsMux, "POST", "/", `{"color":"blue"}`)
+	require.Equal(t, 400, code)
+	require.Contains(t, body, `"Fields":[{"field":"name","code":"required","message":"is required"},`+
+		`{"field":"color","code":"invalid"`)
+
+	code, body = serve(handler.CalendarsMux, "PUT", "/1", `{"visibility":"private"}`)
+	require.Equal(t, 200, code)
+	require.JSONEq(t, `{"id":1,"name":"Work","color":"#1E90FF","visibility":"private","default":false,`+
+		`"owner":"hello"}`, body)
+
+	code, _ = serve(handler.Mux, "POST", "/", `{"name":"Stand-up","startTime":"2023-03-01T09:00:00Z",`+
+		`"endTime":"2023-03-01T09:15:00Z","calendarId":1}`)
+	require.Equal(t, 200, code)
+
+	code, _ = serve(handler.Mux, "POST", "/", `{"name":"Gym","startTime":"2023-03-01T18:00:00Z",`+
+		`"endTime":"2023-03-01T19:00:00Z"}`)
+	require.Equal(t, 200, code)
+
+	code, body = serve(handler.Mux, "GET", "/?calendar=1", "")
+	require.Equal(t, 200, code)
+	require.Contains(t, body, "Stand-up")
+	require.NotContains(t, body, "Gym")
+
+	code, body = serve(handler.CalendarsMux, "GET", "/", "")
+	require.Equal(t, 200, code)
+	require.Contains(t, body, `"name":"Personal"`, "the default calendar is created with the first event")
+
+	code, _ = serve(handler.CalendarsMux, "DELETE", "/1?events=delete", "")
+	require.Equal(t, 204, code)
+
+	code, body = serve(handler.Mux, "GET", "/", "")
+	require.Equal(t, 200, code)
+	require.NotContains(t, body, "Stand-up")
+	require.Contains(t, body, "Gym")
+
+	code, _ = serve(handler.CalendarsMux, "GET", "/1", "")
+	require.Equal(t, 404, code)
+}
+
+func TestSharesHandlers(t *testing.T) {
+	handler := InitHandler(service.InitBusinessLogic(memoryStorage.InitDatabase()))
+
+	serve := func(login, method, target, body string) (int, string) {
+		ctx := contextHelpers.WriteLoginToContext(context.Background(), login)
+		ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+		w := httptest.NewRecorder()
+		handler.CalendarsMux.ServeHTTP(w, httptest.NewRequest(method, target, bytes.NewBufferString(body)).
+			WithContext(ctx))
+		return w.Code, w.Body.String()
+	}
+
+	code, _ := serve("owner", "POST", "/", `{"name":"Team"}`)
+	require.Equal(t, 200, code)
+
+	code, _ = serve("guest", "GET", "/1/shares", "")
+	require.Equal(t, 404, code, "calendars which are not shared are not found")
+
+	code, body := serve("owner", "POST", "/1/shares", `{"login":"guest","level":"read"}`)
+	require.Equal(t, 200, code)
+	require.JSONEq(t, `{"login":"guest","level":"read"}`, body)
+
+	code, body = serve("owner", "POST", "/1/shares", `{"login":"guest","level":"owner"}`)
+	require.Equal(t, 400, code)
+	require.Contains(t, body, `"field":"level","code":"invalid"`)
+
+	code, body = serve("guest", "GET", "/", "")
+	require.Equal(t, 200, code)
+	require.Contains(t, body, `"owner":"owner","access":"read"`)
+
+	code, body = serve("guest", "PUT", "/1", `{"name":"Guests"}`)
+	require.Equal(t, 403, code)
+	require.JSONEq(t, `{"ErrorType":"Unauthorized",`+
+		`"ErrorMessage":"the server cannot process the request due to lack of client's access rights"}`, body)
+
+	code, body = serve("owner", "GET", "/1/shares", "")
+	require.Equal(t, 200, code)
+	require.JSONEq(t, `[{"login":"guest","level":"read"}]`, body)
+
+	code, _ = serve("owner", "DELETE", "/1/shares/guest", "")
+	require.Equal(t, 204, code)
+
+	code, _ = serve("owner", "DELETE", "/1/shares/guest", "")
+	require.Equal(t, 404, code)
+
+	code, body = serve("owner", "GET", "/1/shares", "")
+	require.Equal(t, 200, code)
+	require.JSONEq(t, `[]`, body)
+}
+
+func TestInvitationsHandlers(t *testing.T) {
+	handler := InitHandler(service.InitBusinessLogic(memoryStorage.InitDatabase()))
+
+	request := func(login, method, target, body string) *http.Request {
+		ctx := contextHelpers.WriteLoginToContext(context.Background(), login)
+		ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+		return httptest.NewRequest(method, target, bytes.NewBufferString(body)).WithContext(ctx)
+	}
+
+	serve := func(login, method, target, body string) (int, string) {
+		w := httptest.NewRecorder()
+		handler.Mux.ServeHTTP(w, request(login, method, target, body))
+		return w.Code, w.Body.String()
+	}
+
+	invitations := func(login, query string) (int, string) {
+		w := httptest.NewRecorder()
+		handler.GetInvitationsHandler(w, request(login, "GET", "/api/invitations"+query, ""))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := serve("owner", "POST", "/", `{"name":"Review","startTime":"2023-03-01T10:00:00+01:00",`+
+		`"endTime":"2023-03-01T11:00:00+01:00","attendees":[{"login":"guest"},{"email":"ext@example.com"}]}`)
+	require.Equal(t, 200, code)
+
+	code, body = serve("owner", "GET", "/1", "")
+	require.Equal(t, 200, code)
+	require.Contains(t, body, `"attendees":[{"login":"guest","status":"needsAction"},`+
+		`{"email":"ext@example.com","status":"needsAction"}]`)
+
+	code, body = invitations("guest", "")
+	require.Equal(t, 200, code)
+	require.Contains(t, body, `"organizer":"owner"`)
+	require.Contains(t, body, `"status":"needsAction"}]`)
+
+	code, _ = invitations("guest", "?status=maybe")
+	require.Equal(t, 400, code)
+
+	code, body = serve("guest", "POST", "/1/rsvp", `{"status":"maybe"}`)
+	require.Equal(t, 400, code)
+	require.Contains(t, body, `"field":"status","code":"invalid"`)
+
+	code, _ = serve("stranger", "POST", "/1/rsvp", `{"status":"accepted"}`)
+	require.Equal(t, 404, code)
+
+	code, _ = serve("guest", "POST", "/1/rsvp", `{"status":"accepted"}`)
+	require.Equal(t, 204, code)
+
+	code, body = invitations("guest", "?status=declined")
+	require.Equal(t, 200, code)
+	require.JSONEq(t, `[]`, body)
+
+	code, body = serve("guest", "GET", "/", "")
+	require.Equal(t, 200, code)
+	require.Contains(t, body, `"organizer":"owner"`)
+
+	code, _ = serve("guest", "DELETE", "/1", "")
+	require.Equal(t, 403, code)
+}
+
+func TestExportEventsHandler(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		r              *http.Request
+		w              *httptest.ResponseRecorder
+		expFilters     *types.Filters
+		mockReturn     []byte
+		mockErrReturn  error
+		expReturn      string
+		expContentType string
+		expStatusCode  int
+	}{
+		{
+			testName:       "ExportEvents_month",
+			r:              httptest.NewRequest("GET", "/events.ics?month=3&year=2023", nil),
+			w:              httptest.NewRecorder(),
+			expFilters:     &types.Filters{Month: 3, Year: 2023},
+			mockReturn:     []byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"),
+			expReturn:      "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n",
+			expContentType: "text/calendar; charset=utf-8",
+			expStatusCode:  200,
+		},
+		{
+			testName:       "ExportEvents_invalidFilter",
+			r:              httptest.NewRequest("GET", "/events.ics?month=march", nil),
+			w:              httptest.NewRecorder(),
+			expReturn:      `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}` + "\n",
+			expContentType: "application/json",
+			expStatusCode:  400,
+		},
+		{
+			testName:       "ExportEvents_BadRequest",
+			r:              httptest.NewRequest("GET", "/events.ics?month=13", nil),
+			w:              httptest.NewRecorder(),
+			expFilters:     &types.Filters{Month: 13},
+			mockErrReturn:  customErrors.ErrBadRequest,
+			expReturn:      `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}` + "\n",
+			expContentType: "application/json",
+			expStatusCode:  400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := events.NewMockBusinessLogicInterface(mockCtrl)
+			if tc.expFilters != nil {
+				mockBL.EXPECT().ExportEvents(gomock.Any(), *tc.expFilters).Return(tc.mockReturn, tc.mockErrReturn)
+			}
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			handler.ExportEventsHandler(tc.w, tc.r)
+
+			resp := tc.w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			require.Equal(t, tc.expReturn, string(data))
+			require.Equal(t, tc.expContentType, resp.Header.Get("Content-Type"))
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestFreeBusyHandler(t *testing.T) {
+	from, to := time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC), time.Date(2023, 3, 7, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		testName      string
+		body          string
+		expQuery      *types.FreeBusyQuery
+		mockReturn    []types.FreeBusy
+		mockErrReturn error
+		expReturn     string
+		expStatusCode int
+	}{
+		{
+			testName: "FreeBusy",
+			body:     `{"logins":["bob","nobody"],"from":"2023-03-06T00:00:00Z","to":"2023-03-07T00:00:00Z"}`,
+			expQuery: &types.FreeBusyQuery{Logins: []string{"bob", "nobody"}, From: from, To: to},
+			mockReturn: []types.FreeBusy{
+				{Login: "bob", Busy: []types.BusyInterval{{Start: from.Add(9 * time.Hour),
+					End: from.Add(10 * time.Hour)}}},
+				{Login: "nobody", Busy: []types.BusyInterval{}},
+			},
+			expReturn: `[{"login":"bob","busy":[{"start":"2023-03-06T09:00:00Z","end":"2023-03-06T10:00:00Z"}]},` +
+				`{"login":"nobody","busy":[]}]` + "\n",
+			expStatusCode: 200,
+		},
+		{
+			testName:      "FreeBusy_InvalidJSON",
+			body:          `{"logins":"bob"}`,
+			expReturn:     `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}` + "\n",
+			expStatusCode: 400,
+		},
+		{
+			testName: "FreeBusy_ValidationError",
+			body:     `{"logins":["bob"],"from":"2023-03-07T00:00:00Z","to":"2023-03-06T00:00:00Z"}`,
+			expQuery: &types.FreeBusyQuery{Logins: []string{"bob"}, From: to, To: from},
+			mockErrReturn: customErrors.ValidationError{Fields: []customErrors.FieldError{{Field: "to",
+				Code: customErrors.CodeEndBeforeStart, Message: "should be after from"}}},
+			expReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request",` +
+				`"Fields":[{"field":"to","code":"endBeforeStart","message":"should be after from"}]}` + "\n",
+			expStatusCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := events.NewMockBusinessLogicInterface(mockCtrl)
+			if tc.expQuery != nil {
+				mockBL.EXPECT().GetFreeBusy(gomock.Any(), *tc.expQuery).Return(tc.mockReturn, tc.mockErrReturn)
+			}
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+
+			w := httptest.NewRecorder()
+			handler.FreeBusyHandler(w, httptest.NewRequest("POST", "/api/freebusy", bytes.NewBufferString(tc.body)))
+
+			require.Equal(t, tc.expReturn, w.Body.String())
+			require.Equal(t, tc.expStatusCode, w.Code, "Wrong status code returned")
+		})
+	}
+}
+
+func TestSuggestSlotsHandler(t *testing.T) {
+	from, to := time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC), time.Date(2023, 3, 8, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		testName      string
+		body          string
+		expQuery      *types.SuggestQuery
+		mockReturn    []types.Slot
+		mockErrReturn error
+		expReturn     string
+		expStatusCode int
+	}{
+		{
+			testName: "SuggestSlots",
+			body: `{"attendees":["bob"],"duration":60,"from":"2023-03-06T00:00:00Z","to":"2023-03-08T00:00:00Z",` +
+				`"constraints":{"workingHours":{"start":"08:00","end":"18:00"},"buffer":15,` +
+				`"attendeeWorkingHours":{"bob":{"start":"06:00","end":"10:00","days":["monday"]}}},"limit":2}`,
+			expQuery: &types.SuggestQuery{Attendees: []string{"bob"}, Duration: 60, From: from, To: to, Limit: 2,
+				Constraints: types.SlotConstraints{WorkingHours: &types.WorkingHours{Start: "08:00", End: "18:00"},
+					Buffer: 15, AttendeeWorkingHours: map[string]types.WorkingHours{
+						"bob": {Start: "06:00", End: "10:00", Days: []string{"monday"}}}}},
+			mockReturn: []types.Slot{
+				{Start: from.Add(40 * time.Hour), End: from.Add(41 * time.Hour)},
+				{Start: from.Add(15 * time.Hour), End: from.Add(16 * time.Hour), Busy: []string{"bob"}},
+			},
+			expReturn: `[{"start":"2023-03-07T16:00:00Z","end":"2023-03-07T17:00:00Z"},` +
+				`{"start":"2023-03-06T15:00:00Z","end":"2023-03-06T16:00:00Z","busy":["bob"]}]` + "\n",
+			expStatusCode: 200,
+		},
+		{
+			testName:      "SuggestSlots_NoSlots",
+			body:          `{"duration":60,"from":"2023-03-06T00:00:00Z","to":"2023-03-08T00:00:00Z"}`,
+			expQuery:      &types.SuggestQuery{Duration: 60, From: from, To: to},
+			expReturn:     "[]\n",
+			expStatusCode: 200,
+		},
+		{
+			testName:      "SuggestSlots_InvalidJSON",
+			body:          `{"duration":"1h"}`,
+			expReturn:     `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}` + "\n",
+			expStatusCode: 400,
+		},
+		{
+			testName: "SuggestSlots_ValidationError",
+			body:     `{"from":"2023-03-06T00:00:00Z","to":"2023-03-08T00:00:00Z"}`,
+			expQuery: &types.SuggestQuery{From: from, To: to},
+			mockErrReturn: customErrors.ValidationError{Fields: []customErrors.FieldError{{Field: "duration",
+				Code: customErrors.CodeRequired, Message: "is required"}}},
+			expReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request",` +
+				`"Fields":[{"field":"duration","code":"required","message":"is required"}]}` + "\n",
+			expStatusCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := events.NewMockBusinessLogicInterface(mockCtrl)
+			if tc.expQuery != nil {
+				mockBL.EXPECT().SuggestSlots(gomock.Any(), *tc.expQuery).Return(tc.mockReturn, tc.mockErrReturn)
+			}
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+
+			w := httptest.NewRecorder()
+			handler.SuggestSlotsHandler(w, httptest.NewRequest("POST", "/api/scheduling/suggest",
+				bytes.NewBufferString(tc.body)))
+
+			require.Equal(t, tc.expReturn, w.Body.String())
+			require.Equal(t, tc.expStatusCode, w.Code, "Wrong status code returned")
+		})
+	}
+}
+
+func TestGetEventsPageHandler(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		r             *http.Request
+		w             *httptest.ResponseRecorder
+		expPage       *types.Page
+		expFilters    types.Filters
+		mockReturn    types.EventPage
+		mockErrReturn error
+		expReturn     string
+		expLink       string
+		expStatusCode int
+	}{
+		{
+			testName:      "GetEventsPage_first",
+			r:             httptest.NewRequest("GET", "/api/events?limit=1&sort=-name", nil),
+			w:             httptest.NewRecorder(),
+			expPage:       &types.Page{Limit: 1, Sort: types.SortName, Desc: true},
+			mockReturn:    types.EventPage{Events: []types.Event{{ID: 1, Name: "Lunch"}}, Next: "abc"},
+			expReturn:     `[{"id":1,"name":"Lunch","startTime":"0001-01-01T00:00:00Z","endTime":"0001-01-01T00:00:00Z","alertTime":"0001-01-01T00:00:00Z"}]` + "\n",
+			expLink:       `</api/events?cursor=abc&limit=1&sort=-name>; rel="next"`,
+			expStatusCode: 200,
+		},
+		{
+			testName:      "GetEventsPage_filteredWithCursor",
+			r:             httptest.NewRequest("GET", "/api/events?month=3&cursor=abc", nil),
+			w:             httptest.NewRecorder(),
+			expPage:       &types.Page{Cursor: "abc"},
+			expFilters:    types.Filters{Month: 3},
+			mockReturn:    types.EventPage{Events: []types.Event{}, Next: "def", Prev: "xyz"},
+			expReturn:     "[]\n",
+			expLink:       `</api/events?cursor=def&month=3>; rel="next", </api/events?cursor=xyz&month=3>; rel="prev"`,
+			expStatusCode: 200,
+		},
+		{
+			testName:      "GetEventsPage_invalidLimit",
+			r:             httptest.NewRequest("GET", "/api/events?limit=ten", nil),
+			w:             httptest.NewRecorder(),
+			expReturn:     `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}` + "\n",
+			expStatusCode: 400,
+		},
+		{
+			testName:      "GetEventsPage_BadRequest",
+			r:             httptest.NewRequest("GET", "/api/events?cursor=abc", nil),
+			w:             httptest.NewRecorder(),
+			expPage:       &types.Page{Cursor: "abc"},
+			mockErrReturn: customErrors.ErrBadRequest,
+			expReturn:     `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}` + "\n",
+			expStatusCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := events.NewMockBusinessLogicInterface(mockCtrl)
+			if tc.expPage != nil {
+				mockBL.EXPECT().GetEventsPage(gomock.Any(), tc.expFilters, *tc.expPage).Return(tc.mockReturn, tc.mockErrReturn)
+			}
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			handler.GetEventsHandler(tc.w, tc.r)
+
+			resp := tc.w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			require.Equal(t, tc.expReturn, string(data))
+			require.Equal(t, tc.expLink, resp.Header.Get("Link"))
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestImportEventsHandler(t *testing.T) {
+	calendar := "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"
+
+	testCases := []struct {
+		testName      string
+		contentType   string
+		callsBL       bool
+		mockReturn    types.ImportReport
+		mockErrReturn error
+		expReturn     string
+		expStatusCode int
+	}{
+		{
+			testName:    "ImportEvents_ok",
+			contentType: "text/calendar; charset=utf-8",
+			callsBL:     true,
+			mockReturn: types.ImportReport{Created: 1, Items: []types.ImportItem{{UID: "1@example.com",
+				Name: "Meeting", Status: types.ImportCreated}}},
+			expReturn: `{"created":1,"skipped":0,"rejected":0,"items":[{"uid":"1@example.com","name":"Meeting",` +
+				`"status":"created"}]}` + "\n",
+			expStatusCode: 200,
+		},
+		{
+			testName:      "ImportEvents_wrongContentType",
+			contentType:   "application/json",
+			expReturn:     `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}` + "\n",
+			expStatusCode: 400,
+		},
+		{
+			testName:      "ImportEvents_BadRequest",
+			contentType:   "text/calendar",
+			callsBL:       true,
+			mockErrReturn: customErrors.ErrBadRequest,
+			expReturn:     `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}` + "\n",
+			expStatusCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := events.NewMockBusinessLogicInterface(mockCtrl)
+			if tc.callsBL {
+				mockBL.EXPECT().ImportEvents(gomock.Any(), gomock.Any()).Return(tc.mockReturn, tc.mockErrReturn)
+			}
+
+			r := httptest.NewRequest("POST", "/import", bytes.NewBufferString(calendar))
+			r.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			handler.ImportEventsHandler(w, r)
+
+			resp := w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			require.Equal(t, tc.expReturn, string(data))
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/go-chi/chi"
+)
+
+// GetInvitationsHandler lists events the user is invited to, the status parameter selects the ones answered with it
+func (h *Handler) GetInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	invitations, err := h.bl.GetInvitations(r.Context(), types.RSVPStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	if invitations == nil {
+		invitations = []types.Invitation{}
+	}
+
+	err = json.NewEncoder(w).Encode(invitations)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) RSVPHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	var rsvp types.RSVP
+	err = json.NewDecoder(r.Body).Decode(&rsvp)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.RSVP(r.Context(), id, rsvp.Status)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
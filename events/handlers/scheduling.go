@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/bubo-py/McK/types"
+)
+
+// SuggestSlotsHandler returns times the user can meet the attendees of the posted query, the best ones first
+func (h *Handler) SuggestSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var q types.SuggestQuery
+	err := json.NewDecoder(r.Body).Decode(&q)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	s, err := h.bl.SuggestSlots(r.Context(), q)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	if s == nil {
+		s = []types.Slot{}
+	}
+
+	err = json.NewEncoder(w).Encode(s)
+	if err != nil {
+		log.Println(err)
+	}
+}
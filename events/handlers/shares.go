@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/go-chi/chi"
+)
+
+func (h *Handler) GetSharesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	shares, err := h.bl.GetShares(r.Context(), id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	if shares == nil {
+		shares = []types.Share{}
+	}
+
+	err = json.NewEncoder(w).Encode(shares)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// SetShareHandler shares the calendar with a user, sharing it again with the same user changes the level
+func (h *Handler) SetShareHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	var s types.Share
+	err = json.NewDecoder(r.Body).Decode(&s)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.SetShare(r.Context(), id, s)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(s)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) DeleteShareHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.DeleteShare(r.Context(), id, chi.URLParam(r, "login"))
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
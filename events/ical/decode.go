@@ -0,0 +1,406 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+)
+
+const dateFormat = "20060102"
+
+// ErrInvalidCalendar is returned when the input is not an iCalendar object at all
+var ErrInvalidCalendar = errors.New("invalid iCalendar object")
+
+// VEvent is a single VEVENT of a decoded calendar
+type VEvent struct {
+	UID   string
+	Event types.Event
+
+	// AllDay is set when DTSTART is a DATE, such events start at midnight in the default location
+	AllDay bool
+
+	// RecurrenceID is set when the VEVENT overrides a single occurrence of the series with the same UID
+	RecurrenceID *time.Time
+	ExDates      []time.Time
+
+	// Err tells why the VEVENT cannot be imported
+	Err error
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Decode parses VEVENTs of an RFC 5545 calendar. Floating times and DATE values are interpreted
+// in the given location, times with a TZID parameter in the IANA location it names, which becomes
+// the timezone of the event when its DTSTART has one.
+func Decode(r io.Reader, loc *time.Location) ([]VEvent, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("%w: missing BEGIN:VCALENDAR", ErrInvalidCalendar)
+	}
+
+	var events []VEvent
+	var stack []string
+	var current []property
+	var alarm []property
+	var alarms int
+
+	for i, l := range lines {
+		p, err := parseLine(l)
+		if err != nil {
+			return events, fmt.Errorf("%w: line %d: %v", ErrInvalidCalendar, i+1, err)
+		}
+
+		switch p.name {
+		case "BEGIN":
+			component := strings.ToUpper(p.value)
+			stack = append(stack, component)
+
+			if component == "VEVENT" {
+				current = []property{}
+				alarm = nil
+				alarms = 0
+			}
+
+			if component == "VALARM" {
+				alarms++
+			}
+			continue
+		case "END":
+			component := strings.ToUpper(p.value)
+			if len(stack) == 0 || stack[len(stack)-1] != component {
+				return events, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalidCalendar, i+1, p.value)
+			}
+			stack = stack[:len(stack)-1]
+
+			if component == "VEVENT" {
+				events = append(events, decodeEvent(current, alarm, loc))
+				current = nil
+			}
+			continue
+		}
+
+		if current == nil || len(stack) == 0 {
+			continue
+		}
+
+		switch stack[len(stack)-1] {
+		case "VEVENT":
+			current = append(current, p)
+		case "VALARM":
+			// only the first alarm can be represented by the alert time of an event
+			if alarms == 1 {
+				alarm = append(alarm, p)
+			}
+		}
+	}
+
+	if len(stack) != 0 {
+		return events, fmt.Errorf("%w: missing END:%s", ErrInvalidCalendar, stack[len(stack)-1])
+	}
+
+	return events, nil
+}
+
+func decodeEvent(props, alarm []property, loc *time.Location) VEvent {
+	var v VEvent
+	var duration *time.Duration
+	var hasEnd bool
+
+	for _, p := range props {
+		var err error
+
+		switch p.name {
+		case "UID":
+			v.UID = p.value
+		case "SUMMARY":
+			v.Event.Name = unescape(p.value)
+		case "DESCRIPTION":
+			v.Event.Description = unescape(p.value)
+		case "DTSTART":
+			v.Event.StartTime, v.AllDay, err = parseTime(p, loc)
+			// Times with a TZID keep their timezone, so that the event follows its offsets
+			if _, ok := p.params["TZID"]; ok && err == nil && !v.AllDay {
+				v.Event.Timezone = v.Event.StartTime.Location().String()
+			}
+		case "DTEND":
+			v.Event.EndTime, _, err = parseTime(p, loc)
+			hasEnd = true
+		case "DURATION":
+			var d time.Duration
+			d, err = parseDuration(p.value)
+			duration = &d
+		case "RRULE":
+			v.Event.Recurrence = p.value
+		case "EXDATE":
+			for _, value := range strings.Split(p.value, ",") {
+				var t time.Time
+				t, _, err = parseTime(property{name: p.name, params: p.params, value: value}, loc)
+				if err != nil {
+					break
+				}
+				v.ExDates = append(v.ExDates, t)
+			}
+		case "RECURRENCE-ID":
+			var t time.Time
+			t, _, err = parseTime(p, loc)
+			v.RecurrenceID = &t
+		case "STATUS":
+			if strings.EqualFold(p.value, "CANCELLED") {
+				err = errors.New("event is cancelled")
+			}
+		}
+
+		if err != nil && v.Err == nil {
+			v.Err = fmt.Errorf("%s: %v", p.name, err)
+		}
+	}
+
+	if v.Err != nil {
+		return v
+	}
+
+	if v.Event.StartTime.IsZero() {
+		v.Err = errors.New("missing DTSTART")
+		return v
+	}
+
+	switch {
+	case hasEnd:
+	case duration != nil:
+		v.Event.EndTime = v.Event.StartTime.Add(*duration)
+	case v.AllDay:
+		v.Event.EndTime = v.Event.StartTime.AddDate(0, 0, 1)
+	default:
+		v.Event.EndTime = v.Event.StartTime
+	}
+
+	if v.Event.EndTime.Before(v.Event.StartTime) {
+		v.Err = errors.New("DTEND is before DTSTART")
+		return v
+	}
+
+	v.Event.AlertTime, v.Err = decodeAlarm(alarm, v.Event, loc)
+
+	return v
+}
+
+// decodeAlarm returns the alert time of the first VALARM of an event
+func decodeAlarm(alarm []property, e types.Event, loc *time.Location) (time.Time, error) {
+	for _, p := range alarm {
+		if p.name != "TRIGGER" {
+			continue
+		}
+
+		if strings.EqualFold(p.params["VALUE"], "DATE-TIME") {
+			t, _, err := parseTime(p, loc)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("TRIGGER: %v", err)
+			}
+			return t, nil
+		}
+
+		d, err := parseDuration(p.value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("TRIGGER: %v", err)
+		}
+
+		if strings.EqualFold(p.params["RELATED"], "END") {
+			return e.EndTime.Add(d), nil
+		}
+
+		return e.StartTime.Add(d), nil
+	}
+
+	return time.Time{}, nil
+}
+
+// parseTime parses a DATE or DATE-TIME value, the returned flag tells whether it was a DATE
+func parseTime(p property, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.value)
+
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(value) == len(dateFormat) {
+		t, err := time.ParseInLocation(dateFormat, value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcFormat, value)
+		return t, false, err
+	}
+
+	if tzid, ok := p.params["TZID"]; ok {
+		l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown timezone %q", tzid)
+		}
+		loc = l
+	}
+
+	t, err := time.ParseInLocation(dateTimeFormat, value, loc)
+	return t, false, err
+}
+
+// parseDuration parses a duration according to RFC 5545, section 3.3.6
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	invalid := fmt.Errorf("invalid duration %q", s)
+
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = -1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, invalid
+	}
+	s = s[1:]
+
+	var d time.Duration
+	inTime := false
+	number := ""
+
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			number += string(r)
+			continue
+		case r == 'T' && number == "" && !inTime:
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, invalid
+		}
+		number = ""
+
+		switch {
+		case r == 'W' && !inTime:
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			d += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, invalid
+		}
+	}
+
+	if number != "" {
+		return 0, invalid
+	}
+
+	return sign * d, nil
+}
+
+// unfold joins folded content lines, skipping empty ones
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		l := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCalendar, err)
+	}
+
+	return lines, nil
+}
+
+// parseLine splits a content line into its name, parameters and value,
+// colons and semicolons in quoted parameter values are not separators
+func parseLine(l string) (property, error) {
+	p := property{params: make(map[string]string)}
+
+	quoted := false
+	start := 0
+	var key string
+
+	for i, r := range l {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == ';' || r == ':':
+			part := l[start:i]
+
+			if p.name == "" {
+				p.name = strings.ToUpper(part)
+			} else if key != "" {
+				p.params[key] = strings.Trim(part, `"`)
+				key = ""
+			} else {
+				return p, fmt.Errorf("invalid parameter %q", part)
+			}
+
+			if r == ':' {
+				p.value = l[i+1:]
+				return p, nil
+			}
+			start = i + 1
+		case r == '=' && key == "" && p.name != "":
+			key = strings.ToUpper(l[start:i])
+			start = i + 1
+		}
+	}
+
+	return p, fmt.Errorf("missing value in %q", l)
+}
+
+// unescape reverses escaping of a TEXT value
+func unescape(s string) string {
+	var b strings.Builder
+
+	escaped := false
+	for _, r := range s {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+
+		if escaped && (r == 'n' || r == 'N') {
+			r = '\n'
+		}
+
+		b.WriteRune(r)
+		escaped = false
+	}
+
+	return b.String()
+}
@@ -0,0 +1,205 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	warsaw, _ := time.LoadLocation("Europe/Warsaw")
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTIMEZONE",
+		"TZID:America/New_York",
+		"BEGIN:STANDARD",
+		"DTSTART:19701101T020000",
+		"TZOFFSETFROM:-0400",
+		"TZOFFSETTO:-0500",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:meeting@example.com",
+		`DTSTART;TZID="America/New_York":20230320T090000`,
+		"DURATION:PT1H30M",
+		`SUMMARY:Planning\, Q2`,
+		"DESCRIPTION:First line\\nsecond line that is long enough to be folded by the cli",
+		" ent",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO",
+		"EXDATE;TZID=America/New_York:20230327T090000,20230403T090000",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:AUDIO",
+		"TRIGGER:-P1D",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:holiday@example.com",
+		"DTSTART;VALUE=DATE:20230501",
+		"SUMMARY:Holiday",
+		"BEGIN:VALARM",
+		"TRIGGER;RELATED=END:PT0S",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:meeting@example.com",
+		"RECURRENCE-ID:20230410T130000Z",
+		"DTSTART:20230410T140000Z",
+		"DTEND:20230410T150000Z",
+		"SUMMARY:Planning moved",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:broken@example.com",
+		"DTSTART;TZID=Mars/Olympus:20230320T090000",
+		"SUMMARY:Broken",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Decode(strings.NewReader(calendar), warsaw)
+	require.Nil(t, err)
+	require.Len(t, events, 4)
+
+	meeting := events[0]
+	start := time.Date(2023, 3, 20, 9, 0, 0, 0, newYork)
+	require.Nil(t, meeting.Err)
+	require.Equal(t, "meeting@example.com", meeting.UID)
+	require.Equal(t, "Planning, Q2", meeting.Event.Name)
+	require.Equal(t, "First line\nsecond line that is long enough to be folded by the client", meeting.Event.Description)
+	require.True(t, meeting.Event.StartTime.Equal(start))
+	require.True(t, meeting.Event.EndTime.Equal(start.Add(90*time.Minute)))
+	require.True(t, meeting.Event.AlertTime.Equal(start.Add(-15*time.Minute)), "only the first alarm should be used")
+	require.Equal(t, "FREQ=WEEKLY;BYDAY=MO", meeting.Event.Recurrence)
+	require.Len(t, meeting.ExDates, 2)
+	require.True(t, meeting.ExDates[1].Equal(start.AddDate(0, 0, 14)))
+	require.False(t, meeting.AllDay)
+
+	holiday := events[1]
+	require.Nil(t, holiday.Err)
+	require.True(t, holiday.AllDay)
+	require.Equal(t, time.Date(2023, 5, 1, 0, 0, 0, 0, warsaw), holiday.Event.StartTime)
+	require.Equal(t, time.Date(2023, 5, 2, 0, 0, 0, 0, warsaw), holiday.Event.EndTime)
+	require.Equal(t, holiday.Event.EndTime, holiday.Event.AlertTime)
+
+	override := events[2]
+	require.Nil(t, override.Err)
+	require.NotNil(t, override.RecurrenceID)
+	require.True(t, override.RecurrenceID.Equal(time.Date(2023, 4, 10, 13, 0, 0, 0, time.UTC)))
+
+	require.ErrorContains(t, events[3].Err, `unknown timezone "Mars/Olympus"`)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		calendar string
+	}{
+		{name: "empty", calendar: ""},
+		{name: "notCalendar", calendar: "BEGIN:VCARD\r\nEND:VCARD\r\n"},
+		{name: "unterminated", calendar: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "missingValue", calendar: "BEGIN:VCALENDAR\r\nSUMMARY\r\nEND:VCALENDAR\r\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tc.calendar), time.UTC)
+			require.ErrorIs(t, err, ErrInvalidCalendar)
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	testCases := []struct {
+		s      string
+		exp    time.Duration
+		expErr bool
+	}{
+		{s: "-PT15M", exp: -15 * time.Minute},
+		{s: "P1W", exp: 7 * 24 * time.Hour},
+		{s: "+P1DT2H30S", exp: 26*time.Hour + 30*time.Second},
+		{s: "PT", expErr: true},
+		{s: "P1H", expErr: true},
+		{s: "15M", expErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.s, func(t *testing.T) {
+			d, err := parseDuration(tc.s)
+			if tc.expErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, tc.exp, d)
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, loc)
+
+	c := Calendar{
+		Events: []types.Event{{ID: 1, Name: "Review; final, really", StartTime: ti, EndTime: ti.Add(time.Hour),
+			Description: strings.Repeat("Long description ", 10), AlertTime: ti.Add(-time.Hour)}},
+		Location: loc,
+		Stamp:    ti,
+	}
+
+	var b strings.Builder
+	require.Nil(t, Encode(&b, c))
+
+	events, err := Decode(strings.NewReader(b.String()), time.UTC)
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "1@mck", events[0].UID)
+	require.Equal(t, c.Events[0].Name, events[0].Event.Name)
+	require.Equal(t, c.Events[0].Description, events[0].Event.Description)
+	require.True(t, events[0].Event.StartTime.Equal(ti))
+	require.True(t, events[0].Event.AlertTime.Equal(c.Events[0].AlertTime))
+}
+
+func TestRoundTripEventTimezone(t *testing.T) {
+	warsaw, _ := time.LoadLocation("Europe/Warsaw")
+	newYork, _ := time.LoadLocation("America/New_York")
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, newYork)
+
+	c := Calendar{
+		Events: []types.Event{
+			{ID: 1, Name: "Stand-up", StartTime: ti, EndTime: ti.Add(15 * time.Minute), Recurrence: "FREQ=DAILY",
+				Timezone: "America/New_York"},
+			{ID: 2, Name: "Lunch", StartTime: ti, EndTime: ti.Add(time.Hour)},
+		},
+		Exceptions: []types.EventException{{EventID: 1, OriginalStart: ti.AddDate(0, 0, 1), Name: "Moved",
+			StartTime: ti.AddDate(0, 0, 1).Add(time.Hour), EndTime: ti.AddDate(0, 0, 1).Add(2 * time.Hour)}},
+		Location: warsaw,
+		Stamp:    ti,
+	}
+
+	var b strings.Builder
+	require.Nil(t, Encode(&b, c))
+
+	// Times are written in the timezone of the event, which is described like the one of the calendar
+	out := b.String()
+	require.Contains(t, out, "BEGIN:VTIMEZONE\r\nTZID:Europe/Warsaw\r\n")
+	require.Contains(t, out, "BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\n")
+	require.Contains(t, out, "DTSTART;TZID=America/New_York:20230320T090000\r\n")
+	require.Contains(t, out, "RECURRENCE-ID;TZID=America/New_York:20230321T090000\r\n")
+	require.Contains(t, out, "DTSTART;TZID=Europe/Warsaw:20230320T140000\r\n")
+
+	events, err := Decode(strings.NewReader(out), time.UTC)
+	require.Nil(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, "America/New_York", events[0].Event.Timezone)
+	require.True(t, events[0].Event.StartTime.Equal(ti))
+	require.Equal(t, "Europe/Warsaw", events[1].Event.Timezone)
+	require.True(t, events[2].RecurrenceID.Equal(ti.AddDate(0, 0, 1)))
+}
@@ -0,0 +1,366 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bubo-py/McK/types"
+)
+
+const (
+	prodID = "-//bubo-py//McK//EN"
+
+	dateTimeFormat = "20060102T150405"
+	utcFormat      = "20060102T150405Z"
+
+	// maxLineLength is the limit of octets in a content line, excluding the line break
+	maxLineLength = 75
+)
+
+// Calendar is a set of events exported in their own timezones, or in the timezone of their owner
+// when they have none
+type Calendar struct {
+	Events []types.Event
+
+	// Exceptions of the recurring events, cancelled occurrences become EXDATEs of their series
+	// and the modified ones are exported as separate VEVENTs with a RECURRENCE-ID
+	Exceptions []types.EventException
+
+	Location *time.Location
+
+	// Stamp is the DTSTAMP of every VEVENT, the time the calendar was generated at
+	Stamp time.Time
+}
+
+// UID returns the globally unique identifier of an event, shared by all of its occurrences
+func UID(id int64) string {
+	return fmt.Sprintf("%d@mck", id)
+}
+
+// eventUID keeps the identifier an event was imported with, so that it stays the same across calendars
+func eventUID(e types.Event) string {
+	if e.UID != "" {
+		return e.UID
+	}
+
+	return UID(e.ID)
+}
+
+// Encode writes the calendar as an RFC 5545 VCALENDAR object
+func Encode(w io.Writer, c Calendar) error {
+	e := encoder{w: bufio.NewWriter(w), loc: c.Location}
+	if e.loc == nil {
+		e.loc = time.UTC
+	}
+
+	uids := make(map[int64]string)
+	allDay := make(map[int64]bool)
+	locations := make(map[int64]*time.Location)
+	for _, event := range c.Events {
+		uids[event.ID] = eventUID(event)
+		allDay[event.ID] = event.AllDay
+		locations[event.ID] = e.location(event)
+	}
+
+	cancelled := make(map[int64][]time.Time)
+	for _, ex := range c.Exceptions {
+		if ex.Cancelled {
+			cancelled[ex.EventID] = append(cancelled[ex.EventID], ex.OriginalStart)
+		}
+	}
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", prodID)
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("X-WR-TIMEZONE", e.loc.String())
+
+	// Every timezone the times are written in is described by a VTIMEZONE
+	zones := []*time.Location{e.loc}
+	seen := map[string]bool{e.loc.String(): true}
+	for _, event := range c.Events {
+		loc := locations[event.ID]
+		if !seen[loc.String()] {
+			seen[loc.String()] = true
+			zones = append(zones, loc)
+		}
+	}
+
+	for _, loc := range zones {
+		if loc != time.UTC {
+			e.timezone(loc, c)
+		}
+	}
+
+	for _, event := range c.Events {
+		e.event(event, cancelled[event.ID], locations[event.ID], c.Stamp)
+	}
+
+	for _, ex := range c.Exceptions {
+		if ex.Cancelled {
+			continue
+		}
+
+		loc, ok := locations[ex.EventID]
+		if !ok {
+			loc = e.loc
+		}
+
+		e.exception(ex, uids[ex.EventID], allDay[ex.EventID], loc, c.Stamp)
+	}
+
+	e.line("END", "VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+
+	return e.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	loc *time.Location
+	err error
+}
+
+// location returns the timezone the times of the event are written in, its own one or the one of the calendar
+func (e *encoder) location(event types.Event) *time.Location {
+	if event.Timezone == "" || event.AllDay {
+		return e.loc
+	}
+
+	loc, err := time.LoadLocation(event.Timezone)
+	if err != nil {
+		return e.loc
+	}
+
+	return loc
+}
+
+func (e *encoder) event(event types.Event, exdates []time.Time, loc *time.Location, stamp time.Time) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", eventUID(event))
+	e.line("DTSTAMP", stamp.UTC().Format(utcFormat))
+
+	if event.OriginalStart != nil {
+		e.time("RECURRENCE-ID", *event.OriginalStart, event.AllDay, loc)
+	}
+
+	e.time("DTSTART", event.StartTime, event.AllDay, loc)
+	e.time("DTEND", event.EndTime, event.AllDay, loc)
+	e.line("SUMMARY", escape(event.Name))
+
+	if event.Description != "" {
+		e.line("DESCRIPTION", escape(event.Description))
+	}
+
+	if event.Recurrence != "" && event.OriginalStart == nil {
+		e.line("RRULE", event.Recurrence)
+
+		for _, t := range exdates {
+			e.time("EXDATE", t, event.AllDay, loc)
+		}
+	}
+
+	e.alarm(event.Name, event.StartTime, event.AlertTime)
+	e.line("END", "VEVENT")
+}
+
+func (e *encoder) exception(ex types.EventException, uid string, allDay bool, loc *time.Location,
+	stamp time.Time) {
+	if uid == "" {
+		uid = UID(ex.EventID)
+	}
+
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", uid)
+	e.line("DTSTAMP", stamp.UTC().Format(utcFormat))
+	e.time("RECURRENCE-ID", ex.OriginalStart, allDay, loc)
+	e.time("DTSTART", ex.StartTime, allDay, loc)
+	e.time("DTEND", ex.EndTime, allDay, loc)
+	e.line("SUMMARY", escape(ex.Name))
+
+	if ex.Description != "" {
+		e.line("DESCRIPTION", escape(ex.Description))
+	}
+
+	e.alarm(ex.Name, ex.StartTime, ex.AlertTime)
+	e.line("END", "VEVENT")
+}
+
+// alarm writes a VALARM triggered relatively to the start of the event
+func (e *encoder) alarm(name string, start, alert time.Time) {
+	if alert.IsZero() {
+		return
+	}
+
+	e.line("BEGIN", "VALARM")
+	e.line("ACTION", "DISPLAY")
+	e.line("DESCRIPTION", escape(name))
+	e.line("TRIGGER", formatDuration(alert.Sub(start)))
+	e.line("END", "VALARM")
+}
+
+// timezone writes a VTIMEZONE with every transition of the location in the years the calendar spans
+func (e *encoder) timezone(loc *time.Location, c Calendar) {
+	from, to := c.Stamp.Year(), c.Stamp.Year()+1
+	for _, event := range c.Events {
+		if y := event.StartTime.Year(); y < from {
+			from = y
+		}
+
+		if y := event.EndTime.Year() + 1; y > to {
+			to = y
+		}
+	}
+
+	e.line("BEGIN", "VTIMEZONE")
+	e.line("TZID", loc.String())
+
+	t := time.Date(from, time.January, 1, 0, 0, 0, 0, loc)
+	start, end := t.ZoneBounds()
+
+	if !start.IsZero() {
+		e.observance(start)
+	} else {
+		// The location has no transitions at all, e.g. UTC offsets fixed for decades
+		name, offset := t.Zone()
+		e.line("BEGIN", "STANDARD")
+		e.line("DTSTART", "19700101T000000")
+		e.line("TZOFFSETFROM", formatOffset(offset))
+		e.line("TZOFFSETTO", formatOffset(offset))
+		e.line("TZNAME", escape(name))
+		e.line("END", "STANDARD")
+	}
+
+	for !end.IsZero() && end.Year() < to {
+		e.observance(end)
+		_, end = end.ZoneBounds()
+	}
+
+	e.line("END", "VTIMEZONE")
+}
+
+// observance writes the STANDARD or DAYLIGHT component starting at the given transition
+func (e *encoder) observance(transition time.Time) {
+	kind := "STANDARD"
+	if transition.IsDST() {
+		kind = "DAYLIGHT"
+	}
+
+	name, offsetTo := transition.Zone()
+	_, offsetFrom := transition.Add(-time.Second).Zone()
+
+	e.line("BEGIN", kind)
+	// DTSTART of an observance is the local time of the transition in the offset in effect before it
+	e.line("DTSTART", transition.In(time.FixedZone("", offsetFrom)).Format(dateTimeFormat))
+	e.line("TZOFFSETFROM", formatOffset(offsetFrom))
+	e.line("TZOFFSETTO", formatOffset(offsetTo))
+	e.line("TZNAME", escape(name))
+	e.line("END", kind)
+}
+
+// time writes a DATE-TIME in the given location, dates of all-day events are written as DATE values
+func (e *encoder) time(name string, t time.Time, date bool, loc *time.Location) {
+	if date {
+		e.line(name+";VALUE=DATE", t.UTC().Format(dateFormat))
+		return
+	}
+
+	if loc == time.UTC {
+		e.line(name, t.UTC().Format(utcFormat))
+		return
+	}
+
+	e.line(name+";TZID="+loc.String(), t.In(loc).Format(dateTimeFormat))
+}
+
+// line writes a content line folded after maxLineLength octets, multi-octet characters are never split
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+
+	l := name + ":" + value
+
+	var b strings.Builder
+	length := 0
+	for _, r := range l {
+		size := utf8.RuneLen(r)
+		if length+size > maxLineLength {
+			b.WriteString("\r\n ")
+			length = 1
+		}
+
+		b.WriteRune(r)
+		length += size
+	}
+	b.WriteString("\r\n")
+
+	_, e.err = e.w.WriteString(b.String())
+}
+
+// escape escapes a TEXT value according to RFC 5545, section 3.3.11
+func escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return r.Replace(s)
+}
+
+// formatDuration formats a duration according to RFC 5545, section 3.3.6, e.g. -PT15M
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+	seconds := d / time.Second
+
+	s := sign + "P"
+	if days > 0 {
+		s += fmt.Sprintf("%dD", days)
+	}
+
+	if hours == 0 && minutes == 0 && seconds == 0 {
+		if days == 0 {
+			return "PT0S"
+		}
+		return s
+	}
+
+	s += "T"
+	if hours > 0 {
+		s += fmt.Sprintf("%dH", hours)
+	}
+
+	if minutes > 0 {
+		s += fmt.Sprintf("%dM", minutes)
+	}
+
+	if seconds > 0 {
+		s += fmt.Sprintf("%dS", seconds)
+	}
+
+	return s
+}
+
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+}
@@ -0,0 +1,147 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, loc)
+	stamp := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	originalStart := ti.AddDate(0, 0, 7)
+
+	c := Calendar{
+		Events: []types.Event{
+			{
+				ID:          1,
+				Name:        "Stand-up; daily, short",
+				StartTime:   ti,
+				EndTime:     ti.Add(15 * time.Minute),
+				Description: "First line\nSecond line \\ end",
+				AlertTime:   ti.Add(-10 * time.Minute),
+				Recurrence:  "FREQ=WEEKLY;BYDAY=MO",
+			},
+			{
+				ID:            2,
+				Name:          "Moved",
+				StartTime:     ti.Add(time.Hour),
+				EndTime:       ti.Add(2 * time.Hour),
+				OriginalStart: &originalStart,
+			},
+		},
+		Exceptions: []types.EventException{
+			{EventID: 1, OriginalStart: ti.AddDate(0, 0, 14), Cancelled: true},
+			{EventID: 1, OriginalStart: ti.AddDate(0, 0, 21), Name: "Later", StartTime: ti.AddDate(0, 0, 21).Add(time.Hour),
+				EndTime: ti.AddDate(0, 0, 21).Add(2 * time.Hour)},
+		},
+		Location: loc,
+		Stamp:    stamp,
+	}
+
+	var b bytes.Buffer
+	err := Encode(&b, c)
+	require.Nil(t, err)
+
+	out := b.String()
+	require.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	require.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+
+	for _, l := range []string{
+		"TZID:Europe/Warsaw",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20230326T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\nEND:DAYLIGHT",
+		"BEGIN:STANDARD\r\nDTSTART:20231029T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\nEND:STANDARD",
+		"UID:1@mck\r\nDTSTAMP:20230301T120000Z\r\nDTSTART;TZID=Europe/Warsaw:20230320T090000\r\n" +
+			"DTEND;TZID=Europe/Warsaw:20230320T091500",
+		`SUMMARY:Stand-up\; daily\, short`,
+		`DESCRIPTION:First line\nSecond line \\ end`,
+		"RRULE:FREQ=WEEKLY;BYDAY=MO\r\nEXDATE;TZID=Europe/Warsaw:20230403T090000",
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Stand-up\\; daily\\, short\r\nTRIGGER:-PT10M\r\nEND:VALARM",
+		"UID:2@mck\r\nDTSTAMP:20230301T120000Z\r\nRECURRENCE-ID;TZID=Europe/Warsaw:20230327T090000\r\n",
+		"UID:1@mck\r\nDTSTAMP:20230301T120000Z\r\nRECURRENCE-ID;TZID=Europe/Warsaw:20230410T090000\r\n" +
+			"DTSTART;TZID=Europe/Warsaw:20230410T100000",
+	} {
+		require.Contains(t, out, l)
+	}
+
+	require.Equal(t, 1, strings.Count(out, "RRULE"), "occurrences should not repeat the rule of their series")
+}
+
+func TestEncodeUTC(t *testing.T) {
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, time.UTC)
+
+	var b bytes.Buffer
+	err := Encode(&b, Calendar{
+		Events:   []types.Event{{ID: 1, Name: "Meeting", StartTime: ti, EndTime: ti.Add(time.Hour)}},
+		Location: time.UTC,
+		Stamp:    ti,
+	})
+	require.Nil(t, err)
+
+	require.NotContains(t, b.String(), "VTIMEZONE")
+	require.Contains(t, b.String(), "DTSTART:20230320T090000Z\r\nDTEND:20230320T100000Z\r\n")
+	require.NotContains(t, b.String(), "VALARM")
+}
+
+func TestEncodeAllDay(t *testing.T) {
+	d := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	warsaw, _ := time.LoadLocation("Europe/Warsaw")
+
+	var b bytes.Buffer
+	err := Encode(&b, Calendar{
+		Events: []types.Event{{ID: 1, Name: "Holiday", StartTime: d, EndTime: d.AddDate(0, 0, 1), AllDay: true,
+			Recurrence: "FREQ=YEARLY"}},
+		Exceptions: []types.EventException{{EventID: 1, OriginalStart: d.AddDate(1, 0, 0), Cancelled: true}},
+		Location:   warsaw,
+		Stamp:      d,
+	})
+	require.Nil(t, err)
+
+	// Dates are not shifted to the location of the calendar
+	require.Contains(t, b.String(), "DTSTART;VALUE=DATE:20230501\r\nDTEND;VALUE=DATE:20230502\r\n")
+	require.Contains(t, b.String(), "EXDATE;VALUE=DATE:20240501\r\n")
+}
+
+func TestLineFolding(t *testing.T) {
+	var b bytes.Buffer
+	e := encoder{w: bufio.NewWriter(&b)}
+
+	e.line("DESCRIPTION", strings.Repeat("a", 70)+strings.Repeat("ż", 40))
+	require.Nil(t, e.w.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	require.Len(t, lines, 3)
+
+	for i, l := range lines {
+		require.LessOrEqual(t, len(l), maxLineLength)
+		if i > 0 {
+			require.True(t, strings.HasPrefix(l, " "), "continuation lines should start with a space")
+		}
+	}
+
+	unfolded := strings.ReplaceAll(strings.TrimSuffix(b.String(), "\r\n"), "\r\n ", "")
+	require.Equal(t, "DESCRIPTION:"+strings.Repeat("a", 70)+strings.Repeat("ż", 40), unfolded)
+}
+
+func TestFormatDuration(t *testing.T) {
+	testCases := []struct {
+		d   time.Duration
+		exp string
+	}{
+		{d: -15 * time.Minute, exp: "-PT15M"},
+		{d: 0, exp: "PT0S"},
+		{d: 24 * time.Hour, exp: "P1D"},
+		{d: -(26*time.Hour + 30*time.Second), exp: "-P1DT2H30S"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.exp, func(t *testing.T) {
+			require.Equal(t, tc.exp, formatDuration(tc.d))
+		})
+	}
+}
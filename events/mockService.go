@@ -6,7 +6,9 @@ package events
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
+	time "time"
 
 	types "github.com/bubo-py/McK/types"
 	gomock "github.com/golang/mock/gomock"
@@ -35,6 +37,35 @@ func (m *MockBusinessLogicInterface) EXPECT() *MockBusinessLogicInterfaceMockRec
 	return m.recorder
 }
 
+// AddCalendar mocks base method.
+func (m *MockBusinessLogicInterface) AddCalendar(arg0 context.Context, arg1 types.Calendar) (types.Calendar, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCalendar", arg0, arg1)
+	ret0, _ := ret[0].(types.Calendar)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCalendar indicates an expected call of AddCalendar.
+func (mr *MockBusinessLogicInterfaceMockRecorder) AddCalendar(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCalendar", reflect.TypeOf((*MockBusinessLogicInterface)(nil).AddCalendar), arg0, arg1)
+}
+
+// AddDefaultCalendar mocks base method.
+func (m *MockBusinessLogicInterface) AddDefaultCalendar(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDefaultCalendar", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDefaultCalendar indicates an expected call of AddDefaultCalendar.
+func (mr *MockBusinessLogicInterfaceMockRecorder) AddDefaultCalendar(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDefaultCalendar", reflect.TypeOf((*MockBusinessLogicInterface)(nil).AddDefaultCalendar), arg0, arg1)
+}
+
 // AddEvent mocks base method.
 func (m *MockBusinessLogicInterface) AddEvent(arg0 context.Context, arg1 types.Event) error {
 	m.ctrl.T.Helper()
@@ -49,6 +80,20 @@ func (mr *MockBusinessLogicInterfaceMockRecorder) AddEvent(arg0, arg1 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEvent", reflect.TypeOf((*MockBusinessLogicInterface)(nil).AddEvent), arg0, arg1)
 }
 
+// DeleteCalendar mocks base method.
+func (m *MockBusinessLogicInterface) DeleteCalendar(arg0 context.Context, arg1 int64, arg2 types.CalendarEvents, arg3 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCalendar", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCalendar indicates an expected call of DeleteCalendar.
+func (mr *MockBusinessLogicInterfaceMockRecorder) DeleteCalendar(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCalendar", reflect.TypeOf((*MockBusinessLogicInterface)(nil).DeleteCalendar), arg0, arg1, arg2, arg3)
+}
+
 // DeleteEvent mocks base method.
 func (m *MockBusinessLogicInterface) DeleteEvent(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
@@ -63,6 +108,79 @@ func (mr *MockBusinessLogicInterfaceMockRecorder) DeleteEvent(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEvent", reflect.TypeOf((*MockBusinessLogicInterface)(nil).DeleteEvent), arg0, arg1)
 }
 
+// DeleteOccurrence mocks base method.
+func (m *MockBusinessLogicInterface) DeleteOccurrence(arg0 context.Context, arg1 int64, arg2 time.Time, arg3 types.OccurrenceScope) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOccurrence", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOccurrence indicates an expected call of DeleteOccurrence.
+func (mr *MockBusinessLogicInterfaceMockRecorder) DeleteOccurrence(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOccurrence", reflect.TypeOf((*MockBusinessLogicInterface)(nil).DeleteOccurrence), arg0, arg1, arg2, arg3)
+}
+
+// DeleteShare mocks base method.
+func (m *MockBusinessLogicInterface) DeleteShare(arg0 context.Context, arg1 int64, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteShare", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteShare indicates an expected call of DeleteShare.
+func (mr *MockBusinessLogicInterfaceMockRecorder) DeleteShare(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteShare", reflect.TypeOf((*MockBusinessLogicInterface)(nil).DeleteShare), arg0, arg1, arg2)
+}
+
+// ExportEvents mocks base method.
+func (m *MockBusinessLogicInterface) ExportEvents(arg0 context.Context, arg1 types.Filters) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportEvents", arg0, arg1)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportEvents indicates an expected call of ExportEvents.
+func (mr *MockBusinessLogicInterfaceMockRecorder) ExportEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportEvents", reflect.TypeOf((*MockBusinessLogicInterface)(nil).ExportEvents), arg0, arg1)
+}
+
+// GetCalendar mocks base method.
+func (m *MockBusinessLogicInterface) GetCalendar(arg0 context.Context, arg1 int64) (types.Calendar, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCalendar", arg0, arg1)
+	ret0, _ := ret[0].(types.Calendar)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCalendar indicates an expected call of GetCalendar.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetCalendar(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCalendar", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetCalendar), arg0, arg1)
+}
+
+// GetCalendars mocks base method.
+func (m *MockBusinessLogicInterface) GetCalendars(arg0 context.Context) ([]types.Calendar, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCalendars", arg0)
+	ret0, _ := ret[0].([]types.Calendar)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCalendars indicates an expected call of GetCalendars.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetCalendars(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCalendars", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetCalendars), arg0)
+}
+
 // GetEvent mocks base method.
 func (m *MockBusinessLogicInterface) GetEvent(arg0 context.Context, arg1 int64) (types.Event, error) {
 	m.ctrl.T.Helper()
@@ -93,8 +211,155 @@ func (mr *MockBusinessLogicInterfaceMockRecorder) GetEvents(arg0, arg1 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEvents", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetEvents), arg0, arg1)
 }
 
+// GetEventsInRange mocks base method.
+func (m *MockBusinessLogicInterface) GetEventsInRange(arg0 context.Context, arg1, arg2 time.Time) ([]types.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEventsInRange", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]types.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEventsInRange indicates an expected call of GetEventsInRange.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetEventsInRange(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventsInRange", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetEventsInRange), arg0, arg1, arg2)
+}
+
+// GetEventsPage mocks base method.
+func (m *MockBusinessLogicInterface) GetEventsPage(arg0 context.Context, arg1 types.Filters, arg2 types.Page) (types.EventPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEventsPage", arg0, arg1, arg2)
+	ret0, _ := ret[0].(types.EventPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEventsPage indicates an expected call of GetEventsPage.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetEventsPage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventsPage", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetEventsPage), arg0, arg1, arg2)
+}
+
+// GetFreeBusy mocks base method.
+func (m *MockBusinessLogicInterface) GetFreeBusy(arg0 context.Context, arg1 types.FreeBusyQuery) ([]types.FreeBusy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFreeBusy", arg0, arg1)
+	ret0, _ := ret[0].([]types.FreeBusy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFreeBusy indicates an expected call of GetFreeBusy.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetFreeBusy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFreeBusy", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetFreeBusy), arg0, arg1)
+}
+
+// GetInvitations mocks base method.
+func (m *MockBusinessLogicInterface) GetInvitations(arg0 context.Context, arg1 types.RSVPStatus) ([]types.Invitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInvitations", arg0, arg1)
+	ret0, _ := ret[0].([]types.Invitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInvitations indicates an expected call of GetInvitations.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetInvitations(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvitations", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetInvitations), arg0, arg1)
+}
+
+// GetShares mocks base method.
+func (m *MockBusinessLogicInterface) GetShares(arg0 context.Context, arg1 int64) ([]types.Share, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShares", arg0, arg1)
+	ret0, _ := ret[0].([]types.Share)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShares indicates an expected call of GetShares.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetShares(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShares", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetShares), arg0, arg1)
+}
+
+// ImportEvents mocks base method.
+func (m *MockBusinessLogicInterface) ImportEvents(arg0 context.Context, arg1 io.Reader) (types.ImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportEvents", arg0, arg1)
+	ret0, _ := ret[0].(types.ImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportEvents indicates an expected call of ImportEvents.
+func (mr *MockBusinessLogicInterfaceMockRecorder) ImportEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportEvents", reflect.TypeOf((*MockBusinessLogicInterface)(nil).ImportEvents), arg0, arg1)
+}
+
+// RSVP mocks base method.
+func (m *MockBusinessLogicInterface) RSVP(arg0 context.Context, arg1 int64, arg2 types.RSVPStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RSVP", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RSVP indicates an expected call of RSVP.
+func (mr *MockBusinessLogicInterfaceMockRecorder) RSVP(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RSVP", reflect.TypeOf((*MockBusinessLogicInterface)(nil).RSVP), arg0, arg1, arg2)
+}
+
+// SetShare mocks base method.
+func (m *MockBusinessLogicInterface) SetShare(arg0 context.Context, arg1 int64, arg2 types.Share) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetShare", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetShare indicates an expected call of SetShare.
+func (mr *MockBusinessLogicInterfaceMockRecorder) SetShare(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetShare", reflect.TypeOf((*MockBusinessLogicInterface)(nil).SetShare), arg0, arg1, arg2)
+}
+
+// SuggestSlots mocks base method.
+func (m *MockBusinessLogicInterface) SuggestSlots(arg0 context.Context, arg1 types.SuggestQuery) ([]types.Slot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SuggestSlots", arg0, arg1)
+	ret0, _ := ret[0].([]types.Slot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SuggestSlots indicates an expected call of SuggestSlots.
+func (mr *MockBusinessLogicInterfaceMockRecorder) SuggestSlots(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SuggestSlots", reflect.TypeOf((*MockBusinessLogicInterface)(nil).SuggestSlots), arg0, arg1)
+}
+
+// UpdateCalendar mocks base method.
+func (m *MockBusinessLogicInterface) UpdateCalendar(arg0 context.Context, arg1 types.Calendar, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCalendar", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCalendar indicates an expected call of UpdateCalendar.
+func (mr *MockBusinessLogicInterfaceMockRecorder) UpdateCalendar(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCalendar", reflect.TypeOf((*MockBusinessLogicInterface)(nil).UpdateCalendar), arg0, arg1, arg2)
+}
+
 // UpdateEvent mocks base method.
-func (m *MockBusinessLogicInterface) UpdateEvent(arg0 context.Context, arg1 types.Event, arg2 int64) error {
+func (m *MockBusinessLogicInterface) UpdateEvent(arg0 context.Context, arg1 types.EventUpdate, arg2 int64) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "UpdateEvent", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
@@ -106,3 +371,17 @@ func (mr *MockBusinessLogicInterfaceMockRecorder) UpdateEvent(arg0, arg1, arg2 i
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEvent", reflect.TypeOf((*MockBusinessLogicInterface)(nil).UpdateEvent), arg0, arg1, arg2)
 }
+
+// UpdateOccurrence mocks base method.
+func (m *MockBusinessLogicInterface) UpdateOccurrence(arg0 context.Context, arg1 types.Event, arg2 int64, arg3 time.Time, arg4 types.OccurrenceScope) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOccurrence", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOccurrence indicates an expected call of UpdateOccurrence.
+func (mr *MockBusinessLogicInterfaceMockRecorder) UpdateOccurrence(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOccurrence", reflect.TypeOf((*MockBusinessLogicInterface)(nil).UpdateOccurrence), arg0, arg1, arg2, arg3, arg4)
+}
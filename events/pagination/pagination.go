@@ -0,0 +1,137 @@
+// Package pagination implements keyset pagination of event listings which are not paginated by the database,
+// like recurring events expanded into their occurrences, and the cursors returned to clients.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/bubo-py/McK/types"
+)
+
+// Cursor points to the page following or, when Backward, preceding the key in the sort order
+type Cursor struct {
+	Sort     types.SortField `json:"s"`
+	Desc     bool            `json:"d,omitempty"`
+	Key      types.PageKey   `json:"k"`
+	Backward bool            `json:"b,omitempty"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Encode returns the cursor in the opaque form given to clients
+func Encode(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func Decode(s string) (Cursor, error) {
+	var c Cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	err = json.Unmarshal(b, &c)
+	if err != nil || !ValidSort(c.Sort) {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+func ValidSort(f types.SortField) bool {
+	switch f {
+	case types.SortStartTime, types.SortName, types.SortID:
+		return true
+	default:
+		return false
+	}
+}
+
+// Key returns the position of the event in a listing
+func Key(e types.Event) types.PageKey {
+	return types.PageKey{Name: e.Name, StartTime: e.StartTime, ID: e.ID}
+}
+
+// Compare orders keys by the sorted field in ascending order, it returns a negative number when a goes first
+func Compare(a, b types.PageKey, f types.SortField) int {
+	var c int
+
+	switch f {
+	case types.SortName:
+		c = strings.Compare(a.Name, b.Name)
+	case types.SortStartTime:
+		c = compareTimes(a, b)
+	}
+
+	if c == 0 {
+		c = compareIDs(a, b)
+	}
+
+	if c == 0 {
+		c = compareTimes(a, b)
+	}
+
+	return c
+}
+
+// Select sorts the events and returns the ones selected by the query
+func Select(events []types.Event, q types.PageQuery) []types.Event {
+	sorted := make([]types.Event, len(events))
+	copy(sorted, events)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return follows(Key(sorted[j]), Key(sorted[i]), q)
+	})
+
+	var s []types.Event
+
+	for _, e := range sorted {
+		if q.Limit > 0 && len(s) == q.Limit {
+			break
+		}
+
+		if q.After == nil || follows(Key(e), *q.After, q) {
+			s = append(s, e)
+		}
+	}
+
+	return s
+}
+
+// follows tells whether a goes after b in the order of the query
+func follows(a, b types.PageKey, q types.PageQuery) bool {
+	c := Compare(a, b, q.Sort)
+	if q.Desc {
+		return c < 0
+	}
+
+	return c > 0
+}
+
+func compareTimes(a, b types.PageKey) int {
+	switch {
+	case a.StartTime.Before(b.StartTime):
+		return -1
+	case a.StartTime.After(b.StartTime):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareIDs(a, b types.PageKey) int {
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	default:
+		return 0
+	}
+}
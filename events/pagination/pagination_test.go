@@ -0,0 +1,59 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	c := Cursor{
+		Sort:     types.SortName,
+		Desc:     true,
+		Key:      types.PageKey{Name: "Lunch", StartTime: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), ID: 7},
+		Backward: true,
+	}
+
+	decoded, err := Decode(Encode(c))
+	require.Nil(t, err)
+	require.Equal(t, c, decoded)
+
+	for _, s := range []string{"", "!!!", Encode(Cursor{Sort: "description"})} {
+		_, err = Decode(s)
+		require.ErrorIs(t, err, ErrInvalidCursor, "cursor: %q", s)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	ti := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Occurrences of a recurring event share its ID and are told apart by their start times
+	events := []types.Event{
+		{ID: 2, Name: "Gym", StartTime: ti.AddDate(0, 0, 7)},
+		{ID: 1, Name: "Lunch", StartTime: ti},
+		{ID: 2, Name: "Gym", StartTime: ti},
+		{ID: 3, Name: "Breakfast", StartTime: ti.Add(-time.Hour)},
+	}
+
+	ids := func(e []types.Event) []int64 {
+		var s []int64
+		for _, o := range e {
+			s = append(s, o.ID)
+		}
+		return s
+	}
+
+	s := Select(events, types.PageQuery{Sort: types.SortStartTime, Limit: 3})
+	require.Equal(t, []int64{3, 1, 2}, ids(s))
+
+	after := Key(s[2])
+	s = Select(events, types.PageQuery{Sort: types.SortStartTime, After: &after, Limit: 3})
+	require.Equal(t, []int64{2}, ids(s))
+	require.Equal(t, ti.AddDate(0, 0, 7), s[0].StartTime)
+
+	s = Select(events, types.PageQuery{Sort: types.SortName, Desc: true})
+	require.Equal(t, []int64{1, 2, 2, 3}, ids(s))
+	require.Equal(t, ti.AddDate(0, 0, 7), s[1].StartTime)
+}
@@ -0,0 +1,662 @@
+// Package recurrence implements the subset of RFC 5545 recurrence rules (RRULE) supported by events:
+// FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH, BYSETPOS and WKST.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods guards the expansion against rules which never produce an occurrence
+const maxPeriods = 100000
+
+// endOfTime is the end of the window series bounded by COUNT or UNTIL are expanded over to find their end
+var endOfTime = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeekdayNum is a BYDAY entry, e.g. MO, 2TU or -1FR
+type WeekdayNum struct {
+	N       int // n-th occurrence within the month or year, 0 stands for every such weekday
+	Weekday time.Weekday
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	UntilDate  bool // UNTIL was given as a DATE, every occurrence on that day is included
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+func Parse(s string) (Rule, error) {
+	r := Rule{Interval: 1, WeekStart: time.Monday}
+
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	if s == "" {
+		return r, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return r, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+
+		if seen[name] {
+			return r, fmt.Errorf("%w: %s specified more than once", ErrInvalidRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch Frequency(value) {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = Frequency(value)
+			default:
+				err = fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = parseInt(value, 1, 1000)
+		case "COUNT":
+			r.Count, err = parseInt(value, 1, 10000)
+		case "UNTIL":
+			r.Until, r.UntilDate, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(value, 31)
+		case "BYMONTH":
+			r.ByMonth, err = parseIntList(value, 12)
+			for _, m := range r.ByMonth {
+				if m < 0 {
+					err = fmt.Errorf("month %d out of range", m)
+				}
+			}
+		case "BYSETPOS":
+			r.BySetPos, err = parseIntList(value, 366)
+		case "WKST":
+			wd, ok := weekdays[value]
+			if !ok {
+				err = fmt.Errorf("unknown weekday %q", value)
+			}
+			r.WeekStart = wd
+		default:
+			err = fmt.Errorf("unsupported part %s", name)
+		}
+
+		if err != nil {
+			return r, fmt.Errorf("%w: %s: %v", ErrInvalidRule, name, err)
+		}
+	}
+
+	return r, r.validate()
+}
+
+func (r Rule) validate() error {
+	if r.Freq == "" {
+		return fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+
+	if r.Count != 0 && !r.Until.IsZero() {
+		return fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return fmt.Errorf("%w: BYMONTHDAY cannot be used with a weekly frequency", ErrInvalidRule)
+	}
+
+	if r.Freq == Daily || r.Freq == Weekly {
+		for _, wd := range r.ByDay {
+			if wd.N != 0 {
+				return fmt.Errorf("%w: numeric BYDAY values are only allowed in a monthly or yearly rule", ErrInvalidRule)
+			}
+		}
+	}
+
+	if len(r.BySetPos) > 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 {
+		return fmt.Errorf("%w: BYSETPOS requires another BYxxx part", ErrInvalidRule)
+	}
+
+	return nil
+}
+
+// String returns the canonical representation of the rule, without the RRULE: prefix
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if !r.Until.IsZero() {
+		if r.UntilDate {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			day := weekdayName(wd.Weekday)
+			if wd.N != 0 {
+				day = strconv.Itoa(wd.N) + day
+			}
+			days = append(days, day)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayName(r.WeekStart))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Expand returns start times of the occurrences of a series which overlap the [from, to) window.
+// The series starts at dtstart and every occurrence lasts d. Occurrences keep the wall clock time of dtstart
+// in its location, so a 09:00 meeting stays at 09:00 local time on both sides of a DST change.
+func (r Rule) Expand(dtstart time.Time, d time.Duration, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+
+	start := dateOf(dtstart)
+	count := 0
+
+	k := 0
+	if r.Count == 0 && !from.IsZero() {
+		// periods ending before the window can be skipped, unless COUNT has to be evaluated from the start
+		k = r.periodsBetween(start, dateOf(from.Add(-d).In(dtstart.Location()))) - 1
+		if k < 0 {
+			k = 0
+		}
+	}
+
+	for ; k < maxPeriods; k++ {
+		periodStart := r.period(start, k)
+		midnight := time.Date(periodStart.Year(), periodStart.Month(), periodStart.Day(), 0, 0, 0, 0, dtstart.Location())
+		if !midnight.Before(to) {
+			return occurrences
+		}
+
+		for _, day := range r.applySetPos(r.candidates(periodStart, start)) {
+			t := at(day, dtstart)
+			if t.Before(dtstart) {
+				continue
+			}
+
+			if r.afterUntil(t) {
+				return occurrences
+			}
+
+			count++
+			if r.Count > 0 && count > r.Count {
+				return occurrences
+			}
+
+			if !t.Before(to) {
+				return occurrences
+			}
+
+			if Overlaps(t, t.Add(d), from, to) {
+				occurrences = append(occurrences, t)
+			}
+		}
+	}
+
+	return occurrences
+}
+
+// End returns the end of the last occurrence of a series bounded by COUNT or UNTIL, series which recur forever
+// have no end. A bounded series without any occurrence ends at dtstart.
+func (r Rule) End(dtstart time.Time, d time.Duration) (time.Time, bool) {
+	if r.Count == 0 && r.Until.IsZero() {
+		return time.Time{}, false
+	}
+
+	occurrences := r.Expand(dtstart, d, time.Time{}, endOfTime)
+	if len(occurrences) == 0 {
+		return dtstart, true
+	}
+
+	return occurrences[len(occurrences)-1].Add(d), true
+}
+
+// Includes reports whether the series starting at dtstart has an occurrence starting exactly at t
+func (r Rule) Includes(dtstart time.Time, d time.Duration, t time.Time) bool {
+	for _, start := range r.Expand(dtstart, d, t, t.Add(time.Nanosecond)) {
+		if start.Equal(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Overlaps reports whether an event lasting from start to end falls into the [from, to) window.
+// Zero-length events are treated as taking place at their start time.
+func Overlaps(start, end, from, to time.Time) bool {
+	if !start.Before(to) {
+		return false
+	}
+
+	return end.After(from) || !start.Before(from)
+}
+
+func (r Rule) afterUntil(t time.Time) bool {
+	if r.Until.IsZero() {
+		return false
+	}
+
+	if r.UntilDate {
+		return dateOf(t).After(r.Until)
+	}
+
+	return t.After(r.Until)
+}
+
+// period returns the first day of the k-th period of the series
+func (r Rule) period(start time.Time, k int) time.Time {
+	switch r.Freq {
+	case Daily:
+		return start.AddDate(0, 0, k*r.Interval)
+	case Weekly:
+		return r.weekStart(start).AddDate(0, 0, 7*k*r.Interval)
+	case Monthly:
+		return time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, k*r.Interval, 0)
+	default:
+		return time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(k*r.Interval, 0, 0)
+	}
+}
+
+// periodsBetween returns the index of the period containing the target day
+func (r Rule) periodsBetween(start, target time.Time) int {
+	switch r.Freq {
+	case Daily:
+		return days(start, target) / r.Interval
+	case Weekly:
+		return days(r.weekStart(start), r.weekStart(target)) / 7 / r.Interval
+	case Monthly:
+		return ((target.Year()-start.Year())*12 + int(target.Month()-start.Month())) / r.Interval
+	default:
+		return (target.Year() - start.Year()) / r.Interval
+	}
+}
+
+func (r Rule) weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) - int(r.WeekStart) + 7) % 7))
+}
+
+// candidates returns the sorted days of a single period matching the BYxxx parts of the rule
+func (r Rule) candidates(periodStart, start time.Time) []time.Time {
+	var c []time.Time
+
+	switch r.Freq {
+	case Daily:
+		c = []time.Time{periodStart}
+	case Weekly:
+		if len(r.ByDay) == 0 {
+			c = []time.Time{periodStart.AddDate(0, 0, (int(start.Weekday())-int(r.WeekStart)+7)%7)}
+		}
+		for _, wd := range r.ByDay {
+			c = append(c, periodStart.AddDate(0, 0, (int(wd.Weekday)-int(r.WeekStart)+7)%7))
+		}
+	case Monthly:
+		c = r.monthDays(periodStart.Year(), periodStart.Month(), start)
+	case Yearly:
+		c = r.yearDays(periodStart.Year(), start)
+	}
+
+	var filtered []time.Time
+	for _, day := range c {
+		if r.Freq != Yearly && len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(day.Month())) {
+			continue
+		}
+
+		if r.Freq == Daily && len(r.ByMonthDay) > 0 && !matchesMonthDay(r.ByMonthDay, day) {
+			continue
+		}
+
+		if r.Freq == Daily && len(r.ByDay) > 0 && !matchesWeekday(r.ByDay, day) {
+			continue
+		}
+
+		filtered = append(filtered, day)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Before(filtered[j]) })
+
+	return unique(filtered)
+}
+
+func (r Rule) monthDays(year int, month time.Month, start time.Time) []time.Time {
+	var byMonthDay, byDay []time.Time
+	last := daysIn(year, month)
+
+	for _, md := range r.ByMonthDay {
+		day := md
+		if md < 0 {
+			day = last + md + 1
+		}
+
+		if day >= 1 && day <= last {
+			byMonthDay = append(byMonthDay, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+		}
+	}
+
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	for _, wd := range r.ByDay {
+		byDay = append(byDay, nthWeekdays(first, first.AddDate(0, 1, -1), wd)...)
+	}
+
+	switch {
+	case len(r.ByMonthDay) > 0 && len(r.ByDay) > 0:
+		var both []time.Time
+		for _, day := range byMonthDay {
+			if containsDay(byDay, day) {
+				both = append(both, day)
+			}
+		}
+		return both
+	case len(r.ByMonthDay) > 0:
+		return byMonthDay
+	case len(r.ByDay) > 0:
+		return byDay
+	}
+
+	// neither BYMONTHDAY nor BYDAY, months without the day of DTSTART are skipped
+	if start.Day() > last {
+		return nil
+	}
+
+	return []time.Time{time.Date(year, month, start.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+func (r Rule) yearDays(year int, start time.Time) []time.Time {
+	var c []time.Time
+
+	switch {
+	case len(r.ByMonth) > 0:
+		for _, m := range r.ByMonth {
+			c = append(c, r.monthDays(year, time.Month(m), start)...)
+		}
+	case len(r.ByMonthDay) > 0:
+		for m := time.January; m <= time.December; m++ {
+			c = append(c, r.monthDays(year, m, start)...)
+		}
+	case len(r.ByDay) > 0:
+		first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		for _, wd := range r.ByDay {
+			c = append(c, nthWeekdays(first, first.AddDate(1, 0, -1), wd)...)
+		}
+	default:
+		if start.Day() <= daysIn(year, start.Month()) {
+			c = append(c, time.Date(year, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC))
+		}
+	}
+
+	return c
+}
+
+func (r Rule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 {
+		return days
+	}
+
+	var selected []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(days) + pos
+		}
+
+		if i >= 0 && i < len(days) {
+			selected = append(selected, days[i])
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+
+	return unique(selected)
+}
+
+// nthWeekdays returns the days between first and last (inclusive) matching a BYDAY entry
+func nthWeekdays(first, last time.Time, wd WeekdayNum) []time.Time {
+	firstMatch := first.AddDate(0, 0, (int(wd.Weekday)-int(first.Weekday())+7)%7)
+	lastMatch := last.AddDate(0, 0, -((int(last.Weekday()) - int(wd.Weekday) + 7) % 7))
+
+	switch {
+	case wd.N > 0:
+		day := firstMatch.AddDate(0, 0, 7*(wd.N-1))
+		if day.After(last) {
+			return nil
+		}
+		return []time.Time{day}
+	case wd.N < 0:
+		day := lastMatch.AddDate(0, 0, 7*(wd.N+1))
+		if day.Before(first) {
+			return nil
+		}
+		return []time.Time{day}
+	}
+
+	var all []time.Time
+	for day := firstMatch; !day.After(last); day = day.AddDate(0, 0, 7) {
+		all = append(all, day)
+	}
+
+	return all
+}
+
+func matchesWeekday(byDay []WeekdayNum, day time.Time) bool {
+	for _, wd := range byDay {
+		if wd.Weekday == day.Weekday() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesMonthDay(byMonthDay []int, day time.Time) bool {
+	last := daysIn(day.Year(), day.Month())
+	for _, md := range byMonthDay {
+		if md == day.Day() || last+md+1 == day.Day() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dateOf truncates t to its calendar day (in t's location), represented as midnight UTC
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// at returns the day at the wall clock time of ref, in ref's location
+func at(day, ref time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), ref.Hour(), ref.Minute(), ref.Second(), ref.Nanosecond(), ref.Location())
+}
+
+func days(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func unique(days []time.Time) []time.Time {
+	var u []time.Time
+	for i, day := range days {
+		if i == 0 || !day.Equal(days[i-1]) {
+			u = append(u, day)
+		}
+	}
+
+	return u
+}
+
+func containsDay(days []time.Time, day time.Time) bool {
+	for _, d := range days {
+		if d.Equal(day) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsInt(s []int, v int) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseInt(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return n, fmt.Errorf("%q is not a number", s)
+	}
+
+	if n < min || n > max {
+		return n, fmt.Errorf("%d out of range", n)
+	}
+
+	return n, nil
+}
+
+// parseIntList parses a comma separated list of non-zero numbers within [-max, max]
+func parseIntList(s string, max int) ([]int, error) {
+	var list []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := parseInt(v, -max, max)
+		if err != nil {
+			return list, err
+		}
+
+		if n == 0 {
+			return list, errors.New("0 is not allowed")
+		}
+
+		list = append(list, n)
+	}
+
+	return list, nil
+}
+
+func parseByDay(s string) ([]WeekdayNum, error) {
+	var list []WeekdayNum
+	for _, v := range strings.Split(s, ",") {
+		if len(v) < 2 {
+			return list, fmt.Errorf("malformed weekday %q", v)
+		}
+
+		wd, ok := weekdays[v[len(v)-2:]]
+		if !ok {
+			return list, fmt.Errorf("unknown weekday %q", v)
+		}
+
+		var n int
+		if ordinal := v[:len(v)-2]; ordinal != "" {
+			var err error
+			n, err = parseInt(strings.TrimPrefix(ordinal, "+"), -53, 53)
+			if err != nil || n == 0 {
+				return list, fmt.Errorf("malformed weekday %q", v)
+			}
+		}
+
+		list = append(list, WeekdayNum{N: n, Weekday: wd})
+	}
+
+	return list, nil
+}
+
+func parseUntil(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t, true, nil
+	}
+
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, false, nil
+	}
+
+	// floating date-time, interpreted as UTC
+	if t, err := time.Parse("20060102T150405", s); err == nil {
+		return t, false, nil
+	}
+
+	return time.Time{}, false, fmt.Errorf("malformed date %q", s)
+}
+
+func joinInts(s []int) string {
+	str := make([]string, 0, len(s))
+	for _, i := range s {
+		str = append(str, strconv.Itoa(i))
+	}
+
+	return strings.Join(str, ",")
+}
+
+func weekdayName(wd time.Weekday) string {
+	for name, day := range weekdays {
+		if day == wd {
+			return name
+		}
+	}
+
+	return ""
+}
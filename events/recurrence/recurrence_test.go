@@ -0,0 +1,283 @@
+package recurrence
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		rule     string
+		expRule  string
+		expError bool
+	}{
+		{rule: "FREQ=DAILY", expRule: "FREQ=DAILY"},
+		{rule: "RRULE:freq=weekly;byday=mo,we;interval=2", expRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"},
+		{rule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", expRule: "FREQ=MONTHLY;COUNT=3;BYDAY=-1FR"},
+		{rule: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", expRule: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1"},
+		{rule: "FREQ=YEARLY;UNTIL=20301231", expRule: "FREQ=YEARLY;UNTIL=20301231"},
+		{rule: "FREQ=DAILY;UNTIL=20301231T100000Z", expRule: "FREQ=DAILY;UNTIL=20301231T100000Z"},
+		{rule: "", expError: true},
+		{rule: "INTERVAL=2", expError: true},
+		{rule: "FREQ=HOURLY", expError: true},
+		{rule: "FREQ=DAILY;INTERVAL=0", expError: true},
+		{rule: "FREQ=DAILY;COUNT=2;UNTIL=20301231", expError: true},
+		{rule: "FREQ=DAILY;FREQ=WEEKLY", expError: true},
+		{rule: "FREQ=WEEKLY;BYDAY=1MO", expError: true},
+		{rule: "FREQ=WEEKLY;BYMONTHDAY=1", expError: true},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=32", expError: true},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=0", expError: true},
+		{rule: "FREQ=MONTHLY;BYDAY=XX", expError: true},
+		{rule: "FREQ=MONTHLY;BYSETPOS=1", expError: true},
+		{rule: "FREQ=MONTHLY;BYHOUR=1", expError: true},
+		{rule: "FREQ=MONTHLY;UNTIL=tomorrow", expError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.rule, func(t *testing.T) {
+			r, err := Parse(tc.rule)
+			if tc.expError {
+				require.ErrorIs(t, err, ErrInvalidRule)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, tc.expRule, r.String())
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	warsaw, _ := time.LoadLocation("Europe/Warsaw")
+	date := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, warsaw)
+	}
+
+	testCases := []struct {
+		testName       string
+		rule           string
+		dtstart        time.Time
+		duration       time.Duration
+		from           time.Time
+		to             time.Time
+		expOccurrences []time.Time
+	}{
+		{
+			testName: "Daily_with_count",
+			rule:     "FREQ=DAILY;COUNT=3",
+			dtstart:  date(2022, 9, 14, 9, 0),
+			from:     date(2022, 9, 1, 0, 0),
+			to:       date(2022, 10, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 9, 14, 9, 0), date(2022, 9, 15, 9, 0), date(2022, 9, 16, 9, 0),
+			},
+		},
+		{
+			testName: "Daily_weekdays_only",
+			rule:     "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			dtstart:  date(2022, 9, 16, 9, 0), // Friday
+			from:     date(2022, 9, 16, 0, 0),
+			to:       date(2022, 9, 21, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 9, 16, 9, 0), date(2022, 9, 19, 9, 0), date(2022, 9, 20, 9, 0),
+			},
+		},
+		{
+			testName: "Weekly_keeps_local_time_across_DST",
+			rule:     "FREQ=WEEKLY",
+			dtstart:  date(2022, 3, 21, 9, 0), // CET, UTC+1
+			from:     date(2022, 3, 1, 0, 0),
+			to:       date(2022, 4, 5, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 3, 21, 9, 0), date(2022, 3, 28, 9, 0), date(2022, 4, 4, 9, 0), // CEST, UTC+2
+			},
+		},
+		{
+			testName: "Weekly_every_other_week_two_days",
+			rule:     "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+			dtstart:  date(2022, 9, 13, 10, 0), // Tuesday
+			from:     date(2022, 9, 1, 0, 0),
+			to:       date(2022, 10, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 9, 13, 10, 0), date(2022, 9, 15, 10, 0), date(2022, 9, 27, 10, 0), date(2022, 9, 29, 10, 0),
+			},
+		},
+		{
+			testName: "Weekly_window_far_from_start",
+			rule:     "FREQ=WEEKLY;BYDAY=MO",
+			dtstart:  date(2010, 1, 4, 8, 30),
+			from:     date(2022, 9, 12, 0, 0),
+			to:       date(2022, 9, 20, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 9, 12, 8, 30), date(2022, 9, 19, 8, 30),
+			},
+		},
+		{
+			testName: "Weekly_occurrence_overlapping_window_start",
+			rule:     "FREQ=WEEKLY",
+			dtstart:  date(2022, 9, 5, 23, 0),
+			duration: 2 * time.Hour,
+			from:     date(2022, 9, 13, 0, 0),
+			to:       date(2022, 9, 14, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 9, 12, 23, 0),
+			},
+		},
+		{
+			testName: "Monthly_last_friday",
+			rule:     "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart:  date(2022, 9, 30, 16, 0),
+			from:     date(2022, 9, 1, 0, 0),
+			to:       date(2023, 1, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 9, 30, 16, 0), date(2022, 10, 28, 16, 0), date(2022, 11, 25, 16, 0), date(2022, 12, 30, 16, 0),
+			},
+		},
+		{
+			testName: "Monthly_last_workday",
+			rule:     "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			dtstart:  date(2022, 9, 30, 12, 0),
+			from:     date(2022, 9, 1, 0, 0),
+			to:       date(2023, 1, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 9, 30, 12, 0), date(2022, 10, 31, 12, 0), date(2022, 11, 30, 12, 0), date(2022, 12, 30, 12, 0),
+			},
+		},
+		{
+			testName: "Monthly_skips_months_without_the_day",
+			rule:     "FREQ=MONTHLY;COUNT=3",
+			dtstart:  date(2023, 1, 31, 12, 0),
+			from:     date(2023, 1, 1, 0, 0),
+			to:       date(2024, 1, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2023, 1, 31, 12, 0), date(2023, 3, 31, 12, 0), date(2023, 5, 31, 12, 0),
+			},
+		},
+		{
+			testName: "Monthly_last_day",
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=20230401",
+			dtstart:  date(2023, 1, 31, 12, 0),
+			from:     date(2023, 1, 1, 0, 0),
+			to:       date(2024, 1, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2023, 1, 31, 12, 0), date(2023, 2, 28, 12, 0), date(2023, 3, 31, 12, 0),
+			},
+		},
+		{
+			testName: "Yearly_leap_day",
+			rule:     "FREQ=YEARLY",
+			dtstart:  date(2020, 2, 29, 12, 0),
+			from:     date(2020, 1, 1, 0, 0),
+			to:       date(2029, 1, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2020, 2, 29, 12, 0), date(2024, 2, 29, 12, 0), date(2028, 2, 29, 12, 0),
+			},
+		},
+		{
+			testName: "Yearly_fourth_thursday_of_november",
+			rule:     "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			dtstart:  date(2022, 11, 24, 18, 0),
+			from:     date(2022, 1, 1, 0, 0),
+			to:       date(2025, 1, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 11, 24, 18, 0), date(2023, 11, 23, 18, 0), date(2024, 11, 28, 18, 0),
+			},
+		},
+		{
+			testName: "Until_date_time",
+			rule:     "FREQ=DAILY;UNTIL=20220915T070000Z",
+			dtstart:  date(2022, 9, 14, 9, 0),
+			from:     date(2022, 9, 1, 0, 0),
+			to:       date(2022, 10, 1, 0, 0),
+			expOccurrences: []time.Time{
+				date(2022, 9, 14, 9, 0), date(2022, 9, 15, 9, 0),
+			},
+		},
+		{
+			testName: "Window_before_start",
+			rule:     "FREQ=DAILY",
+			dtstart:  date(2022, 9, 14, 9, 0),
+			from:     date(2022, 8, 1, 0, 0),
+			to:       date(2022, 9, 1, 0, 0),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			r, err := Parse(tc.rule)
+			require.Nil(t, err)
+
+			occurrences := r.Expand(tc.dtstart, tc.duration, tc.from, tc.to)
+			require.Equal(t, fmt.Sprint(tc.expOccurrences), fmt.Sprint(occurrences))
+		})
+	}
+}
+
+func TestExpandDSTOffsets(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	r, err := Parse("FREQ=DAILY")
+	require.Nil(t, err)
+
+	// DST starts in New York on 2022-03-13
+	dtstart := time.Date(2022, 3, 12, 9, 0, 0, 0, newYork)
+	occurrences := r.Expand(dtstart, time.Hour, dtstart, dtstart.AddDate(0, 0, 2))
+
+	require.Len(t, occurrences, 2)
+	require.Equal(t, 14, occurrences[0].UTC().Hour(), "EST should be UTC-5")
+	require.Equal(t, 13, occurrences[1].UTC().Hour(), "EDT should be UTC-4")
+	require.Equal(t, 9, occurrences[1].Hour(), "local time should be preserved")
+}
+
+func TestOverlaps(t *testing.T) {
+	from := time.Date(2022, 9, 14, 14, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 9, 14, 16, 0, 0, 0, time.UTC)
+
+	require.True(t, Overlaps(from.Add(-time.Hour), from.Add(time.Hour), from, to))
+	require.True(t, Overlaps(from, from, from, to), "zero-length event at the window start")
+	require.False(t, Overlaps(from.Add(-time.Hour), from, from, to), "event ending at the window start")
+	require.False(t, Overlaps(to, to.Add(time.Hour), from, to), "event starting at the window end")
+}
+
+func TestIncludes(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	dtstart := time.Date(2023, 3, 20, 9, 0, 0, 0, loc)
+
+	r, err := Parse("FREQ=WEEKLY;BYDAY=MO,WE;COUNT=6")
+	require.Nil(t, err)
+
+	require.True(t, r.Includes(dtstart, time.Hour, dtstart))
+	require.True(t, r.Includes(dtstart, time.Hour, time.Date(2023, 3, 29, 9, 0, 0, 0, loc)), "after the DST change")
+	require.True(t, r.Includes(dtstart, time.Hour, time.Date(2023, 3, 29, 7, 0, 0, 0, time.UTC)), "same instant in UTC")
+	require.False(t, r.Includes(dtstart, time.Hour, time.Date(2023, 3, 29, 8, 0, 0, 0, loc)), "wrong time of day")
+	require.False(t, r.Includes(dtstart, time.Hour, time.Date(2023, 3, 28, 9, 0, 0, 0, loc)), "wrong weekday")
+	require.False(t, r.Includes(dtstart, time.Hour, time.Date(2023, 4, 10, 9, 0, 0, 0, loc)), "beyond COUNT")
+}
+
+func TestEnd(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	dtstart := time.Date(2023, 3, 20, 9, 0, 0, 0, loc)
+
+	testCases := []struct {
+		rule    string
+		exp     time.Time
+		expOpen bool
+	}{
+		{rule: "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=6", exp: time.Date(2023, 4, 5, 10, 0, 0, 0, loc)},
+		{rule: "FREQ=DAILY;UNTIL=20230325", exp: time.Date(2023, 3, 25, 10, 0, 0, 0, loc)},
+		{rule: "FREQ=DAILY;UNTIL=20230401T060000Z", exp: time.Date(2023, 3, 31, 10, 0, 0, 0, loc)},
+		{rule: "FREQ=DAILY;UNTIL=20230301", exp: dtstart},
+		{rule: "FREQ=MONTHLY;BYDAY=-1FR", expOpen: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.rule, func(t *testing.T) {
+			r, err := Parse(tc.rule)
+			require.Nil(t, err)
+
+			end, ok := r.End(dtstart, time.Hour)
+			require.Equal(t, !tc.expOpen, ok)
+			require.True(t, tc.exp.Equal(end), "got: %v", end)
+		})
+	}
+}
@@ -2,96 +2,809 @@ package memoryStorage
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"sort"
+	"time"
 
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/events/pagination"
+	"github.com/bubo-py/McK/events/recurrence"
 	"github.com/bubo-py/McK/types"
 )
 
 type Database struct {
-	ID      int64
-	Storage []types.Event
+	ID         int64
+	Storage    []types.Event
+	Owners     map[int64]string                 // event ID -> login of the user who owns it
+	Exceptions map[int64][]types.EventException // event ID -> exceptions of its occurrences
+	Timezones  map[string]string                // login -> timezone of the user, UTC when missing
+	Outbox     map[string][]types.OutboxMessage // login -> webhook messages about changes of the user's events
+
+	AlertID    int64
+	Alerts     []types.Alert
+	AlertLocks map[int64]time.Time // alert ID -> time until which it is claimed by a dispatcher
+
+	CalendarID     int64
+	Calendars      []types.Calendar
+	CalendarOwners map[int64]string // calendar ID -> login of the user who owns it
+	Shares         map[int64][]types.Share
+
+	Attendees map[int64][]types.Attendee // event ID -> users and guests invited to it
 }
 
 func InitDatabase() *Database {
-	return &Database{}
+	return &Database{Owners: make(map[int64]string), Exceptions: make(map[int64][]types.EventException),
+		Timezones: make(map[string]string), Outbox: make(map[string][]types.OutboxMessage),
+		AlertLocks: make(map[int64]time.Time), CalendarOwners: make(map[int64]string),
+		Shares: make(map[int64][]types.Share), Attendees: make(map[int64][]types.Attendee)}
 }
 
-func (db *Database) GetEvents(ctx context.Context) ([]types.Event, error) {
-	return db.Storage, nil
+func (db *Database) GetEvents(ctx context.Context, login string) ([]types.Event, error) {
+	var s []types.Event
+
+	for _, event := range db.Storage {
+		if db.Owners[event.ID] == login {
+			s = append(s, event)
+		}
+	}
+
+	return s, nil
 }
 
-func (db *Database) GetEvent(ctx context.Context, id int64) (types.Event, error) {
+func (db *Database) GetEvent(ctx context.Context, id int64, login string) (types.Event, error) {
 	for i, event := range db.Storage {
-		if event.ID == id {
+		if event.ID == id && db.Owners[event.ID] == login {
 			return db.Storage[i], nil
 		}
 	}
-	return types.Event{}, errors.New("event with specified id not found")
+	return types.Event{}, customErrors.ErrNotFound
 }
 
-func (db *Database) AddEvent(ctx context.Context, e types.Event) error {
+func (db *Database) GetEventByUID(ctx context.Context, uid, login string) (types.Event, error) {
+	for i, event := range db.Storage {
+		if event.UID == uid && db.Owners[event.ID] == login {
+			return db.Storage[i], nil
+		}
+	}
+	return types.Event{}, customErrors.ErrNotFound
+}
+
+func (db *Database) AddEvent(ctx context.Context, e types.Event, login string) (int64, error) {
+	if err := checkColumns(e.Name, e.Description); err != nil {
+		return 0, err
+	}
+
+	if e.CalendarID == 0 {
+		c, err := db.GetDefaultCalendar(ctx, login)
+		if err != nil {
+			return 0, err
+		}
+		e.CalendarID = c.ID
+	}
+
+	// Attendees are stored apart from events, like Postgres does
+	e.Attendees, e.Organizer = nil, ""
+
 	db.ID += 1
 	e.ID = db.ID
 	db.Storage = append(db.Storage, e)
+	db.Owners[e.ID] = login
 
-	return nil
+	return e.ID, nil
 }
 
-func (db *Database) DeleteEvent(ctx context.Context, id int64) error {
+func (db *Database) DeleteEvent(ctx context.Context, id int64, login string) error {
 	for i, event := range db.Storage {
-		if event.ID == id {
+		if event.ID == id && db.Owners[event.ID] == login {
 			copy(db.Storage[i:], db.Storage[i+1:])
 			db.Storage[len(db.Storage)-1] = types.Event{}
 			db.Storage = db.Storage[:len(db.Storage)-1]
+			delete(db.Owners, id)
+			delete(db.Exceptions, id)
+			delete(db.Attendees, id)
+
+			var alerts []types.Alert
+			for _, a := range db.Alerts {
+				if a.EventID != id {
+					alerts = append(alerts, a)
+				}
+			}
+			db.Alerts = alerts
+
 			return nil
 		}
 	}
-	return errors.New("event with specified id not found")
+	return customErrors.ErrNotFound
 }
 
-func (db *Database) UpdateEvent(ctx context.Context, e types.Event, id int64) error {
+// UpdateEvent sets the fields given in the update and keeps the other ones, like Postgres does
+func (db *Database) UpdateEvent(ctx context.Context, u types.EventUpdate, id int64, login string) error {
+	e := u.Event
+	if err := checkColumns(e.Name, e.Description); err != nil {
+		return err
+	}
+
 	for i, event := range db.Storage {
-		if event.ID == id {
-			db.Storage[i].Name = e.Name
-			db.Storage[i].StartTime = e.StartTime
-			db.Storage[i].EndTime = e.EndTime
-			db.Storage[i].Description = e.Description
-			db.Storage[i].AlertTime = e.AlertTime
+		if event.ID == id && db.Owners[event.ID] == login {
+			if e.Name != "" {
+				db.Storage[i].Name = e.Name
+			}
+
+			if !e.StartTime.IsZero() {
+				db.Storage[i].StartTime = e.StartTime
+			}
+
+			if !e.EndTime.IsZero() {
+				db.Storage[i].EndTime = e.EndTime
+			}
+
+			if u.AllDay != nil {
+				db.Storage[i].AllDay = *u.AllDay
+			}
+
+			if e.Description != "" {
+				db.Storage[i].Description = e.Description
+			}
+
+			if !e.AlertTime.IsZero() {
+				db.Storage[i].AlertTime = e.AlertTime
+			}
+
+			if e.Recurrence != "" {
+				db.Storage[i].Recurrence = e.Recurrence
+			}
+
+			if e.Timezone != "" {
+				db.Storage[i].Timezone = e.Timezone
+			}
+
+			if e.CalendarID != 0 {
+				db.Storage[i].CalendarID = e.CalendarID
+			}
+
 			return nil
 		}
 	}
-	return errors.New("event with specified id not found")
+	return customErrors.ErrNotFound
 }
 
-func (db *Database) GetEventsFiltered(ctx context.Context, f types.Filters) ([]types.Event, error) {
+// GetEventsFiltered returns events starting on the day, month and year in the given location, all-day events
+// starting on the date. Multi-day events are returned regardless of the days they span, like recurring ones.
+func (db *Database) GetEventsFiltered(ctx context.Context, f types.Filters, loc *time.Location,
+	login string) ([]types.Event, error) {
 	var filtered []types.Event
-	isDay := true
-	isMonth := true
-	isYear := true
 
 	for _, event := range db.Storage {
-		if f.Day != 0 {
-			if event.StartTime.Day() != f.Day {
-				isDay = false
+		if db.Owners[event.ID] != login {
+			continue
+		}
+
+		// Recurring events are returned regardless of their first occurrence
+		if event.Recurrence != "" {
+			filtered = append(filtered, event)
+			continue
+		}
+
+		t, end := event.StartTime.In(loc), event.EndTime.In(loc)
+		if event.AllDay {
+			t, end = event.StartTime, event.EndTime.AddDate(0, 0, -1)
+		}
+
+		if multiDay(t, end) {
+			filtered = append(filtered, event)
+			continue
+		}
+
+		if (f.Day == 0 || t.Day() == f.Day) && (f.Month == 0 || int(t.Month()) == f.Month) &&
+			(f.Year == 0 || t.Year() == f.Year) {
+			filtered = append(filtered, event)
+		}
+	}
+
+	return filtered, nil
+}
+
+// GetEventsInRange returns single events overlapping the [from, to) window together with every recurring event
+// which starts before the end of the window. All-day events are matched with a margin of a day like in Postgres.
+func (db *Database) GetEventsInRange(ctx context.Context, from, to time.Time, login string) ([]types.Event, error) {
+	var s []types.Event
+
+	for _, event := range db.Storage {
+		if db.Owners[event.ID] == login && inRange(event, from, to) {
+			s = append(s, event)
+		}
+	}
+
+	sort.SliceStable(s, func(i, j int) bool { return s[i].StartTime.Before(s[j].StartTime) })
+
+	return s, nil
+}
+
+func (db *Database) GetEventsPage(ctx context.Context, q types.PageQuery, login string) ([]types.Event, error) {
+	events, err := db.GetEvents(ctx, login)
+	if err != nil {
+		return events, err
+	}
+
+	var s []types.Event
+	for _, e := range events {
+		if q.CalendarID != 0 && e.CalendarID != q.CalendarID {
+			continue
+		}
+
+		if (len(q.CalendarIDs) > 0 || len(q.EventIDs) > 0) &&
+			!contains(q.CalendarIDs, e.CalendarID) && !contains(q.EventIDs, e.ID) {
+			continue
+		}
+
+		s = append(s, e)
+	}
+
+	return pagination.Select(s, q), nil
+}
+
+func (db *Database) GetEventExceptions(ctx context.Context, ids []int64, login string) ([]types.EventException, error) {
+	var s []types.EventException
+
+	for _, id := range ids {
+		if db.Owners[id] == login {
+			s = append(s, db.Exceptions[id]...)
+		}
+	}
+
+	return s, nil
+}
+
+func (db *Database) SaveEventException(ctx context.Context, ex types.EventException, login string) error {
+	if _, err := db.GetEvent(ctx, ex.EventID, login); err != nil {
+		return err
+	}
+
+	if err := checkColumns(ex.Name, ex.Description); err != nil {
+		return err
+	}
+
+	for i, stored := range db.Exceptions[ex.EventID] {
+		if stored.OriginalStart.Equal(ex.OriginalStart) {
+			db.Exceptions[ex.EventID][i] = ex
+			return nil
+		}
+	}
+
+	db.Exceptions[ex.EventID] = append(db.Exceptions[ex.EventID], ex)
+
+	return nil
+}
+
+func (db *Database) SplitEvent(ctx context.Context, id int64, from time.Time, recurrence string, login string) error {
+	if _, err := db.GetEvent(ctx, id, login); err != nil {
+		return err
+	}
+
+	if recurrence == "" {
+		_ = db.DeleteEvent(ctx, id, login)
+	} else {
+		for i := range db.Storage {
+			if db.Storage[i].ID == id {
+				db.Storage[i].Recurrence = recurrence
 			}
 		}
 
-		if f.Month != 0 {
-			if event.StartTime.Day() != f.Month {
-				isMonth = false
+		var kept []types.EventException
+		for _, ex := range db.Exceptions[id] {
+			if ex.OriginalStart.Before(from) {
+				kept = append(kept, ex)
 			}
 		}
+		db.Exceptions[id] = kept
+	}
 
-		if f.Year != 0 {
-			if event.StartTime.Day() != f.Year {
-				isYear = false
+	return nil
+}
+
+// InTransaction runs fn right away, the memory storage does not roll back changes
+func (db *Database) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (db *Database) AddOutboxMessage(ctx context.Context, m types.OutboxMessage, login string) error {
+	db.Outbox[login] = append(db.Outbox[login], m)
+
+	return nil
+}
+
+func (db *Database) GetAlertingEvents(ctx context.Context, from, to time.Time) ([]types.AlertingEvent, error) {
+	var s []types.AlertingEvent
+
+	for _, event := range db.Storage {
+		if event.AlertTime.IsZero() || !event.AlertTime.Before(to) {
+			continue
+		}
+
+		if event.Recurrence == "" && event.AlertTime.Before(from) {
+			continue
+		}
+
+		if event.Recurrence != "" && db.seriesEnded(event, from) {
+			continue
+		}
+
+		login := db.Owners[event.ID]
+		timezone, ok := db.Timezones[login]
+		if !ok {
+			timezone = "UTC"
+		}
+
+		s = append(s, types.AlertingEvent{Event: event, Login: login, Timezone: timezone,
+			Exceptions: db.Exceptions[event.ID]})
+	}
+
+	return s, nil
+}
+
+// seriesEnded reports whether the last occurrence of the series alerts before from, with a day of margin
+// for the timezone it is expanded in, and none of its exceptions alerts from then on
+func (db *Database) seriesEnded(e types.Event, from time.Time) bool {
+	for _, ex := range db.Exceptions[e.ID] {
+		if !ex.Cancelled && !ex.AlertTime.Before(from) {
+			return false
+		}
+	}
+
+	rule, err := recurrence.Parse(e.Recurrence)
+	if err != nil {
+		return false
+	}
+
+	end, ok := rule.End(e.StartTime.UTC(), e.EndTime.Sub(e.StartTime))
+	if !ok {
+		return false
+	}
+
+	return end.AddDate(0, 0, 1).Add(e.AlertTime.Sub(e.StartTime)).Before(from)
+}
+
+func (db *Database) ScheduleAlerts(ctx context.Context, alerts []types.Alert) error {
+	for _, a := range alerts {
+		if db.alertScheduled(a) {
+			continue
+		}
+
+		db.AlertID += 1
+		a.ID = db.AlertID
+		a.Status = types.AlertPending
+		a.NextAttempt = a.AlertTime
+		db.Alerts = append(db.Alerts, a)
+	}
+
+	return nil
+}
+
+func (db *Database) ClaimAlerts(ctx context.Context, now, lockedUntil time.Time, limit int) ([]types.Alert, error) {
+	var s []types.Alert
+
+	sort.SliceStable(db.Alerts, func(i, j int) bool { return db.Alerts[i].NextAttempt.Before(db.Alerts[j].NextAttempt) })
+
+	for i, a := range db.Alerts {
+		if len(s) == limit {
+			break
+		}
+
+		if a.Status != types.AlertPending || a.NextAttempt.After(now) || db.AlertLocks[a.ID].After(now) {
+			continue
+		}
+
+		db.Alerts[i].Attempts++
+		db.AlertLocks[a.ID] = lockedUntil
+
+		a = db.Alerts[i]
+		a.Login = db.Owners[a.EventID]
+		a.Timezone = db.Timezones[a.Login]
+		if a.Timezone == "" {
+			a.Timezone = "UTC"
+		}
+
+		s = append(s, a)
+	}
+
+	return s, nil
+}
+
+func (db *Database) UpdateAlert(ctx context.Context, a types.Alert) error {
+	for i, stored := range db.Alerts {
+		if stored.ID == a.ID {
+			db.Alerts[i].Status = a.Status
+			db.Alerts[i].LastError = a.LastError
+			db.Alerts[i].NextAttempt = a.NextAttempt
+			db.Alerts[i].DeliveredAt = a.DeliveredAt
+			delete(db.AlertLocks, a.ID)
+			return nil
+		}
+	}
+
+	return customErrors.ErrNotFound
+}
+
+func (db *Database) alertScheduled(a types.Alert) bool {
+	for _, stored := range db.Alerts {
+		if stored.EventID == a.EventID && stored.OccurrenceStart.Equal(a.OccurrenceStart) &&
+			stored.AlertTime.Equal(a.AlertTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// multiDay tells whether the times are on different days, they are compared in the location of the first one
+func multiDay(start, end time.Time) bool {
+	y1, m1, d1 := start.Date()
+	y2, m2, d2 := end.In(start.Location()).Date()
+
+	return y1 != y2 || m1 != m2 || d1 != d2
+}
+
+func (db *Database) GetCalendars(ctx context.Context, login string) ([]types.Calendar, error) {
+	var s []types.Calendar
+
+	for _, c := range db.Calendars {
+		c, ok := db.visibleCalendar(c, login)
+		if ok {
+			s = append(s, c)
+		}
+	}
+
+	return s, nil
+}
+
+func (db *Database) GetCalendar(ctx context.Context, id int64, login string) (types.Calendar, error) {
+	for _, c := range db.Calendars {
+		if c.ID != id {
+			continue
+		}
+
+		c, ok := db.visibleCalendar(c, login)
+		if ok {
+			return c, nil
+		}
+	}
+	return types.Calendar{}, customErrors.ErrNotFound
+}
+
+func (db *Database) GetEventCalendar(ctx context.Context, id int64, login string) (types.Calendar, error) {
+	for _, e := range db.Storage {
+		if e.ID == id {
+			return db.GetCalendar(ctx, e.CalendarID, login)
+		}
+	}
+	return types.Calendar{}, customErrors.ErrNotFound
+}
+
+// visibleCalendar returns the calendar with the level it is shared with the user at, if the user can see it
+func (db *Database) visibleCalendar(c types.Calendar, login string) (types.Calendar, bool) {
+	if db.CalendarOwners[c.ID] == login {
+		return c, true
+	}
+
+	for _, s := range db.Shares[c.ID] {
+		if s.Login == login {
+			c.Access = s.Level
+			return c, true
+		}
+	}
+
+	return c, false
+}
+
+func (db *Database) GetDefaultCalendar(ctx context.Context, login string) (types.Calendar, error) {
+	for _, c := range db.Calendars {
+		if c.Default && db.CalendarOwners[c.ID] == login {
+			return c, nil
+		}
+	}
+
+	c := types.Calendar{Name: types.DefaultCalendarName, Visibility: types.VisibilityFreeBusy, Default: true,
+		Owner: login}
+
+	var err error
+	c.ID, err = db.AddCalendar(ctx, c, login)
+	return c, err
+}
+
+func (db *Database) AddCalendar(ctx context.Context, c types.Calendar, login string) (int64, error) {
+	if len([]rune(c.Name)) > 255 {
+		return 0, fmt.Errorf("%w: value too long for its column", customErrors.ErrBadRequest)
+	}
+
+	db.CalendarID += 1
+	c.ID = db.CalendarID
+	c.Owner, c.Access = login, ""
+	db.Calendars = append(db.Calendars, c)
+	db.CalendarOwners[c.ID] = login
+
+	return c.ID, nil
+}
+
+// UpdateCalendar sets the fields given in the update and keeps the other ones, like Postgres does
+func (db *Database) UpdateCalendar(ctx context.Context, c types.Calendar, id int64, login string) error {
+	if len([]rune(c.Name)) > 255 {
+		return fmt.Errorf("%w: value too long for its column", customErrors.ErrBadRequest)
+	}
+
+	for i, stored := range db.Calendars {
+		if stored.ID == id && db.CalendarOwners[stored.ID] == login {
+			if c.Name != "" {
+				db.Calendars[i].Name = c.Name
 			}
+
+			if c.Color != "" {
+				db.Calendars[i].Color = c.Color
+			}
+
+			if c.Timezone != "" {
+				db.Calendars[i].Timezone = c.Timezone
+			}
+
+			if c.Visibility != "" {
+				db.Calendars[i].Visibility = c.Visibility
+			}
+
+			return nil
 		}
+	}
+	return customErrors.ErrNotFound
+}
 
-		if isDay && isMonth && isYear {
-			filtered = append(filtered, event)
+func (db *Database) DeleteCalendar(ctx context.Context, id, moveTo int64, login string) error {
+	if db.CalendarOwners[id] != login || (moveTo != 0 && db.CalendarOwners[moveTo] != login) {
+		return customErrors.ErrNotFound
+	}
+
+	var ids []int64
+	for i, e := range db.Storage {
+		if e.CalendarID != id {
+			continue
+		}
+
+		if moveTo != 0 {
+			db.Storage[i].CalendarID = moveTo
+		} else {
+			ids = append(ids, e.ID)
 		}
 	}
 
-	return filtered, nil
+	for _, eventID := range ids {
+		_ = db.DeleteEvent(ctx, eventID, login)
+	}
+
+	for i, c := range db.Calendars {
+		if c.ID == id {
+			db.Calendars = append(db.Calendars[:i], db.Calendars[i+1:]...)
+			delete(db.CalendarOwners, id)
+			delete(db.Shares, id)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (db *Database) GetAttendees(ctx context.Context, ids []int64, login string) (map[int64][]types.Attendee,
+	error) {
+	attendees := make(map[int64][]types.Attendee)
+
+	for _, id := range ids {
+		if db.Owners[id] == login && len(db.Attendees[id]) > 0 {
+			attendees[id] = append([]types.Attendee(nil), db.Attendees[id]...)
+		}
+	}
+
+	return attendees, nil
+}
+
+// SetAttendees accepts any invited login, as users are not known to the memory storage
+func (db *Database) SetAttendees(ctx context.Context, id int64, attendees []types.Attendee, login string) error {
+	if db.Owners[id] != login {
+		return customErrors.ErrNotFound
+	}
+
+	if len(attendees) == 0 {
+		delete(db.Attendees, id)
+		return nil
+	}
+
+	db.Attendees[id] = append([]types.Attendee(nil), attendees...)
+	return nil
+}
+
+func (db *Database) GetInvitations(ctx context.Context, login string) ([]types.Invitation, error) {
+	var s []types.Invitation
+
+	for _, e := range db.Storage {
+		for _, a := range db.Attendees[e.ID] {
+			if a.Login == login {
+				e.Organizer = db.Owners[e.ID]
+				s = append(s, types.Invitation{Event: e, Status: a.Status})
+			}
+		}
+	}
+
+	sort.SliceStable(s, func(i, j int) bool {
+		if !s[i].Event.StartTime.Equal(s[j].Event.StartTime) {
+			return s[i].Event.StartTime.Before(s[j].Event.StartTime)
+		}
+		return s[i].Event.ID < s[j].Event.ID
+	})
+
+	return s, nil
+}
+
+func (db *Database) GetInvitation(ctx context.Context, id int64, login string) (types.Invitation, error) {
+	invitations, _ := db.GetInvitations(ctx, login)
+	for _, i := range invitations {
+		if i.Event.ID == id {
+			return i, nil
+		}
+	}
+
+	return types.Invitation{}, customErrors.ErrNotFound
+}
+
+func (db *Database) SetRSVP(ctx context.Context, id int64, status types.RSVPStatus, login string) error {
+	for i, a := range db.Attendees[id] {
+		if a.Login == login {
+			db.Attendees[id][i].Status = status
+			return nil
+		}
+	}
+
+	return customErrors.ErrNotFound
+}
+
+func (db *Database) GetBusyEvents(ctx context.Context, logins []string, from, to time.Time,
+	login string) ([]types.BusyEvent, error) {
+	var s []types.BusyEvent
+
+	for _, busy := range logins {
+		for _, event := range db.Storage {
+			if !inRange(event, from, to) {
+				continue
+			}
+
+			owner := db.Owners[event.ID]
+			if owner == busy && busy != login && !db.showsBusyTime(event.CalendarID, login) {
+				continue
+			}
+
+			if owner != busy && !db.accepted(event.ID, busy) {
+				continue
+			}
+
+			timezone, ok := db.Timezones[owner]
+			if !ok {
+				timezone = "UTC"
+			}
+
+			s = append(s, types.BusyEvent{Event: event, Login: busy, Timezone: timezone,
+				Exceptions: append([]types.EventException(nil), db.Exceptions[event.ID]...)})
+		}
+	}
+
+	return s, nil
+}
+
+// GetTimezones treats every login as a user, as users are not known to the memory storage
+func (db *Database) GetTimezones(ctx context.Context, logins []string) (map[string]string, error) {
+	timezones := make(map[string]string)
+
+	for _, l := range logins {
+		timezone, ok := db.Timezones[l]
+		if !ok {
+			timezone = "UTC"
+		}
+
+		timezones[l] = timezone
+	}
+
+	return timezones, nil
+}
+
+// showsBusyTime tells whether the user sees when events of the calendar take place
+func (db *Database) showsBusyTime(calendarID int64, login string) bool {
+	for _, c := range db.Calendars {
+		if c.ID != calendarID {
+			continue
+		}
+
+		_, shared := db.visibleCalendar(c, login)
+		return shared || c.Visibility == types.VisibilityFreeBusy
+	}
+
+	return false
+}
+
+func (db *Database) accepted(id int64, login string) bool {
+	for _, a := range db.Attendees[id] {
+		if a.Login == login && a.Status == types.RSVPAccepted {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (db *Database) GetShares(ctx context.Context, calendarID int64, login string) ([]types.Share, error) {
+	if db.CalendarOwners[calendarID] != login {
+		return nil, customErrors.ErrNotFound
+	}
+
+	return append([]types.Share(nil), db.Shares[calendarID]...), nil
+}
+
+// SetShare accepts any grantee, as users are not known to the memory storage
+func (db *Database) SetShare(ctx context.Context, calendarID int64, s types.Share, login string) error {
+	if db.CalendarOwners[calendarID] != login {
+		return customErrors.ErrNotFound
+	}
+
+	for i, share := range db.Shares[calendarID] {
+		if share.Login == s.Login {
+			db.Shares[calendarID][i].Level = s.Level
+			return nil
+		}
+	}
+
+	db.Shares[calendarID] = append(db.Shares[calendarID], s)
+	return nil
+}
+
+func (db *Database) DeleteShare(ctx context.Context, calendarID int64, grantee, login string) error {
+	if db.CalendarOwners[calendarID] != login {
+		return customErrors.ErrNotFound
+	}
+
+	shares := db.Shares[calendarID]
+	for i, share := range shares {
+		if share.Login == grantee {
+			db.Shares[calendarID] = append(shares[:i:i], shares[i+1:]...)
+			return nil
+		}
+	}
+	return customErrors.ErrNotFound
+}
+
+// checkColumns rejects names and descriptions longer than the columns Postgres stores them in
+func checkColumns(name, description string) error {
+	if len([]rune(name)) > 255 || len([]rune(description)) > 510 {
+		return fmt.Errorf("%w: value too long for its column", customErrors.ErrBadRequest)
+	}
+
+	return nil
+}
+
+// inRange tells whether the event may overlap the window, all-day events are matched with a margin like Postgres
+// does, as their days depend on the timezone they are viewed in
+func inRange(event types.Event, from, to time.Time) bool {
+	fromDate, toDate := from.UTC().AddDate(0, 0, -1), to.UTC().AddDate(0, 0, 2)
+
+	switch {
+	case event.AllDay && event.Recurrence != "":
+		return event.StartTime.Before(toDate)
+	case event.Recurrence != "":
+		return event.StartTime.Before(to)
+	case event.AllDay:
+		return event.StartTime.Before(toDate) && event.EndTime.After(fromDate)
+	default:
+		return recurrence.Overlaps(event.StartTime, event.EndTime, from, to)
+	}
+}
+
+func contains(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
 }
@@ -4,14 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/types"
 )
 
 var ctx context.Context
 
+const login = "hello"
+
 func TestAppendEvent(t *testing.T) {
 	db := InitDatabase()
 	ti := time.Date(2022, 9, 16, 20, 30, 0, 0, time.Local)
@@ -33,10 +38,10 @@ func TestAppendEvent(t *testing.T) {
 		Description: "A Weekly meeting for frontend team",
 		AlertTime:   ti,
 	}
-	_ = db.AddEvent(ctx, event)
-	_ = db.AddEvent(ctx, event2)
+	_, _ = db.AddEvent(ctx, event, login)
+	_, _ = db.AddEvent(ctx, event2, login)
 
-	e, _ := db.GetEvents(ctx)
+	e, _ := db.GetEvents(ctx, login)
 
 	if len(e) < 2 {
 		t.Error("Failed to add an event")
@@ -53,7 +58,7 @@ func TestDeleteEvent(t *testing.T) {
 	}{
 		{1, 1, nil},
 		{2, 1, nil},
-		{8, 2, errors.New("event with specified id not found")},
+		{8, 2, customErrors.ErrNotFound},
 	}
 	for _, tc := range testCases {
 		testName := fmt.Sprintf("Delete id %d", tc.id)
@@ -69,12 +74,12 @@ func TestDeleteEvent(t *testing.T) {
 				AlertTime:   ti,
 			}
 
-			_ = db.AddEvent(ctx, event)
-			_ = db.AddEvent(ctx, event)
+			_, _ = db.AddEvent(ctx, event, login)
+			_, _ = db.AddEvent(ctx, event, login)
 
-			err := db.DeleteEvent(ctx, tc.id)
+			err := db.DeleteEvent(ctx, tc.id, login)
 
-			e, _ := db.GetEvents(ctx)
+			e, _ := db.GetEvents(ctx, login)
 			if len(e) != tc.expLength {
 				t.Errorf("Failed to delete an event: got length: %v, expected: %v", len(e), tc.expLength)
 			}
@@ -97,7 +102,7 @@ func TestUpdateEvent(t *testing.T) {
 		expError error
 	}{
 		{1, "Updated event", nil},
-		{8, "", errors.New("event with specified id not found")},
+		{8, "", customErrors.ErrNotFound},
 	}
 	for _, tc := range testCases {
 		testName := fmt.Sprintf("Delete id %d", tc.id)
@@ -122,17 +127,17 @@ func TestUpdateEvent(t *testing.T) {
 				AlertTime:   ti,
 			}
 
-			_ = db.AddEvent(ctx, event)
-			_ = db.AddEvent(ctx, event)
+			_, _ = db.AddEvent(ctx, event, login)
+			_, _ = db.AddEvent(ctx, event, login)
 
-			err := db.UpdateEvent(ctx, uEvent, tc.id)
+			err := db.UpdateEvent(ctx, types.EventUpdate{Event: uEvent}, tc.id, login)
 			if err != nil {
 				if err.Error() != tc.expError.Error() {
 					t.Errorf("Should return different error: got: %v, expected: %v", err, tc.expError)
 				}
 			}
 
-			e, _ := db.GetEvent(ctx, tc.id)
+			e, _ := db.GetEvent(ctx, tc.id, login)
 			if e.Name != tc.expName {
 				t.Errorf("Failed to update an event: got name: %v, expected: %v", e.Name, tc.expName)
 			}
@@ -140,3 +145,465 @@ func TestUpdateEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestPartialUpdate(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2022, 9, 16, 20, 30, 0, 0, time.UTC)
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Daily meeting", StartTime: ti, EndTime: ti.Add(time.Hour),
+		Description: "A daily meeting", AlertTime: ti.Add(-time.Minute)}, login)
+
+	_ = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Description: "A weekly meeting"}}, 1, login)
+
+	e, _ := db.GetEvent(ctx, 1, login)
+	if e.Name != "Daily meeting" || !e.EndTime.Equal(ti.Add(time.Hour)) || e.Description != "A weekly meeting" ||
+		!e.AlertTime.Equal(ti.Add(-time.Minute)) {
+		t.Errorf("Failed to keep fields missing from the update: got: %v", e)
+	}
+
+	err := db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Description: strings.Repeat("a", 511)}}, 1, login)
+	if !errors.Is(err, customErrors.ErrBadRequest) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrBadRequest)
+	}
+
+	_, err = db.AddEvent(ctx, types.Event{Name: strings.Repeat("a", 256), StartTime: ti, EndTime: ti}, login)
+	if !errors.Is(err, customErrors.ErrBadRequest) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrBadRequest)
+	}
+}
+
+func TestAllDayUpdate(t *testing.T) {
+	db := InitDatabase()
+	d := time.Date(2022, 9, 16, 0, 0, 0, 0, time.UTC)
+	timed := false
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Vacation", StartTime: d, EndTime: d.AddDate(0, 0, 5), AllDay: true},
+		login)
+
+	// Updates without the flag keep the event all-day
+	_ = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Name: "Holidays"}}, 1, login)
+
+	e, _ := db.GetEvent(ctx, 1, login)
+	if !e.AllDay {
+		t.Errorf("Failed to keep the event all-day: got: %v", e)
+	}
+
+	err := db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{StartTime: d.Add(9 * time.Hour),
+		EndTime: d.Add(17 * time.Hour)}, AllDay: &timed}, 1, login)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e, _ = db.GetEvent(ctx, 1, login)
+	if e.AllDay || !e.StartTime.Equal(d.Add(9*time.Hour)) || !e.EndTime.Equal(d.Add(17*time.Hour)) {
+		t.Errorf("Failed to turn the all-day event into a timed one: got: %v", e)
+	}
+}
+
+func TestEventOwnership(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2022, 9, 16, 20, 30, 0, 0, time.Local)
+
+	event := types.Event{
+		Name:      "Daily meeting",
+		StartTime: ti,
+		EndTime:   ti,
+	}
+
+	_, _ = db.AddEvent(ctx, event, "owner")
+	_, _ = db.AddEvent(ctx, event, "other")
+
+	e, _ := db.GetEvents(ctx, "owner")
+	if len(e) != 1 || e[0].ID != 1 {
+		t.Errorf("Failed to scope events to their owner: got: %v", e)
+	}
+
+	e, _ = db.GetEventsFiltered(ctx, types.Filters{}, time.UTC, "other")
+	if len(e) != 1 || e[0].ID != 2 {
+		t.Errorf("Failed to scope filtered events to their owner: got: %v", e)
+	}
+
+	if _, err := db.GetEvent(ctx, 1, "other"); err != customErrors.ErrNotFound {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	if err := db.UpdateEvent(ctx, types.EventUpdate{Event: event}, 1, "other"); err != customErrors.ErrNotFound {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	if err := db.DeleteEvent(ctx, 1, "other"); err != customErrors.ErrNotFound {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	if err := db.DeleteEvent(ctx, 1, "owner"); err != nil {
+		t.Errorf("Failed to delete own event: %v", err)
+	}
+}
+
+func TestGetEventsInRange(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2022, 9, 16, 20, 30, 0, 0, time.UTC)
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Before", StartTime: ti.AddDate(0, 0, -7), EndTime: ti.AddDate(0, 0, -7)}, login)
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Inside", StartTime: ti, EndTime: ti.Add(time.Hour)}, login)
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Series", StartTime: ti.AddDate(0, -1, 0), EndTime: ti.AddDate(0, -1, 0),
+		Recurrence: "FREQ=DAILY"}, login)
+
+	e, _ := db.GetEventsInRange(ctx, ti.Add(-time.Hour), ti.Add(2*time.Hour), login)
+	if len(e) != 2 || e[0].Name != "Series" || e[1].Name != "Inside" {
+		t.Errorf("Failed to fetch events in range: got: %v", e)
+	}
+}
+
+func TestEventExceptions(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2022, 9, 16, 20, 30, 0, 0, time.UTC)
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Series", StartTime: ti, EndTime: ti, Recurrence: "FREQ=DAILY"}, login)
+
+	ex := types.EventException{EventID: 1, OriginalStart: ti.AddDate(0, 0, 1), Cancelled: true}
+	if err := db.SaveEventException(ctx, ex, "other"); err != customErrors.ErrNotFound {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	_ = db.SaveEventException(ctx, ex, login)
+	ex.Cancelled = false
+	_ = db.SaveEventException(ctx, ex, login)
+	_ = db.SaveEventException(ctx, types.EventException{EventID: 1, OriginalStart: ti.AddDate(0, 0, 3)}, login)
+
+	e, _ := db.GetEventExceptions(ctx, []int64{1}, login)
+	if len(e) != 2 || e[0].Cancelled {
+		t.Errorf("Failed to replace an exception of the same occurrence: got: %v", e)
+	}
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Following", StartTime: ti.AddDate(0, 0, 2), EndTime: ti.AddDate(0, 0, 2)},
+		login)
+	_ = db.SplitEvent(ctx, 1, ti.AddDate(0, 0, 2), "FREQ=DAILY;COUNT=2", login)
+
+	e, _ = db.GetEventExceptions(ctx, []int64{1}, login)
+	if len(e) != 1 || !e[0].OriginalStart.Equal(ti.AddDate(0, 0, 1)) {
+		t.Errorf("Failed to drop exceptions of the following occurrences: got: %v", e)
+	}
+
+	events, _ := db.GetEvents(ctx, login)
+	if len(events) != 2 || events[0].Recurrence != "FREQ=DAILY;COUNT=2" || events[1].Name != "Following" {
+		t.Errorf("Failed to split the series: got: %v", events)
+	}
+
+	_ = db.SplitEvent(ctx, 2, ti.AddDate(0, 0, 2), "", login)
+
+	events, _ = db.GetEvents(ctx, login)
+	if len(events) != 1 {
+		t.Errorf("Failed to remove the series split at its first occurrence: got: %v", events)
+	}
+}
+
+func TestGetEventsFiltered(t *testing.T) {
+	db := InitDatabase()
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	// 20:30 UTC on 31 December is already 1 January in Tokyo
+	ti := time.Date(2022, 12, 31, 20, 30, 0, 0, time.UTC)
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "New Year", StartTime: ti, EndTime: ti}, login)
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Other day", StartTime: ti.AddDate(0, 0, -2), EndTime: ti.AddDate(0, 0, -2)}, login)
+
+	e, _ := db.GetEventsFiltered(ctx, types.Filters{Day: 31, Month: 12, Year: 2022}, time.UTC, login)
+	if len(e) != 1 || e[0].Name != "New Year" {
+		t.Errorf("Failed to filter events in UTC: got: %v", e)
+	}
+
+	e, _ = db.GetEventsFiltered(ctx, types.Filters{Day: 1, Month: 1, Year: 2023}, tokyo, login)
+	if len(e) != 1 || e[0].Name != "New Year" {
+		t.Errorf("Failed to filter events in the location: got: %v", e)
+	}
+
+	e, _ = db.GetEventsFiltered(ctx, types.Filters{Month: 12}, tokyo, login)
+	if len(e) != 1 || e[0].Name != "Other day" {
+		t.Errorf("Failed to filter events by month: got: %v", e)
+	}
+
+	e, _ = db.GetEventsFiltered(ctx, types.Filters{Year: 2022}, time.UTC, login)
+	if len(e) != 2 {
+		t.Errorf("Failed to filter events by year: got: %v", e)
+	}
+}
+
+func TestAllDayEvents(t *testing.T) {
+	db := InitDatabase()
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	d := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Holiday", StartTime: d, EndTime: d.AddDate(0, 0, 1), AllDay: true}, login)
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Vacation", StartTime: d.AddDate(0, 0, 7), EndTime: d.AddDate(0, 0, 12),
+		AllDay: true}, login)
+
+	// Dates of all-day events are not shifted to the location
+	e, _ := db.GetEventsFiltered(ctx, types.Filters{Day: 1, Month: 5, Year: 2023}, tokyo, login)
+	if len(e) != 2 || e[0].Name != "Holiday" || e[1].Name != "Vacation" {
+		t.Errorf("Failed to filter all-day and multi-day events: got: %v", e)
+	}
+
+	// The window is matched with a margin of a day as the dates depend on the timezone of the user
+	e, _ = db.GetEventsInRange(ctx, d.Add(-12*time.Hour), d.Add(-time.Hour), login)
+	if len(e) != 1 || e[0].Name != "Holiday" {
+		t.Errorf("Failed to fetch all-day events in range: got: %v", e)
+	}
+
+	e, _ = db.GetEventsInRange(ctx, d.AddDate(0, 0, 14), d.AddDate(0, 0, 15), login)
+	if len(e) != 0 {
+		t.Errorf("Failed to skip all-day events out of range: got: %v", e)
+	}
+}
+
+func TestGetEventsPage(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, name := range []string{"Lunch", "Breakfast", "Dinner"} {
+		_, _ = db.AddEvent(ctx, types.Event{Name: name, StartTime: ti.AddDate(0, 0, -i), EndTime: ti.AddDate(0, 0, -i)}, login)
+	}
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Someone else's", StartTime: ti, EndTime: ti}, "other")
+
+	e, _ := db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortStartTime, Limit: 2}, login)
+	if len(e) != 2 || e[0].Name != "Dinner" || e[1].Name != "Breakfast" {
+		t.Errorf("Failed to get the first page: got: %v", e)
+	}
+
+	key := types.PageKey{Name: e[1].Name, StartTime: e[1].StartTime, ID: e[1].ID}
+	e, _ = db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortStartTime, After: &key, Limit: 2}, login)
+	if len(e) != 1 || e[0].Name != "Lunch" {
+		t.Errorf("Failed to get the page after the key: got: %v", e)
+	}
+
+	e, _ = db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortName, Desc: true, Limit: 10}, login)
+	if len(e) != 3 || e[0].Name != "Lunch" || e[2].Name != "Breakfast" {
+		t.Errorf("Failed to sort events by name: got: %v", e)
+	}
+}
+
+func TestCalendars(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	def, _ := db.GetDefaultCalendar(ctx, login)
+	again, _ := db.GetDefaultCalendar(ctx, login)
+	if def.ID != again.ID || !def.Default || def.Name != types.DefaultCalendarName {
+		t.Errorf("Failed to get the default calendar: got: %v and %v", def, again)
+	}
+
+	work, _ := db.AddCalendar(ctx, types.Calendar{Name: "Work", Visibility: types.VisibilityPrivate}, login)
+	_, _ = db.AddCalendar(ctx, types.Calendar{Name: "Someone else's"}, "other")
+
+	c, _ := db.GetCalendars(ctx, login)
+	if len(c) != 2 || c[1].Name != "Work" {
+		t.Errorf("Failed to get calendars of the user: got: %v", c)
+	}
+
+	_, err := db.GetCalendar(ctx, 3, login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	_ = db.UpdateCalendar(ctx, types.Calendar{Color: "#FF0000"}, work, login)
+	w, _ := db.GetCalendar(ctx, work, login)
+	if w.Name != "Work" || w.Color != "#FF0000" || w.Visibility != types.VisibilityPrivate {
+		t.Errorf("Failed to keep fields missing from the update: got: %v", w)
+	}
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Personal", StartTime: ti, EndTime: ti}, login)
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Stand-up", StartTime: ti, EndTime: ti, CalendarID: work}, login)
+
+	e, _ := db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortStartTime, Limit: 10, CalendarID: work}, login)
+	if len(e) != 1 || e[0].Name != "Stand-up" {
+		t.Errorf("Failed to get events of the calendar: got: %v", e)
+	}
+
+	err = db.DeleteCalendar(ctx, work, def.ID, login)
+	if err != nil {
+		t.Errorf("Failed to delete the calendar: %v", err)
+	}
+
+	e, _ = db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortStartTime, Limit: 10, CalendarID: def.ID}, login)
+	if len(e) != 2 {
+		t.Errorf("Failed to move events to the default calendar: got: %v", e)
+	}
+
+	trash, _ := db.AddCalendar(ctx, types.Calendar{Name: "Trash"}, login)
+	_ = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{CalendarID: trash}}, 2, login)
+
+	_ = db.DeleteCalendar(ctx, trash, 0, login)
+	e, _ = db.GetEvents(ctx, login)
+	if len(e) != 1 || e[0].Name != "Personal" {
+		t.Errorf("Failed to delete events together with the calendar: got: %v", e)
+	}
+}
+
+func TestShares(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	team, _ := db.AddCalendar(ctx, types.Calendar{Name: "Team"}, login)
+	id, _ := db.AddEvent(ctx, types.Event{Name: "Planning", StartTime: ti, EndTime: ti, CalendarID: team}, login)
+
+	_, err := db.GetCalendar(ctx, team, "other")
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.SetShare(ctx, team, types.Share{Login: "other", Level: types.ShareRead}, "other")
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	_ = db.SetShare(ctx, team, types.Share{Login: "other", Level: types.ShareRead}, login)
+	_ = db.SetShare(ctx, team, types.Share{Login: "other", Level: types.ShareWrite}, login)
+
+	s, _ := db.GetShares(ctx, team, login)
+	if len(s) != 1 || s[0].Level != types.ShareWrite {
+		t.Errorf("Failed to change the level of the share: got: %v", s)
+	}
+
+	c, _ := db.GetCalendars(ctx, "other")
+	if len(c) != 1 || c[0].Owner != login || c[0].Access != types.ShareWrite {
+		t.Errorf("Failed to get calendars shared with the user: got: %v", c)
+	}
+
+	c0, _ := db.GetEventCalendar(ctx, id, "other")
+	if c0.ID != team || c0.Access != types.ShareWrite {
+		t.Errorf("Failed to get the calendar of a shared event: got: %v", c0)
+	}
+
+	err = db.DeleteShare(ctx, team, "other", login)
+	if err != nil {
+		t.Errorf("Failed to delete the share: %v", err)
+	}
+
+	_, err = db.GetEventCalendar(ctx, id, "other")
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.DeleteShare(ctx, team, "other", login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+}
+
+func TestAttendees(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	id, _ := db.AddEvent(ctx, types.Event{Name: "Planning", StartTime: ti, EndTime: ti}, login)
+
+	err := db.SetAttendees(ctx, id, []types.Attendee{{Login: "other"}}, "other")
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	attendees := []types.Attendee{{Login: "other", Status: types.RSVPNeedsAction},
+		{Email: "guest@example.com", Status: types.RSVPNeedsAction}}
+	_ = db.SetAttendees(ctx, id, attendees, login)
+
+	a, _ := db.GetAttendees(ctx, []int64{id}, login)
+	if !reflect.DeepEqual(a[id], attendees) {
+		t.Errorf("Failed to get attendees of the event: got: %v, expected: %v", a[id], attendees)
+	}
+
+	i, err := db.GetInvitation(ctx, id, "other")
+	if err != nil || i.Event.Organizer != login || i.Status != types.RSVPNeedsAction {
+		t.Errorf("Failed to get the invitation: got: %v, error: %v", i, err)
+	}
+
+	_ = db.SetRSVP(ctx, id, types.RSVPAccepted, "other")
+
+	s, _ := db.GetInvitations(ctx, "other")
+	if len(s) != 1 || s[0].Status != types.RSVPAccepted {
+		t.Errorf("Failed to answer the invitation: got: %v", s)
+	}
+
+	err = db.SetRSVP(ctx, id, types.RSVPAccepted, "nobody")
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	_ = db.DeleteEvent(ctx, id, login)
+
+	_, err = db.GetInvitation(ctx, id, "other")
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+}
+
+func TestGetBusyEvents(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	personal, _ := db.AddCalendar(ctx, types.Calendar{Name: "Personal", Visibility: types.VisibilityFreeBusy}, login)
+	private, _ := db.AddCalendar(ctx, types.Calendar{Name: "Private", Visibility: types.VisibilityPrivate}, login)
+
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Lunch", StartTime: ti, EndTime: ti.Add(time.Hour),
+		CalendarID: personal}, login)
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Doctor", StartTime: ti, EndTime: ti.Add(time.Hour),
+		CalendarID: private}, login)
+	_, _ = db.AddEvent(ctx, types.Event{Name: "Later", StartTime: ti.AddDate(0, 1, 0),
+		EndTime: ti.AddDate(0, 1, 0), CalendarID: personal}, login)
+	invitation, _ := db.AddEvent(ctx, types.Event{Name: "Interview", StartTime: ti, EndTime: ti.Add(time.Hour)},
+		"other")
+
+	_ = db.SetAttendees(ctx, invitation, []types.Attendee{{Login: login, Status: types.RSVPAccepted}}, "other")
+
+	from, to := ti.Add(-time.Hour), ti.Add(time.Hour)
+
+	s, _ := db.GetBusyEvents(ctx, []string{login}, from, to, "viewer")
+	if len(s) != 2 || s[0].Name != "Lunch" || s[1].Name != "Interview" || s[1].Login != login {
+		t.Errorf("Failed to get events the user is busy with: got: %v", s)
+	}
+
+	s, _ = db.GetBusyEvents(ctx, []string{login}, from, to, login)
+	if len(s) != 3 {
+		t.Errorf("Failed to get events of private calendars of the user: got: %v", s)
+	}
+
+	_ = db.SetShare(ctx, private, types.Share{Login: "viewer", Level: types.ShareFreeBusy}, login)
+
+	s, _ = db.GetBusyEvents(ctx, []string{login}, from, to, "viewer")
+	if len(s) != 3 {
+		t.Errorf("Failed to get events of calendars shared with the user: got: %v", s)
+	}
+}
+
+func TestGetAlertingEvents(t *testing.T) {
+	db := InitDatabase()
+	ti := time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC)
+	start := ti.AddDate(0, -1, 0)
+
+	for _, name := range []string{"Ended", "Moved", "Weekly"} {
+		e := types.Event{Name: name, StartTime: start, EndTime: start.Add(time.Hour),
+			AlertTime: start.Add(-time.Minute), Recurrence: "FREQ=WEEKLY"}
+		if name != "Weekly" {
+			e.Recurrence = "FREQ=DAILY;COUNT=3"
+		}
+
+		id, _ := db.AddEvent(ctx, e, login)
+		if name == "Moved" {
+			_ = db.SaveEventException(ctx, types.EventException{EventID: id, OriginalStart: start.AddDate(0, 0, 1),
+				StartTime: ti, EndTime: ti.Add(time.Hour), AlertTime: ti.Add(-time.Minute)}, login)
+		}
+	}
+
+	s, _ := db.GetAlertingEvents(ctx, ti.Add(-time.Hour), ti)
+
+	names := make(map[string]types.AlertingEvent)
+	for _, e := range s {
+		names[e.Name] = e
+	}
+
+	if _, ok := names["Ended"]; ok || len(s) != 2 {
+		t.Errorf("Failed to leave out series which ended: got: %v", s)
+	}
+
+	if len(names["Moved"].Exceptions) != 1 {
+		t.Errorf("Failed to get exceptions of series alerting in the window: got: %v", s)
+	}
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	types "github.com/bubo-py/McK/types"
 	gomock "github.com/golang/mock/gomock"
@@ -35,89 +36,513 @@ func (m *MockDatabaseRepository) EXPECT() *MockDatabaseRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddCalendar mocks base method.
+func (m *MockDatabaseRepository) AddCalendar(arg0 context.Context, arg1 types.Calendar, arg2 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCalendar", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCalendar indicates an expected call of AddCalendar.
+func (mr *MockDatabaseRepositoryMockRecorder) AddCalendar(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCalendar", reflect.TypeOf((*MockDatabaseRepository)(nil).AddCalendar), arg0, arg1, arg2)
+}
+
 // AddEvent mocks base method.
-func (m *MockDatabaseRepository) AddEvent(arg0 context.Context, arg1 types.Event) error {
+func (m *MockDatabaseRepository) AddEvent(arg0 context.Context, arg1 types.Event, arg2 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddEvent", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddEvent indicates an expected call of AddEvent.
+func (mr *MockDatabaseRepositoryMockRecorder) AddEvent(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).AddEvent), arg0, arg1, arg2)
+}
+
+// AddOutboxMessage mocks base method.
+func (m *MockDatabaseRepository) AddOutboxMessage(arg0 context.Context, arg1 types.OutboxMessage, arg2 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddEvent", arg0, arg1)
+	ret := m.ctrl.Call(m, "AddOutboxMessage", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// AddEvent indicates an expected call of AddEvent.
-func (mr *MockDatabaseRepositoryMockRecorder) AddEvent(arg0, arg1 interface{}) *gomock.Call {
+// AddOutboxMessage indicates an expected call of AddOutboxMessage.
+func (mr *MockDatabaseRepositoryMockRecorder) AddOutboxMessage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOutboxMessage", reflect.TypeOf((*MockDatabaseRepository)(nil).AddOutboxMessage), arg0, arg1, arg2)
+}
+
+// ClaimAlerts mocks base method.
+func (m *MockDatabaseRepository) ClaimAlerts(arg0 context.Context, arg1, arg2 time.Time, arg3 int) ([]types.Alert, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimAlerts", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]types.Alert)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimAlerts indicates an expected call of ClaimAlerts.
+func (mr *MockDatabaseRepositoryMockRecorder) ClaimAlerts(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimAlerts", reflect.TypeOf((*MockDatabaseRepository)(nil).ClaimAlerts), arg0, arg1, arg2, arg3)
+}
+
+// DeleteCalendar mocks base method.
+func (m *MockDatabaseRepository) DeleteCalendar(arg0 context.Context, arg1, arg2 int64, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCalendar", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCalendar indicates an expected call of DeleteCalendar.
+func (mr *MockDatabaseRepositoryMockRecorder) DeleteCalendar(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).AddEvent), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCalendar", reflect.TypeOf((*MockDatabaseRepository)(nil).DeleteCalendar), arg0, arg1, arg2, arg3)
 }
 
 // DeleteEvent mocks base method.
-func (m *MockDatabaseRepository) DeleteEvent(arg0 context.Context, arg1 int64) error {
+func (m *MockDatabaseRepository) DeleteEvent(arg0 context.Context, arg1 int64, arg2 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteEvent", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeleteEvent", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteEvent indicates an expected call of DeleteEvent.
-func (mr *MockDatabaseRepositoryMockRecorder) DeleteEvent(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockDatabaseRepositoryMockRecorder) DeleteEvent(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).DeleteEvent), arg0, arg1, arg2)
+}
+
+// DeleteShare mocks base method.
+func (m *MockDatabaseRepository) DeleteShare(arg0 context.Context, arg1 int64, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteShare", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteShare indicates an expected call of DeleteShare.
+func (mr *MockDatabaseRepositoryMockRecorder) DeleteShare(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteShare", reflect.TypeOf((*MockDatabaseRepository)(nil).DeleteShare), arg0, arg1, arg2, arg3)
+}
+
+// GetAlertingEvents mocks base method.
+func (m *MockDatabaseRepository) GetAlertingEvents(arg0 context.Context, arg1, arg2 time.Time) ([]types.AlertingEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertingEvents", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]types.AlertingEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertingEvents indicates an expected call of GetAlertingEvents.
+func (mr *MockDatabaseRepositoryMockRecorder) GetAlertingEvents(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).DeleteEvent), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertingEvents", reflect.TypeOf((*MockDatabaseRepository)(nil).GetAlertingEvents), arg0, arg1, arg2)
+}
+
+// GetAttendees mocks base method.
+func (m *MockDatabaseRepository) GetAttendees(arg0 context.Context, arg1 []int64, arg2 string) (map[int64][]types.Attendee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAttendees", arg0, arg1, arg2)
+	ret0, _ := ret[0].(map[int64][]types.Attendee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAttendees indicates an expected call of GetAttendees.
+func (mr *MockDatabaseRepositoryMockRecorder) GetAttendees(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttendees", reflect.TypeOf((*MockDatabaseRepository)(nil).GetAttendees), arg0, arg1, arg2)
+}
+
+// GetBusyEvents mocks base method.
+func (m *MockDatabaseRepository) GetBusyEvents(arg0 context.Context, arg1 []string, arg2, arg3 time.Time, arg4 string) ([]types.BusyEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBusyEvents", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]types.BusyEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBusyEvents indicates an expected call of GetBusyEvents.
+func (mr *MockDatabaseRepositoryMockRecorder) GetBusyEvents(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBusyEvents", reflect.TypeOf((*MockDatabaseRepository)(nil).GetBusyEvents), arg0, arg1, arg2, arg3, arg4)
+}
+
+// GetCalendar mocks base method.
+func (m *MockDatabaseRepository) GetCalendar(arg0 context.Context, arg1 int64, arg2 string) (types.Calendar, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCalendar", arg0, arg1, arg2)
+	ret0, _ := ret[0].(types.Calendar)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCalendar indicates an expected call of GetCalendar.
+func (mr *MockDatabaseRepositoryMockRecorder) GetCalendar(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCalendar", reflect.TypeOf((*MockDatabaseRepository)(nil).GetCalendar), arg0, arg1, arg2)
+}
+
+// GetCalendars mocks base method.
+func (m *MockDatabaseRepository) GetCalendars(arg0 context.Context, arg1 string) ([]types.Calendar, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCalendars", arg0, arg1)
+	ret0, _ := ret[0].([]types.Calendar)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCalendars indicates an expected call of GetCalendars.
+func (mr *MockDatabaseRepositoryMockRecorder) GetCalendars(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCalendars", reflect.TypeOf((*MockDatabaseRepository)(nil).GetCalendars), arg0, arg1)
+}
+
+// GetDefaultCalendar mocks base method.
+func (m *MockDatabaseRepository) GetDefaultCalendar(arg0 context.Context, arg1 string) (types.Calendar, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDefaultCalendar", arg0, arg1)
+	ret0, _ := ret[0].(types.Calendar)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDefaultCalendar indicates an expected call of GetDefaultCalendar.
+func (mr *MockDatabaseRepositoryMockRecorder) GetDefaultCalendar(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDefaultCalendar", reflect.TypeOf((*MockDatabaseRepository)(nil).GetDefaultCalendar), arg0, arg1)
 }
 
 // GetEvent mocks base method.
-func (m *MockDatabaseRepository) GetEvent(arg0 context.Context, arg1 int64) (types.Event, error) {
+func (m *MockDatabaseRepository) GetEvent(arg0 context.Context, arg1 int64, arg2 string) (types.Event, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEvent", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetEvent", arg0, arg1, arg2)
 	ret0, _ := ret[0].(types.Event)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEvent indicates an expected call of GetEvent.
-func (mr *MockDatabaseRepositoryMockRecorder) GetEvent(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockDatabaseRepositoryMockRecorder) GetEvent(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEvent), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEvent), arg0, arg1, arg2)
+}
+
+// GetEventByUID mocks base method.
+func (m *MockDatabaseRepository) GetEventByUID(arg0 context.Context, arg1, arg2 string) (types.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEventByUID", arg0, arg1, arg2)
+	ret0, _ := ret[0].(types.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEventByUID indicates an expected call of GetEventByUID.
+func (mr *MockDatabaseRepositoryMockRecorder) GetEventByUID(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventByUID", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEventByUID), arg0, arg1, arg2)
+}
+
+// GetEventCalendar mocks base method.
+func (m *MockDatabaseRepository) GetEventCalendar(arg0 context.Context, arg1 int64, arg2 string) (types.Calendar, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEventCalendar", arg0, arg1, arg2)
+	ret0, _ := ret[0].(types.Calendar)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEventCalendar indicates an expected call of GetEventCalendar.
+func (mr *MockDatabaseRepositoryMockRecorder) GetEventCalendar(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventCalendar", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEventCalendar), arg0, arg1, arg2)
+}
+
+// GetEventExceptions mocks base method.
+func (m *MockDatabaseRepository) GetEventExceptions(arg0 context.Context, arg1 []int64, arg2 string) ([]types.EventException, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEventExceptions", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]types.EventException)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEventExceptions indicates an expected call of GetEventExceptions.
+func (mr *MockDatabaseRepositoryMockRecorder) GetEventExceptions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventExceptions", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEventExceptions), arg0, arg1, arg2)
 }
 
 // GetEvents mocks base method.
-func (m *MockDatabaseRepository) GetEvents(arg0 context.Context) ([]types.Event, error) {
+func (m *MockDatabaseRepository) GetEvents(arg0 context.Context, arg1 string) ([]types.Event, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEvents", arg0)
+	ret := m.ctrl.Call(m, "GetEvents", arg0, arg1)
 	ret0, _ := ret[0].([]types.Event)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEvents indicates an expected call of GetEvents.
-func (mr *MockDatabaseRepositoryMockRecorder) GetEvents(arg0 interface{}) *gomock.Call {
+func (mr *MockDatabaseRepositoryMockRecorder) GetEvents(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEvents", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEvents), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEvents", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEvents), arg0, arg1)
 }
 
 // GetEventsFiltered mocks base method.
-func (m *MockDatabaseRepository) GetEventsFiltered(arg0 context.Context, arg1 types.Filters) ([]types.Event, error) {
+func (m *MockDatabaseRepository) GetEventsFiltered(arg0 context.Context, arg1 types.Filters, arg2 *time.Location, arg3 string) ([]types.Event, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEventsFiltered", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetEventsFiltered", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].([]types.Event)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEventsFiltered indicates an expected call of GetEventsFiltered.
-func (mr *MockDatabaseRepositoryMockRecorder) GetEventsFiltered(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockDatabaseRepositoryMockRecorder) GetEventsFiltered(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventsFiltered", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEventsFiltered), arg0, arg1, arg2, arg3)
+}
+
+// GetEventsInRange mocks base method.
+func (m *MockDatabaseRepository) GetEventsInRange(arg0 context.Context, arg1, arg2 time.Time, arg3 string) ([]types.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEventsInRange", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]types.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEventsInRange indicates an expected call of GetEventsInRange.
+func (mr *MockDatabaseRepositoryMockRecorder) GetEventsInRange(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventsInRange", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEventsInRange), arg0, arg1, arg2, arg3)
+}
+
+// GetEventsPage mocks base method.
+func (m *MockDatabaseRepository) GetEventsPage(arg0 context.Context, arg1 types.PageQuery, arg2 string) ([]types.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEventsPage", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]types.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEventsPage indicates an expected call of GetEventsPage.
+func (mr *MockDatabaseRepositoryMockRecorder) GetEventsPage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventsPage", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEventsPage), arg0, arg1, arg2)
+}
+
+// GetInvitation mocks base method.
+func (m *MockDatabaseRepository) GetInvitation(arg0 context.Context, arg1 int64, arg2 string) (types.Invitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInvitation", arg0, arg1, arg2)
+	ret0, _ := ret[0].(types.Invitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInvitation indicates an expected call of GetInvitation.
+func (mr *MockDatabaseRepositoryMockRecorder) GetInvitation(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvitation", reflect.TypeOf((*MockDatabaseRepository)(nil).GetInvitation), arg0, arg1, arg2)
+}
+
+// GetInvitations mocks base method.
+func (m *MockDatabaseRepository) GetInvitations(arg0 context.Context, arg1 string) ([]types.Invitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInvitations", arg0, arg1)
+	ret0, _ := ret[0].([]types.Invitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInvitations indicates an expected call of GetInvitations.
+func (mr *MockDatabaseRepositoryMockRecorder) GetInvitations(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvitations", reflect.TypeOf((*MockDatabaseRepository)(nil).GetInvitations), arg0, arg1)
+}
+
+// GetShares mocks base method.
+func (m *MockDatabaseRepository) GetShares(arg0 context.Context, arg1 int64, arg2 string) ([]types.Share, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShares", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]types.Share)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShares indicates an expected call of GetShares.
+func (mr *MockDatabaseRepositoryMockRecorder) GetShares(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShares", reflect.TypeOf((*MockDatabaseRepository)(nil).GetShares), arg0, arg1, arg2)
+}
+
+// GetTimezones mocks base method.
+func (m *MockDatabaseRepository) GetTimezones(arg0 context.Context, arg1 []string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTimezones", arg0, arg1)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTimezones indicates an expected call of GetTimezones.
+func (mr *MockDatabaseRepositoryMockRecorder) GetTimezones(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTimezones", reflect.TypeOf((*MockDatabaseRepository)(nil).GetTimezones), arg0, arg1)
+}
+
+// InTransaction mocks base method.
+func (m *MockDatabaseRepository) InTransaction(arg0 context.Context, arg1 func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InTransaction", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InTransaction indicates an expected call of InTransaction.
+func (mr *MockDatabaseRepositoryMockRecorder) InTransaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InTransaction", reflect.TypeOf((*MockDatabaseRepository)(nil).InTransaction), arg0, arg1)
+}
+
+// SaveEventException mocks base method.
+func (m *MockDatabaseRepository) SaveEventException(arg0 context.Context, arg1 types.EventException, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveEventException", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveEventException indicates an expected call of SaveEventException.
+func (mr *MockDatabaseRepositoryMockRecorder) SaveEventException(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveEventException", reflect.TypeOf((*MockDatabaseRepository)(nil).SaveEventException), arg0, arg1, arg2)
+}
+
+// ScheduleAlerts mocks base method.
+func (m *MockDatabaseRepository) ScheduleAlerts(arg0 context.Context, arg1 []types.Alert) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleAlerts", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleAlerts indicates an expected call of ScheduleAlerts.
+func (mr *MockDatabaseRepositoryMockRecorder) ScheduleAlerts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleAlerts", reflect.TypeOf((*MockDatabaseRepository)(nil).ScheduleAlerts), arg0, arg1)
+}
+
+// SetAttendees mocks base method.
+func (m *MockDatabaseRepository) SetAttendees(arg0 context.Context, arg1 int64, arg2 []types.Attendee, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAttendees", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAttendees indicates an expected call of SetAttendees.
+func (mr *MockDatabaseRepositoryMockRecorder) SetAttendees(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAttendees", reflect.TypeOf((*MockDatabaseRepository)(nil).SetAttendees), arg0, arg1, arg2, arg3)
+}
+
+// SetRSVP mocks base method.
+func (m *MockDatabaseRepository) SetRSVP(arg0 context.Context, arg1 int64, arg2 types.RSVPStatus, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRSVP", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRSVP indicates an expected call of SetRSVP.
+func (mr *MockDatabaseRepositoryMockRecorder) SetRSVP(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRSVP", reflect.TypeOf((*MockDatabaseRepository)(nil).SetRSVP), arg0, arg1, arg2, arg3)
+}
+
+// SetShare mocks base method.
+func (m *MockDatabaseRepository) SetShare(arg0 context.Context, arg1 int64, arg2 types.Share, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetShare", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetShare indicates an expected call of SetShare.
+func (mr *MockDatabaseRepositoryMockRecorder) SetShare(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetShare", reflect.TypeOf((*MockDatabaseRepository)(nil).SetShare), arg0, arg1, arg2, arg3)
+}
+
+// SplitEvent mocks base method.
+func (m *MockDatabaseRepository) SplitEvent(arg0 context.Context, arg1 int64, arg2 time.Time, arg3, arg4 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SplitEvent", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SplitEvent indicates an expected call of SplitEvent.
+func (mr *MockDatabaseRepositoryMockRecorder) SplitEvent(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SplitEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).SplitEvent), arg0, arg1, arg2, arg3, arg4)
+}
+
+// UpdateAlert mocks base method.
+func (m *MockDatabaseRepository) UpdateAlert(arg0 context.Context, arg1 types.Alert) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAlert", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAlert indicates an expected call of UpdateAlert.
+func (mr *MockDatabaseRepositoryMockRecorder) UpdateAlert(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAlert", reflect.TypeOf((*MockDatabaseRepository)(nil).UpdateAlert), arg0, arg1)
+}
+
+// UpdateCalendar mocks base method.
+func (m *MockDatabaseRepository) UpdateCalendar(arg0 context.Context, arg1 types.Calendar, arg2 int64, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCalendar", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCalendar indicates an expected call of UpdateCalendar.
+func (mr *MockDatabaseRepositoryMockRecorder) UpdateCalendar(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEventsFiltered", reflect.TypeOf((*MockDatabaseRepository)(nil).GetEventsFiltered), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCalendar", reflect.TypeOf((*MockDatabaseRepository)(nil).UpdateCalendar), arg0, arg1, arg2, arg3)
 }
 
 // UpdateEvent mocks base method.
-func (m *MockDatabaseRepository) UpdateEvent(arg0 context.Context, arg1 types.Event, arg2 int64) error {
+func (m *MockDatabaseRepository) UpdateEvent(arg0 context.Context, arg1 types.EventUpdate, arg2 int64, arg3 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateEvent", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "UpdateEvent", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateEvent indicates an expected call of UpdateEvent.
-func (mr *MockDatabaseRepositoryMockRecorder) UpdateEvent(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockDatabaseRepositoryMockRecorder) UpdateEvent(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).UpdateEvent), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEvent", reflect.TypeOf((*MockDatabaseRepository)(nil).UpdateEvent), arg0, arg1, arg2, arg3)
 }
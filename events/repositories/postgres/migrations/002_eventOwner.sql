@@ -0,0 +1,10 @@
+ALTER TABLE events ADD COLUMN owner_id BIGINT REFERENCES users(id) ON DELETE CASCADE;
+
+-- Events created before ownership was introduced are left without an owner, which keeps them invisible to every user
+-- until an operator hands them over to one with "McK events assign-unowned --user <login>".
+CREATE INDEX events_owner_id_idx ON events (owner_id);
+
+---- create above / drop below ----
+
+DROP INDEX events_owner_id_idx;
+ALTER TABLE events DROP COLUMN owner_id;
@@ -0,0 +1,5 @@
+ALTER TABLE events ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+
+---- create above / drop below ----
+
+ALTER TABLE events DROP COLUMN recurrence;
@@ -0,0 +1,15 @@
+CREATE TABLE event_exceptions (
+    event_id BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    original_start TIMESTAMP NOT NULL,
+    cancelled BOOLEAN NOT NULL DEFAULT FALSE,
+    name VARCHAR(255) NOT NULL,
+    startTime TIMESTAMP NOT NULL,
+    endTime TIMESTAMP NOT NULL,
+    description VARCHAR(510) NOT NULL DEFAULT '',
+    alertTime TIMESTAMP NOT NULL,
+    PRIMARY KEY (event_id, original_start)
+);
+
+---- create above / drop below ----
+
+drop table event_exceptions;
@@ -0,0 +1,7 @@
+ALTER TABLE events ADD COLUMN uid VARCHAR(255) NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX events_owner_uid_idx ON events (owner_id, uid) WHERE uid <> '';
+
+---- create above / drop below ----
+
+DROP INDEX events_owner_uid_idx;
+ALTER TABLE events DROP COLUMN uid;
@@ -0,0 +1,24 @@
+CREATE TABLE event_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    occurrence_start TIMESTAMP NOT NULL,
+    alert_time TIMESTAMP NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    delivered_at TIMESTAMP,
+    UNIQUE (event_id, occurrence_start, alert_time)
+);
+
+CREATE INDEX event_alerts_due_idx ON event_alerts (next_attempt) WHERE status = 'pending';
+
+-- End of the last occurrence of series bounded by COUNT or UNTIL, NULL for series recurring forever
+ALTER TABLE events ADD COLUMN series_end TIMESTAMP;
+
+---- create above / drop below ----
+
+ALTER TABLE events DROP COLUMN series_end;
+
+drop table event_alerts;
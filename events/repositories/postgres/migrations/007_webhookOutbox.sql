@@ -0,0 +1,11 @@
+CREATE TABLE webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+---- create above / drop below ----
+
+drop table webhook_outbox;
@@ -0,0 +1,7 @@
+CREATE INDEX events_single_range_idx ON events (owner_id, endTime, startTime) WHERE recurrence = '';
+CREATE INDEX events_recurring_start_idx ON events (owner_id, startTime) WHERE recurrence <> '';
+
+---- create above / drop below ----
+
+drop index events_recurring_start_idx;
+drop index events_single_range_idx;
@@ -0,0 +1,7 @@
+CREATE INDEX events_owner_start_idx ON events (owner_id, startTime, id);
+CREATE INDEX events_owner_name_idx ON events (owner_id, name, id);
+
+---- create above / drop below ----
+
+drop index events_owner_name_idx;
+drop index events_owner_start_idx;
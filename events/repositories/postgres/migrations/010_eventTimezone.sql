@@ -0,0 +1,5 @@
+ALTER TABLE events ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
+
+---- create above / drop below ----
+
+ALTER TABLE events DROP COLUMN timezone;
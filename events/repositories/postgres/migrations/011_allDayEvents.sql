@@ -0,0 +1,17 @@
+ALTER TABLE events ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE events ADD COLUMN start_date DATE;
+ALTER TABLE events ADD COLUMN end_date DATE;
+
+DROP INDEX events_single_range_idx;
+CREATE INDEX events_single_range_idx ON events (owner_id, endTime, startTime) WHERE recurrence = '' AND NOT all_day;
+CREATE INDEX events_all_day_range_idx ON events (owner_id, end_date, start_date) WHERE recurrence = '' AND all_day;
+
+---- create above / drop below ----
+
+DROP INDEX events_all_day_range_idx;
+DROP INDEX events_single_range_idx;
+CREATE INDEX events_single_range_idx ON events (owner_id, endTime, startTime) WHERE recurrence = '';
+
+ALTER TABLE events DROP COLUMN end_date;
+ALTER TABLE events DROP COLUMN start_date;
+ALTER TABLE events DROP COLUMN all_day;
@@ -0,0 +1,29 @@
+CREATE TABLE calendars (
+    id BIGSERIAL PRIMARY KEY,
+    owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '',
+    timezone VARCHAR(64) NOT NULL DEFAULT '',
+    visibility VARCHAR(16) NOT NULL DEFAULT 'freeBusy',
+    is_default BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX calendars_owner_id_idx ON calendars (owner_id);
+CREATE UNIQUE INDEX calendars_default_idx ON calendars (owner_id) WHERE is_default;
+
+-- Every user gets the default calendar, which keeps the events created before calendars were introduced
+INSERT INTO calendars (owner_id, name, is_default) SELECT id, 'Personal', true FROM users;
+
+ALTER TABLE events ADD COLUMN calendar_id BIGINT REFERENCES calendars(id) ON DELETE CASCADE;
+UPDATE events SET calendar_id = (SELECT id FROM calendars WHERE owner_id = events.owner_id AND is_default)
+    WHERE owner_id IS NOT NULL;
+
+-- Events without an owner get a calendar when they are assigned to a user
+ALTER TABLE events ADD CONSTRAINT events_calendar_id_check CHECK (owner_id IS NULL OR calendar_id IS NOT NULL);
+CREATE INDEX events_calendar_id_idx ON events (calendar_id);
+
+---- create above / drop below ----
+
+DROP INDEX events_calendar_id_idx;
+ALTER TABLE events DROP COLUMN calendar_id;
+DROP TABLE calendars;
@@ -0,0 +1,13 @@
+CREATE TABLE calendar_shares (
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    level VARCHAR(16) NOT NULL,
+    PRIMARY KEY (calendar_id, user_id)
+);
+
+-- Calendars shared with a user are looked up when their events are listed
+CREATE INDEX calendar_shares_user_id_idx ON calendar_shares (user_id);
+
+---- create above / drop below ----
+
+DROP TABLE calendar_shares;
@@ -0,0 +1,20 @@
+-- Attendees are users invited by login or external guests invited by email address
+CREATE TABLE event_attendees (
+    event_id BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(320),
+    status VARCHAR(16) NOT NULL,
+    PRIMARY KEY (event_id, position),
+    CHECK ((user_id IS NULL) <> (email IS NULL))
+);
+
+CREATE UNIQUE INDEX event_attendees_user_idx ON event_attendees (event_id, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX event_attendees_email_idx ON event_attendees (event_id, email) WHERE email IS NOT NULL;
+
+-- Invitations of a user are looked up when the user's events are listed
+CREATE INDEX event_attendees_user_id_idx ON event_attendees (user_id);
+
+---- create above / drop below ----
+
+DROP TABLE event_attendees;
@@ -3,14 +3,19 @@ package postgres
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/events/recurrence"
 	"github.com/bubo-py/McK/types"
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jackc/tern/migrate"
 )
@@ -25,12 +30,149 @@ type eventDb struct {
 	EndTime     time.Time `db:"endtime"`   // RFC 3339, section 5.6
 	Description string    `db:"description,omitempty"`
 	AlertTime   time.Time `db:"alerttime,omitempty"`
+	Recurrence  string    `db:"recurrence"`
+
+	Timezone string `db:"timezone"`
+
+	AllDay bool `db:"all_day"`
+
+	CalendarID int64 `db:"calendar_id"`
+
+	UID string `db:"uid"`
+
+	OriginalStart *time.Time `db:"-"`
+
+	Attendees []types.Attendee `db:"-"`
+	Organizer string           `db:"organizer"`
+}
+
+// stringDataRightTruncation is the SQLSTATE of values too long for their VARCHAR columns
+const stringDataRightTruncation = "22001"
+
+// checkViolation is the SQLSTATE of a row failing a CHECK constraint, e.g. an attendee who is not a user
+const checkViolation = "23514"
+
+// notNullViolation is the SQLSTATE of a NULL written to a NOT NULL column, e.g. the ID of a user who does not exist
+const notNullViolation = "23502"
+
+var eventColumns = []string{"id", "name", "startTime", "endTime", "description", "alertTime", "recurrence", "timezone",
+	"all_day", "calendar_id", "uid"}
+
+type exceptionDb struct {
+	EventID       int64     `db:"event_id"`
+	OriginalStart time.Time `db:"original_start"`
+	Cancelled     bool      `db:"cancelled"`
+	Name          string    `db:"name"`
+	StartTime     time.Time `db:"starttime"`
+	EndTime       time.Time `db:"endtime"`
+	Description   string    `db:"description"`
+	AlertTime     time.Time `db:"alerttime"`
+}
+
+var exceptionColumns = []string{"event_id", "original_start", "cancelled", "name", "startTime", "endTime",
+	"description", "alertTime"}
+
+type calendarDb struct {
+	ID         int64                    `db:"id"`
+	Name       string                   `db:"name"`
+	Color      string                   `db:"color"`
+	Timezone   string                   `db:"timezone"`
+	Visibility types.CalendarVisibility `db:"visibility"`
+	Default    bool                     `db:"is_default"`
+	Owner      string                   `db:"owner"`
+	Access     types.ShareLevel         `db:"access"`
+}
+
+type attendeeDb struct {
+	EventID int64            `db:"event_id"`
+	Login   string           `db:"login"`
+	Email   string           `db:"email"`
+	Status  types.RSVPStatus `db:"status"`
+}
+
+type invitationDb struct {
+	eventDb
+	Status types.RSVPStatus `db:"rsvp_status"`
+}
+
+type shareDb struct {
+	Login string           `db:"login"`
+	Level types.ShareLevel `db:"level"`
+}
+
+var calendarColumns = []string{"calendars.id", "calendars.name", "calendars.color", "calendars.timezone",
+	"calendars.visibility", "calendars.is_default", "users.login AS owner",
+	"COALESCE(calendar_shares.level, '') AS access"}
+
+type alertingEventDb struct {
+	eventDb
+	Login        string `db:"login"`
+	UserTimezone string `db:"user_timezone"`
+
+	// Exception columns are NULL for events without exceptions
+	OriginalStart        *time.Time `db:"original_start"`
+	Cancelled            *bool      `db:"cancelled"`
+	ExceptionName        *string    `db:"exception_name"`
+	ExceptionStart       *time.Time `db:"exception_start"`
+	ExceptionEnd         *time.Time `db:"exception_end"`
+	ExceptionDescription *string    `db:"exception_description"`
+	ExceptionAlert       *time.Time `db:"exception_alert"`
+}
+
+type busyEventDb struct {
+	eventDb
+	Login        string `db:"login"`
+	UserTimezone string `db:"user_timezone"`
+
+	// Exception columns are NULL for events without exceptions
+	OriginalStart  *time.Time `db:"original_start"`
+	Cancelled      *bool      `db:"cancelled"`
+	ExceptionStart *time.Time `db:"exception_start"`
+	ExceptionEnd   *time.Time `db:"exception_end"`
+}
+
+type userTimezoneDb struct {
+	Login    string `db:"login"`
+	Timezone string `db:"timezone"`
+}
+
+type alertDb struct {
+	ID              int64      `db:"id"`
+	EventID         int64      `db:"event_id"`
+	OccurrenceStart time.Time  `db:"occurrence_start"`
+	AlertTime       time.Time  `db:"alert_time"`
+	Status          string     `db:"status"`
+	Attempts        int        `db:"attempts"`
+	LastError       string     `db:"last_error"`
+	NextAttempt     time.Time  `db:"next_attempt"`
+	DeliveredAt     *time.Time `db:"delivered_at"`
+	Login           string     `db:"login"`
+	Timezone        string     `db:"timezone"`
 }
 
 type Db struct {
 	pool *pgxpool.Pool
 }
 
+// querier is implemented both by the pool and by transactions
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	BeginFunc(ctx context.Context, f func(pgx.Tx) error) error
+}
+
+type txKey struct{}
+
+// conn returns the transaction the context is a part of, or the pool outside of transactions
+func (pg Db) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+
+	return pg.pool
+}
+
 func Init(ctx context.Context, connString string) (Db, error) {
 	var pg Db
 
@@ -86,13 +228,19 @@ func RunMigration(ctx context.Context, db Db) error {
 	return nil
 }
 
-func (pg Db) GetEvents(ctx context.Context) ([]types.Event, error) {
+func (pg Db) GetEvents(ctx context.Context, login string) ([]types.Event, error) {
 	var s []types.Event
 	var events []*eventDb
 
-	q := sqlbuilder.Select("*").From("events")
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select(eventColumns...)
+	sb.From("events")
+	sb.Where(sb.Equal("owner_id", ownerID(login)))
+
+	q, args := sb.Build()
 
-	err := pgxscan.Select(ctx, pg.pool, &events, q.String())
+	err := pgxscan.Select(ctx, pg.conn(ctx), &events, q, args...)
 	if err != nil {
 		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
 	}
@@ -104,23 +252,23 @@ func (pg Db) GetEvents(ctx context.Context) ([]types.Event, error) {
 	return s, nil
 }
 
-func (pg Db) GetEvent(ctx context.Context, id int64) (types.Event, error) {
+func (pg Db) GetEvent(ctx context.Context, id int64, login string) (types.Event, error) {
 	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
 	var e eventDb
 
-	exists, err := pg.exists(ctx, id)
+	exists, err := pg.exists(ctx, id, login)
 	if err != nil {
 		return types.Event(e), err
 	}
 
 	if exists {
-		sb.Select("id", "name", "startTime", "endTime", "description", "alertTime")
+		sb.Select(eventColumns...)
 		sb.From("events")
-		sb.Where(sb.Equal("id", id))
+		sb.Where(sb.Equal("id", id), sb.Equal("owner_id", ownerID(login)))
 
 		q, args := sb.Build()
 
-		err := pgxscan.Get(ctx, pg.pool, &e, q, args...)
+		err := pgxscan.Get(ctx, pg.conn(ctx), &e, q, args...)
 		if err != nil {
 			return types.Event(e), fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
 		}
@@ -131,38 +279,75 @@ func (pg Db) GetEvent(ctx context.Context, id int64) (types.Event, error) {
 	return types.Event(e), customErrors.ErrNotFound
 }
 
-func (pg Db) AddEvent(ctx context.Context, e types.Event) error {
+// GetEventByUID returns the event imported with the given iCalendar UID
+func (pg Db) GetEventByUID(ctx context.Context, uid, login string) (types.Event, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var events []*eventDb
+
+	sb.Select(eventColumns...)
+	sb.From("events")
+	sb.Where(sb.Equal("uid", uid), sb.Equal("owner_id", ownerID(login)))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &events, q, args...)
+	if err != nil {
+		return types.Event{}, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if len(events) == 0 {
+		return types.Event{}, customErrors.ErrNotFound
+	}
+
+	return types.Event(*events[0]), nil
+}
+
+func (pg Db) AddEvent(ctx context.Context, e types.Event, login string) (int64, error) {
 	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+	var id int64
+
+	startDate, endDate := dates(e)
+
+	if e.CalendarID == 0 {
+		c, err := pg.GetDefaultCalendar(ctx, login)
+		if err != nil {
+			return id, err
+		}
+		e.CalendarID = c.ID
+	}
 
 	ib.InsertInto("events")
-	ib.Cols("name", "startTime", "endTime", "description", "alertTime")
-	ib.Values(e.Name, e.StartTime, e.EndTime, e.Description, e.AlertTime)
+	ib.Cols("name", "startTime", "endTime", "description", "alertTime", "recurrence", "timezone", "all_day",
+		"start_date", "end_date", "series_end", "calendar_id", "uid", "owner_id")
+	ib.Values(e.Name, e.StartTime, e.EndTime, e.Description, e.AlertTime, e.Recurrence, e.Timezone, e.AllDay,
+		startDate, endDate, seriesEnd(e), e.CalendarID, e.UID, ownerID(login))
+	ib.SQL("RETURNING id")
 
 	q, args := ib.Build()
 
-	_, err := pg.pool.Exec(ctx, q, args...)
+	err := pg.conn(ctx).QueryRow(ctx, q, args...).Scan(&id)
 	if err != nil {
-		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+		return id, writeError(err)
 	}
 
-	return nil
+	return id, nil
 }
 
-func (pg Db) DeleteEvent(ctx context.Context, id int64) error {
+func (pg Db) DeleteEvent(ctx context.Context, id int64, login string) error {
 	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
 
-	exists, err := pg.exists(ctx, id)
+	exists, err := pg.exists(ctx, id, login)
 	if err != nil {
 		return err
 	}
 
 	if exists {
 		db.DeleteFrom("events")
-		db.Where(db.Equal("id", id))
+		db.Where(db.Equal("id", id), db.Equal("owner_id", ownerID(login)))
 
 		q, args := db.Build()
 
-		_, err = pg.pool.Exec(ctx, q, args...)
+		_, err = pg.conn(ctx).Exec(ctx, q, args...)
 		if err != nil {
 			return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
 		}
@@ -173,10 +358,16 @@ func (pg Db) DeleteEvent(ctx context.Context, id int64) error {
 	return customErrors.ErrNotFound
 }
 
-func (pg Db) UpdateEvent(ctx context.Context, e types.Event, id int64) error {
+// UpdateEvent sets the fields given in the update. Turning an all-day event into a timed one clears its dates.
+func (pg Db) UpdateEvent(ctx context.Context, u types.EventUpdate, id int64, login string) error {
 	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
 
-	exists, err := pg.exists(ctx, id)
+	e := u.Event
+	if u.AllDay != nil {
+		e.AllDay = *u.AllDay
+	}
+
+	exists, err := pg.exists(ctx, id, login)
 	if err != nil {
 		return err
 	}
@@ -191,15 +382,30 @@ func (pg Db) UpdateEvent(ctx context.Context, e types.Event, id int64) error {
 		ub.SetMore(ub.Assign("name", e.Name))
 	}
 
+	startDate, endDate := dates(e)
+	timed := u.AllDay != nil && !*u.AllDay
+
 	if !e.StartTime.IsZero() {
 		ub.SetMore(ub.Assign("startTime", e.StartTime))
 	}
 
+	if !e.StartTime.IsZero() || timed {
+		ub.SetMore(ub.Assign("start_date", startDate))
+	}
+
 	if !e.EndTime.IsZero() {
 		ub.SetMore(ub.Assign("endTime", e.EndTime))
 	}
 
-	if e.Description == "" {
+	if !e.EndTime.IsZero() || timed {
+		ub.SetMore(ub.Assign("end_date", endDate))
+	}
+
+	if u.AllDay != nil {
+		ub.SetMore(ub.Assign("all_day", e.AllDay))
+	}
+
+	if e.Description != "" {
 		ub.SetMore(ub.Assign("description", e.Description))
 	}
 
@@ -207,43 +413,165 @@ func (pg Db) UpdateEvent(ctx context.Context, e types.Event, id int64) error {
 		ub.SetMore(ub.Assign("alertTime", e.AlertTime))
 	}
 
-	ub.Where(ub.Equal("id", id))
+	if e.Recurrence != "" {
+		ub.SetMore(ub.Assign("recurrence", e.Recurrence))
+	}
+
+	if e.Timezone != "" {
+		ub.SetMore(ub.Assign("timezone", e.Timezone))
+	}
+
+	if e.CalendarID != 0 {
+		ub.SetMore(ub.Assign("calendar_id", e.CalendarID))
+	}
+
+	ub.Where(ub.Equal("id", id), ub.Equal("owner_id", ownerID(login)))
 
 	q, args := ub.Build()
 
-	_, err = pg.pool.Exec(ctx, q, args...)
+	_, err = pg.conn(ctx).Exec(ctx, q, args...)
 	if err != nil {
-		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+		return writeError(err)
+	}
+
+	if !e.StartTime.IsZero() || !e.EndTime.IsZero() || e.Recurrence != "" {
+		return pg.updateSeriesEnd(ctx, id)
 	}
 
 	return nil
 
 }
 
-func (pg Db) GetEventsFiltered(ctx context.Context, f types.Filters) ([]types.Event, error) {
+// writeError wraps an error of a query writing an event, values too long for their columns are rejected
+// like the business logic rejects them
+func writeError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == stringDataRightTruncation {
+		return fmt.Errorf("%w: %s", customErrors.ErrBadRequest, pgErr.Message)
+	}
+
+	return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+}
+
+// dates returns the dates of an all-day event, which are stored in DATE columns besides the midnights in startTime
+// and endTime, so that all-day events are sorted together with the others. Other events have no dates.
+func dates(e types.Event) (*time.Time, *time.Time) {
+	if !e.AllDay {
+		return nil, nil
+	}
+
+	return &e.StartTime, &e.EndTime
+}
+
+// seriesEnd returns the end of the last occurrence of a recurring event bounded by COUNT or UNTIL, it is NULL
+// for series recurring forever. The business logic expands series in the timezone of the event or its owner,
+// which may move their days by one from the expansion in UTC, so the end is given a day of margin.
+func seriesEnd(e types.Event) *time.Time {
+	if e.Recurrence == "" {
+		return nil
+	}
+
+	rule, err := recurrence.Parse(e.Recurrence)
+	if err != nil {
+		return nil
+	}
+
+	end, ok := rule.End(e.StartTime.UTC(), e.EndTime.Sub(e.StartTime))
+	if !ok {
+		return nil
+	}
+
+	end = end.AddDate(0, 0, 1)
+
+	return &end
+}
+
+// updateSeriesEnd recomputes the end of the series from the times and the rule stored for the event
+func (pg Db) updateSeriesEnd(ctx context.Context, id int64) error {
+	var events []*eventDb
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select(eventColumns...)
+	sb.From("events")
+	sb.Where(sb.Equal("id", id))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &events, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if len(events) == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("events")
+	ub.Set(ub.Assign("series_end", seriesEnd(types.Event(*events[0]))))
+	ub.Where(ub.Equal("id", id))
+
+	q, args = ub.Build()
+
+	_, err = pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+// GetEventsFiltered returns events starting on the day, month and year in the given location, all-day events
+// starting on the date. Multi-day events are returned regardless of the days they span, like recurring ones.
+func (pg Db) GetEventsFiltered(ctx context.Context, f types.Filters, loc *time.Location,
+	login string) ([]types.Event, error) {
 	var filtered []types.Event
 	var events []*eventDb
 
 	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
 
-	sb.Select("id", "name", "startTime", "endTime", "description", "alertTime")
+	sb.Select(eventColumns...)
 	sb.From("events")
+	sb.Where(sb.Equal("owner_id", ownerID(login)))
+
+	var dateFilters []string
+
+	// Times are stored in UTC, they are converted to the wall-clock time of the location before extracting,
+	// dates of all-day events are not
+	local := func(column string) string {
+		return fmt.Sprintf("((%s AT TIME ZONE 'UTC') AT TIME ZONE %s)", column, sb.Var(loc.String()))
+	}
+
+	extract := func(field string, value int) string {
+		return fmt.Sprintf("EXTRACT(%s FROM CASE WHEN all_day THEN start_date::timestamp ELSE %s END) = %s", field,
+			local("startTime"), sb.Var(value))
+	}
+
+	multiDay := fmt.Sprintf("CASE WHEN all_day THEN end_date - start_date > 1 ELSE %s::date < %s::date END",
+		local("startTime"), local("endTime"))
 
 	if f.Day != 0 {
-		sb.Where(sb.Equal("EXTRACT(day FROM startTime)", f.Day))
+		dateFilters = append(dateFilters, extract("day", f.Day))
 	}
 
 	if f.Month != 0 {
-		sb.Where(sb.Equal("EXTRACT(month FROM startTime)", f.Month))
+		dateFilters = append(dateFilters, extract("month", f.Month))
 	}
 
 	if f.Year != 0 {
-		sb.Where(sb.Equal("EXTRACT(year FROM startTime)", f.Year))
+		dateFilters = append(dateFilters, extract("year", f.Year))
+	}
+
+	// Recurring events are returned regardless of their first occurrence, they are expanded by the business logic
+	if len(dateFilters) > 0 {
+		sb.Where(sb.Or(sb.NotEqual("recurrence", ""), multiDay, sb.And(dateFilters...)))
 	}
 
 	q, args := sb.Build()
 
-	err := pgxscan.Select(ctx, pg.pool, &events, q, args...)
+	err := pgxscan.Select(ctx, pg.conn(ctx), &events, q, args...)
 	if err != nil {
 		return filtered, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
 	}
@@ -255,29 +583,1129 @@ func (pg Db) GetEventsFiltered(ctx context.Context, f types.Filters) ([]types.Ev
 	return filtered, nil
 }
 
-func (pg Db) exists(ctx context.Context, id int64) (bool, error) {
+// GetEventsInRange returns single events overlapping the [from, to) window together with
+// every recurring event which starts before the end of the window. The days of all-day events depend on
+// the timezone of the user, so they are matched with a margin of a day and the business logic drops the ones
+// outside of the window.
+func (pg Db) GetEventsInRange(ctx context.Context, from, to time.Time, login string) ([]types.Event, error) {
+	var s []types.Event
+	var events []*eventDb
+
 	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
-	var exists bool
 
-	sb.Select("EXISTS(select 1 from events)")
+	sb.Select(eventColumns...)
 	sb.From("events")
-	sb.Where(sb.Equal("id", id))
+	sb.Where(sb.Equal("owner_id", ownerID(login)), inRange(sb, "events", from, to))
+	sb.OrderBy("startTime", "id")
 
 	q, args := sb.Build()
 
-	rows, err := pg.pool.Query(ctx, q, args...)
+	err := pgxscan.Select(ctx, pg.conn(ctx), &events, q, args...)
 	if err != nil {
-		return exists, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
 	}
 
-	for rows.Next() {
+	for _, event := range events {
+		s = append(s, types.Event(*event))
+	}
 
-		values, err := rows.Values()
-		if err != nil {
-			return exists, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	return s, nil
+}
+
+// GetEventsPage returns a page of events with a keyset predicate on the sorted columns, so that a page
+// following a key is found in the index without counting the events before it
+func (pg Db) GetEventsPage(ctx context.Context, q types.PageQuery, login string) ([]types.Event, error) {
+	var s []types.Event
+	var events []*eventDb
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select(eventColumns...)
+	sb.From("events")
+	sb.Where(sb.Equal("owner_id", ownerID(login)))
+
+	if q.CalendarID != 0 {
+		sb.Where(sb.Equal("calendar_id", q.CalendarID))
+	}
+
+	var narrowed []string
+	if len(q.CalendarIDs) > 0 {
+		narrowed = append(narrowed, sb.In("calendar_id", sqlbuilder.Flatten(q.CalendarIDs)...))
+	}
+	if len(q.EventIDs) > 0 {
+		narrowed = append(narrowed, sb.In("id", sqlbuilder.Flatten(q.EventIDs)...))
+	}
+	if len(narrowed) > 0 {
+		sb.Where(sb.Or(narrowed...))
+	}
+
+	columns := []string{"startTime", "id"}
+	switch q.Sort {
+	case types.SortName:
+		columns = []string{"name", "id"}
+	case types.SortID:
+		columns = []string{"id"}
+	}
+
+	if q.After != nil {
+		values := map[string]interface{}{"startTime": q.After.StartTime.UTC(), "name": q.After.Name, "id": q.After.ID}
+
+		vars := make([]string, 0, len(columns))
+		for _, c := range columns {
+			vars = append(vars, sb.Var(values[c]))
 		}
-		exists = values[0].(bool)
+
+		op := ">"
+		if q.Desc {
+			op = "<"
+		}
+
+		sb.Where(fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op, strings.Join(vars, ", ")))
 	}
 
-	return exists, nil
+	order := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if q.Desc {
+			c += " DESC"
+		}
+		order = append(order, c)
+	}
+
+	sb.OrderBy(order...)
+	sb.Limit(q.Limit)
+
+	query, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &events, query, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, event := range events {
+		s = append(s, types.Event(*event))
+	}
+
+	return s, nil
+}
+
+func (pg Db) GetEventExceptions(ctx context.Context, ids []int64, login string) ([]types.EventException, error) {
+	var s []types.EventException
+	var exceptions []*exceptionDb
+
+	if len(ids) == 0 {
+		return s, nil
+	}
+
+	eventIDs := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		eventIDs = append(eventIDs, id)
+	}
+
+	columns := make([]string, 0, len(exceptionColumns))
+	for _, c := range exceptionColumns {
+		columns = append(columns, "event_exceptions."+c)
+	}
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select(columns...)
+	sb.From("event_exceptions")
+	sb.Join("events", "events.id = event_exceptions.event_id")
+	sb.Where(sb.In("event_exceptions.event_id", eventIDs...), sb.Equal("events.owner_id", ownerID(login)))
+	sb.OrderBy("event_exceptions.event_id", "event_exceptions.original_start")
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &exceptions, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, ex := range exceptions {
+		s = append(s, types.EventException(*ex))
+	}
+
+	return s, nil
+}
+
+// SaveEventException adds an exception to a recurring event or replaces the one
+// already stored for the same occurrence
+func (pg Db) SaveEventException(ctx context.Context, ex types.EventException, login string) error {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	exists, err := pg.exists(ctx, ex.EventID, login)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return customErrors.ErrNotFound
+	}
+
+	ib.InsertInto("event_exceptions")
+	ib.Cols(exceptionColumns...)
+	ib.Values(ex.EventID, ex.OriginalStart, ex.Cancelled, ex.Name, ex.StartTime, ex.EndTime, ex.Description,
+		ex.AlertTime)
+	ib.SQL("ON CONFLICT (event_id, original_start) DO UPDATE SET cancelled = EXCLUDED.cancelled, " +
+		"name = EXCLUDED.name, startTime = EXCLUDED.startTime, endTime = EXCLUDED.endTime, " +
+		"description = EXCLUDED.description, alertTime = EXCLUDED.alertTime")
+
+	q, args := ib.Build()
+
+	_, err = pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return writeError(err)
+	}
+
+	return nil
+}
+
+// SplitEvent ends a recurring event before the occurrence starting at from. The rule of the event is replaced
+// by recurrence, or the event is deleted when it is empty, and its exceptions from then on are dropped.
+func (pg Db) SplitEvent(ctx context.Context, id int64, from time.Time, recurrence string, login string) error {
+	exists, err := pg.exists(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return customErrors.ErrNotFound
+	}
+
+	return pg.InTransaction(ctx, func(ctx context.Context) error {
+		var q string
+		var args []interface{}
+
+		if recurrence == "" {
+			db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+			db.DeleteFrom("events")
+			db.Where(db.Equal("id", id), db.Equal("owner_id", ownerID(login)))
+			q, args = db.Build()
+		} else {
+			ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+			ub.Update("events")
+			ub.Set(ub.Assign("recurrence", recurrence))
+			ub.Where(ub.Equal("id", id), ub.Equal("owner_id", ownerID(login)))
+			q, args = ub.Build()
+		}
+
+		_, err := pg.conn(ctx).Exec(ctx, q, args...)
+		if err != nil {
+			return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+		}
+
+		if recurrence != "" {
+			err = pg.updateSeriesEnd(ctx, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+		db.DeleteFrom("event_exceptions")
+		db.Where(db.Equal("event_id", id), db.GreaterEqualThan("original_start", from))
+		q, args = db.Build()
+
+		_, err = pg.conn(ctx).Exec(ctx, q, args...)
+		if err != nil {
+			return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+		}
+
+		return nil
+	})
+}
+
+// InTransaction runs fn in a transaction, calls nested in another transaction run in a savepoint of it
+func (pg Db) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	var fnErr error
+
+	err := pg.conn(ctx).BeginFunc(ctx, func(tx pgx.Tx) error {
+		fnErr = fn(context.WithValue(ctx, txKey{}, tx))
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: transaction error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+// AddOutboxMessage stores a webhook message, it is delivered to the user's webhooks once the transaction commits
+func (pg Db) AddOutboxMessage(ctx context.Context, m types.OutboxMessage, login string) error {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	ib.InsertInto("webhook_outbox")
+	ib.Cols("owner_id", "type", "payload", "created_at")
+	ib.Values(ownerID(login), string(m.Type), string(m.Data), m.CreatedAt.UTC())
+
+	q, args := ib.Build()
+
+	_, err := pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+// inRange returns the condition matching events of the table which may overlap the window. All-day events are
+// matched with a margin, as their days depend on the timezone they are viewed in. The branches are covered
+// by partial indexes on the owner and the times of single and recurring events.
+func inRange(sb *sqlbuilder.SelectBuilder, table string, from, to time.Time) string {
+	fromDate, toDate := from.UTC().AddDate(0, 0, -1), to.UTC().AddDate(0, 0, 2)
+
+	column := func(name string) string {
+		return table + "." + name
+	}
+
+	return sb.Or(
+		sb.And(
+			sb.Equal(column("recurrence"), ""),
+			"NOT "+column("all_day"),
+			sb.LessThan(column("startTime"), to),
+			sb.Or(sb.GreaterThan(column("endTime"), from), sb.GreaterEqualThan(column("startTime"), from)),
+		),
+		sb.And(
+			sb.Equal(column("recurrence"), ""),
+			column("all_day"),
+			sb.LessThan(column("start_date"), toDate),
+			sb.GreaterThan(column("end_date"), fromDate),
+		),
+		sb.And(
+			sb.NotEqual(column("recurrence"), ""),
+			sb.Or(
+				sb.LessThan(column("startTime"), to),
+				sb.And(column("all_day"), sb.LessThan(column("startTime"), toDate)),
+			),
+		),
+	)
+}
+
+// GetBusyEvents finds events of all the users in a single query. Events the users own or accepted invitations to
+// are joined with exceptions of the recurring ones, which carry only their times, and with the timezone
+// of the owner of every event.
+func (pg Db) GetBusyEvents(ctx context.Context, logins []string, from, to time.Time,
+	login string) ([]types.BusyEvent, error) {
+	var s []types.BusyEvent
+	var rows []*busyEventDb
+
+	if len(logins) == 0 {
+		return s, nil
+	}
+
+	users := make([]interface{}, 0, len(logins))
+	for _, l := range logins {
+		users = append(users, l)
+	}
+
+	columns := func(busy string) []string {
+		c := make([]string, 0, len(eventColumns)+2)
+		for _, column := range eventColumns {
+			c = append(c, "events."+column)
+		}
+		return append(c, busy+" AS login", "owners.timezone AS user_timezone")
+	}
+
+	// Events of calendars the owners show free/busy time of or share with the user, every one of the user's own
+	own := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	own.Select(columns("owners.login")...)
+	own.From("events")
+	own.Join("users owners", "owners.id = events.owner_id")
+	own.Join("calendars", "calendars.id = events.calendar_id")
+	own.JoinWithOption(sqlbuilder.LeftJoin, "calendar_shares", "calendar_shares.calendar_id = calendars.id",
+		own.Equal("calendar_shares.user_id", ownerID(login)))
+	own.Where(own.In("owners.login", users...), inRange(own, "events", from, to), own.Or(
+		own.Equal("owners.login", login),
+		own.Equal("calendars.visibility", types.VisibilityFreeBusy),
+		"calendar_shares.user_id IS NOT NULL",
+	))
+
+	invited := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	invited.Select(columns("attendees.login")...)
+	invited.From("event_attendees")
+	invited.Join("users attendees", "attendees.id = event_attendees.user_id")
+	invited.Join("events", "events.id = event_attendees.event_id")
+	invited.Join("users owners", "owners.id = events.owner_id")
+	invited.Where(invited.In("attendees.login", users...), invited.Equal("event_attendees.status", types.RSVPAccepted),
+		inRange(invited, "events", from, to))
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select("busy.*", "event_exceptions.original_start", "event_exceptions.cancelled",
+		"event_exceptions.startTime AS exception_start", "event_exceptions.endTime AS exception_end")
+	sb.From(sb.BuilderAs(sqlbuilder.PostgreSQL.NewUnionBuilder().UnionAll(own, invited), "busy"))
+	sb.JoinWithOption(sqlbuilder.LeftJoin, "event_exceptions",
+		"event_exceptions.event_id = busy.id", "busy.recurrence <> ''")
+	sb.OrderBy("busy.login", "busy.startTime", "busy.id", "event_exceptions.original_start")
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &rows, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, r := range rows {
+		last := len(s) - 1
+		if last < 0 || s[last].ID != r.ID || s[last].Login != r.Login {
+			s = append(s, types.BusyEvent{Event: types.Event(r.eventDb), Login: r.Login, Timezone: r.UserTimezone})
+			last++
+		}
+
+		if r.OriginalStart != nil {
+			s[last].Exceptions = append(s[last].Exceptions, types.EventException{EventID: r.ID,
+				OriginalStart: *r.OriginalStart, Cancelled: *r.Cancelled, StartTime: *r.ExceptionStart,
+				EndTime: *r.ExceptionEnd})
+		}
+	}
+
+	return s, nil
+}
+
+// GetTimezones looks up timezones of the users, logins which are not users are left out
+func (pg Db) GetTimezones(ctx context.Context, logins []string) (map[string]string, error) {
+	timezones := make(map[string]string)
+	var users []*userTimezoneDb
+
+	if len(logins) == 0 {
+		return timezones, nil
+	}
+
+	args := make([]interface{}, 0, len(logins))
+	for _, l := range logins {
+		args = append(args, l)
+	}
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select("login", "timezone")
+	sb.From("users")
+	sb.Where(sb.In("login", args...))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &users, q, args...)
+	if err != nil {
+		return timezones, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, u := range users {
+		timezones[u.Login] = u.Timezone
+	}
+
+	return timezones, nil
+}
+
+// GetAlertingEvents returns events of all users with an alert in the [from, to) window, together with recurring
+// events with an alert whose series have not ended before the window or which have exceptions alerting in it.
+// Recurring events are joined with their exceptions, so that they are expanded without a query per event.
+func (pg Db) GetAlertingEvents(ctx context.Context, from, to time.Time) ([]types.AlertingEvent, error) {
+	var s []types.AlertingEvent
+	var rows []*alertingEventDb
+
+	columns := make([]string, 0, len(eventColumns)+2)
+	for _, c := range eventColumns {
+		columns = append(columns, "events."+c)
+	}
+	columns = append(columns, "users.login", "users.timezone AS user_timezone")
+
+	moved := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	moved.Select("1")
+	moved.From("event_exceptions")
+	moved.Where("event_exceptions.event_id = events.id", "NOT event_exceptions.cancelled",
+		moved.GreaterEqualThan("event_exceptions.alertTime", from))
+
+	alerting := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	alerting.Select(columns...)
+	alerting.From("events")
+	alerting.Join("users", "users.id = events.owner_id")
+	alerting.Where(alerting.GreaterThan("events.alertTime", time.Time{}), alerting.LessThan("events.alertTime", to))
+	alerting.Where(alerting.Or(
+		alerting.And(alerting.Equal("events.recurrence", ""), alerting.GreaterEqualThan("events.alertTime", from)),
+		alerting.And(alerting.NotEqual("events.recurrence", ""), alerting.Or(
+			alerting.IsNull("events.series_end"),
+			// The last occurrence alerts as far ahead of its start as the first one
+			alerting.GreaterEqualThan("events.series_end - events.startTime + events.alertTime", from),
+			"EXISTS ("+alerting.Var(moved)+")",
+		)),
+	))
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select("alerting.*", "event_exceptions.original_start", "event_exceptions.cancelled",
+		"event_exceptions.name AS exception_name", "event_exceptions.startTime AS exception_start",
+		"event_exceptions.endTime AS exception_end", "event_exceptions.description AS exception_description",
+		"event_exceptions.alertTime AS exception_alert")
+	sb.From(sb.BuilderAs(alerting, "alerting"))
+	sb.JoinWithOption(sqlbuilder.LeftJoin, "event_exceptions",
+		"event_exceptions.event_id = alerting.id", "alerting.recurrence <> ''")
+	sb.OrderBy("alerting.id", "event_exceptions.original_start")
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &rows, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, r := range rows {
+		last := len(s) - 1
+		if last < 0 || s[last].ID != r.ID {
+			s = append(s, types.AlertingEvent{Event: types.Event(r.eventDb), Login: r.Login, Timezone: r.UserTimezone})
+			last++
+		}
+
+		if r.OriginalStart != nil {
+			s[last].Exceptions = append(s[last].Exceptions, types.EventException{EventID: r.ID,
+				OriginalStart: *r.OriginalStart, Cancelled: *r.Cancelled, Name: *r.ExceptionName,
+				StartTime: *r.ExceptionStart, EndTime: *r.ExceptionEnd, Description: *r.ExceptionDescription,
+				AlertTime: *r.ExceptionAlert})
+		}
+	}
+
+	return s, nil
+}
+
+// ScheduleAlerts adds pending alerts, the ones already scheduled for the same occurrence and alert time are kept
+func (pg Db) ScheduleAlerts(ctx context.Context, alerts []types.Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	ib.InsertInto("event_alerts")
+	ib.Cols("event_id", "occurrence_start", "alert_time", "status", "next_attempt")
+
+	for _, a := range alerts {
+		ib.Values(a.EventID, a.OccurrenceStart.UTC(), a.AlertTime.UTC(), string(types.AlertPending), a.AlertTime.UTC())
+	}
+
+	ib.SQL("ON CONFLICT (event_id, occurrence_start, alert_time) DO NOTHING")
+
+	q, args := ib.Build()
+
+	_, err := pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+// ClaimAlerts locks up to limit pending alerts due at now until lockedUntil and counts the delivery attempt.
+// Rows locked by other dispatchers are skipped, so concurrent replicas never claim the same alert, and alerts
+// of a dispatcher which did not finish them are claimed again once the lock expires.
+func (pg Db) ClaimAlerts(ctx context.Context, now, lockedUntil time.Time, limit int) ([]types.Alert, error) {
+	var s []types.Alert
+	var alerts []*alertDb
+
+	q, args := sqlbuilder.WithFlavor(sqlbuilder.Buildf(`WITH claimed AS (
+		UPDATE event_alerts SET attempts = attempts + 1, locked_until = %v
+		WHERE id IN (
+			SELECT id FROM event_alerts
+			WHERE status = %v AND next_attempt <= %v AND (locked_until IS NULL OR locked_until <= %v)
+			ORDER BY next_attempt
+			LIMIT %v
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_id, occurrence_start, alert_time, status, attempts, last_error, next_attempt, delivered_at
+	)
+	SELECT claimed.*, users.login, users.timezone FROM claimed
+	JOIN events ON events.id = claimed.event_id
+	JOIN users ON users.id = events.owner_id
+	ORDER BY claimed.next_attempt, claimed.id`,
+		lockedUntil.UTC(), string(types.AlertPending), now.UTC(), now.UTC(), limit), sqlbuilder.PostgreSQL).Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &alerts, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, a := range alerts {
+		s = append(s, types.Alert{
+			ID:              a.ID,
+			EventID:         a.EventID,
+			OccurrenceStart: a.OccurrenceStart,
+			AlertTime:       a.AlertTime,
+			Login:           a.Login,
+			Timezone:        a.Timezone,
+			Status:          types.AlertStatus(a.Status),
+			Attempts:        a.Attempts,
+			LastError:       a.LastError,
+			NextAttempt:     a.NextAttempt,
+			DeliveredAt:     a.DeliveredAt,
+		})
+	}
+
+	return s, nil
+}
+
+// UpdateAlert records the outcome of a delivery attempt and releases the alert
+func (pg Db) UpdateAlert(ctx context.Context, a types.Alert) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("event_alerts")
+	ub.Set(
+		ub.Assign("status", string(a.Status)),
+		ub.Assign("last_error", a.LastError),
+		ub.Assign("next_attempt", a.NextAttempt.UTC()),
+		ub.Assign("delivered_at", a.DeliveredAt),
+		ub.Assign("locked_until", nil),
+	)
+	ub.Where(ub.Equal("id", a.ID))
+
+	q, args := ub.Build()
+
+	tag, err := pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (pg Db) GetCalendars(ctx context.Context, login string) ([]types.Calendar, error) {
+	var s []types.Calendar
+	var calendars []*calendarDb
+
+	sb := calendarsBuilder(login)
+	sb.OrderBy("calendars.id")
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &calendars, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, c := range calendars {
+		s = append(s, types.Calendar(*c))
+	}
+
+	return s, nil
+}
+
+func (pg Db) GetCalendar(ctx context.Context, id int64, login string) (types.Calendar, error) {
+	sb := calendarsBuilder(login)
+	sb.Where(sb.Equal("calendars.id", id))
+
+	return pg.getCalendar(ctx, sb)
+}
+
+func (pg Db) GetEventCalendar(ctx context.Context, id int64, login string) (types.Calendar, error) {
+	sb := calendarsBuilder(login)
+	sb.Join("events", "events.calendar_id = calendars.id")
+	sb.Where(sb.Equal("events.id", id))
+
+	return pg.getCalendar(ctx, sb)
+}
+
+func (pg Db) GetDefaultCalendar(ctx context.Context, login string) (types.Calendar, error) {
+	sb := calendarsBuilder(login)
+	sb.Where(sb.Equal("calendars.owner_id", ownerID(login)), "calendars.is_default")
+
+	c, err := pg.getCalendar(ctx, sb)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		return c, err
+	}
+
+	// Concurrent requests may create the calendar at the same time, the unique index keeps only one of them
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	ib.InsertInto("calendars")
+	ib.Cols("name", "visibility", "is_default", "owner_id")
+	ib.Values(types.DefaultCalendarName, types.VisibilityFreeBusy, true, ownerID(login))
+	ib.SQL("ON CONFLICT (owner_id) WHERE is_default DO NOTHING")
+
+	q, args := ib.Build()
+
+	_, err = pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return c, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return pg.getCalendar(ctx, sb)
+}
+
+// calendarsBuilder selects calendars the user owns or which are shared with the user, with the level of the share
+func calendarsBuilder(login string) *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select(calendarColumns...)
+	sb.From("calendars")
+	sb.Join("users", "users.id = calendars.owner_id")
+	sb.JoinWithOption(sqlbuilder.LeftJoin, "calendar_shares", "calendar_shares.calendar_id = calendars.id",
+		sb.Equal("calendar_shares.user_id", ownerID(login)))
+	sb.Where(sb.Or(sb.Equal("calendars.owner_id", ownerID(login)), "calendar_shares.user_id IS NOT NULL"))
+
+	return sb
+}
+
+// getCalendar returns the single calendar selected by sb
+func (pg Db) getCalendar(ctx context.Context, sb *sqlbuilder.SelectBuilder) (types.Calendar, error) {
+	var calendars []*calendarDb
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &calendars, q, args...)
+	if err != nil {
+		return types.Calendar{}, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if len(calendars) == 0 {
+		return types.Calendar{}, customErrors.ErrNotFound
+	}
+
+	return types.Calendar(*calendars[0]), nil
+}
+
+func (pg Db) AddCalendar(ctx context.Context, c types.Calendar, login string) (int64, error) {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+	var id int64
+
+	ib.InsertInto("calendars")
+	ib.Cols("name", "color", "timezone", "visibility", "is_default", "owner_id")
+	ib.Values(c.Name, c.Color, c.Timezone, c.Visibility, c.Default, ownerID(login))
+	ib.SQL("RETURNING id")
+
+	q, args := ib.Build()
+
+	err := pg.conn(ctx).QueryRow(ctx, q, args...).Scan(&id)
+	if err != nil {
+		return id, writeError(err)
+	}
+
+	return id, nil
+}
+
+func (pg Db) UpdateCalendar(ctx context.Context, c types.Calendar, id int64, login string) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("calendars")
+
+	// The ID is assigned to itself, so that an update without fields still tells whether the calendar exists
+	ub.SetMore(ub.Assign("id", sqlbuilder.Raw("id")))
+
+	if c.Name != "" {
+		ub.SetMore(ub.Assign("name", c.Name))
+	}
+
+	if c.Color != "" {
+		ub.SetMore(ub.Assign("color", c.Color))
+	}
+
+	if c.Timezone != "" {
+		ub.SetMore(ub.Assign("timezone", c.Timezone))
+	}
+
+	if c.Visibility != "" {
+		ub.SetMore(ub.Assign("visibility", c.Visibility))
+	}
+
+	ub.Where(ub.Equal("id", id), ub.Equal("owner_id", ownerID(login)))
+
+	q, args := ub.Build()
+
+	tag, err := pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return writeError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (pg Db) DeleteCalendar(ctx context.Context, id, moveTo int64, login string) error {
+	err := pg.ownCalendar(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	if moveTo != 0 {
+		err = pg.ownCalendar(ctx, moveTo, login)
+		if err != nil {
+			return err
+		}
+
+		ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+		ub.Update("events")
+		ub.Set(ub.Assign("calendar_id", moveTo))
+		ub.Where(ub.Equal("calendar_id", id), ub.Equal("owner_id", ownerID(login)))
+
+		q, args := ub.Build()
+
+		_, err = pg.conn(ctx).Exec(ctx, q, args...)
+		if err != nil {
+			return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+		}
+	}
+
+	// Events left in the calendar are deleted together with it
+	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+
+	db.DeleteFrom("calendars")
+	db.Where(db.Equal("id", id), db.Equal("owner_id", ownerID(login)))
+
+	q, args := db.Build()
+
+	_, err = pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+func (pg Db) GetShares(ctx context.Context, calendarID int64, login string) ([]types.Share, error) {
+	var s []types.Share
+	var shares []*shareDb
+
+	err := pg.ownCalendar(ctx, calendarID, login)
+	if err != nil {
+		return s, err
+	}
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select("users.login", "calendar_shares.level")
+	sb.From("calendar_shares")
+	sb.Join("users", "users.id = calendar_shares.user_id")
+	sb.Where(sb.Equal("calendar_shares.calendar_id", calendarID))
+	sb.OrderBy("users.login")
+
+	q, args := sb.Build()
+
+	err = pgxscan.Select(ctx, pg.conn(ctx), &shares, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, share := range shares {
+		s = append(s, types.Share(*share))
+	}
+
+	return s, nil
+}
+
+func (pg Db) SetShare(ctx context.Context, calendarID int64, s types.Share, login string) error {
+	err := pg.ownCalendar(ctx, calendarID, login)
+	if err != nil {
+		return err
+	}
+
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	ib.InsertInto("calendar_shares")
+	ib.Cols("calendar_id", "user_id", "level")
+	ib.Values(calendarID, ownerID(s.Login), s.Level)
+	ib.SQL("ON CONFLICT (calendar_id, user_id) DO UPDATE SET level = EXCLUDED.level")
+
+	q, args := ib.Build()
+
+	_, err = pg.conn(ctx).Exec(ctx, q, args...)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == notNullViolation {
+		return fmt.Errorf("%w: user %q does not exist", customErrors.ErrNotFound, s.Login)
+	}
+
+	if err != nil {
+		return writeError(err)
+	}
+
+	return nil
+}
+
+func (pg Db) DeleteShare(ctx context.Context, calendarID int64, grantee, login string) error {
+	err := pg.ownCalendar(ctx, calendarID, login)
+	if err != nil {
+		return err
+	}
+
+	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+
+	db.DeleteFrom("calendar_shares")
+	db.Where(db.Equal("calendar_id", calendarID), db.Equal("user_id", ownerID(grantee)))
+
+	q, args := db.Build()
+
+	tag, err := pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// ownCalendar returns ErrNotFound unless the user owns the calendar, calendars shared with the user do not count
+func (pg Db) ownCalendar(ctx context.Context, id int64, login string) error {
+	c, err := pg.GetCalendar(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	if c.Owner != login {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (pg Db) GetAttendees(ctx context.Context, ids []int64, login string) (map[int64][]types.Attendee, error) {
+	attendees := make(map[int64][]types.Attendee)
+	var rows []*attendeeDb
+
+	if len(ids) == 0 {
+		return attendees, nil
+	}
+
+	eventIDs := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		eventIDs = append(eventIDs, id)
+	}
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select("event_attendees.event_id", "COALESCE(users.login, '') AS login",
+		"COALESCE(event_attendees.email, '') AS email", "event_attendees.status")
+	sb.From("event_attendees")
+	sb.Join("events", "events.id = event_attendees.event_id")
+	sb.JoinWithOption(sqlbuilder.LeftJoin, "users", "users.id = event_attendees.user_id")
+	sb.Where(sb.In("event_attendees.event_id", eventIDs...), sb.Equal("events.owner_id", ownerID(login)))
+	sb.OrderBy("event_attendees.event_id", "event_attendees.position")
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &rows, q, args...)
+	if err != nil {
+		return attendees, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, a := range rows {
+		attendees[a.EventID] = append(attendees[a.EventID], types.Attendee{Login: a.Login, Email: a.Email,
+			Status: a.Status})
+	}
+
+	return attendees, nil
+}
+
+// SetAttendees replaces attendees of the event in a single transaction, users are invited by their IDs
+// and the CHECK constraint of the table rejects logins no user has
+func (pg Db) SetAttendees(ctx context.Context, id int64, attendees []types.Attendee, login string) error {
+	exists, err := pg.exists(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return customErrors.ErrNotFound
+	}
+
+	return pg.InTransaction(ctx, func(ctx context.Context) error {
+		db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+
+		db.DeleteFrom("event_attendees")
+		db.Where(db.Equal("event_id", id))
+
+		q, args := db.Build()
+
+		_, err := pg.conn(ctx).Exec(ctx, q, args...)
+		if err != nil {
+			return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+		}
+
+		if len(attendees) == 0 {
+			return nil
+		}
+
+		ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+		ib.InsertInto("event_attendees")
+		ib.Cols("event_id", "position", "user_id", "email", "status")
+		for i, a := range attendees {
+			var user, email interface{}
+			if a.Login != "" {
+				user = ownerID(a.Login)
+			}
+			if a.Email != "" {
+				email = a.Email
+			}
+
+			ib.Values(id, i, user, email, a.Status)
+		}
+
+		q, args = ib.Build()
+
+		_, err = pg.conn(ctx).Exec(ctx, q, args...)
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == checkViolation {
+			return fmt.Errorf("%w: an invited user does not exist", customErrors.ErrBadRequest)
+		}
+
+		if err != nil {
+			return writeError(err)
+		}
+
+		return nil
+	})
+}
+
+// GetInvitations returns events the user is invited to ordered by their start time
+func (pg Db) GetInvitations(ctx context.Context, login string) ([]types.Invitation, error) {
+	return pg.getInvitations(ctx, invitationsBuilder(login))
+}
+
+func (pg Db) GetInvitation(ctx context.Context, id int64, login string) (types.Invitation, error) {
+	sb := invitationsBuilder(login)
+	sb.Where(sb.Equal("events.id", id))
+
+	invitations, err := pg.getInvitations(ctx, sb)
+	if err != nil {
+		return types.Invitation{}, err
+	}
+
+	if len(invitations) == 0 {
+		return types.Invitation{}, customErrors.ErrNotFound
+	}
+
+	return invitations[0], nil
+}
+
+func (pg Db) SetRSVP(ctx context.Context, id int64, status types.RSVPStatus, login string) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("event_attendees")
+	ub.Set(ub.Assign("status", status))
+	ub.Where(ub.Equal("event_id", id), ub.Equal("user_id", ownerID(login)))
+
+	q, args := ub.Build()
+
+	tag, err := pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// invitationsBuilder selects events the user attends with the login of their organizer and the answer of the user
+func invitationsBuilder(login string) *sqlbuilder.SelectBuilder {
+	columns := make([]string, 0, len(eventColumns)+2)
+	for _, c := range eventColumns {
+		columns = append(columns, "events."+c)
+	}
+	columns = append(columns, "users.login AS organizer", "event_attendees.status AS rsvp_status")
+
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+
+	sb.Select(columns...)
+	sb.From("event_attendees")
+	sb.Join("events", "events.id = event_attendees.event_id")
+	sb.Join("users", "users.id = events.owner_id")
+	sb.Where(sb.Equal("event_attendees.user_id", ownerID(login)))
+	sb.OrderBy("events.startTime", "events.id")
+
+	return sb
+}
+
+func (pg Db) getInvitations(ctx context.Context, sb *sqlbuilder.SelectBuilder) ([]types.Invitation, error) {
+	var s []types.Invitation
+	var invitations []*invitationDb
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.conn(ctx), &invitations, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, i := range invitations {
+		s = append(s, types.Invitation{Event: types.Event(i.eventDb), Status: i.Status})
+	}
+
+	return s, nil
+}
+
+func (pg Db) exists(ctx context.Context, id int64, login string) (bool, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var exists bool
+
+	sb.Select("EXISTS(select 1 from events)")
+	sb.From("events")
+	sb.Where(sb.Equal("id", id), sb.Equal("owner_id", ownerID(login)))
+
+	q, args := sb.Build()
+
+	rows, err := pg.conn(ctx).Query(ctx, q, args...)
+	if err != nil {
+		return exists, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for rows.Next() {
+
+		values, err := rows.Values()
+		if err != nil {
+			return exists, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+		}
+		exists = values[0].(bool)
+	}
+
+	return exists, nil
+}
+
+// AssignUnownedEvents hands events created before ownership was introduced over to the user, into their default
+// calendar, and returns the number of them. Migrations leave such events without an owner, so that they stay
+// invisible until an operator chooses who they belong to.
+func (pg Db) AssignUnownedEvents(ctx context.Context, login string) (int64, error) {
+	c, err := pg.GetDefaultCalendar(ctx, login)
+	if err != nil {
+		return 0, err
+	}
+
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("events")
+	ub.Set(ub.Assign("owner_id", ownerID(login)), ub.Assign("calendar_id", c.ID))
+	ub.Where(ub.IsNull("owner_id"))
+
+	q, args := ub.Build()
+
+	tag, err := pg.conn(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ownerID resolves a login into the users.id it belongs to, so that every query
+// can be scoped to the events owned by the currently authenticated user.
+func ownerID(login string) sqlbuilder.Builder {
+	return sqlbuilder.Buildf("(SELECT id FROM users WHERE login = %v)", login)
 }
@@ -2,12 +2,26 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/types"
+	usersPostgres "github.com/bubo-py/McK/users/repositories/postgres"
+)
+
+const (
+	login      = "events-owner"
+	otherLogin = "events-other"
+	pageLogin  = "events-pages"
+
+	// legacyLogin is given events without an owner, see TestPostgresDb_AssignUnownedEvents
+	legacyLogin = "events-legacy"
 )
 
 func TestMain(m *testing.M) {
@@ -19,10 +33,27 @@ func TestMain(m *testing.M) {
 		log.Fatalf("Could not initialize database: %v", err)
 	}
 
-	_, _ = db.pool.Exec(ctx, "DROP TABLE events")
+	// Events reference their owners, so the users domain has to be migrated first
+	usersDb, err := usersPostgres.Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		log.Fatalf("Could not initialize database: %v", err)
+	}
+	_ = usersPostgres.RunMigration(ctx, usersDb)
+
+	_, _ = db.pool.Exec(ctx, "DROP TABLE events CASCADE")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE calendar_shares")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE calendars")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE event_exceptions")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE event_attendees")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE event_alerts")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE webhook_outbox")
 	_, _ = db.pool.Exec(ctx, "DROP TABLE events_migration")
 	_ = RunMigration(ctx, db)
 
+	for _, l := range []string{login, otherLogin, pageLogin, legacyLogin} {
+		_, _ = db.pool.Exec(ctx, "INSERT INTO users (login, password, timezone) VALUES ($1, 'hash', 'UTC') ON CONFLICT DO NOTHING", l)
+	}
+
 	code := m.Run()
 
 	// Tear down
@@ -64,18 +95,18 @@ func TestPostgresDb_GetEvent(t *testing.T) {
 		AlertTime:   ti2,
 	}
 
-	err = db.AddEvent(ctx, event)
+	_, err = db.AddEvent(ctx, event, login)
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = db.AddEvent(ctx, event2)
+	_, err = db.AddEvent(ctx, event2, login)
 	if err != nil {
 		t.Error(err)
 	}
 
 	var id int64 = 1
-	e, err := db.GetEvent(ctx, id)
+	e, err := db.GetEvent(ctx, id, login)
 	if err != nil {
 		t.Error(err)
 	}
@@ -84,12 +115,12 @@ func TestPostgresDb_GetEvent(t *testing.T) {
 		t.Error("Failed to fetch an event with given id")
 	}
 
-	err = db.UpdateEvent(ctx, event, 20)
+	err = db.UpdateEvent(ctx, types.EventUpdate{Event: event}, 20, login)
 	if err == nil {
 		t.Errorf("Error is nil, should have: %s", "event with specified id not found")
 	}
 
-	events, err := db.GetEventsFiltered(ctx, f)
+	events, err := db.GetEventsFiltered(ctx, f, time.UTC, login)
 	if err != nil {
 		t.Error(err)
 	}
@@ -127,27 +158,27 @@ func TestPostgresDb_DeleteEvent(t *testing.T) {
 		AlertTime:   ti2,
 	}
 
-	err = db.AddEvent(ctx, event)
+	_, err = db.AddEvent(ctx, event, login)
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = db.AddEvent(ctx, event2)
+	_, err = db.AddEvent(ctx, event2, login)
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = db.DeleteEvent(ctx, 2)
+	err = db.DeleteEvent(ctx, 2, login)
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = db.DeleteEvent(ctx, 2)
+	err = db.DeleteEvent(ctx, 2, login)
 	if err == nil {
 		t.Errorf("Error is nil, should have: %s", "event with specified id not found")
 	}
 
-	e, err := db.GetEvents(ctx)
+	e, err := db.GetEvents(ctx, login)
 	if err != nil {
 		t.Error(err)
 	}
@@ -160,3 +191,928 @@ func TestPostgresDb_DeleteEvent(t *testing.T) {
 		t.Errorf("Events added incorrectly, should have less than 5, got: %d", len(e))
 	}
 }
+
+func TestPostgresDb_EventOwnership(t *testing.T) {
+	ti := time.Date(2021, 3, 10, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	event := types.Event{
+		Name:      "Private meeting",
+		StartTime: ti,
+		EndTime:   ti,
+	}
+
+	_, err = db.AddEvent(ctx, event, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	events, err := db.GetEvents(ctx, otherLogin)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("Failed to fetch events of their owner: got: %v, error: %v", events, err)
+	}
+	id := events[0].ID
+
+	events, err = db.GetEvents(ctx, login)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, e := range events {
+		if e.ID == id {
+			t.Errorf("Event of another user should not be listed")
+		}
+	}
+
+	_, err = db.GetEvent(ctx, id, login)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Should return a different error: got %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Name: "Hijacked"}}, id, login)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Should return a different error: got %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.DeleteEvent(ctx, id, login)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Should return a different error: got %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+}
+
+func TestPostgresDb_GetEventsInRange(t *testing.T) {
+	ti := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	events := []types.Event{
+		{Name: "Range before", StartTime: ti.AddDate(0, 0, -7), EndTime: ti.AddDate(0, 0, -7)},
+		{Name: "Range inside", StartTime: ti, EndTime: ti.Add(time.Hour)},
+		{Name: "Range series", StartTime: ti.AddDate(0, -1, 0), EndTime: ti.AddDate(0, -1, 0), Recurrence: "FREQ=DAILY"},
+	}
+
+	for _, e := range events {
+		_, err = db.AddEvent(ctx, e, otherLogin)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	e, err := db.GetEventsInRange(ctx, ti.Add(-time.Hour), ti.Add(2*time.Hour), otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(e) != 2 || e[0].Name != "Range series" || e[1].Name != "Range inside" {
+		t.Errorf("Failed to fetch events in range: got: %v", e)
+	}
+}
+
+func TestPostgresDb_GetEventsFilteredInLocation(t *testing.T) {
+	// 20:30 UTC on 31 December 2019 is already 1 January 2020 in Tokyo
+	ti := time.Date(2019, 12, 31, 20, 30, 0, 0, time.UTC)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.AddEvent(ctx, types.Event{Name: "New Year in Tokyo", StartTime: ti, EndTime: ti}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e, err := db.GetEventsFiltered(ctx, types.Filters{Day: 1, Month: 1, Year: 2020}, tokyo, otherLogin)
+	if err != nil || len(e) != 1 || e[0].Name != "New Year in Tokyo" {
+		t.Errorf("Failed to filter events in the location: got: %v, error: %v", e, err)
+	}
+
+	e, err = db.GetEventsFiltered(ctx, types.Filters{Day: 1, Month: 1, Year: 2020}, time.UTC, otherLogin)
+	if err != nil || len(e) != 0 {
+		t.Errorf("Event should not match the filters in UTC: got: %v, error: %v", e, err)
+	}
+}
+
+func TestPostgresDb_GetEventsPage(t *testing.T) {
+	ti := time.Date(2021, 8, 1, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Events starting at the same time are ordered by their IDs
+	var ids []int64
+	for _, e := range []types.Event{
+		{Name: "Page C", StartTime: ti, EndTime: ti},
+		{Name: "Page A", StartTime: ti, EndTime: ti},
+		{Name: "Page B", StartTime: ti.AddDate(0, 0, -1), EndTime: ti.AddDate(0, 0, -1)},
+	} {
+		id, err := db.AddEvent(ctx, e, pageLogin)
+		if err != nil {
+			t.Error(err)
+		}
+		ids = append(ids, id)
+	}
+
+	e, err := db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortStartTime, Limit: 2}, pageLogin)
+	if err != nil || len(e) != 2 || e[0].Name != "Page B" || e[1].Name != "Page C" {
+		t.Errorf("Failed to get the first page: got: %v, error: %v", e, err)
+	}
+
+	key := types.PageKey{Name: e[1].Name, StartTime: e[1].StartTime, ID: e[1].ID}
+	e, err = db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortStartTime, After: &key, Limit: 2}, pageLogin)
+	if err != nil || len(e) != 1 || e[0].Name != "Page A" {
+		t.Errorf("Failed to get the page after the key: got: %v, error: %v", e, err)
+	}
+
+	e, err = db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortName, Desc: true, After: &key, Limit: 5}, pageLogin)
+	if err != nil || len(e) != 2 || e[0].Name != "Page B" || e[1].Name != "Page A" {
+		t.Errorf("Failed to get the page in descending order: got: %v, error: %v", e, err)
+	}
+
+	// Events of any of the calendars or with any of the IDs are selected
+	e, err = db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortID, Limit: 5, CalendarIDs: []int64{-1},
+		EventIDs: []int64{ids[2], ids[0]}}, pageLogin)
+	if err != nil || len(e) != 2 || e[0].Name != "Page C" || e[1].Name != "Page B" {
+		t.Errorf("Failed to narrow the page to the events: got: %v, error: %v", e, err)
+	}
+}
+
+func TestPostgresDb_EventExceptions(t *testing.T) {
+	ti := time.Date(2021, 7, 5, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.AddEvent(ctx, types.Event{Name: "Exceptions series", StartTime: ti, EndTime: ti,
+		Recurrence: "FREQ=DAILY"}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	events, err := db.GetEvents(ctx, otherLogin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id int64
+	for _, e := range events {
+		if e.Name == "Exceptions series" {
+			id = e.ID
+		}
+	}
+
+	ex := types.EventException{EventID: id, OriginalStart: ti.AddDate(0, 0, 1), Cancelled: true, StartTime: ti,
+		EndTime: ti}
+
+	err = db.SaveEventException(ctx, ex, login)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Should return a different error: got %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	for _, e := range []types.EventException{ex, {EventID: id, OriginalStart: ti.AddDate(0, 0, 1), Name: "Moved",
+		StartTime: ti, EndTime: ti}, {EventID: id, OriginalStart: ti.AddDate(0, 0, 3), StartTime: ti, EndTime: ti}} {
+		err = db.SaveEventException(ctx, e, otherLogin)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	exceptions, err := db.GetEventExceptions(ctx, []int64{id}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(exceptions) != 2 || exceptions[0].Cancelled || exceptions[0].Name != "Moved" {
+		t.Errorf("Failed to replace an exception of the same occurrence: got: %v", exceptions)
+	}
+
+	exceptions, err = db.GetEventExceptions(ctx, []int64{id}, login)
+	if err != nil || len(exceptions) != 0 {
+		t.Errorf("Exceptions of another user's event should not be listed: got: %v, error: %v", exceptions, err)
+	}
+
+	err = db.SplitEvent(ctx, id, ti.AddDate(0, 0, 2), "FREQ=DAILY;COUNT=2", otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	exceptions, err = db.GetEventExceptions(ctx, []int64{id}, otherLogin)
+	if err != nil || len(exceptions) != 1 {
+		t.Errorf("Failed to drop exceptions of the following occurrences: got: %v, error: %v", exceptions, err)
+	}
+
+	e, err := db.GetEvent(ctx, id, otherLogin)
+	if err != nil || e.Recurrence != "FREQ=DAILY;COUNT=2" {
+		t.Errorf("Failed to truncate the series: got: %v, error: %v", e, err)
+	}
+
+	err = db.SplitEvent(ctx, id, ti, "", otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.GetEvent(ctx, id, otherLogin)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Series split at its first occurrence should be removed: got %v", err)
+	}
+}
+
+func TestPostgresDb_GetEventByUID(t *testing.T) {
+	ti := time.Date(2021, 8, 2, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.AddEvent(ctx, types.Event{Name: "Imported", StartTime: ti, EndTime: ti, UID: "imported@example.com"},
+		otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e, err := db.GetEventByUID(ctx, "imported@example.com", otherLogin)
+	if err != nil || e.Name != "Imported" {
+		t.Errorf("Failed to fetch an event by its UID: got: %v, error: %v", e, err)
+	}
+
+	_, err = db.GetEventByUID(ctx, "imported@example.com", login)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Should return a different error: got %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	_, err = db.AddEvent(ctx, types.Event{Name: "Imported twice", StartTime: ti, EndTime: ti,
+		UID: "imported@example.com"}, otherLogin)
+	if err == nil {
+		t.Error("Error is nil, UIDs should be unique among events of their owner")
+	}
+
+	// Events created through the API have no UID, they must not conflict with each other
+	for i := 0; i < 2; i++ {
+		_, err = db.AddEvent(ctx, types.Event{Name: "Without UID", StartTime: ti, EndTime: ti}, otherLogin)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestPostgresDb_EventTimezone(t *testing.T) {
+	ti := time.Date(2021, 8, 9, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	id, err := db.AddEvent(ctx, types.Event{Name: "Flight", StartTime: ti, EndTime: ti, Timezone: "Asia/Tokyo"},
+		otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Updates without a timezone keep the stored one
+	err = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Name: "Delayed flight"}}, id, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e, err := db.GetEvent(ctx, id, otherLogin)
+	if err != nil || e.Timezone != "Asia/Tokyo" {
+		t.Errorf("Failed to keep the timezone of the event: got: %v, error: %v", e, err)
+	}
+
+	err = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Timezone: "Europe/Warsaw"}}, id, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e, err = db.GetEvent(ctx, id, otherLogin)
+	if err != nil || e.Timezone != "Europe/Warsaw" {
+		t.Errorf("Failed to update the timezone of the event: got: %v, error: %v", e, err)
+	}
+}
+
+func TestPostgresDb_AllDayEvents(t *testing.T) {
+	d := time.Date(2021, 8, 16, 0, 0, 0, 0, time.UTC)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	events := []types.Event{
+		{Name: "All-day holiday", StartTime: d, EndTime: d.AddDate(0, 0, 1), AllDay: true},
+		{Name: "All-day vacation", StartTime: d.AddDate(0, 0, 7), EndTime: d.AddDate(0, 0, 12), AllDay: true},
+	}
+
+	var ids []int64
+	for _, e := range events {
+		id, err := db.AddEvent(ctx, e, otherLogin)
+		if err != nil {
+			t.Error(err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Dates of all-day events are not shifted to the location, multi-day events are returned for every day
+	e, err := db.GetEventsFiltered(ctx, types.Filters{Day: 16, Month: 8, Year: 2021}, tokyo, otherLogin)
+	if err != nil || len(e) != 2 || e[0].Name != "All-day holiday" || !e[0].AllDay || !e[0].StartTime.Equal(d) {
+		t.Errorf("Failed to filter all-day events: got: %v, error: %v", e, err)
+	}
+
+	e, err = db.GetEventsInRange(ctx, d.Add(-12*time.Hour), d.Add(-time.Hour), otherLogin)
+	if err != nil || len(e) != 1 || e[0].Name != "All-day holiday" {
+		t.Errorf("Failed to fetch all-day events in range: got: %v, error: %v", e, err)
+	}
+
+	e, err = db.GetEventsInRange(ctx, d.AddDate(0, 0, 14), d.AddDate(0, 0, 15), otherLogin)
+	if err != nil || len(e) != 0 {
+		t.Errorf("Failed to skip all-day events out of range: got: %v, error: %v", e, err)
+	}
+
+	// Turning an all-day event into a timed one clears its dates
+	timed := false
+	start := d.AddDate(0, 0, 7).Add(9 * time.Hour)
+
+	err = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{StartTime: start, EndTime: start.Add(time.Hour)},
+		AllDay: &timed}, ids[1], otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	vacation, err := db.GetEvent(ctx, ids[1], otherLogin)
+	if err != nil || vacation.AllDay || !vacation.StartTime.Equal(start) ||
+		!vacation.EndTime.Equal(start.Add(time.Hour)) {
+		t.Errorf("Failed to turn the all-day event into a timed one: got: %v, error: %v", vacation, err)
+	}
+
+	var startDate, endDate *time.Time
+	err = db.pool.QueryRow(ctx, "SELECT start_date, end_date FROM events WHERE id = $1", ids[1]).
+		Scan(&startDate, &endDate)
+	if err != nil || startDate != nil || endDate != nil {
+		t.Errorf("Failed to clear dates of the timed event: got: %v, %v, error: %v", startDate, endDate, err)
+	}
+}
+
+func TestPostgresDb_AssignUnownedEvents(t *testing.T) {
+	ti := time.Date(2021, 9, 5, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Events created before ownership was introduced are left without an owner by the migrations
+	var id int64
+	err = db.pool.QueryRow(ctx, "INSERT INTO events (name, startTime, endTime) VALUES ('Legacy', $1, $2) RETURNING id",
+		ti, ti.Add(time.Hour)).Scan(&id)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.GetEvent(ctx, id, legacyLogin)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Events without an owner should be invisible: got: %v", err)
+	}
+
+	n, err := db.AssignUnownedEvents(ctx, legacyLogin)
+	if err != nil || n != 1 {
+		t.Errorf("Failed to assign events without an owner: got: %d, error: %v", n, err)
+	}
+
+	c, err := db.GetDefaultCalendar(ctx, legacyLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e, err := db.GetEvent(ctx, id, legacyLogin)
+	if err != nil || e.Name != "Legacy" || e.CalendarID != c.ID {
+		t.Errorf("Failed to get an assigned event in the default calendar: got: %v, error: %v", e, err)
+	}
+
+	n, err = db.AssignUnownedEvents(ctx, login)
+	if err != nil || n != 0 {
+		t.Errorf("Assigned events should not be assigned again: got: %d, error: %v", n, err)
+	}
+}
+
+func TestPostgresDb_PartialUpdate(t *testing.T) {
+	ti := time.Date(2021, 8, 23, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	id, err := db.AddEvent(ctx, types.Event{Name: "Partial update", StartTime: ti, EndTime: ti.Add(time.Hour),
+		Description: "A meeting"}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Description: "Another meeting"}}, id, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e, err := db.GetEvent(ctx, id, otherLogin)
+	if err != nil || e.Name != "Partial update" || e.Description != "Another meeting" {
+		t.Errorf("Failed to update the description only: got: %v, error: %v", e, err)
+	}
+
+	// Values too long for their columns are bad requests, not unexpected errors
+	err = db.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Description: strings.Repeat("a", 511)}}, id,
+		otherLogin)
+	if !errors.Is(err, customErrors.ErrBadRequest) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrBadRequest)
+	}
+
+	_, err = db.AddEvent(ctx, types.Event{Name: strings.Repeat("a", 256), StartTime: ti, EndTime: ti}, otherLogin)
+	if !errors.Is(err, customErrors.ErrBadRequest) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrBadRequest)
+	}
+}
+
+func TestPostgresDb_Calendars(t *testing.T) {
+	ti := time.Date(2021, 9, 1, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	def, err := db.GetDefaultCalendar(ctx, otherLogin)
+	if err != nil || !def.Default || def.Name != types.DefaultCalendarName {
+		t.Errorf("Failed to create the default calendar: got: %v, error: %v", def, err)
+	}
+
+	again, err := db.GetDefaultCalendar(ctx, otherLogin)
+	if err != nil || again.ID != def.ID {
+		t.Errorf("Failed to get the existing default calendar: got: %v, error: %v", again, err)
+	}
+
+	work, err := db.AddCalendar(ctx, types.Calendar{Name: "Work", Visibility: types.VisibilityFreeBusy}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.GetCalendar(ctx, work, login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.UpdateCalendar(ctx, types.Calendar{Color: "#00FF00"}, work, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	c, err := db.GetCalendar(ctx, work, otherLogin)
+	if err != nil || c.Name != "Work" || c.Color != "#00FF00" {
+		t.Errorf("Failed to keep fields missing from the update: got: %v, error: %v", c, err)
+	}
+
+	err = db.UpdateCalendar(ctx, types.Calendar{Name: "Stolen"}, work, login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	moved, err := db.AddEvent(ctx, types.Event{Name: "Moved", StartTime: ti, EndTime: ti, CalendarID: work}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e, err := db.GetEventsPage(ctx, types.PageQuery{Sort: types.SortStartTime, Limit: 10, CalendarID: work},
+		otherLogin)
+	if err != nil || len(e) != 1 || e[0].ID != moved {
+		t.Errorf("Failed to get events of the calendar: got: %v, error: %v", e, err)
+	}
+
+	err = db.DeleteCalendar(ctx, work, def.ID, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	event, err := db.GetEvent(ctx, moved, otherLogin)
+	if err != nil || event.CalendarID != def.ID {
+		t.Errorf("Failed to move events to the default calendar: got: %v, error: %v", event, err)
+	}
+
+	trash, err := db.AddCalendar(ctx, types.Calendar{Name: "Trash", Visibility: types.VisibilityPrivate}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleted, err := db.AddEvent(ctx, types.Event{Name: "Deleted", StartTime: ti, EndTime: ti, CalendarID: trash},
+		otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.DeleteCalendar(ctx, trash, 0, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.GetEvent(ctx, deleted, otherLogin)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Failed to delete events together with the calendar: got error: %v", err)
+	}
+}
+
+func TestPostgresDb_Shares(t *testing.T) {
+	ti := time.Date(2021, 9, 3, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	team, err := db.AddCalendar(ctx, types.Calendar{Name: "Team", Visibility: types.VisibilityFreeBusy}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	id, err := db.AddEvent(ctx, types.Event{Name: "Planning", StartTime: ti, EndTime: ti, CalendarID: team},
+		otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.GetEventCalendar(ctx, id, login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.SetShare(ctx, team, types.Share{Login: "nobody", Level: types.ShareRead}, otherLogin)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.SetShare(ctx, team, types.Share{Login: login, Level: types.ShareRead}, login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.SetShare(ctx, team, types.Share{Login: login, Level: types.ShareRead}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.SetShare(ctx, team, types.Share{Login: login, Level: types.ShareWrite}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	shares, err := db.GetShares(ctx, team, otherLogin)
+	if err != nil || len(shares) != 1 || shares[0] != (types.Share{Login: login, Level: types.ShareWrite}) {
+		t.Errorf("Failed to change the level of the share: got: %v, error: %v", shares, err)
+	}
+
+	c, err := db.GetCalendar(ctx, team, login)
+	if err != nil || c.Owner != otherLogin || c.Access != types.ShareWrite {
+		t.Errorf("Failed to get the calendar shared with the user: got: %v, error: %v", c, err)
+	}
+
+	calendars, err := db.GetCalendars(ctx, login)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var shared []types.Calendar
+	for _, c := range calendars {
+		if c.Owner != login {
+			shared = append(shared, c)
+		}
+	}
+
+	if len(shared) != 1 || shared[0].ID != team {
+		t.Errorf("Failed to list calendars shared with the user: got: %v", calendars)
+	}
+
+	c, err = db.GetEventCalendar(ctx, id, login)
+	if err != nil || c.ID != team || c.Access != types.ShareWrite {
+		t.Errorf("Failed to get the calendar of a shared event: got: %v, error: %v", c, err)
+	}
+
+	err = db.DeleteShare(ctx, team, login, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.DeleteShare(ctx, team, login, otherLogin)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	_, err = db.GetCalendar(ctx, team, login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+}
+
+func TestPostgresDb_Attendees(t *testing.T) {
+	ti := time.Date(2021, 9, 4, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	id, err := db.AddEvent(ctx, types.Event{Name: "Review", StartTime: ti, EndTime: ti}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.SetAttendees(ctx, id, []types.Attendee{{Login: "nobody", Status: types.RSVPNeedsAction}}, otherLogin)
+	if !errors.Is(err, customErrors.ErrBadRequest) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrBadRequest)
+	}
+
+	attendees := []types.Attendee{{Login: login, Status: types.RSVPNeedsAction},
+		{Email: "guest@example.com", Status: types.RSVPNeedsAction}}
+
+	err = db.SetAttendees(ctx, id, attendees, login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.SetAttendees(ctx, id, attendees, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	a, err := db.GetAttendees(ctx, []int64{id}, otherLogin)
+	if err != nil || !reflect.DeepEqual(a[id], attendees) {
+		t.Errorf("Failed to get attendees of the event: got: %v, expected: %v, error: %v", a[id], attendees, err)
+	}
+
+	i, err := db.GetInvitation(ctx, id, login)
+	if err != nil || i.Event.Name != "Review" || i.Event.Organizer != otherLogin || i.Status != types.RSVPNeedsAction {
+		t.Errorf("Failed to get the invitation: got: %v, error: %v", i, err)
+	}
+
+	err = db.SetRSVP(ctx, id, types.RSVPAccepted, login)
+	if err != nil {
+		t.Error(err)
+	}
+
+	invitations, err := db.GetInvitations(ctx, login)
+	if err != nil || len(invitations) != 1 || invitations[0].Status != types.RSVPAccepted {
+		t.Errorf("Failed to answer the invitation: got: %v, error: %v", invitations, err)
+	}
+
+	err = db.SetRSVP(ctx, id, types.RSVPAccepted, pageLogin)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+
+	err = db.DeleteEvent(ctx, id, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.GetInvitation(ctx, id, login)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return different error: got: %v, expected: %v", err, customErrors.ErrNotFound)
+	}
+}
+
+func TestPostgresDb_BusyEvents(t *testing.T) {
+	ti := time.Date(2021, 9, 5, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	private, err := db.AddCalendar(ctx, types.Calendar{Name: "Health", Visibility: types.VisibilityPrivate},
+		otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	events := []struct {
+		login string
+		e     types.Event
+	}{
+		{otherLogin, types.Event{Name: "Busy", StartTime: ti, EndTime: ti.Add(time.Hour)}},
+		{otherLogin, types.Event{Name: "Doctor", StartTime: ti, EndTime: ti.Add(time.Hour), CalendarID: private}},
+		{otherLogin, types.Event{Name: "Stand-up", StartTime: ti.AddDate(0, 0, -7), EndTime: ti.AddDate(0, 0, -7),
+			Recurrence: "FREQ=DAILY"}},
+		{pageLogin, types.Event{Name: "Interview", StartTime: ti, EndTime: ti.Add(time.Hour)}},
+	}
+
+	ids := make([]int64, len(events))
+	for i, tc := range events {
+		ids[i], err = db.AddEvent(ctx, tc.e, tc.login)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	err = db.SaveEventException(ctx, types.EventException{EventID: ids[2], OriginalStart: ti, Cancelled: true,
+		Name: "Stand-up", StartTime: ti, EndTime: ti}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.SetAttendees(ctx, ids[3], []types.Attendee{{Login: otherLogin, Status: types.RSVPAccepted}}, pageLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	s, err := db.GetBusyEvents(ctx, []string{otherLogin, "nobody"}, ti, ti.Add(time.Hour), login)
+	if err != nil {
+		t.Error(err)
+	}
+
+	busy := make(map[string]types.BusyEvent)
+	for _, e := range s {
+		if e.Login != otherLogin || e.Timezone != "UTC" {
+			t.Errorf("Failed to get the user and the timezone of a busy event: got: %v", e)
+		}
+		busy[e.Name] = e
+	}
+
+	for _, name := range []string{"Busy", "Stand-up", "Interview"} {
+		if _, ok := busy[name]; !ok {
+			t.Errorf("Failed to get events the user is busy with: %s is missing in %v", name, s)
+		}
+	}
+
+	if _, ok := busy["Doctor"]; ok {
+		t.Errorf("Events of private calendars should not be returned: got: %v", s)
+	}
+
+	if ex := busy["Stand-up"].Exceptions; len(ex) != 1 || !ex[0].Cancelled || !ex[0].OriginalStart.Equal(ti) {
+		t.Errorf("Failed to get exceptions of a recurring event: got: %v", ex)
+	}
+
+	s, err = db.GetBusyEvents(ctx, []string{otherLogin}, ti, ti.Add(time.Hour), otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	found := false
+	for _, e := range s {
+		found = found || e.CalendarID == private
+	}
+
+	if !found {
+		t.Errorf("Failed to get events of private calendars of the user: got: %v", s)
+	}
+}
+
+func TestPostgresDb_Timezones(t *testing.T) {
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	timezones, err := db.GetTimezones(ctx, []string{login, "nobody"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(timezones) != 1 || timezones[login] != "UTC" {
+		t.Errorf("Failed to get timezones of the users: got: %v", timezones)
+	}
+}
+
+func TestPostgresDb_Alerts(t *testing.T) {
+	ti := time.Date(2021, 9, 6, 8, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.AddEvent(ctx, types.Event{Name: "Alerting", StartTime: ti, EndTime: ti,
+		AlertTime: ti.Add(-10 * time.Minute), Timezone: "Asia/Tokyo"}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	events, err := db.GetAlertingEvents(ctx, ti.Add(-time.Hour), ti)
+	if err != nil || len(events) != 1 || events[0].Name != "Alerting" || events[0].Login != otherLogin {
+		t.Fatalf("Failed to fetch events alerting in the window: got: %v, error: %v", events, err)
+	}
+
+	if events[0].Timezone != "UTC" || events[0].Event.Timezone != "Asia/Tokyo" {
+		t.Errorf("Failed to fetch timezones of the owner and the event: got: %v", events[0])
+	}
+
+	alert := types.Alert{EventID: events[0].ID, OccurrenceStart: ti, AlertTime: ti.Add(-10 * time.Minute)}
+
+	// Scheduling the same alert twice keeps a single one
+	for i := 0; i < 2; i++ {
+		err = db.ScheduleAlerts(ctx, []types.Alert{alert})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	claimed, err := db.ClaimAlerts(ctx, ti.Add(-20*time.Minute), ti, 10)
+	if err != nil || len(claimed) != 0 {
+		t.Errorf("Alerts should not be claimed before they are due: got: %v, error: %v", claimed, err)
+	}
+
+	claimed, err = db.ClaimAlerts(ctx, ti, ti.Add(time.Minute), 10)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 || claimed[0].Timezone != "UTC" {
+		t.Fatalf("Failed to claim a due alert: got: %v, error: %v", claimed, err)
+	}
+
+	again, err := db.ClaimAlerts(ctx, ti, ti.Add(time.Minute), 10)
+	if err != nil || len(again) != 0 {
+		t.Errorf("Claimed alerts should be locked: got: %v, error: %v", again, err)
+	}
+
+	deliveredAt := ti
+	claimed[0].Status = types.AlertDelivered
+	claimed[0].DeliveredAt = &deliveredAt
+
+	err = db.UpdateAlert(ctx, claimed[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	again, err = db.ClaimAlerts(ctx, ti.Add(time.Hour), ti.Add(2*time.Hour), 10)
+	if err != nil || len(again) != 0 {
+		t.Errorf("Delivered alerts should not be claimed again: got: %v, error: %v", again, err)
+	}
+
+	// Series which ended before the window are left out, unless an exception alerts in it
+	start := ti.AddDate(0, -1, 0)
+	for _, e := range []types.Event{
+		{Name: "Ended", Recurrence: "FREQ=DAILY;COUNT=3"},
+		{Name: "Moved", Recurrence: "FREQ=DAILY;UNTIL=20210810"},
+		{Name: "Weekly", Recurrence: "FREQ=WEEKLY"},
+	} {
+		e.StartTime, e.EndTime, e.AlertTime = start, start.Add(time.Hour), start.Add(-10*time.Minute)
+
+		e.ID, err = db.AddEvent(ctx, e, otherLogin)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if e.Name == "Moved" {
+			err = db.SaveEventException(ctx, types.EventException{EventID: e.ID, OriginalStart: start.AddDate(0, 0, 1),
+				Name: "Moved", StartTime: ti, EndTime: ti.Add(time.Hour), AlertTime: ti.Add(-10 * time.Minute)},
+				otherLogin)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	events, err = db.GetAlertingEvents(ctx, ti.Add(-time.Hour), ti)
+	if err != nil {
+		t.Error(err)
+	}
+
+	names := make(map[string]types.AlertingEvent)
+	for _, e := range events {
+		names[e.Name] = e
+	}
+
+	if _, ok := names["Ended"]; ok {
+		t.Errorf("Series which ended should not alert: got: %v", events)
+	}
+
+	if _, ok := names["Weekly"]; !ok {
+		t.Errorf("Failed to fetch series recurring forever: got: %v", events)
+	}
+
+	if moved, ok := names["Moved"]; !ok || len(moved.Exceptions) != 1 || !moved.Exceptions[0].StartTime.Equal(ti) {
+		t.Errorf("Failed to fetch an ended series with an exception alerting in the window: got: %v", events)
+	}
+}
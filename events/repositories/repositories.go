@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/bubo-py/McK/types"
 )
@@ -9,10 +10,61 @@ import (
 //go:generate mockgen --build_flags=--mod=mod -destination=mocks/mockDatabase.go -package=mocks github.com/bubo-py/McK/events/repositories DatabaseRepository
 
 type DatabaseRepository interface {
-	GetEvents(ctx context.Context) ([]types.Event, error)
-	GetEventsFiltered(ctx context.Context, f types.Filters) ([]types.Event, error)
-	GetEvent(ctx context.Context, id int64) (types.Event, error)
-	AddEvent(ctx context.Context, e types.Event) error
-	DeleteEvent(ctx context.Context, id int64) error
-	UpdateEvent(ctx context.Context, e types.Event, id int64) error
+	GetEvents(ctx context.Context, login string) ([]types.Event, error)
+	GetEventsFiltered(ctx context.Context, f types.Filters, loc *time.Location, login string) ([]types.Event, error)
+	GetEventsInRange(ctx context.Context, from, to time.Time, login string) ([]types.Event, error)
+	GetEventsPage(ctx context.Context, q types.PageQuery, login string) ([]types.Event, error)
+	GetEvent(ctx context.Context, id int64, login string) (types.Event, error)
+	GetEventByUID(ctx context.Context, uid, login string) (types.Event, error)
+	AddEvent(ctx context.Context, e types.Event, login string) (int64, error)
+	DeleteEvent(ctx context.Context, id int64, login string) error
+	UpdateEvent(ctx context.Context, u types.EventUpdate, id int64, login string) error
+	GetEventExceptions(ctx context.Context, ids []int64, login string) ([]types.EventException, error)
+	SaveEventException(ctx context.Context, ex types.EventException, login string) error
+	SplitEvent(ctx context.Context, id int64, from time.Time, recurrence string, login string) error
+
+	// Calendars shared with the user are returned together with the user's ones, with the level of the share
+	GetCalendars(ctx context.Context, login string) ([]types.Calendar, error)
+	GetCalendar(ctx context.Context, id int64, login string) (types.Calendar, error)
+	// GetEventCalendar returns the calendar of the event, if the user owns it or it is shared with the user
+	GetEventCalendar(ctx context.Context, id int64, login string) (types.Calendar, error)
+	// GetDefaultCalendar creates the default calendar when the user has none yet
+	GetDefaultCalendar(ctx context.Context, login string) (types.Calendar, error)
+	AddCalendar(ctx context.Context, c types.Calendar, login string) (int64, error)
+	UpdateCalendar(ctx context.Context, c types.Calendar, id int64, login string) error
+	// DeleteCalendar moves events of the calendar to the moveTo one, or deletes them when moveTo is zero
+	DeleteCalendar(ctx context.Context, id, moveTo int64, login string) error
+
+	// Shares are managed with the login of the owner of the calendar
+	GetShares(ctx context.Context, calendarID int64, login string) ([]types.Share, error)
+	// SetShare grants the access or changes its level, ErrNotFound is returned when the grantee does not exist
+	SetShare(ctx context.Context, calendarID int64, s types.Share, login string) error
+	DeleteShare(ctx context.Context, calendarID int64, grantee, login string) error
+
+	// Attendees are managed with the login of the owner of the event, GetAttendees groups them by event ID
+	GetAttendees(ctx context.Context, ids []int64, login string) (map[int64][]types.Attendee, error)
+	// SetAttendees replaces attendees of the event, ErrBadRequest is returned when an invited user does not exist
+	SetAttendees(ctx context.Context, id int64, attendees []types.Attendee, login string) error
+
+	// Invitations are looked up with the login of the attendee, their events carry the login of the organizer
+	GetInvitations(ctx context.Context, login string) ([]types.Invitation, error)
+	GetInvitation(ctx context.Context, id int64, login string) (types.Invitation, error)
+	SetRSVP(ctx context.Context, id int64, status types.RSVPStatus, login string) error
+
+	// GetBusyEvents returns events overlapping the window which make the users busy, as seen by the user
+	// with the login: events of calendars the owners show free/busy time of or share with the user, all events
+	// of the user and events the users accepted invitations to
+	GetBusyEvents(ctx context.Context, logins []string, from, to time.Time, login string) ([]types.BusyEvent, error)
+	// GetTimezones returns timezones of the users by their logins, the ones of users who do not exist are missing
+	GetTimezones(ctx context.Context, logins []string) (map[string]string, error)
+
+	// InTransaction runs fn in a transaction, repository calls made with the context passed to fn are a part of it
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	AddOutboxMessage(ctx context.Context, m types.OutboxMessage, login string) error
+
+	// Alerts are dispatched for events of all users, so they are not scoped to a login
+	GetAlertingEvents(ctx context.Context, from, to time.Time) ([]types.AlertingEvent, error)
+	ScheduleAlerts(ctx context.Context, alerts []types.Alert) error
+	ClaimAlerts(ctx context.Context, now, lockedUntil time.Time, limit int) ([]types.Alert, error)
+	UpdateAlert(ctx context.Context, a types.Alert) error
 }
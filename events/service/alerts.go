@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+)
+
+// AlertsInterface is used by the alert dispatcher, unlike BusinessLogicInterface it works on events of all users
+type AlertsInterface interface {
+	PlanAlerts(ctx context.Context, from, to time.Time) error
+	ClaimAlerts(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]types.Alert, error)
+	FinishAlert(ctx context.Context, a types.Alert) error
+}
+
+// PlanAlerts schedules alerts of the occurrences alerting in the [from, to) window. Recurring events are expanded
+// in the timezone of their owner, an event which fails to expand is logged and skipped so that it does not hold up
+// alerts of the others. Planning is idempotent, alerts scheduled before are kept with their state.
+func (bl BusinessLogic) PlanAlerts(ctx context.Context, from, to time.Time) error {
+	events, err := bl.db.GetAlertingEvents(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	var alerts []types.Alert
+
+	for _, e := range events {
+		if e.Recurrence == "" {
+			alerts = append(alerts, types.Alert{EventID: e.ID, OccurrenceStart: e.StartTime, AlertTime: e.AlertTime})
+			continue
+		}
+
+		loc, err := time.LoadLocation(e.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		// An occurrence alerts in the window when it starts in the window shifted by the advance of the alert
+		advance := e.StartTime.Sub(e.AlertTime)
+
+		occurrences, err := expand(e.Event, e.Exceptions, from.Add(advance), to.Add(advance), loc)
+		if err != nil {
+			log.Printf("Skipping alerts of event %d: %v", e.ID, err)
+			continue
+		}
+
+		for _, o := range occurrences {
+			if o.AlertTime.IsZero() || o.AlertTime.Before(from) || !o.AlertTime.Before(to) {
+				continue
+			}
+
+			alerts = append(alerts, types.Alert{EventID: e.ID, OccurrenceStart: *o.OriginalStart, AlertTime: o.AlertTime})
+		}
+	}
+
+	return bl.db.ScheduleAlerts(ctx, alerts)
+}
+
+// ClaimAlerts claims due alerts for the lease and fills them with the details of their occurrences.
+// Alerts of occurrences changed since planned are cancelled instead of being returned.
+func (bl BusinessLogic) ClaimAlerts(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]types.Alert, error) {
+	var alerts []types.Alert
+
+	claimed, err := bl.db.ClaimAlerts(ctx, now, now.Add(lease), limit)
+	if err != nil {
+		return alerts, err
+	}
+
+	for _, a := range claimed {
+		current, err := bl.alertOccurrence(ctx, a)
+		if errors.Is(err, customErrors.ErrNotFound) {
+			a.Status = types.AlertCancelled
+			a.LastError = err.Error()
+
+			err = bl.db.UpdateAlert(ctx, a)
+			if err != nil {
+				return alerts, err
+			}
+			continue
+		}
+
+		if err != nil {
+			return alerts, err
+		}
+
+		alerts = append(alerts, current)
+	}
+
+	return alerts, nil
+}
+
+// FinishAlert records the outcome of the delivery of a claimed alert
+func (bl BusinessLogic) FinishAlert(ctx context.Context, a types.Alert) error {
+	return bl.db.UpdateAlert(ctx, a)
+}
+
+// alertOccurrence looks up the occurrence the alert was planned for in the calendar of its owner,
+// it is not found when the occurrence or its alert time have changed since then.
+func (bl BusinessLogic) alertOccurrence(ctx context.Context, a types.Alert) (types.Alert, error) {
+	ctx = contextHelpers.WriteLoginToContext(ctx, a.Login)
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, a.Timezone)
+
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return a, err
+	}
+
+	e, err := bl.db.GetEvent(ctx, a.EventID, a.Login)
+	if err != nil {
+		return a, err
+	}
+
+	o := e
+	if e.Recurrence != "" {
+		// Occurrences of all-day events start at midnight UTC of their date
+		start := a.OccurrenceStart.In(loc)
+		if e.AllDay {
+			start = a.OccurrenceStart.UTC()
+		}
+
+		_, o, err = bl.seriesOccurrence(ctx, e.ID, start, a.Login)
+		if err != nil {
+			return a, err
+		}
+	} else if !e.StartTime.Equal(a.OccurrenceStart) {
+		return a, fmt.Errorf("%w: event %d was moved", customErrors.ErrNotFound, e.ID)
+	}
+
+	if !o.AlertTime.Equal(a.AlertTime) {
+		return a, fmt.Errorf("%w: alert of event %d was changed", customErrors.ErrNotFound, e.ID)
+	}
+
+	o = eventInLocation(o, loc)
+	a.Name = o.Name
+	a.StartTime = o.StartTime
+	a.EndTime = o.EndTime
+	a.Description = o.Description
+
+	return a, nil
+}
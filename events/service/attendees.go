@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+)
+
+// rsvpStatuses are the statuses attendees can have
+var rsvpStatuses = map[types.RSVPStatus]bool{
+	types.RSVPNeedsAction: true,
+	types.RSVPAccepted:    true,
+	types.RSVPDeclined:    true,
+	types.RSVPTentative:   true,
+}
+
+// GetInvitations returns events of other users the user is invited to, the ones answered with the status
+// when it is given
+func (bl BusinessLogic) GetInvitations(ctx context.Context, status types.RSVPStatus) ([]types.Invitation, error) {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != "" && !rsvpStatuses[status] {
+		return nil, fmt.Errorf("%w: status should be one of needsAction, accepted, declined, tentative",
+			customErrors.ErrBadRequest)
+	}
+
+	invitations, err := bl.db.GetInvitations(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var s []types.Invitation
+	for _, i := range invitations {
+		if status != "" && i.Status != status {
+			continue
+		}
+
+		events, err := bl.withAttendees(ctx, []types.Event{i.Event}, i.Event.Organizer)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Event = eventInLocation(events[0], loc)
+		s = append(s, i)
+	}
+
+	return s, nil
+}
+
+// RSVP answers the invitation of the user to the event, the organizer is notified about the answer
+func (bl BusinessLogic) RSVP(ctx context.Context, id int64, status types.RSVPStatus) error {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if status == types.RSVPNeedsAction || !rsvpStatuses[status] {
+		return customErrors.ValidationError{Fields: []customErrors.FieldError{{Field: "status",
+			Code: customErrors.CodeInvalid, Message: "should be one of accepted, declined, tentative"}}}
+	}
+
+	i, err := bl.db.GetInvitation(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	return bl.db.InTransaction(ctx, func(ctx context.Context) error {
+		err := bl.db.SetRSVP(ctx, id, status, login)
+		if err != nil {
+			return err
+		}
+
+		return bl.emitUpdated(ctx, id, i.Event.Organizer)
+	})
+}
+
+// invitedEvent returns the event of another user the user is invited to, with the login of its organizer
+func (bl BusinessLogic) invitedEvent(ctx context.Context, id int64, login string) (types.Event, error) {
+	i, err := bl.db.GetInvitation(ctx, id, login)
+	if err != nil {
+		return types.Event{}, err
+	}
+
+	events, err := bl.withAttendees(ctx, []types.Event{i.Event}, i.Event.Organizer)
+	if err != nil {
+		return types.Event{}, err
+	}
+
+	return events[0], nil
+}
+
+// acceptedInvitations returns IDs of events the user accepted the invitation to, grouped by their organizers
+func (bl BusinessLogic) acceptedInvitations(ctx context.Context, login string) (map[string]map[int64]bool, error) {
+	invitations, err := bl.db.GetInvitations(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := make(map[string]map[int64]bool)
+	for _, i := range invitations {
+		if i.Status != types.RSVPAccepted {
+			continue
+		}
+
+		if accepted[i.Event.Organizer] == nil {
+			accepted[i.Event.Organizer] = make(map[int64]bool)
+		}
+		accepted[i.Event.Organizer][i.Event.ID] = true
+	}
+
+	return accepted, nil
+}
+
+// withAttendees sets attendees of the events of the owner, occurrences of a recurring event share its ones
+func (bl BusinessLogic) withAttendees(ctx context.Context, events []types.Event,
+	owner string) ([]types.Event, error) {
+	if len(events) == 0 {
+		return events, nil
+	}
+
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, e := range events {
+		if !seen[e.ID] {
+			seen[e.ID] = true
+			ids = append(ids, e.ID)
+		}
+	}
+
+	attendees, err := bl.db.GetAttendees(ctx, ids, owner)
+	if err != nil {
+		return events, err
+	}
+
+	for i := range events {
+		events[i].Attendees = attendees[events[i].ID]
+	}
+
+	return events, nil
+}
+
+// updatedAttendees returns attendees of an updated event and whether they have to be saved
+func (bl BusinessLogic) updatedAttendees(ctx context.Context, stored, updated types.Event, update []types.Attendee,
+	owner string) ([]types.Attendee, bool, error) {
+	reset := timeChanged(stored, updated)
+	if update == nil && !reset {
+		return nil, false, nil
+	}
+
+	current, err := bl.db.GetAttendees(ctx, []int64{stored.ID}, owner)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if update == nil && len(current[stored.ID]) == 0 {
+		return nil, false, nil
+	}
+
+	return nextAttendees(current[stored.ID], update, reset), true, nil
+}
+
+// nextAttendees returns the attendees after an update. Attendees missing in the update are kept, the ones kept
+// in it keep their answers unless the time of the event changes, then everyone is asked again.
+func nextAttendees(current, update []types.Attendee, reset bool) []types.Attendee {
+	attendees := append([]types.Attendee(nil), current...)
+	if update != nil {
+		attendees = keepAnswers(update, current)
+	}
+
+	if reset {
+		for i := range attendees {
+			attendees[i].Status = types.RSVPNeedsAction
+		}
+	}
+
+	return attendees
+}
+
+// newAttendees returns attendees of a new event, who have not answered yet
+func newAttendees(attendees []types.Attendee) []types.Attendee {
+	return keepAnswers(attendees, nil)
+}
+
+// keepAnswers returns the attendees with the answers they gave before, new ones have not answered yet.
+// Email addresses are compared regardless of their case.
+func keepAnswers(attendees, before []types.Attendee) []types.Attendee {
+	if attendees == nil {
+		return nil
+	}
+
+	s := make([]types.Attendee, 0, len(attendees))
+	for _, a := range attendees {
+		a.Email = strings.ToLower(a.Email)
+		a.Status = types.RSVPNeedsAction
+
+		for _, b := range before {
+			if b.Login == a.Login && strings.EqualFold(b.Email, a.Email) {
+				a.Status = b.Status
+			}
+		}
+
+		s = append(s, a)
+	}
+
+	return s
+}
+
+// timeChanged tells whether an update moves the event or changes its recurrence, invalidating answers to it
+func timeChanged(stored, updated types.Event) bool {
+	return !stored.StartTime.Equal(updated.StartTime) || !stored.EndTime.Equal(updated.EndTime) ||
+		stored.Recurrence != updated.Recurrence || stored.AllDay != updated.AllDay
+}
+
+// validateAttendees checks that every attendee is either a user or a guest with a valid email address,
+// that nobody is invited twice and that the organizer does not attend their own event. Invalid attendees
+// are reported in a customErrors.ValidationError.
+func validateAttendees(attendees []types.Attendee, organizer string) error {
+	var fields []customErrors.FieldError
+
+	seen := make(map[types.Attendee]bool)
+	for i, a := range attendees {
+		field := fmt.Sprintf("attendees[%d]", i)
+
+		switch {
+		case (a.Login == "") == (a.Email == ""):
+			fields = append(fields, customErrors.FieldError{Field: field, Code: customErrors.CodeInvalid,
+				Message: "should have either a login or an email"})
+			continue
+		case a.Email != "" && !validEmail(a.Email):
+			fields = append(fields, customErrors.FieldError{Field: field + ".email", Code: customErrors.CodeInvalid,
+				Message: "should be an email address"})
+			continue
+		case a.Login != "" && a.Login == organizer:
+			fields = append(fields, customErrors.FieldError{Field: field + ".login", Code: customErrors.CodeInvalid,
+				Message: "the organizer does not attend their own event"})
+			continue
+		}
+
+		key := types.Attendee{Login: a.Login, Email: strings.ToLower(a.Email)}
+		if seen[key] {
+			fields = append(fields, customErrors.FieldError{Field: field, Code: customErrors.CodeInvalid,
+				Message: "is invited twice"})
+		}
+		seen[key] = true
+	}
+
+	if len(fields) > 0 {
+		return customErrors.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+)
+
+var colorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (bl BusinessLogic) GetCalendars(ctx context.Context) ([]types.Calendar, error) {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return bl.db.GetCalendars(ctx, login)
+}
+
+func (bl BusinessLogic) GetCalendar(ctx context.Context, id int64) (types.Calendar, error) {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return types.Calendar{}, err
+	}
+
+	return bl.db.GetCalendar(ctx, id, login)
+}
+
+// AddCalendar adds a calendar of the user, the default calendar is created with the user and cannot be added
+func (bl BusinessLogic) AddCalendar(ctx context.Context, c types.Calendar) (types.Calendar, error) {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return c, err
+	}
+
+	if c.Visibility == "" {
+		c.Visibility = types.VisibilityFreeBusy
+	}
+
+	c.Default = false
+	c.Owner, c.Access = login, ""
+
+	err = validateCalendar(c)
+	if err != nil {
+		return c, err
+	}
+
+	c.ID, err = bl.db.AddCalendar(ctx, c, login)
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// AddDefaultCalendar creates the default calendar of a new user, it does nothing if the user has one already
+func (bl BusinessLogic) AddDefaultCalendar(ctx context.Context, login string) error {
+	_, err := bl.db.GetDefaultCalendar(ctx, login)
+	return err
+}
+
+// UpdateCalendar changes fields of the calendar set in the update, the other ones are kept. Calendars shared
+// with the user are changed with the manage access.
+func (bl BusinessLogic) UpdateCalendar(ctx context.Context, c types.Calendar, id int64) error {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if c.Name == "" && c.Color == "" && c.Timezone == "" && c.Visibility == "" {
+		return fmt.Errorf("%w: nothing to update", customErrors.ErrBadRequest)
+	}
+
+	stored, err := bl.calendarAccess(ctx, id, login, types.ShareManage)
+	if err != nil {
+		return err
+	}
+
+	err = validateCalendar(updatedCalendar(stored, c))
+	if err != nil {
+		return err
+	}
+
+	return bl.db.UpdateCalendar(ctx, c, id, stored.Owner)
+}
+
+// DeleteCalendar removes the calendar together with its events or, when they are moved, puts them into the target
+// calendar, which is the default one unless given. Webhooks are notified about every deleted or moved event.
+// Only the owner can delete a calendar.
+func (bl BusinessLogic) DeleteCalendar(ctx context.Context, id int64, action types.CalendarEvents,
+	target int64) error {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	c, err := bl.db.GetCalendar(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	if c.Owner != login {
+		return fmt.Errorf("%w: only the owner can delete calendar %d", customErrors.ErrUnauthorized, id)
+	}
+
+	if c.Default {
+		return fmt.Errorf("%w: the default calendar cannot be deleted", customErrors.ErrBadRequest)
+	}
+
+	switch action {
+	case types.CalendarEventsDelete:
+		if target != 0 {
+			return fmt.Errorf("%w: target is given only when events are moved", customErrors.ErrBadRequest)
+		}
+	case types.CalendarEventsMove:
+		if target == 0 {
+			def, err := bl.db.GetDefaultCalendar(ctx, login)
+			if err != nil {
+				return err
+			}
+			target = def.ID
+		}
+
+		if target == id {
+			return fmt.Errorf("%w: events cannot be moved to the deleted calendar", customErrors.ErrBadRequest)
+		}
+
+		to, err := bl.db.GetCalendar(ctx, target, login)
+		if err != nil {
+			return err
+		}
+
+		if to.Owner != login {
+			return fmt.Errorf("%w: events cannot be moved to calendars of another user", customErrors.ErrBadRequest)
+		}
+	default:
+		return fmt.Errorf("%w: events should be one of move, delete", customErrors.ErrBadRequest)
+	}
+
+	events, err := bl.db.GetEvents(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	return bl.db.InTransaction(ctx, func(ctx context.Context) error {
+		err := bl.db.DeleteCalendar(ctx, id, target, login)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if e.CalendarID != id {
+				continue
+			}
+
+			if action == types.CalendarEventsDelete {
+				err = bl.emitDeleted(ctx, e.ID, login)
+			} else {
+				err = bl.emitUpdated(ctx, e.ID, login)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// eventCalendar returns the calendar of an event, which is the default one when the event does not name it
+func (bl BusinessLogic) eventCalendar(ctx context.Context, id int64, login string) (types.Calendar, error) {
+	if id == 0 {
+		return bl.db.GetDefaultCalendar(ctx, login)
+	}
+
+	c, err := bl.db.GetCalendar(ctx, id, login)
+	if errors.Is(err, customErrors.ErrNotFound) {
+		return c, fmt.Errorf("%w: calendar %d does not exist", customErrors.ErrBadRequest, id)
+	}
+
+	return c, err
+}
+
+// calendarEvents returns events listed by GetEvents which belong to the calendar of the filters
+func (bl BusinessLogic) calendarEvents(ctx context.Context, f types.Filters, login string) ([]types.Event, error) {
+	_, err := bl.db.GetCalendar(ctx, f.CalendarID, login)
+	if err != nil {
+		return nil, err
+	}
+
+	id := f.CalendarID
+	f.CalendarID = 0
+
+	events, err := bl.GetEvents(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var s []types.Event
+	for _, e := range events {
+		if e.CalendarID == id {
+			s = append(s, e)
+		}
+	}
+
+	return s, nil
+}
+
+// validateCalendar checks the complete state of a calendar and reports every invalid field of it
+// in a customErrors.ValidationError
+func validateCalendar(c types.Calendar) error {
+	var fields []customErrors.FieldError
+
+	invalid := func(field, code, message string) {
+		fields = append(fields, customErrors.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	switch {
+	case c.Name == "":
+		invalid("name", customErrors.CodeRequired, "is required")
+	case len([]rune(c.Name)) > maxNameLength:
+		invalid("name", customErrors.CodeTooLong, fmt.Sprintf("should be at most %d characters", maxNameLength))
+	}
+
+	if c.Color != "" && !colorRegexp.MatchString(c.Color) {
+		invalid("color", customErrors.CodeInvalid, "should be a hex color, e.g. #1E90FF")
+	}
+
+	if validateTimezone(c.Timezone) != nil {
+		invalid("timezone", customErrors.CodeInvalid, "should be an IANA timezone, e.g. Europe/Warsaw")
+	}
+
+	switch c.Visibility {
+	case types.VisibilityFreeBusy, types.VisibilityPrivate:
+	default:
+		invalid("visibility", customErrors.CodeInvalid, "should be one of freeBusy, private")
+	}
+
+	if len(fields) > 0 {
+		return customErrors.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+// updatedCalendar returns the stored calendar with the fields set in the update, the way repositories apply updates
+func updatedCalendar(stored, update types.Calendar) types.Calendar {
+	if update.Name != "" {
+		stored.Name = update.Name
+	}
+
+	if update.Color != "" {
+		stored.Color = update.Color
+	}
+
+	if update.Timezone != "" {
+		stored.Timezone = update.Timezone
+	}
+
+	if update.Visibility != "" {
+		stored.Visibility = update.Visibility
+	}
+
+	return stored
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"time"
+
+	"github.com/bubo-py/McK/events/recurrence"
+	"github.com/bubo-py/McK/types"
+)
+
+// maxSpannedDays bounds the days of an event checked against the day/month/year filters
+const maxSpannedDays = 10 * 366
+
+// date returns the wall-clock date of t as midnight UTC, which is how dates of all-day events are kept
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// dateWindow returns the dates the [from, to) window spans in the location, as an exclusive window of dates
+func dateWindow(from, to time.Time, loc *time.Location) (time.Time, time.Time) {
+	from, to = from.In(loc), to.In(loc)
+
+	toDate := date(to)
+	if !toDate.Equal(newDateWithLocation(to, time.UTC)) {
+		toDate = toDate.AddDate(0, 0, 1)
+	}
+
+	return date(from), toDate
+}
+
+// overlaps tells whether the event overlaps the [from, to) window, all-day events overlap it on the dates
+// the window spans in the location
+func overlaps(e types.Event, from, to time.Time, loc *time.Location) bool {
+	if e.AllDay {
+		from, to = dateWindow(from, to, loc)
+	}
+
+	return recurrence.Overlaps(e.StartTime, e.EndTime, from, to)
+}
+
+// spannedDays returns the first and the last day the event spans in the location as dates,
+// an event ending at midnight does not span the following day
+func spannedDays(e types.Event, loc *time.Location) (time.Time, time.Time) {
+	start, end := e.StartTime, e.EndTime
+	if !e.AllDay {
+		start, end = start.In(loc), end.In(loc)
+	}
+
+	first, last := date(start), date(end)
+	if end.After(start) && last.Equal(newDateWithLocation(end, time.UTC)) {
+		last = last.AddDate(0, 0, -1)
+	}
+
+	if last.Before(first) {
+		last = first
+	}
+
+	return first, last
+}
+
+// multiDay tells whether the event spans more than one day in the location
+func multiDay(e types.Event, loc *time.Location) bool {
+	first, last := spannedDays(e, loc)
+
+	return last.After(first)
+}
+
+// matchesDays tells whether any day the event spans in the location matches the day/month/year filters,
+// so that multi-day events are listed on every day of them
+func matchesDays(e types.Event, f types.Filters, loc *time.Location) bool {
+	first, last := spannedDays(e, loc)
+
+	for d, n := first, 0; !d.After(last) && n < maxSpannedDays; d, n = d.AddDate(0, 0, 1), n+1 {
+		if matchesFilters(d, f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toDates turns the times of an all-day event into dates, the time of the day and the offset are ignored
+func toDates(e types.Event) types.Event {
+	if !e.StartTime.IsZero() {
+		e.StartTime = date(e.StartTime)
+	}
+
+	if !e.EndTime.IsZero() {
+		e.EndTime = date(e.EndTime)
+	}
+
+	return e
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+)
+
+// Bounds of a free/busy query, the users are looked up in a single query over the whole window
+const (
+	maxFreeBusyUsers = 50
+	maxFreeBusyDays  = 92
+)
+
+// GetFreeBusy returns when the users are busy in the [from, to) window, whose times are wall-clock times
+// in the user's timezone like the ones of the from/to filters. Busy intervals are clipped to the window,
+// merged and returned in the user's timezone. Users show only calendars they share with the user or keep
+// visible as free/busy time, users who do not exist are never busy.
+func (bl BusinessLogic) GetFreeBusy(ctx context.Context, q types.FreeBusyQuery) ([]types.FreeBusy, error) {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := newDateWithLocation(q.From, loc), newDateWithLocation(q.To, loc)
+
+	err = validateFreeBusy(q)
+	if err != nil {
+		return nil, err
+	}
+
+	logins := uniqueLogins(q.Logins)
+
+	busy, err := bl.busyTimes(ctx, logins, from, to, login, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := make([]types.FreeBusy, 0, len(logins))
+	for _, l := range logins {
+		s = append(s, types.FreeBusy{Login: l, Busy: busy[l]})
+	}
+
+	return s, nil
+}
+
+// busyTimes returns merged busy intervals of the users in the window as seen by the user with the login,
+// every user has a non-nil list of them with times in the location
+func (bl BusinessLogic) busyTimes(ctx context.Context, logins []string, from, to time.Time, login string,
+	loc *time.Location) (map[string][]types.BusyInterval, error) {
+	events, err := bl.db.GetBusyEvents(ctx, logins, from.UTC(), to.UTC(), login)
+	if err != nil {
+		return nil, err
+	}
+
+	intervals := make(map[string][]types.BusyInterval)
+	for _, e := range events {
+		busy, err := busyIntervals(e, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		intervals[e.Login] = append(intervals[e.Login], busy...)
+	}
+
+	merged := make(map[string][]types.BusyInterval)
+	for _, l := range logins {
+		merged[l] = mergeIntervals(intervals[l], loc)
+	}
+
+	return merged, nil
+}
+
+// busyIntervals returns the times the event takes in the window. Recurring events are expanded in the timezone
+// of their owner and all-day events take whole days of it, the way the owner sees them.
+func busyIntervals(e types.BusyEvent, from, to time.Time) ([]types.BusyInterval, error) {
+	loc, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	occurrences := []types.Event{e.Event}
+	if e.Recurrence != "" {
+		occurrences, err = expand(e.Event, e.Exceptions, from, to, loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var s []types.BusyInterval
+	for _, o := range occurrences {
+		start, end := o.StartTime, o.EndTime
+		if o.AllDay {
+			start, end = newDateWithLocation(start.UTC(), loc), newDateWithLocation(end.UTC(), loc)
+		}
+
+		if start.Before(from) {
+			start = from
+		}
+
+		if end.After(to) {
+			end = to
+		}
+
+		if start.Before(end) {
+			s = append(s, types.BusyInterval{Start: start, End: end})
+		}
+	}
+
+	return s, nil
+}
+
+// mergeIntervals sorts the intervals and merges the overlapping and adjacent ones, times are returned
+// in the location
+func mergeIntervals(intervals []types.BusyInterval, loc *time.Location) []types.BusyInterval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+
+	merged := make([]types.BusyInterval, 0, len(intervals))
+	for _, i := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && !i.Start.After(merged[last].End) {
+			if i.End.After(merged[last].End) {
+				merged[last].End = i.End
+			}
+			continue
+		}
+
+		merged = append(merged, i)
+	}
+
+	for i := range merged {
+		merged[i].Start, merged[i].End = merged[i].Start.In(loc), merged[i].End.In(loc)
+	}
+
+	return merged
+}
+
+// uniqueLogins returns the logins without the repeated ones, in the order they were given
+func uniqueLogins(logins []string) []string {
+	seen := make(map[string]bool)
+
+	var s []string
+	for _, l := range logins {
+		if !seen[l] {
+			seen[l] = true
+			s = append(s, l)
+		}
+	}
+
+	return s
+}
+
+// validateFreeBusy reports every invalid field of the query in a customErrors.ValidationError
+func validateFreeBusy(q types.FreeBusyQuery) error {
+	var fields []customErrors.FieldError
+
+	if len(q.Logins) == 0 {
+		fields = append(fields, customErrors.FieldError{Field: "logins", Code: customErrors.CodeRequired,
+			Message: "is required"})
+	}
+
+	fields = append(fields, loginsFields("logins", q.Logins, 0)...)
+	fields = append(fields, windowFields(q.From, q.To)...)
+
+	if len(fields) > 0 {
+		return customErrors.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+// loginsFields checks the logins of a query, which is made for at most maxFreeBusyUsers users together
+// with the given number of other ones
+func loginsFields(field string, logins []string, others int) []customErrors.FieldError {
+	var fields []customErrors.FieldError
+
+	if len(uniqueLogins(logins))+others > maxFreeBusyUsers {
+		fields = append(fields, customErrors.FieldError{Field: field, Code: customErrors.CodeInvalid,
+			Message: fmt.Sprintf("should have at most %d users", maxFreeBusyUsers-others)})
+	}
+
+	for i, l := range logins {
+		if l == "" {
+			fields = append(fields, customErrors.FieldError{Field: fmt.Sprintf("%s[%d]", field, i),
+				Code: customErrors.CodeRequired, Message: "is required"})
+		}
+	}
+
+	return fields
+}
+
+// windowFields checks the window of a query, which is measured in wall-clock time
+func windowFields(from, to time.Time) []customErrors.FieldError {
+	var fields []customErrors.FieldError
+
+	invalid := func(field, code, message string) {
+		fields = append(fields, customErrors.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if from.IsZero() {
+		invalid("from", customErrors.CodeRequired, "is required")
+	}
+
+	if to.IsZero() {
+		invalid("to", customErrors.CodeRequired, "is required")
+	}
+
+	if !from.IsZero() && !to.IsZero() {
+		from, to = newDateWithLocation(from, time.UTC), newDateWithLocation(to, time.UTC)
+
+		switch {
+		case !to.After(from):
+			invalid("to", customErrors.CodeEndBeforeStart, "should be after from")
+		case to.After(from.AddDate(0, 0, maxFreeBusyDays)):
+			invalid("to", customErrors.CodeInvalid, fmt.Sprintf("should be at most %d days after from",
+				maxFreeBusyDays))
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+)
+
+// Slots are suggested every slotStep of the user's day, up to maxSlots of them
+const (
+	slotStep         = 30 * time.Minute
+	defaultSlotLimit = 10
+	maxSlots         = 50
+	maxBuffer        = 4 * 60
+)
+
+// defaultWorkingHours apply to attendees when the query gives no working hours
+var defaultWorkingHours = types.WorkingHours{Start: "09:00", End: "17:00",
+	Days: []string{"monday", "tuesday", "wednesday", "thursday", "friday"}}
+
+// weekdays are the names of the days of working hours
+var weekdays = map[string]time.Weekday{
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+	"sunday":    time.Sunday,
+}
+
+// SuggestSlots returns times the user can meet the attendees for the duration in the window, whose times
+// are wall-clock times in the user's timezone. Every slot is within working hours of every attendee in their own
+// timezone and within the earliest and latest time of the user's day, while the user is free. Slots which suit
+// everyone come first, followed by the ones fewest attendees are busy at, earlier slots rank higher among equal
+// ones. Times of the slots are in the user's timezone.
+func (bl BusinessLogic) SuggestSlots(ctx context.Context, q types.SuggestQuery) ([]types.Slot, error) {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateSuggestQuery(q, login)
+	if err != nil {
+		return nil, err
+	}
+
+	hours := defaultWorkingHours
+	if q.Constraints.WorkingHours != nil {
+		hours = *q.Constraints.WorkingHours
+	}
+
+	earliest, latest := parseClock(q.Constraints.Earliest, clock{}), parseClock(q.Constraints.Latest, endOfDay)
+
+	if q.Limit == 0 {
+		q.Limit = defaultSlotLimit
+	}
+
+	attendees := uniqueLogins(append([]string{login}, q.Attendees...))
+
+	work := make(map[string]workTime)
+	for _, l := range attendees {
+		h, ok := q.Constraints.AttendeeWorkingHours[l]
+		if !ok {
+			h = hours
+		}
+
+		work[l] = newWorkTime(h)
+	}
+
+	timezones, err := bl.db.GetTimezones(ctx, attendees)
+	if err != nil {
+		return nil, err
+	}
+
+	locations, err := attendeeLocations(q.Attendees, timezones)
+	if err != nil {
+		return nil, err
+	}
+	locations[login] = loc
+
+	from, to := newDateWithLocation(q.From, loc), newDateWithLocation(q.To, loc)
+	duration := time.Duration(q.Duration) * time.Minute
+	buffer := time.Duration(q.Constraints.Buffer) * time.Minute
+
+	// Attendees should be free during the buffers around the slots at the edges of the window as well
+	busy, err := bl.busyTimes(ctx, attendees, from.Add(-buffer), to.Add(buffer), login, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []types.Slot
+	for start := alignToStep(from, loc); !start.Add(duration).After(to); start = start.Add(slotStep) {
+		end := start.Add(duration)
+
+		if !withinDay(start, end, earliest, latest, loc) {
+			continue
+		}
+
+		working := true
+		for _, l := range attendees {
+			working = working && work[l].fits(start, end, locations[l])
+		}
+
+		if !working || busyAt(busy[login], start.Add(-buffer), end.Add(buffer)) {
+			continue
+		}
+
+		slot := types.Slot{Start: start, End: end}
+		for _, l := range attendees[1:] {
+			if busyAt(busy[l], start.Add(-buffer), end.Add(buffer)) {
+				slot.Busy = append(slot.Busy, l)
+			}
+		}
+
+		slots = append(slots, slot)
+	}
+
+	sort.SliceStable(slots, func(i, j int) bool { return len(slots[i].Busy) < len(slots[j].Busy) })
+
+	if len(slots) > q.Limit {
+		slots = slots[:q.Limit]
+	}
+
+	return slots, nil
+}
+
+// workTime is the working time of an attendee, the days of the week and the hours of them
+type workTime struct {
+	days       map[time.Weekday]bool
+	start, end clock
+}
+
+// newWorkTime parses validated working hours, weekdays are the working days when they give none
+func newWorkTime(h types.WorkingHours) workTime {
+	if len(h.Days) == 0 {
+		h.Days = defaultWorkingHours.Days
+	}
+
+	w := workTime{days: make(map[time.Weekday]bool), start: parseClock(h.Start, clock{}),
+		end: parseClock(h.End, endOfDay)}
+	for _, d := range h.Days {
+		w.days[weekdays[d]] = true
+	}
+
+	return w
+}
+
+// fits tells whether the slot is within the working time in the location
+func (w workTime) fits(start, end time.Time, loc *time.Location) bool {
+	return w.days[start.In(loc).Weekday()] && withinDay(start, end, w.start, w.end, loc)
+}
+
+// attendeeLocations loads timezones of the attendees by their logins, attendees who are not users
+// are reported in a customErrors.ValidationError
+func attendeeLocations(attendees []string, timezones map[string]string) (map[string]*time.Location, error) {
+	var fields []customErrors.FieldError
+
+	locations := make(map[string]*time.Location)
+	for l, timezone := range timezones {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		locations[l] = loc
+	}
+
+	for i, l := range attendees {
+		if _, ok := locations[l]; !ok {
+			fields = append(fields, customErrors.FieldError{Field: fmt.Sprintf("attendees[%d]", i),
+				Code: customErrors.CodeInvalid, Message: "is not a user"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, customErrors.ValidationError{Fields: fields}
+	}
+
+	return locations, nil
+}
+
+// alignToStep returns the first time from t on which is a multiple of slotStep since the midnight of its day
+// in the location
+func alignToStep(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	return midnight.Add((t.Sub(midnight) + slotStep - 1) / slotStep * slotStep)
+}
+
+// clock is a time of day, which is turned into a time of a given day in the timezone it applies in
+type clock struct {
+	hour, minute int
+}
+
+// endOfDay is the midnight ending a day
+var endOfDay = clock{hour: 24}
+
+// parseClock parses a validated HH:MM time of day, the default is returned when it is left out
+func parseClock(s string, def clock) clock {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return def
+	}
+
+	return clock{hour: t.Hour(), minute: t.Minute()}
+}
+
+// on returns the time of the day of t in the location
+func (c clock) on(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+
+	return time.Date(t.Year(), t.Month(), t.Day(), c.hour, c.minute, 0, 0, loc)
+}
+
+// withinDay tells whether the slot starts no earlier and ends no later than the given times of the day it starts
+// on in the location
+func withinDay(start, end time.Time, from, until clock, loc *time.Location) bool {
+	return !start.Before(from.on(start, loc)) && !end.After(until.on(start, loc))
+}
+
+// busyAt tells whether any of the sorted busy intervals overlaps the [from, to) window
+func busyAt(busy []types.BusyInterval, from, to time.Time) bool {
+	i := sort.Search(len(busy), func(i int) bool { return busy[i].End.After(from) })
+
+	return i < len(busy) && busy[i].Start.Before(to)
+}
+
+// validateSuggestQuery reports every invalid field of the query of the user in a customErrors.ValidationError
+func validateSuggestQuery(q types.SuggestQuery, login string) error {
+	var fields []customErrors.FieldError
+
+	invalid := func(field, code, message string) {
+		fields = append(fields, customErrors.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	// The user attends the meeting as well
+	fields = append(fields, loginsFields("attendees", q.Attendees, 1)...)
+
+	switch {
+	case q.Duration == 0:
+		invalid("duration", customErrors.CodeRequired, "is required")
+	case q.Duration < 0 || q.Duration > 24*60:
+		invalid("duration", customErrors.CodeInvalid, "should be between 1 and 1440 minutes")
+	}
+
+	fields = append(fields, windowFields(q.From, q.To)...)
+
+	c := q.Constraints
+
+	if c.Buffer < 0 || c.Buffer > maxBuffer {
+		invalid("constraints.buffer", customErrors.CodeInvalid,
+			fmt.Sprintf("should be between 0 and %d minutes", maxBuffer))
+	}
+
+	fields = append(fields, hoursFields("constraints.earliest", "constraints.latest", c.Earliest, c.Latest)...)
+
+	if c.WorkingHours != nil {
+		fields = append(fields, workingHoursFields("constraints.workingHours", *c.WorkingHours)...)
+	}
+
+	attending := map[string]bool{login: true}
+	for _, l := range q.Attendees {
+		attending[l] = true
+	}
+
+	logins := make([]string, 0, len(c.AttendeeWorkingHours))
+	for l := range c.AttendeeWorkingHours {
+		logins = append(logins, l)
+	}
+	sort.Strings(logins)
+
+	for _, l := range logins {
+		field := "constraints.attendeeWorkingHours." + l
+		if !attending[l] {
+			invalid(field, customErrors.CodeInvalid, "should be the user or one of the attendees")
+			continue
+		}
+
+		fields = append(fields, workingHoursFields(field, c.AttendeeWorkingHours[l])...)
+	}
+
+	if q.Limit < 0 || q.Limit > maxSlots {
+		invalid("limit", customErrors.CodeInvalid, fmt.Sprintf("should be between 1 and %d", maxSlots))
+	}
+
+	if len(fields) > 0 {
+		return customErrors.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+// workingHoursFields checks working hours given in the field
+func workingHoursFields(field string, h types.WorkingHours) []customErrors.FieldError {
+	var fields []customErrors.FieldError
+
+	invalid := func(field, code, message string) {
+		fields = append(fields, customErrors.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if h.Start == "" {
+		invalid(field+".start", customErrors.CodeRequired, "is required")
+	}
+
+	if h.End == "" {
+		invalid(field+".end", customErrors.CodeRequired, "is required")
+	}
+
+	fields = append(fields, hoursFields(field+".start", field+".end", h.Start, h.End)...)
+
+	for i, d := range h.Days {
+		if _, ok := weekdays[d]; !ok {
+			invalid(fmt.Sprintf("%s.days[%d]", field, i), customErrors.CodeInvalid,
+				"should be one of monday, tuesday, wednesday, thursday, friday, saturday, sunday")
+		}
+	}
+
+	return fields
+}
+
+// hoursFields checks HH:MM times of a day, the start should be before the end when both are given
+func hoursFields(startField, endField, start, end string) []customErrors.FieldError {
+	var fields []customErrors.FieldError
+
+	startTime, startErr := time.Parse("15:04", start)
+	if start != "" && startErr != nil {
+		fields = append(fields, customErrors.FieldError{Field: startField, Code: customErrors.CodeInvalid,
+			Message: "should be a time of day as HH:MM"})
+	}
+
+	endTime, endErr := time.Parse("15:04", end)
+	if end != "" && endErr != nil {
+		fields = append(fields, customErrors.FieldError{Field: endField, Code: customErrors.CodeInvalid,
+			Message: "should be a time of day as HH:MM"})
+	}
+
+	if start != "" && end != "" && startErr == nil && endErr == nil && !endTime.After(startTime) {
+		fields = append(fields, customErrors.FieldError{Field: endField, Code: customErrors.CodeEndBeforeStart,
+			Message: fmt.Sprintf("should be after %s", startField)})
+	}
+
+	return fields
+}
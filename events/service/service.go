@@ -1,13 +1,20 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"sort"
 	"time"
 
 	"github.com/bubo-py/McK/contextHelpers"
 	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/events/ical"
+	"github.com/bubo-py/McK/events/pagination"
+	"github.com/bubo-py/McK/events/recurrence"
 	"github.com/bubo-py/McK/events/repositories"
 	"github.com/bubo-py/McK/types"
 )
@@ -16,12 +23,40 @@ import (
 
 type BusinessLogicInterface interface {
 	GetEvents(ctx context.Context, f types.Filters) ([]types.Event, error)
+	GetEventsInRange(ctx context.Context, from, to time.Time) ([]types.Event, error)
+	GetEventsPage(ctx context.Context, f types.Filters, p types.Page) (types.EventPage, error)
 	GetEvent(ctx context.Context, id int64) (types.Event, error)
 	AddEvent(ctx context.Context, e types.Event) error
 	DeleteEvent(ctx context.Context, id int64) error
-	UpdateEvent(ctx context.Context, e types.Event, id int64) error
+	UpdateEvent(ctx context.Context, u types.EventUpdate, id int64) error
+	UpdateOccurrence(ctx context.Context, e types.Event, id int64, originalStart time.Time,
+		scope types.OccurrenceScope) error
+	DeleteOccurrence(ctx context.Context, id int64, originalStart time.Time, scope types.OccurrenceScope) error
+	ExportEvents(ctx context.Context, f types.Filters) ([]byte, error)
+	ImportEvents(ctx context.Context, r io.Reader) (types.ImportReport, error)
+	GetCalendars(ctx context.Context) ([]types.Calendar, error)
+	GetCalendar(ctx context.Context, id int64) (types.Calendar, error)
+	AddCalendar(ctx context.Context, c types.Calendar) (types.Calendar, error)
+	AddDefaultCalendar(ctx context.Context, login string) error
+	UpdateCalendar(ctx context.Context, c types.Calendar, id int64) error
+	DeleteCalendar(ctx context.Context, id int64, action types.CalendarEvents, target int64) error
+	GetShares(ctx context.Context, id int64) ([]types.Share, error)
+	SetShare(ctx context.Context, id int64, s types.Share) error
+	DeleteShare(ctx context.Context, id int64, grantee string) error
+	GetInvitations(ctx context.Context, status types.RSVPStatus) ([]types.Invitation, error)
+	RSVP(ctx context.Context, id int64, status types.RSVPStatus) error
+	GetFreeBusy(ctx context.Context, q types.FreeBusyQuery) ([]types.FreeBusy, error)
+	SuggestSlots(ctx context.Context, q types.SuggestQuery) ([]types.Slot, error)
 }
 
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// recurringHorizon limits the expansion of recurring events when filters do not narrow it down to a single year
+const recurringHorizon = 2 * 365 * 24 * time.Hour
+
 type BusinessLogic struct {
 	db repositories.DatabaseRepository
 }
@@ -35,34 +70,39 @@ func InitBusinessLogic(db repositories.DatabaseRepository) BusinessLogic {
 func (bl BusinessLogic) GetEvents(ctx context.Context, f types.Filters) ([]types.Event, error) {
 	var s []types.Event
 
-	if f.Day == 0 && f.Month == 0 && f.Year == 0 {
-		e, err := bl.db.GetEvents(ctx)
-		if err != nil {
-			return s, err
-		}
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return s, err
+	}
 
-		s = append(s, e...)
+	if f.CalendarID != 0 {
+		return bl.calendarEvents(ctx, f, login)
+	}
 
-		for i := range s {
-			if !s[i].AlertTime.IsZero() {
-				s[i].AlertTime, err = bl.eventToUserTime(ctx, s[i].AlertTime)
-				if err != nil {
-					return e, err
-				}
-			}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		return bl.getEventsBetween(ctx, f)
+	}
+
+	if f.Day == 0 && f.Month == 0 && f.Year == 0 {
+		return bl.visibleEvents(ctx, login, func(owner string) ([]types.Event, error) {
+			var s []types.Event
 
-			s[i].StartTime, err = bl.eventToUserTime(ctx, s[i].StartTime)
+			e, err := bl.db.GetEvents(ctx, owner)
 			if err != nil {
-				return e, err
+				return s, err
 			}
 
-			s[i].EndTime, err = bl.eventToUserTime(ctx, s[i].EndTime)
+			loc, err := bl.userLocation(ctx)
 			if err != nil {
 				return e, err
 			}
-		}
 
-		return s, nil
+			for _, event := range e {
+				s = append(s, eventInLocation(event, loc))
+			}
+
+			return s, nil
+		})
 	}
 
 	if f.Day != 0 {
@@ -83,49 +123,269 @@ func (bl BusinessLogic) GetEvents(ctx context.Context, f types.Filters) ([]types
 		}
 	}
 
-	e, err := bl.db.GetEventsFiltered(ctx, f)
+	loc, err := bl.userLocation(ctx)
 	if err != nil {
 		return s, err
 	}
 
-	s = append(s, e...)
+	return bl.visibleEvents(ctx, login, func(owner string) ([]types.Event, error) {
+		var s []types.Event
 
-	for i := range s {
-		if !s[i].AlertTime.IsZero() {
-			s[i].AlertTime, err = bl.eventToUserTime(ctx, s[i].AlertTime)
-			if err != nil {
-				return e, err
+		e, err := bl.db.GetEventsFiltered(ctx, f, loc, owner)
+		if err != nil {
+			return s, err
+		}
+
+		// Multi-day events are returned regardless of the days they span, they are matched here
+		for _, event := range e {
+			if event.Recurrence == "" && (!multiDay(event, loc) || matchesDays(event, f, loc)) {
+				s = append(s, eventInLocation(event, loc))
 			}
 		}
 
-		s[i].StartTime, err = bl.eventToUserTime(ctx, s[i].StartTime)
+		occurrences, err := bl.expandFiltered(ctx, e, f, owner)
 		if err != nil {
-			return e, err
+			return s, err
+		}
+
+		if len(occurrences) > 0 {
+			s = append(s, occurrences...)
+			sortByStartTime(s)
+		}
+
+		return s, nil
+	})
+}
+
+// getEventsBetween returns events overlapping the interval of the from/to filters, which are wall-clock times
+// in the user's timezone
+func (bl BusinessLogic) getEventsBetween(ctx context.Context, f types.Filters) ([]types.Event, error) {
+	if f.Day != 0 || f.Month != 0 || f.Year != 0 {
+		return nil, fmt.Errorf("%w: from and to cannot be combined with day, month or year", customErrors.ErrBadRequest)
+	}
+
+	if f.From.IsZero() || f.To.IsZero() {
+		return nil, fmt.Errorf("%w: both from and to are required", customErrors.ErrBadRequest)
+	}
+
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return bl.GetEventsInRange(ctx, newDateWithLocation(f.From, loc), newDateWithLocation(f.To, loc))
+}
+
+// GetEventsInRange returns events overlapping the [from, to) window,
+// recurring events are expanded into their occurrences in the user's timezone.
+func (bl BusinessLogic) GetEventsInRange(ctx context.Context, from, to time.Time) ([]types.Event, error) {
+	var s []types.Event
+
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return s, err
+	}
+
+	if !from.Before(to) {
+		return s, fmt.Errorf("%w: from should be before to", customErrors.ErrBadRequest)
+	}
+
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return s, err
+	}
+
+	s, err = bl.visibleEvents(ctx, login, func(owner string) ([]types.Event, error) {
+		var s []types.Event
+
+		e, err := bl.db.GetEventsInRange(ctx, from.UTC(), to.UTC(), owner)
+		if err != nil {
+			return s, err
 		}
 
-		s[i].EndTime, err = bl.eventToUserTime(ctx, s[i].EndTime)
+		exceptions, err := bl.seriesExceptions(ctx, e, owner)
 		if err != nil {
-			return e, err
+			return s, err
+		}
+
+		for _, event := range e {
+			// All-day events are returned with a margin, as their days depend on the timezone
+			if event.Recurrence == "" {
+				if overlaps(event, from, to, loc) {
+					s = append(s, eventInLocation(event, loc))
+				}
+				continue
+			}
+
+			occurrences, err := expand(event, exceptions[event.ID], from, to, loc)
+			if err != nil {
+				return s, err
+			}
+
+			s = append(s, occurrences...)
 		}
+
+		return s, nil
+	})
+	if err != nil {
+		return s, err
 	}
 
+	sortByStartTime(s)
+
 	return s, nil
 }
 
-func (bl BusinessLogic) GetEvent(ctx context.Context, id int64) (types.Event, error) {
-	e, err := bl.db.GetEvent(ctx, id)
+// GetEventsPage returns a page of the events listed by GetEvents with cursors of the neighbouring pages.
+// Listings without filters are paginated by the database, filtered ones after recurring events are expanded.
+func (bl BusinessLogic) GetEventsPage(ctx context.Context, f types.Filters, p types.Page) (types.EventPage, error) {
+	var page types.EventPage
+
+	login, err := retrieveLogin(ctx)
 	if err != nil {
-		return e, err
+		return page, err
 	}
 
-	e.StartTime, err = bl.eventToUserTime(ctx, e.StartTime)
-	if err != nil {
-		return e, err
+	if p.Limit == 0 {
+		p.Limit = defaultPageLimit
+	}
+
+	if p.Limit < 0 || p.Limit > maxPageLimit {
+		return page, fmt.Errorf("%w: limit should be between 1 and %d", customErrors.ErrBadRequest, maxPageLimit)
+	}
+
+	// One more event is queried to tell whether there is a page after this one
+	q := types.PageQuery{Sort: p.Sort, Desc: p.Desc, Limit: p.Limit + 1}
+	backward := false
+
+	if p.Cursor != "" {
+		c, err := pagination.Decode(p.Cursor)
+		if err != nil {
+			return page, fmt.Errorf("%w: %v", customErrors.ErrBadRequest, err)
+		}
+
+		if p.Sort != "" && (p.Sort != c.Sort || p.Desc != c.Desc) {
+			return page, fmt.Errorf("%w: sort does not match the cursor", customErrors.ErrBadRequest)
+		}
+
+		q.Sort, q.Desc, q.After = c.Sort, c.Desc, &c.Key
+		backward = c.Backward
+	}
+
+	if q.Sort == "" {
+		q.Sort = types.SortStartTime
+	}
+
+	if !pagination.ValidSort(q.Sort) {
+		return page, fmt.Errorf("%w: sort", customErrors.ErrBadRequest)
+	}
+
+	// Pages preceding the cursor are read in the reverse order and flipped back afterwards
+	desc := q.Desc
+	if backward {
+		q.Desc = !q.Desc
 	}
 
-	e.EndTime, err = bl.eventToUserTime(ctx, e.EndTime)
+	var events []types.Event
+
+	// Listings filtered by dates expand recurring events into their occurrences, so they are paginated in memory
+	filtered := f.Day != 0 || f.Month != 0 || f.Year != 0 || !f.From.IsZero() || !f.To.IsZero()
+
+	if filtered {
+		all, err := bl.GetEvents(ctx, f)
+		if err != nil {
+			return page, err
+		}
+
+		events = pagination.Select(all, q)
+	} else {
+		events, err = bl.visiblePage(ctx, q, f.CalendarID, login)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	more := len(events) > p.Limit
+	if more {
+		events = events[:p.Limit]
+	}
+
+	if backward {
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+	}
+
+	page.Events = events
+
+	if len(events) == 0 {
+		return page, nil
+	}
+
+	// Going backward, the page the cursor came from follows this one and more events precede it
+	hasNext, hasPrev := more, q.After != nil
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+
+	if hasNext {
+		page.Next = pagination.Encode(pagination.Cursor{Sort: q.Sort, Desc: desc, Key: pagination.Key(events[len(events)-1])})
+	}
+
+	if hasPrev {
+		page.Prev = pagination.Encode(pagination.Cursor{Sort: q.Sort, Desc: desc, Key: pagination.Key(events[0]),
+			Backward: true})
+	}
+
+	return page, nil
+}
+
+func (bl BusinessLogic) GetEvent(ctx context.Context, id int64) (types.Event, error) {
+	login, err := retrieveLogin(ctx)
 	if err != nil {
-		return e, err
+		return types.Event{}, err
+	}
+
+	// Events of other users are found through the calendars shared with the user or the invitations
+	var e types.Event
+
+	c, err := bl.eventAccess(ctx, id, login, types.ShareFreeBusy)
+	switch {
+	case errors.Is(err, customErrors.ErrNotFound):
+		e, err = bl.invitedEvent(ctx, id, login)
+		if err != nil {
+			return e, err
+		}
+	case err != nil:
+		return types.Event{}, err
+	default:
+		e, err = bl.db.GetEvent(ctx, id, c.Owner)
+		if err != nil {
+			return e, err
+		}
+
+		if allows(c, login, types.ShareRead) {
+			var events []types.Event
+			events, err = bl.withAttendees(ctx, []types.Event{e}, c.Owner)
+			if err != nil {
+				return e, err
+			}
+			e = events[0]
+		} else {
+			e = busy(e)
+		}
+	}
+
+	if !e.AllDay {
+		e.StartTime, err = bl.eventToUserTime(ctx, e.StartTime)
+		if err != nil {
+			return e, err
+		}
+
+		e.EndTime, err = bl.eventToUserTime(ctx, e.EndTime)
+		if err != nil {
+			return e, err
+		}
 	}
 
 	e.AlertTime, err = bl.eventToUserTime(ctx, e.AlertTime)
@@ -137,119 +397,1143 @@ func (bl BusinessLogic) GetEvent(ctx context.Context, id int64) (types.Event, er
 }
 
 func (bl BusinessLogic) AddEvent(ctx context.Context, e types.Event) error {
-	err := validatePostRequest(e)
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	e.Recurrence, err = validateRecurrence(e.Recurrence)
+	if err != nil {
+		return err
+	}
+
+	err = validateTimezone(e.Timezone)
+	if err != nil {
+		return err
+	}
+
+	c, err := bl.eventCalendar(ctx, e.CalendarID, login)
 	if err != nil {
 		return err
 	}
 
-	err = validateLength(e.Name)
+	err = authorize(c, login, types.ShareWrite)
 	if err != nil {
 		return err
 	}
 
+	// Events without their own timezone are in the one of their calendar, if it has one
+	e.CalendarID = c.ID
+	if e.Timezone == "" {
+		e.Timezone = c.Timezone
+	}
+
+	e.Attendees, e.Organizer = newAttendees(e.Attendees), ""
+
 	e, err = bl.eventToUTC(ctx, e)
 	if err != nil {
 		return err
 	}
 
-	err = bl.db.AddEvent(ctx, e)
+	err = validateEvent(e)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = validateAttendees(e.Attendees, c.Owner)
+	if err != nil {
+		return err
+	}
+
+	// Events belong to the owner of their calendar, who is notified about them and organizes them
+	if e.UID != "" {
+		err = bl.validateUID(ctx, e.UID, c.Owner)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bl.db.InTransaction(ctx, func(ctx context.Context) error {
+		e.ID, err = bl.db.AddEvent(ctx, e, c.Owner)
+		if err != nil {
+			return err
+		}
+
+		if len(e.Attendees) > 0 {
+			err = bl.db.SetAttendees(ctx, e.ID, e.Attendees, c.Owner)
+			if err != nil {
+				return err
+			}
+		}
+
+		return bl.emitEvent(ctx, types.EventCreated, e, c.Owner)
+	})
 }
 
 func (bl BusinessLogic) DeleteEvent(ctx context.Context, id int64) error {
-	return bl.db.DeleteEvent(ctx, id)
-}
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	c, err := bl.eventAccess(ctx, id, login, types.ShareWrite)
+	if err != nil {
+		return err
+	}
 
-func (bl BusinessLogic) UpdateEvent(ctx context.Context, e types.Event, id int64) error {
-	if e.Name != "" {
-		err := validateLength(e.Name)
+	return bl.db.InTransaction(ctx, func(ctx context.Context) error {
+		err := bl.db.DeleteEvent(ctx, id, c.Owner)
 		if err != nil {
 			return err
 		}
+
+		return bl.emitDeleted(ctx, id, c.Owner)
+	})
+}
+
+// UpdateEvent changes the fields given in the update, the other ones keep their stored values.
+// Turning an event all-day or back into a timed one requires new start and end times.
+func (bl BusinessLogic) UpdateEvent(ctx context.Context, u types.EventUpdate, id int64) error {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
 	}
 
-	e, err := bl.eventToUTC(ctx, e)
+	e := u.Event
+
+	e.Recurrence, err = validateRecurrence(e.Recurrence)
 	if err != nil {
 		return err
 	}
 
-	return bl.db.UpdateEvent(ctx, e, id)
-}
+	err = validateTimezone(e.Timezone)
+	if err != nil {
+		return err
+	}
 
-func (bl BusinessLogic) eventToUserTime(ctx context.Context, t time.Time) (time.Time, error) {
-	userLocation, ok := contextHelpers.RetrieveTimezoneFromContext(ctx)
-	if !ok {
-		return t, fmt.Errorf("%w: failed to fetch timezone from context", customErrors.ErrUnexpected)
+	c, err := bl.eventAccess(ctx, id, login, types.ShareWrite)
+	if err != nil {
+		return err
 	}
 
-	location, err := time.LoadLocation(userLocation)
+	stored, err := bl.db.GetEvent(ctx, id, c.Owner)
 	if err != nil {
-		return t, fmt.Errorf("failed to load location: %w", err)
+		return err
 	}
 
-	t = t.In(location)
-	return t, nil
-}
+	if e.CalendarID != 0 {
+		err = bl.moveTarget(ctx, c, e.CalendarID, login)
+		if err != nil {
+			return err
+		}
+	}
 
-func (bl BusinessLogic) eventToUTC(ctx context.Context, e types.Event) (types.Event, error) {
-	userLocation, ok := contextHelpers.RetrieveTimezoneFromContext(ctx)
-	if !ok {
-		return e, fmt.Errorf("%w: failed to fetch timezone from context", customErrors.ErrUnexpected)
+	// Times of the update are given in the timezone of the event, unless the update changes it,
+	// and they are dates when the event is all-day
+	if e.Timezone == "" {
+		e.Timezone = stored.Timezone
 	}
 
-	startTimeWithLocation := bl.newDateWithLocation(e.StartTime, userLocation)
+	e.AllDay = stored.AllDay
+	if u.AllDay != nil {
+		e.AllDay = *u.AllDay
+	}
 
-	if !e.StartTime.IsZero() {
-		e.StartTime = startTimeWithLocation.In(time.UTC)
+	if e.AllDay != stored.AllDay && (e.StartTime.IsZero() || e.EndTime.IsZero()) {
+		return fmt.Errorf("%w: start and end times are required to make an event all-day or timed",
+			customErrors.ErrBadRequest)
 	}
 
-	endTimeWithLocation := bl.newDateWithLocation(e.EndTime, userLocation)
-	if !e.EndTime.IsZero() {
-		e.EndTime = endTimeWithLocation.In(time.UTC)
+	e, err = bl.eventToUTC(ctx, e)
+	if err != nil {
+		return err
 	}
 
-	if !e.AlertTime.IsZero() {
-		alertTimeWithLocation := bl.newDateWithLocation(e.AlertTime, userLocation)
-		e.AlertTime = alertTimeWithLocation.In(time.UTC)
+	// The update is validated together with the fields it keeps
+	updated := updatedEvent(stored, e)
+
+	err = validateEvent(updated)
+	if err != nil {
+		return err
 	}
 
-	return e, nil
-}
+	err = validateAttendees(e.Attendees, c.Owner)
+	if err != nil {
+		return err
+	}
 
-func (bl BusinessLogic) newDateWithLocation(t time.Time, locStr string) time.Time {
-	loc, err := time.LoadLocation(locStr)
+	attendees, changed, err := bl.updatedAttendees(ctx, stored, updated, e.Attendees, c.Owner)
 	if err != nil {
-		log.Println(err)
+		return err
 	}
 
-	newDate := time.Date(
-		t.Year(),
-		t.Month(),
-		t.Day(),
-		t.Hour(),
-		t.Minute(),
-		t.Second(),
-		t.Nanosecond(),
-		loc)
+	return bl.db.InTransaction(ctx, func(ctx context.Context) error {
+		err := bl.db.UpdateEvent(ctx, types.EventUpdate{Event: e, AllDay: &e.AllDay}, id, c.Owner)
+		if err != nil {
+			return err
+		}
 
-	return newDate
+		if changed {
+			err = bl.db.SetAttendees(ctx, id, attendees, c.Owner)
+			if err != nil {
+				return err
+			}
+		}
+
+		return bl.emitUpdated(ctx, id, c.Owner)
+	})
 }
 
-func validatePostRequest(e types.Event) error {
-	if e.Name == "" || e.StartTime.IsZero() || e.EndTime.IsZero() {
-		return fmt.Errorf("%w: invalid post request", customErrors.ErrBadRequest)
+// ExportEvents returns events of the user as an iCalendar object. Without filters recurring events
+// are exported as series together with their exceptions, filters expand them into the matching occurrences.
+func (bl BusinessLogic) ExportEvents(ctx context.Context, f types.Filters) ([]byte, error) {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := ical.Calendar{Stamp: time.Now()}
+
+	c.Location, err = bl.userLocation(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	c.Events, err = bl.GetEvents(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filtered events are expanded into occurrences, otherwise series are exported with their exceptions
+	if f.Day == 0 && f.Month == 0 && f.Year == 0 && f.From.IsZero() && f.To.IsZero() {
+		exceptions, err := bl.visibleExceptions(ctx, c.Events, login)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range c.Events {
+			c.Exceptions = append(c.Exceptions, exceptions[e.ID]...)
+		}
+	}
+
+	var b bytes.Buffer
+	err = ical.Encode(&b, c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to encode calendar: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// ImportEvents adds VEVENTs of an iCalendar object as events of the user. A VEVENT with the UID of an event
+// imported before is skipped, the one which cannot be added is rejected and the report tells why.
+// Overrides of single occurrences are applied to the series imported together with them.
+func (bl BusinessLogic) ImportEvents(ctx context.Context, r io.Reader) (types.ImportReport, error) {
+	var report types.ImportReport
+
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	vevents, err := ical.Decode(r, loc)
+	if err != nil {
+		return report, fmt.Errorf("%w: %v", customErrors.ErrBadRequest, err)
+	}
+
+	report.Items = make([]types.ImportItem, len(vevents))
+	series := make(map[string]int64) // UID -> ID of the recurring event created by this import
+
+	// Series have to be added before the overrides of their occurrences
+	for _, overrides := range []bool{false, true} {
+		for i, v := range vevents {
+			if (v.RecurrenceID != nil) != overrides {
+				continue
+			}
+
+			item := types.ImportItem{UID: v.UID, Name: v.Event.Name, RecurrenceID: v.RecurrenceID}
+
+			switch {
+			case v.Err != nil:
+				err = v.Err
+			case overrides:
+				err = bl.importOverride(ctx, v, series, loc, login)
+			default:
+				err = bl.importEvent(ctx, v, series, loc, login)
+			}
+
+			switch {
+			case err == nil:
+				item.Status = types.ImportCreated
+				report.Created++
+			case errors.Is(err, errDuplicateUID):
+				item.Status = types.ImportSkipped
+				item.Reason = err.Error()
+				report.Skipped++
+			case errors.Is(err, customErrors.ErrUnexpected):
+				return report, err
+			default:
+				item.Status = types.ImportRejected
+				item.Reason = err.Error()
+				report.Rejected++
+			}
+
+			report.Items[i] = item
+		}
+	}
+
+	return report, nil
+}
+
+// errDuplicateUID marks VEVENTs skipped by the import
+var errDuplicateUID = errors.New("an event with the same UID already exists")
+
+func (bl BusinessLogic) importEvent(ctx context.Context, v ical.VEvent, series map[string]int64, loc *time.Location,
+	login string) error {
+	if v.UID == "" && len(v.ExDates) > 0 {
+		return errors.New("EXDATE requires the event to have a UID")
+	}
+
+	if v.UID != "" {
+		_, err := bl.db.GetEventByUID(ctx, v.UID, login)
+		if err == nil {
+			return errDuplicateUID
+		}
+
+		if !errors.Is(err, customErrors.ErrNotFound) {
+			return err
+		}
+	}
+
+	// Times of events with a timezone are added in it, so that they keep the offsets of the calendar they come from
+	e := eventInLocation(v.Event, seriesLocation(v.Event, loc))
+	e.UID = v.UID
+	e.AllDay = v.AllDay
+
+	err := bl.AddEvent(ctx, e)
+	if err != nil || v.UID == "" || e.Recurrence == "" {
+		return err
+	}
+
+	created, err := bl.db.GetEventByUID(ctx, v.UID, login)
+	if err != nil {
+		return err
+	}
+	series[v.UID] = created.ID
+
+	for _, t := range v.ExDates {
+		err = bl.DeleteOccurrence(ctx, created.ID, t.In(loc), types.ScopeThis)
+		// EXDATEs which do not match any occurrence are ignored, like iCalendar clients do
+		if err != nil && !errors.Is(err, customErrors.ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (bl BusinessLogic) importOverride(ctx context.Context, v ical.VEvent, series map[string]int64,
+	loc *time.Location, login string) error {
+	if v.UID == "" {
+		return errors.New("RECURRENCE-ID requires the event to have a UID")
+	}
+
+	id, ok := series[v.UID]
+	if !ok {
+		// The series was imported before, so is the override
+		_, err := bl.db.GetEventByUID(ctx, v.UID, login)
+		if err == nil {
+			return errDuplicateUID
+		}
+
+		return fmt.Errorf("RECURRENCE-ID: no recurring event with UID %q in the calendar", v.UID)
+	}
+
+	stored, err := bl.db.GetEvent(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	// Occurrences keep the timezone of their series, times of the override are given in it
+	seriesLoc := loc
+	if !stored.AllDay {
+		seriesLoc = seriesLocation(stored, loc)
+	}
+
+	e := eventInLocation(v.Event, seriesLoc)
+	e.Timezone = ""
+
+	return bl.UpdateOccurrence(ctx, e, id, v.RecurrenceID.In(loc), types.ScopeThis)
+}
+
+// UpdateOccurrence modifies an occurrence of a recurring event identified by its original start time.
+// Depending on the scope only this occurrence, this and the following ones or the whole series is changed.
+func (bl BusinessLogic) UpdateOccurrence(ctx context.Context, e types.Event, id int64, originalStart time.Time,
+	scope types.OccurrenceScope) error {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = validateScope(scope)
+	if err != nil {
+		return err
+	}
+
+	if scope == types.ScopeAll {
+		u := types.EventUpdate{Event: e}
+		if e.AllDay {
+			u.AllDay = &e.AllDay
+		}
+
+		return bl.UpdateEvent(ctx, u, id)
+	}
+
+	e.Recurrence, err = validateRecurrence(e.Recurrence)
+	if err != nil {
+		return err
+	}
+
+	err = validateTimezone(e.Timezone)
+	if err != nil {
+		return err
+	}
+
+	c, err := bl.eventAccess(ctx, id, login, types.ShareWrite)
+	if err != nil {
+		return err
+	}
+
+	series, o, err := bl.seriesOccurrence(ctx, id, originalStart, c.Owner)
+	if err != nil {
+		return err
+	}
+
+	if e.CalendarID != 0 && e.CalendarID != series.CalendarID {
+		if scope == types.ScopeThis {
+			return fmt.Errorf("%w: calendar of a single occurrence cannot be changed", customErrors.ErrBadRequest)
+		}
+
+		err = bl.moveTarget(ctx, c, e.CalendarID, login)
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.AllDay && !series.AllDay {
+		return fmt.Errorf("%w: occurrences cannot be made all-day, the whole series can", customErrors.ErrBadRequest)
+	}
+
+	if e.Timezone == "" {
+		e.Timezone = series.Timezone
+	}
+
+	e.AllDay = series.AllDay
+
+	e, err = bl.eventToUTC(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	if scope == types.ScopeFollowing {
+		following := mergeEvent(occurrence(series, *o.OriginalStart), e)
+
+		err = validateEvent(following)
+		if err != nil {
+			return err
+		}
+
+		err = validateAttendees(e.Attendees, c.Owner)
+		if err != nil {
+			return err
+		}
+
+		// The following occurrences are attended by the attendees of the series, unless the update changes them
+		current, err := bl.db.GetAttendees(ctx, []int64{series.ID}, c.Owner)
+		if err != nil {
+			return err
+		}
+		following.Attendees = nextAttendees(current[series.ID], e.Attendees, timeChanged(o, following))
+
+		return bl.splitSeries(ctx, series, *o.OriginalStart, &following, c.Owner)
+	}
+
+	// Attendees answer invitations to whole series
+	if e.Attendees != nil {
+		return fmt.Errorf("%w: attendees of a single occurrence cannot be changed", customErrors.ErrBadRequest)
+	}
+
+	if e.Recurrence != "" {
+		return fmt.Errorf("%w: recurrence of a single occurrence cannot be changed", customErrors.ErrBadRequest)
+	}
+
+	if e.Timezone != series.Timezone {
+		return fmt.Errorf("%w: timezone of a single occurrence cannot be changed", customErrors.ErrBadRequest)
+	}
+
+	o = mergeEvent(o, e)
+
+	err = validateEvent(o)
+	if err != nil {
+		return err
+	}
+
+	return bl.saveException(ctx, types.EventException{
+		EventID:       id,
+		OriginalStart: o.OriginalStart.UTC(),
+		Name:          o.Name,
+		StartTime:     o.StartTime.UTC(),
+		EndTime:       o.EndTime.UTC(),
+		Description:   o.Description,
+		AlertTime:     o.AlertTime.UTC(),
+	}, c.Owner)
+}
+
+// DeleteOccurrence cancels an occurrence of a recurring event identified by its original start time.
+// Depending on the scope only this occurrence, this and the following ones or the whole series is removed.
+func (bl BusinessLogic) DeleteOccurrence(ctx context.Context, id int64, originalStart time.Time,
+	scope types.OccurrenceScope) error {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = validateScope(scope)
+	if err != nil {
+		return err
+	}
+
+	if scope == types.ScopeAll {
+		return bl.DeleteEvent(ctx, id)
+	}
+
+	c, err := bl.eventAccess(ctx, id, login, types.ShareWrite)
+	if err != nil {
+		return err
+	}
+
+	series, o, err := bl.seriesOccurrence(ctx, id, originalStart, c.Owner)
+	if err != nil {
+		return err
+	}
+
+	if scope == types.ScopeFollowing {
+		return bl.splitSeries(ctx, series, *o.OriginalStart, nil, c.Owner)
+	}
+
+	return bl.saveException(ctx, types.EventException{
+		EventID:       id,
+		OriginalStart: o.OriginalStart.UTC(),
+		Cancelled:     true,
+		Name:          o.Name,
+		StartTime:     o.StartTime.UTC(),
+		EndTime:       o.EndTime.UTC(),
+		Description:   o.Description,
+		AlertTime:     o.AlertTime.UTC(),
+	}, c.Owner)
+}
+
+// seriesOccurrence returns the recurring event with the given ID together with its occurrence originally starting
+// at originalStart, which is interpreted in the user's timezone like every other time sent by the user,
+// or as a date for all-day events. The occurrence is in the timezone the series is expanded in.
+// Exceptions already stored for it are applied.
+func (bl BusinessLogic) seriesOccurrence(ctx context.Context, id int64, originalStart time.Time,
+	login string) (types.Event, types.Event, error) {
+	series, err := bl.db.GetEvent(ctx, id, login)
+	if err != nil {
+		return series, types.Event{}, err
+	}
+
+	if series.Recurrence == "" {
+		return series, types.Event{}, fmt.Errorf("%w: event %d is not recurring", customErrors.ErrBadRequest, id)
+	}
+
+	rule, err := recurrence.Parse(series.Recurrence)
+	if err != nil {
+		return series, types.Event{}, fmt.Errorf("%w: recurrence of event %d: %v", customErrors.ErrUnexpected, id, err)
+	}
+
+	userLoc, err := bl.userLocation(ctx)
+	if err != nil {
+		return series, types.Event{}, err
+	}
+
+	loc := seriesLocation(series, userLoc)
+	start := newDateWithLocation(originalStart, userLoc).In(loc)
+	if series.AllDay {
+		start = date(originalStart)
+	}
+
+	if !rule.Includes(series.StartTime.In(loc), series.EndTime.Sub(series.StartTime), start) {
+		return series, types.Event{}, fmt.Errorf("%w: event %d has no occurrence starting at %s",
+			customErrors.ErrNotFound, id, start.Format(time.RFC3339))
+	}
+
+	o := occurrence(series, start)
+
+	exceptions, err := bl.db.GetEventExceptions(ctx, []int64{id}, login)
+	if err != nil {
+		return series, o, err
+	}
+
+	for _, ex := range exceptions {
+		if !ex.OriginalStart.Equal(start) {
+			continue
+		}
+
+		if ex.Cancelled {
+			return series, o, fmt.Errorf("%w: occurrence starting at %s was cancelled",
+				customErrors.ErrNotFound, start.Format(time.RFC3339))
+		}
+
+		o = applyException(o, ex, loc)
+	}
+
+	return series, o, nil
+}
+
+// splitSeries ends the series before the occurrence originally starting at originalStart,
+// the following series replaces the remaining occurrences when given.
+func (bl BusinessLogic) splitSeries(ctx context.Context, series types.Event, originalStart time.Time,
+	following *types.Event, login string) error {
+	rule, err := recurrence.Parse(series.Recurrence)
+	if err != nil {
+		return fmt.Errorf("%w: recurrence of event %d: %v", customErrors.ErrUnexpected, series.ID, err)
+	}
+
+	before := len(rule.Expand(series.StartTime.In(originalStart.Location()), series.EndTime.Sub(series.StartTime),
+		time.Time{}, originalStart))
+
+	// Splitting at the first occurrence replaces the whole series
+	truncated := ""
+	if before > 0 {
+		r := rule
+		if r.Count > 0 {
+			r.Count = before
+		} else {
+			r.Until = originalStart.Add(-time.Second).UTC()
+			r.UntilDate = false
+		}
+		truncated = r.String()
+	}
+
+	if following != nil {
+		following.ID = 0
+		following.UID = ""
+		following.OriginalStart = nil
+		following.StartTime = following.StartTime.UTC()
+		following.EndTime = following.EndTime.UTC()
+		following.AlertTime = following.AlertTime.UTC()
+
+		if following.Recurrence == series.Recurrence && rule.Count > 0 {
+			r := rule
+			r.Count -= before
+			following.Recurrence = r.String()
+		}
+	}
+
+	return bl.db.InTransaction(ctx, func(ctx context.Context) error {
+		err := bl.db.SplitEvent(ctx, series.ID, originalStart.UTC(), truncated, login)
+		if err != nil {
+			return err
+		}
+
+		if truncated == "" {
+			err = bl.emitDeleted(ctx, series.ID, login)
+		} else {
+			err = bl.emitUpdated(ctx, series.ID, login)
+		}
+
+		if err != nil || following == nil {
+			return err
+		}
+
+		following.ID, err = bl.db.AddEvent(ctx, *following, login)
+		if err != nil {
+			return err
+		}
+
+		if len(following.Attendees) > 0 {
+			err = bl.db.SetAttendees(ctx, following.ID, following.Attendees, login)
+			if err != nil {
+				return err
+			}
+		}
+
+		return bl.emitEvent(ctx, types.EventCreated, *following, login)
+	})
+}
+
+// saveException stores an exception of a recurring event, webhooks are notified about the update of the series
+func (bl BusinessLogic) saveException(ctx context.Context, ex types.EventException, login string) error {
+	return bl.db.InTransaction(ctx, func(ctx context.Context) error {
+		err := bl.db.SaveEventException(ctx, ex, login)
+		if err != nil {
+			return err
+		}
+
+		return bl.emitUpdated(ctx, ex.EventID, login)
+	})
+}
+
+// emit writes a webhook message to the outbox. It has to be called in the transaction of the change,
+// so that the message is stored if and only if the change is.
+func (bl BusinessLogic) emit(ctx context.Context, t types.WebhookEventType, data interface{}, login string) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("%w: failed to encode webhook message: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return bl.db.AddOutboxMessage(ctx, types.OutboxMessage{Type: t, Data: payload, CreatedAt: time.Now().UTC()}, login)
+}
+
+// emitEvent writes a webhook message with the event in the timezone of the user
+func (bl BusinessLogic) emitEvent(ctx context.Context, t types.WebhookEventType, e types.Event, login string) error {
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return err
+	}
+
+	return bl.emit(ctx, t, eventInLocation(e, loc), login)
+}
+
+// emitUpdated writes a webhook message with the event as it is after the update
+func (bl BusinessLogic) emitUpdated(ctx context.Context, id int64, login string) error {
+	e, err := bl.db.GetEvent(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	events, err := bl.withAttendees(ctx, []types.Event{e}, login)
+	if err != nil {
+		return err
+	}
+
+	return bl.emitEvent(ctx, types.EventUpdated, events[0], login)
+}
+
+// emitDeleted writes a webhook message about a deleted event, which is sent with its ID only
+func (bl BusinessLogic) emitDeleted(ctx context.Context, id int64, login string) error {
+	return bl.emit(ctx, types.EventDeleted, struct {
+		ID int64 `json:"id"`
+	}{id}, login)
+}
+
+// seriesExceptions fetches exceptions of the recurring events among the given ones, grouped by the event ID
+func (bl BusinessLogic) seriesExceptions(ctx context.Context, events []types.Event,
+	login string) (map[int64][]types.EventException, error) {
+	var ids []int64
+	for _, e := range events {
+		if e.Recurrence != "" {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	exceptions, err := bl.db.GetEventExceptions(ctx, ids, login)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[int64][]types.EventException)
+	for _, ex := range exceptions {
+		grouped[ex.EventID] = append(grouped[ex.EventID], ex)
+	}
+
+	return grouped, nil
+}
+
+func (bl BusinessLogic) eventToUserTime(ctx context.Context, t time.Time) (time.Time, error) {
+	userLocation, ok := contextHelpers.RetrieveTimezoneFromContext(ctx)
+	if !ok {
+		return t, fmt.Errorf("%w: failed to fetch timezone from context", customErrors.ErrUnexpected)
+	}
+
+	location, err := time.LoadLocation(userLocation)
+	if err != nil {
+		return t, fmt.Errorf("failed to load location: %w", err)
+	}
+
+	t = t.In(location)
+	return t, nil
+}
+
+func (bl BusinessLogic) userLocation(ctx context.Context) (*time.Location, error) {
+	userLocation, ok := contextHelpers.RetrieveTimezoneFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%w: failed to fetch timezone from context", customErrors.ErrUnexpected)
+	}
+
+	location, err := time.LoadLocation(userLocation)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to load location: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return location, nil
+}
+
+// seriesLocation returns the location a recurring event is expanded in, so that its occurrences keep their
+// wall-clock time across DST changes. It is the timezone of the event, or the given location of the user.
+// Dates of all-day events are expanded in UTC.
+func seriesLocation(e types.Event, loc *time.Location) *time.Location {
+	if e.AllDay {
+		return time.UTC
+	}
+
+	if e.Timezone == "" {
+		return loc
+	}
+
+	eventLoc, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		return loc
+	}
+
+	return eventLoc
+}
+
+// expandFiltered expands recurring events into their occurrences
+// matching the day/month/year filters in the user's timezone.
+func (bl BusinessLogic) expandFiltered(ctx context.Context, events []types.Event, f types.Filters,
+	login string) ([]types.Event, error) {
+	var occurrences []types.Event
+	var loc *time.Location
+
+	exceptions, err := bl.seriesExceptions(ctx, events, login)
+	if err != nil {
+		return occurrences, err
+	}
+
+	for _, e := range events {
+		if e.Recurrence == "" {
+			continue
+		}
+
+		if loc == nil {
+			loc, err = bl.userLocation(ctx)
+			if err != nil {
+				return occurrences, err
+			}
+		}
+
+		from, to := filtersWindow(f, loc)
+
+		expanded, err := expand(e, exceptions[e.ID], from, to, loc)
+		if err != nil {
+			return occurrences, err
+		}
+
+		for _, o := range expanded {
+			if matchesDays(o, f, loc) {
+				occurrences = append(occurrences, o)
+			}
+		}
+	}
+
+	return occurrences, nil
+}
+
+// eventToUTC converts wall-clock times of the event to UTC, they are given in the timezone of the event
+// or in the user's one when the event has none. Times of all-day events are turned into dates.
+func (bl BusinessLogic) eventToUTC(ctx context.Context, e types.Event) (types.Event, error) {
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return e, err
+	}
+
+	if e.Timezone != "" {
+		loc, err = time.LoadLocation(e.Timezone)
+		if err != nil {
+			return e, fmt.Errorf("%w: unknown timezone %q", customErrors.ErrBadRequest, e.Timezone)
+		}
+	}
+
+	if e.AllDay {
+		e = toDates(e)
+	}
+
+	if !e.StartTime.IsZero() && !e.AllDay {
+		e.StartTime = newDateWithLocation(e.StartTime, loc).In(time.UTC)
+	}
+
+	if !e.EndTime.IsZero() && !e.AllDay {
+		e.EndTime = newDateWithLocation(e.EndTime, loc).In(time.UTC)
+	}
+
+	if !e.AlertTime.IsZero() {
+		e.AlertTime = newDateWithLocation(e.AlertTime, loc).In(time.UTC)
+	}
+
+	return e, nil
+}
+
+// newDateWithLocation returns the wall-clock time of t in the given location, the offset of t is ignored
+func newDateWithLocation(t time.Time, loc *time.Location) time.Time {
+	newDate := time.Date(
+		t.Year(),
+		t.Month(),
+		t.Day(),
+		t.Hour(),
+		t.Minute(),
+		t.Second(),
+		t.Nanosecond(),
+		loc)
+
+	return newDate
+}
+
+// retrieveLogin returns the login of the authenticated user, every event operation is scoped to it.
+func retrieveLogin(ctx context.Context) (string, error) {
+	login, ok := contextHelpers.RetrieveLoginFromContext(ctx)
+	if !ok {
+		return login, fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+	}
+
+	return login, nil
+}
+
+// expand returns the occurrences of a recurring event overlapping the [from, to) window with its exceptions applied,
+// their times are in the given location. The series is expanded in the timezone of the event, or in the given
+// location when it has none, so occurrences keep their local time across DST changes.
+func expand(e types.Event, exceptions []types.EventException, from, to time.Time,
+	viewerLoc *time.Location) ([]types.Event, error) {
+	var occurrences []types.Event
+
+	// Dates of all-day events are expanded over the dates the window spans in the given location
+	if e.AllDay {
+		from, to = dateWindow(from, to, viewerLoc)
+		viewerLoc = time.UTC
+	}
+
+	loc := seriesLocation(e, viewerLoc)
+
+	rule, err := recurrence.Parse(e.Recurrence)
+	if err != nil {
+		return occurrences, fmt.Errorf("%w: recurrence of event %d: %v", customErrors.ErrUnexpected, e.ID, err)
+	}
+
+	dtstart := e.StartTime.In(loc)
+	duration := e.EndTime.Sub(e.StartTime)
+
+	for _, start := range rule.Expand(dtstart, duration, from, to) {
+		o := occurrence(e, start)
+
+		if ex, ok := findException(exceptions, start); ok {
+			if ex.Cancelled {
+				continue
+			}
+
+			o = applyException(o, ex, loc)
+			if !recurrence.Overlaps(o.StartTime, o.EndTime, from, to) {
+				continue
+			}
+		}
+
+		occurrences = append(occurrences, o)
+	}
+
+	// Occurrences moved into the window from outside of it
+	for _, ex := range exceptions {
+		if ex.Cancelled || !recurrence.Overlaps(ex.StartTime, ex.EndTime, from, to) {
+			continue
+		}
+
+		start := ex.OriginalStart.In(loc)
+		if recurrence.Overlaps(start, start.Add(duration), from, to) || !rule.Includes(dtstart, duration, start) {
+			continue
+		}
+
+		occurrences = append(occurrences, applyException(occurrence(e, start), ex, loc))
+	}
+
+	for i, o := range occurrences {
+		originalStart := o.OriginalStart.In(viewerLoc)
+		occurrences[i] = eventInLocation(o, viewerLoc)
+		occurrences[i].OriginalStart = &originalStart
+	}
+
+	return occurrences, nil
+}
+
+// occurrence returns a copy of the recurring event shifted to the given start time
+func occurrence(e types.Event, start time.Time) types.Event {
+	o := e
+	o.StartTime = start
+	o.EndTime = start.Add(e.EndTime.Sub(e.StartTime))
+	o.OriginalStart = &start
+
+	if !e.AlertTime.IsZero() {
+		o.AlertTime = start.Add(e.AlertTime.Sub(e.StartTime))
+	}
+
+	return o
+}
+
+func findException(exceptions []types.EventException, originalStart time.Time) (types.EventException, bool) {
+	for _, ex := range exceptions {
+		if ex.OriginalStart.Equal(originalStart) {
+			return ex, true
+		}
+	}
+
+	return types.EventException{}, false
+}
+
+func applyException(o types.Event, ex types.EventException, loc *time.Location) types.Event {
+	o.Name = ex.Name
+	o.Description = ex.Description
+	o.StartTime = ex.StartTime.In(loc)
+	o.EndTime = ex.EndTime.In(loc)
+	o.AlertTime = time.Time{}
+
+	if !ex.AlertTime.IsZero() {
+		o.AlertTime = ex.AlertTime.In(loc)
+	}
+
+	return o
+}
+
+// mergeEvent overrides fields of the event with the ones set in the update. Moving the start time
+// keeps the duration of the event and the advance of its alert, unless they are given as well.
+func mergeEvent(e, update types.Event) types.Event {
+	if update.Name != "" {
+		e.Name = update.Name
+	}
+
+	if update.Description != "" {
+		e.Description = update.Description
+	}
+
+	if update.Recurrence != "" {
+		e.Recurrence = update.Recurrence
+	}
+
+	if update.Timezone != "" {
+		e.Timezone = update.Timezone
+	}
+
+	if update.CalendarID != 0 {
+		e.CalendarID = update.CalendarID
+	}
+
+	if !update.StartTime.IsZero() {
+		duration := e.EndTime.Sub(e.StartTime)
+		advance := e.StartTime.Sub(e.AlertTime)
+
+		if !e.AlertTime.IsZero() {
+			e.AlertTime = update.StartTime.Add(-advance)
+		}
+
+		e.StartTime = update.StartTime
+		e.EndTime = update.StartTime.Add(duration)
+	}
+
+	if !update.EndTime.IsZero() {
+		e.EndTime = update.EndTime
+	}
+
+	if !update.AlertTime.IsZero() {
+		e.AlertTime = update.AlertTime
+	}
+
+	return e
+}
+
+// eventInLocation returns the event with times in the location, dates of all-day events are the same everywhere
+func eventInLocation(e types.Event, loc *time.Location) types.Event {
+	if !e.AllDay {
+		e.StartTime = e.StartTime.In(loc)
+		e.EndTime = e.EndTime.In(loc)
+	}
+
+	if !e.AlertTime.IsZero() {
+		e.AlertTime = e.AlertTime.In(loc)
+	}
+
+	return e
+}
+
+// filtersWindow returns the time window the day/month/year filters can match in
+func filtersWindow(f types.Filters, loc *time.Location) (time.Time, time.Time) {
+	switch {
+	case f.Year == 0:
+		return time.Time{}, time.Now().Add(recurringHorizon)
+	case f.Month == 0:
+		from := time.Date(f.Year, time.January, 1, 0, 0, 0, 0, loc)
+		return from, from.AddDate(1, 0, 0)
+	case f.Day == 0:
+		from := time.Date(f.Year, time.Month(f.Month), 1, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 1, 0)
+	default:
+		from := time.Date(f.Year, time.Month(f.Month), f.Day, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 0, 1)
+	}
+}
+
+func matchesFilters(t time.Time, f types.Filters) bool {
+	return (f.Day == 0 || t.Day() == f.Day) &&
+		(f.Month == 0 || int(t.Month()) == f.Month) &&
+		(f.Year == 0 || t.Year() == f.Year)
+}
+
+func sortByStartTime(s []types.Event) {
+	sort.SliceStable(s, func(i, j int) bool { return s[i].StartTime.Before(s[j].StartTime) })
+}
+
+// validateRecurrence checks the RRULE of an event and returns it in its canonical form
+func validateRecurrence(s string) (string, error) {
+	if s == "" {
+		return s, nil
+	}
+
+	r, err := recurrence.Parse(s)
+	if err != nil {
+		return s, fmt.Errorf("%w: %v", customErrors.ErrBadRequest, err)
+	}
+
+	return r.String(), nil
+}
+
+// validateUID checks that the user has no event with the given UID yet
+func (bl BusinessLogic) validateUID(ctx context.Context, uid, login string) error {
+	_, err := bl.db.GetEventByUID(ctx, uid, login)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %v", customErrors.ErrBadRequest, errDuplicateUID)
+	case errors.Is(err, customErrors.ErrNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+func validateScope(scope types.OccurrenceScope) error {
+	switch scope {
+	case types.ScopeThis, types.ScopeFollowing, types.ScopeAll:
+		return nil
+	default:
+		return fmt.Errorf("%w: scope should be one of this, following, all", customErrors.ErrBadRequest)
+	}
+}
+
+// validateTimezone accepts IANA zones, e.g. Asia/Tokyo, the empty timezone stands for the user's one.
+// "Local" is rejected, as it depends on the server.
+func validateTimezone(s string) error {
+	if s == "" {
+		return nil
+	}
 
-func validateLength(s string) error {
-	if len([]rune(s)) > 255 {
-		return fmt.Errorf("%w: length should be less than 255 characters", customErrors.ErrBadRequest)
+	_, err := time.LoadLocation(s)
+	if err != nil || s == "Local" {
+		return fmt.Errorf("%w: unknown timezone %q", customErrors.ErrBadRequest, s)
 	}
 
 	return nil
@@ -3,11 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bubo-py/McK/contextHelpers"
 	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/events/pagination"
 	"github.com/bubo-py/McK/events/repositories/memoryStorage"
 	"github.com/bubo-py/McK/events/repositories/mocks"
 	"github.com/bubo-py/McK/types"
@@ -15,10 +17,14 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-var timezoneFetchErr = fmt.Errorf("%w: failed to fetch timezone from context", customErrors.ErrUnexpected)
+var (
+	timezoneFetchErr = fmt.Errorf("%w: failed to fetch timezone from context", customErrors.ErrUnexpected)
+	loginFetchErr    = fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+)
 
 func TestGetEvents(t *testing.T) {
 	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
 	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
 
 	ti := time.Date(2015, 5, 15, 20, 30, 0, 0, time.UTC)
@@ -98,6 +104,16 @@ func TestGetEvents(t *testing.T) {
 			filters:  types.Filters{Day: 10, Month: 10, Year: -20},
 			expError: fmt.Errorf("%w: year", customErrors.ErrBadRequest),
 		},
+		{
+			testName: "GetEventsWithRangeBadRequest_Combined",
+			filters:  types.Filters{Day: 10, From: ti, To: ti2},
+			expError: fmt.Errorf("%w: from and to cannot be combined with day, month or year", customErrors.ErrBadRequest),
+		},
+		{
+			testName: "GetEventsWithRangeBadRequest_NoTo",
+			filters:  types.Filters{From: ti},
+			expError: fmt.Errorf("%w: both from and to are required", customErrors.ErrBadRequest),
+		},
 		{
 			testName:   "GetEventsWithFilters3",
 			filters:    types.Filters{Day: 4, Month: 10, Year: 2019},
@@ -114,15 +130,20 @@ func TestGetEvents(t *testing.T) {
 			mockDB := mocks.NewMockDatabaseRepository(mockCtrl)
 			bl := InitBusinessLogic(mockDB)
 
+			// The user has no calendars shared with them and attends no events of other users
+			mockDB.EXPECT().GetCalendars(ctx, "hello").Return(nil, nil).AnyTimes()
+			mockDB.EXPECT().GetInvitations(ctx, "hello").Return(nil, nil).AnyTimes()
+			mockDB.EXPECT().GetAttendees(ctx, gomock.Any(), "hello").Return(nil, nil).AnyTimes()
+
 			if tc.noFilters {
-				mockDB.EXPECT().GetEvents(ctx).Return(tc.mockOutput, tc.mockError).Times(1)
+				mockDB.EXPECT().GetEvents(ctx, "hello").Return(tc.mockOutput, tc.mockError).Times(1)
 
 				e, err := bl.GetEvents(ctx, tc.filters)
 				require.Equal(t, tc.expOutput, e, "events should be equal")
 				require.Equal(t, tc.expError, err, "errors should be equal")
 			} else {
 				if tc.expError == nil {
-					mockDB.EXPECT().GetEventsFiltered(ctx, tc.filters).Return(tc.mockOutput, tc.mockError).Times(1)
+					mockDB.EXPECT().GetEventsFiltered(ctx, tc.filters, loc, "hello").Return(tc.mockOutput, tc.mockError).Times(1)
 				}
 
 				e, err := bl.GetEvents(ctx, tc.filters)
@@ -135,6 +156,7 @@ func TestGetEvents(t *testing.T) {
 
 func TestGetEvent(t *testing.T) {
 	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
 	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Asia/Tokyo")
 
 	tiUTC := time.Date(2015, 5, 15, 10, 30, 0, 0, time.UTC)
@@ -187,7 +209,9 @@ func TestGetEvent(t *testing.T) {
 			bl := InitBusinessLogic(mockDB)
 
 			if tc.callMock {
-				mockDB.EXPECT().GetEvent(ctx, tc.id).Return(tc.eventFromDB, tc.mockError)
+				mockDB.EXPECT().GetEventCalendar(ctx, tc.id, "hello").Return(types.Calendar{ID: 1, Owner: "hello"}, nil)
+				mockDB.EXPECT().GetEvent(ctx, tc.id, "hello").Return(tc.eventFromDB, tc.mockError)
+				mockDB.EXPECT().GetAttendees(ctx, gomock.Any(), "hello").Return(nil, nil).AnyTimes()
 			}
 			event, err := bl.GetEvent(ctx, tc.id)
 
@@ -199,6 +223,7 @@ func TestGetEvent(t *testing.T) {
 
 func TestAddEvent(t *testing.T) {
 	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
 	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Asia/Tokyo")
 
 	tiUTC := time.Date(2015, 5, 15, 10, 30, 0, 0, time.UTC)
@@ -206,6 +231,11 @@ func TestAddEvent(t *testing.T) {
 	loc, _ := time.LoadLocation("Asia/Tokyo")
 	tiJST := time.Date(2015, 5, 15, 19, 30, 0, 0, loc)
 
+	required := func(field string) error {
+		return customErrors.ValidationError{Fields: []customErrors.FieldError{{Field: field,
+			Code: customErrors.CodeRequired, Message: "is required"}}}
+	}
+
 	testCases := []struct {
 		testName               string
 		badRequestPresent      bool
@@ -223,10 +253,11 @@ func TestAddEvent(t *testing.T) {
 				EndTime:   tiJST,
 			},
 			eventConvertedTimezone: types.Event{
-				ID:        3,
-				Name:      "hello",
-				StartTime: tiUTC,
-				EndTime:   tiUTC,
+				ID:         3,
+				Name:       "hello",
+				StartTime:  tiUTC,
+				EndTime:    tiUTC,
+				CalendarID: 1,
 			},
 		},
 		{
@@ -238,7 +269,7 @@ func TestAddEvent(t *testing.T) {
 				StartTime: tiJST,
 				EndTime:   tiJST,
 			},
-			expError: fmt.Errorf("%w: invalid post request", customErrors.ErrBadRequest),
+			expError: required("name"),
 		},
 		{
 			testName:          "AddEventBadRequestNoStartTime",
@@ -248,7 +279,7 @@ func TestAddEvent(t *testing.T) {
 				Name:    "hello",
 				EndTime: tiJST,
 			},
-			expError: fmt.Errorf("%w: invalid post request", customErrors.ErrBadRequest),
+			expError: required("startTime"),
 		},
 		{
 			testName:          "AddEventBadRequestNoEndTime",
@@ -258,7 +289,7 @@ func TestAddEvent(t *testing.T) {
 				Name:      "hello",
 				StartTime: tiUTC,
 			},
-			expError: fmt.Errorf("%w: invalid post request", customErrors.ErrBadRequest),
+			expError: required("endTime"),
 		},
 		{
 			testName: "AddEventUnexpected",
@@ -269,10 +300,11 @@ func TestAddEvent(t *testing.T) {
 				EndTime:   tiJST,
 			},
 			eventConvertedTimezone: types.Event{
-				ID:        3,
-				Name:      "hello",
-				StartTime: tiUTC,
-				EndTime:   tiUTC,
+				ID:         3,
+				Name:       "hello",
+				StartTime:  tiUTC,
+				EndTime:    tiUTC,
+				CalendarID: 1,
 			},
 			mockError: customErrors.ErrUnexpected,
 			expError:  customErrors.ErrUnexpected,
@@ -288,12 +320,16 @@ func TestAddEvent(t *testing.T) {
 			mockDB := mocks.NewMockDatabaseRepository(mockCtrl)
 			bl := InitBusinessLogic(mockDB)
 
+			mockDB.EXPECT().GetDefaultCalendar(ctx, "hello").Return(types.Calendar{ID: 1, Name: "Personal",
+				Visibility: types.VisibilityFreeBusy, Default: true, Owner: "hello"}, nil)
+
 			if tc.badRequestPresent {
 				err := bl.AddEvent(ctx, tc.eventToAdd)
 
 				require.Equal(t, tc.expError, err, "errors should be equal")
 			} else {
-				mockDB.EXPECT().AddEvent(ctx, tc.eventConvertedTimezone).Return(tc.mockError)
+				expectTransaction(mockDB)
+				mockDB.EXPECT().AddEvent(ctx, tc.eventConvertedTimezone, "hello").Return(tc.eventConvertedTimezone.ID, tc.mockError)
 
 				err := bl.AddEvent(ctx, tc.eventToAdd)
 				require.Equal(t, tc.expError, err, "errors should be equal")
@@ -304,6 +340,7 @@ func TestAddEvent(t *testing.T) {
 
 func TestUpdateEvent(t *testing.T) {
 	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
 	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Asia/Tokyo")
 
 	tiUTC := time.Date(2015, 5, 15, 10, 30, 0, 0, time.UTC)
@@ -378,6 +415,17 @@ func TestUpdateEvent(t *testing.T) {
 				StartTime: tiUTC,
 			},
 		},
+		{
+			testName:          "UpdateEventEndBeforeStoredStart",
+			id:                3,
+			badRequestPresent: true,
+			eventToUpdate: types.Event{
+				ID:      3,
+				EndTime: tiJST.Add(-time.Hour),
+			},
+			expError: customErrors.ValidationError{Fields: []customErrors.FieldError{{Field: "endTime",
+				Code: customErrors.CodeEndBeforeStart, Message: "should not be before startTime"}}},
+		},
 		{
 			testName: "UpdateEventUnexpected",
 			id:       3,
@@ -407,140 +455,1731 @@ func TestUpdateEvent(t *testing.T) {
 			mockDB := mocks.NewMockDatabaseRepository(mockCtrl)
 			bl := InitBusinessLogic(mockDB)
 
+			// Updates are validated together with the stored event
+			stored := types.Event{ID: tc.id, Name: "stored", StartTime: tiUTC, EndTime: tiUTC}
+			mockDB.EXPECT().GetEventCalendar(ctx, tc.id, "hello").Return(types.Calendar{ID: 1, Owner: "hello"}, nil).
+				AnyTimes()
+			mockDB.EXPECT().GetAttendees(ctx, gomock.Any(), "hello").Return(nil, nil).AnyTimes()
+			mockDB.EXPECT().GetEvent(ctx, tc.id, "hello").Return(stored, nil).AnyTimes()
+
 			if tc.badRequestPresent {
-				err := bl.UpdateEvent(ctx, tc.eventToUpdate, tc.id)
+				err := bl.UpdateEvent(ctx, types.EventUpdate{Event: tc.eventToUpdate}, tc.id)
 
 				require.Equal(t, tc.expError, err, "errors should be equal")
 			} else {
-				mockDB.EXPECT().UpdateEvent(ctx, tc.eventConvertedTimezone, tc.id).Return(tc.mockError)
+				expectTransaction(mockDB)
+				u := types.EventUpdate{Event: tc.eventConvertedTimezone, AllDay: new(bool)}
+				mockDB.EXPECT().UpdateEvent(ctx, u, tc.id, "hello").Return(tc.mockError)
 
-				err := bl.UpdateEvent(ctx, tc.eventToUpdate, tc.id)
+				err := bl.UpdateEvent(ctx, types.EventUpdate{Event: tc.eventToUpdate}, tc.id)
 				require.Equal(t, tc.expError, err, "errors should be equal")
 			}
 		})
 	}
 }
 
-func TestValidatePostRequest(t *testing.T) {
-	ti := time.Date(2020, 5, 15, 20, 30, 0, 0, time.Local)
+func TestDeleteEvent(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
 
-	requestData := types.Event{
-		ID:          300,
-		Name:        "Daily meeting",
-		StartTime:   ti,
-		EndTime:     ti,
-		Description: "A daily meeting for backend team",
-		AlertTime:   ti,
+	testCases := []struct {
+		testName  string
+		id        int64
+		mockError error
+		expError  error
+	}{
+		{
+			testName: "DeleteEventNoError",
+			id:       3,
+		},
+		{
+			testName:  "DeleteEventNotFound",
+			id:        4,
+			mockError: customErrors.ErrNotFound,
+			expError:  customErrors.ErrNotFound,
+		},
 	}
 
-	invalidRequestData := types.Event{
-		ID:          400,
-		Name:        "No time meeting",
-		Description: "A daily meeting for backend team",
-	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
 
-	badReq := fmt.Errorf("%w: invalid post request", customErrors.ErrBadRequest)
-	err := validatePostRequest(invalidRequestData)
-	require.Equal(t, badReq, err)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
 
-	err = validatePostRequest(requestData)
-	require.Nil(t, err)
-}
+			mockDB := mocks.NewMockDatabaseRepository(mockCtrl)
+			bl := InitBusinessLogic(mockDB)
 
-func TestValidateLength(t *testing.T) {
-	testCases := []struct {
-		text     string
-		expError error
-	}{
-		{text: "ok ok ok", expError: nil},
-		{text: "😂", expError: nil},
-
-		//len 288, rune 96
-		{text: "嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複" +
-			"嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複",
-			expError: nil},
-
-		// len 256, rune 64
-		{text: "😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂" +
-			"😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂😂",
-			expError: nil},
-
-		// len 789, rune 263
-		{text: "嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略" +
-			"》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉" +
-			"定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》" +
-			"影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略" +
-			"》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略",
-			expError: fmt.Errorf("%w: length should be less than 255 characters", customErrors.ErrBadRequest)},
-	}
-	for i, tc := range testCases {
-		testName := fmt.Sprintf("Test %d", i)
-		t.Run(testName, func(t *testing.T) {
-			err := validateLength(tc.text)
-
-			if tc.expError != nil {
-				require.Equal(t, tc.expError, err)
-			} else {
-				require.Nil(t, err)
-			}
+			expectTransaction(mockDB)
+			mockDB.EXPECT().GetEventCalendar(ctx, tc.id, "hello").Return(types.Calendar{ID: 1, Owner: "hello"}, nil)
+			mockDB.EXPECT().DeleteEvent(ctx, tc.id, "hello").Return(tc.mockError)
+
+			err := bl.DeleteEvent(ctx, tc.id)
+			require.Equal(t, tc.expError, err, "errors should be equal")
 		})
 	}
 }
 
-func TestEventToUserTime(t *testing.T) {
+// expectTransaction lets changes run in a transaction and write any webhook messages
+func expectTransaction(mockDB *mocks.MockDatabaseRepository) {
+	mockDB.EXPECT().InTransaction(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		})
+	mockDB.EXPECT().AddOutboxMessage(gomock.Any(), gomock.Any(), "hello").Return(nil).AnyTimes()
+}
+
+func TestEventsWithoutLogin(t *testing.T) {
 	ctx := context.Background()
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	ti := time.Date(2020, 5, 15, 10, 0, 0, 0, time.UTC)
+	event := types.Event{Name: "Daily meeting", StartTime: ti, EndTime: ti}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// No repository call is expected, nothing should be reachable without knowing the owner
+	mockDB := mocks.NewMockDatabaseRepository(mockCtrl)
+	bl := InitBusinessLogic(mockDB)
+
+	_, err := bl.GetEvents(ctx, types.Filters{})
+	require.Equal(t, loginFetchErr, err)
+
+	_, err = bl.GetEvent(ctx, 1)
+	require.Equal(t, loginFetchErr, err)
+
+	err = bl.AddEvent(ctx, event)
+	require.Equal(t, loginFetchErr, err)
+
+	err = bl.UpdateEvent(ctx, types.EventUpdate{Event: event}, 1)
+	require.Equal(t, loginFetchErr, err)
+
+	err = bl.DeleteEvent(ctx, 1)
+	require.Equal(t, loginFetchErr, err)
+}
+
+func TestEventsOwnership(t *testing.T) {
+	ctxOwner := context.Background()
+	ctxOwner = contextHelpers.WriteLoginToContext(ctxOwner, "owner")
+	ctxOwner = contextHelpers.WriteTimezoneToContext(ctxOwner, "Europe/Warsaw")
+
+	ctxOther := context.Background()
+	ctxOther = contextHelpers.WriteLoginToContext(ctxOther, "other")
+	ctxOther = contextHelpers.WriteTimezoneToContext(ctxOther, "Europe/Warsaw")
 
 	db := memoryStorage.InitDatabase()
 	bl := InitBusinessLogic(db)
 
-	// UTC time, in Europe/Warsaw should be 12
 	ti := time.Date(2020, 5, 15, 10, 0, 0, 0, time.UTC)
+	event := types.Event{Name: "Daily meeting", StartTime: ti, EndTime: ti}
+
+	err := bl.AddEvent(ctxOwner, event)
+	require.Nil(t, err)
+
+	e, err := bl.GetEvents(ctxOwner, types.Filters{})
+	require.Nil(t, err)
+	require.Len(t, e, 1, "owner should see their own event")
+
+	e, err = bl.GetEvents(ctxOther, types.Filters{})
+	require.Nil(t, err)
+	require.Empty(t, e, "other users' events should not be listed")
+
+	_, err = bl.GetEvent(ctxOther, 1)
+	require.Equal(t, customErrors.ErrNotFound, err)
+
+	err = bl.UpdateEvent(ctxOther, types.EventUpdate{Event: types.Event{Name: "Hijacked"}}, 1)
+	require.Equal(t, customErrors.ErrNotFound, err)
+
+	err = bl.DeleteEvent(ctxOther, 1)
+	require.Equal(t, customErrors.ErrNotFound, err)
+
+	ownerEvent, err := bl.GetEvent(ctxOwner, 1)
+	require.Nil(t, err)
+	require.Equal(t, "Daily meeting", ownerEvent.Name, "event should stay untouched")
+
+	err = bl.DeleteEvent(ctxOwner, 1)
+	require.Nil(t, err)
+}
+
+func TestRecurringEvents(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Europe/Warsaw")
 
+	// Weekly on Mondays at 9:00 Warsaw time, the series crosses the DST change on 2023-03-26
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, loc)
 	event := types.Event{
-		ID:        300,
-		Name:      "Daily meeting",
-		StartTime: ti,
-		EndTime:   ti,
+		Name:       "Weekly sync",
+		StartTime:  ti,
+		EndTime:    ti.Add(time.Hour),
+		AlertTime:  ti.Add(-15 * time.Minute),
+		Recurrence: "freq=weekly;byday=MO;count=4",
 	}
 
-	_, err := bl.eventToUserTime(ctx, event.StartTime)
-	require.NotNilf(t, err, "Should return an error: %v", timezoneFetchErr)
-	require.Equal(t, err, timezoneFetchErr)
+	err := bl.AddEvent(ctx, event)
+	require.Nil(t, err)
+
+	stored, err := bl.GetEvent(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, "FREQ=WEEKLY;COUNT=4;BYDAY=MO", stored.Recurrence, "rule should be stored in its canonical form")
+
+	from := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	e, err := bl.GetEventsInRange(ctx, from, to)
+	require.Nil(t, err)
+	require.Len(t, e, 4)
+
+	for i, o := range e {
+		require.Equal(t, ti.AddDate(0, 0, 7*i).Day(), o.StartTime.Day())
+		require.Equal(t, 9, o.StartTime.Hour(), "occurrences should keep their local time across DST")
+		require.Equal(t, time.Hour, o.EndTime.Sub(o.StartTime))
+		require.Equal(t, 15*time.Minute, o.StartTime.Sub(o.AlertTime))
+	}
+
+	e, err = bl.GetEvents(ctx, types.Filters{Day: 3, Month: 4, Year: 2023})
+	require.Nil(t, err)
+	require.Len(t, e, 1)
+	require.Equal(t, time.Date(2023, 4, 3, 9, 0, 0, 0, loc), e[0].StartTime)
+
+	e, err = bl.GetEvents(ctx, types.Filters{Day: 4, Month: 4, Year: 2023})
+	require.Nil(t, err)
+	require.Empty(t, e)
+
+	_, err = bl.GetEventsInRange(ctx, to, from)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	event.Recurrence = "FREQ=HOURLY"
+	err = bl.AddEvent(ctx, event)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Recurrence: "FREQ=WEEKLY;COUNT=x"}}, 1)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+}
+
+func TestEventsInUserTimezone(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Asia/Tokyo")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	names := func(e []types.Event) []string {
+		var s []string
+		for _, o := range e {
+			s = append(s, o.Name)
+		}
+		return s
+	}
+
+	// 20:30 UTC on 31 December is 5:30 on 1 January in Tokyo
+	for _, e := range []types.Event{
+		{Name: "Breakfast", StartTime: time.Date(2023, 1, 1, 5, 30, 0, 0, loc), EndTime: time.Date(2023, 1, 1, 6, 30, 0, 0, loc)},
+		{Name: "Party", StartTime: time.Date(2022, 12, 31, 22, 0, 0, 0, loc), EndTime: time.Date(2023, 1, 1, 2, 0, 0, 0, loc)},
+		{Name: "Lunch", StartTime: time.Date(2023, 1, 1, 12, 0, 0, 0, loc), EndTime: time.Date(2023, 1, 1, 13, 0, 0, 0, loc)},
+		{Name: "Gym", StartTime: time.Date(2022, 12, 26, 7, 0, 0, 0, loc), EndTime: time.Date(2022, 12, 26, 8, 0, 0, 0, loc),
+			Recurrence: "FREQ=WEEKLY"},
+	} {
+		err := bl.AddEvent(ctx, e)
+		require.Nil(t, err)
+	}
+
+	e, err := bl.GetEvents(ctx, types.Filters{Day: 1, Month: 1, Year: 2023})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Breakfast", "Party", "Lunch"}, names(e),
+		"days should be evaluated in the user's timezone, the party lasts until 2:00 on 1 January")
+
+	// Wall-clock times of the user, events overlapping the interval are returned
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 1, 1, 6, 0, 0, 0, time.UTC)
+
+	e, err = bl.GetEvents(ctx, types.Filters{From: from, To: to})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Party", "Breakfast"}, names(e))
+	require.Equal(t, loc, e[1].StartTime.Location())
 
+	e, err = bl.GetEvents(ctx, types.Filters{From: from, To: from.AddDate(0, 0, 7)})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Party", "Breakfast", "Lunch", "Gym"}, names(e))
+	require.Equal(t, time.Date(2023, 1, 2, 7, 0, 0, 0, loc), e[3].StartTime)
+
+	_, err = bl.GetEvents(ctx, types.Filters{From: to, To: from})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+}
+
+func TestEventTimezones(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
 	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
 
-	event.StartTime, err = bl.eventToUserTime(ctx, event.StartTime)
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	warsaw, _ := time.LoadLocation("Europe/Warsaw")
+
+	// Times are wall-clock times of the event's timezone, 10:00 in Tokyo is 2:00 in Warsaw
+	err := bl.AddEvent(ctx, types.Event{
+		Name:      "Flight",
+		StartTime: time.Date(2023, 3, 20, 10, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2023, 3, 20, 12, 0, 0, 0, time.UTC),
+		Timezone:  "Asia/Tokyo",
+	})
 	require.Nil(t, err)
 
-	require.Equal(t, event.StartTime.Hour(), 12, "Failed to convert timezone")
+	e, err := bl.GetEvent(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, "Asia/Tokyo", e.Timezone)
+	require.Equal(t, time.Date(2023, 3, 20, 2, 0, 0, 0, warsaw), e.StartTime)
+
+	// Updates without a timezone are given in the one of the event
+	err = bl.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Name: "Flight",
+		StartTime: time.Date(2023, 3, 20, 11, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2023, 3, 20, 13, 0, 0, 0, time.UTC)}}, 1)
+	require.Nil(t, err)
+
+	e, err = bl.GetEvent(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, "Asia/Tokyo", e.Timezone)
+	require.Equal(t, time.Date(2023, 3, 20, 3, 0, 0, 0, warsaw), e.StartTime)
+
+	// The series keeps 9:00 in New York, which moves in Warsaw while DST starts on different days
+	err = bl.AddEvent(ctx, types.Event{
+		Name:       "Call",
+		StartTime:  time.Date(2023, 3, 6, 9, 0, 0, 0, time.UTC),
+		EndTime:    time.Date(2023, 3, 6, 10, 0, 0, 0, time.UTC),
+		Recurrence: "FREQ=WEEKLY;COUNT=4",
+		Timezone:   "America/New_York",
+	})
+	require.Nil(t, err)
+
+	occurrences, err := bl.GetEventsInRange(ctx, time.Date(2023, 3, 1, 0, 0, 0, 0, warsaw),
+		time.Date(2023, 4, 1, 0, 0, 0, 0, warsaw))
+	require.Nil(t, err)
+
+	var starts []time.Time
+	for _, o := range occurrences {
+		if o.Name == "Call" {
+			starts = append(starts, o.StartTime)
+		}
+	}
+	require.Equal(t, []time.Time{
+		time.Date(2023, 3, 6, 15, 0, 0, 0, warsaw),
+		time.Date(2023, 3, 13, 14, 0, 0, 0, warsaw),
+		time.Date(2023, 3, 20, 14, 0, 0, 0, warsaw),
+		time.Date(2023, 3, 27, 15, 0, 0, 0, warsaw),
+	}, starts)
+
+	// Occurrences are identified by their start time in the user's timezone
+	err = bl.UpdateOccurrence(ctx, types.Event{Timezone: "Asia/Tokyo"}, 2, time.Date(2023, 3, 13, 14, 0, 0, 0, warsaw),
+		types.ScopeThis)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest, "timezone of a single occurrence cannot change")
+
+	err = bl.DeleteOccurrence(ctx, 2, time.Date(2023, 3, 13, 14, 0, 0, 0, warsaw), types.ScopeThis)
+	require.Nil(t, err)
 
+	for _, tz := range []string{"Mars/Olympus_Mons", "Local"} {
+		err = bl.AddEvent(ctx, types.Event{Name: "Flight", StartTime: time.Now(), EndTime: time.Now(), Timezone: tz})
+		require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+		err = bl.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Timezone: tz}}, 1)
+		require.ErrorIs(t, err, customErrors.ErrBadRequest)
+	}
 }
 
-func TestEventToUTC(t *testing.T) {
+func TestAllDayEvents(t *testing.T) {
 	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	tokyo := contextHelpers.WriteTimezoneToContext(ctx, "Asia/Tokyo")
+	losAngeles := contextHelpers.WriteTimezoneToContext(ctx, "America/Los_Angeles")
 
 	db := memoryStorage.InitDatabase()
 	bl := InitBusinessLogic(db)
 
-	loc, _ := time.LoadLocation("Europe/Warsaw")
+	names := func(e []types.Event) []string {
+		var s []string
+		for _, o := range e {
+			s = append(s, o.Name)
+		}
+		return s
+	}
 
-	// Should convert to 10 UTC
-	ti := time.Date(2020, 5, 15, 12, 0, 0, 0, loc)
-	event := types.Event{
-		ID:        300,
-		Name:      "Daily meeting",
-		StartTime: ti,
-		EndTime:   ti,
+	// Only the dates are kept, the end date is exclusive
+	err := bl.AddEvent(tokyo, types.Event{Name: "Holiday", StartTime: time.Date(2023, 5, 1, 15, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC), AllDay: true})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(tokyo, types.Event{Name: "Vacation", StartTime: time.Date(2023, 7, 10, 0, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2023, 7, 15, 0, 0, 0, 0, time.UTC), AllDay: true})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(tokyo, types.Event{Name: "Conference", StartTime: time.Date(2023, 9, 1, 18, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2023, 9, 3, 10, 0, 0, 0, time.UTC)})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(tokyo, types.Event{Name: "Birthday", StartTime: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC), Recurrence: "FREQ=YEARLY", AllDay: true})
+	require.Nil(t, err)
+
+	// Dates are the same in every timezone
+	for _, ctx := range []context.Context{tokyo, losAngeles} {
+		e, err := bl.GetEvent(ctx, 1)
+		require.Nil(t, err)
+		require.True(t, e.AllDay)
+		require.Equal(t, time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC), e.StartTime)
+		require.Equal(t, time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC), e.EndTime)
+
+		e2, err := bl.GetEvents(ctx, types.Filters{Day: 1, Month: 5, Year: 2023})
+		require.Nil(t, err)
+		require.Equal(t, []string{"Holiday"}, names(e2))
+
+		e2, err = bl.GetEvents(ctx, types.Filters{Day: 30, Month: 4, Year: 2023})
+		require.Nil(t, err)
+		require.Empty(t, e2)
 	}
 
-	_, err := bl.eventToUTC(ctx, event)
-	require.NotNilf(t, err, "Should return an error: %v", timezoneFetchErr)
-	require.Equal(t, err, timezoneFetchErr)
+	// Multi-day events are listed on every day they span
+	for day := 10; day <= 15; day++ {
+		e, err := bl.GetEvents(losAngeles, types.Filters{Day: day, Month: 7, Year: 2023})
+		require.Nil(t, err)
+
+		if day < 15 {
+			require.Equal(t, []string{"Vacation"}, names(e), "day %d", day)
+		} else {
+			require.Empty(t, e, "the end date is exclusive")
+		}
+	}
+
+	for day, exp := range map[int][]string{1: {"Conference"}, 2: {"Conference"}, 3: {"Conference"}, 4: nil} {
+		e, err := bl.GetEvents(tokyo, types.Filters{Day: day, Month: 9, Year: 2023})
+		require.Nil(t, err)
+		require.Equal(t, exp, names(e), "day %d", day)
+	}
+
+	loc, _ := time.LoadLocation("America/Los_Angeles")
+
+	e, err := bl.GetEventsInRange(losAngeles, time.Date(2023, 7, 14, 23, 0, 0, 0, loc),
+		time.Date(2023, 7, 15, 1, 0, 0, 0, loc))
+	require.Nil(t, err)
+	require.Equal(t, []string{"Vacation"}, names(e))
+
+	e, err = bl.GetEventsInRange(losAngeles, time.Date(2023, 7, 15, 0, 0, 0, 0, loc),
+		time.Date(2023, 7, 16, 0, 0, 0, 0, loc))
+	require.Nil(t, err)
+	require.Empty(t, e)
+
+	e, err = bl.GetEvents(losAngeles, types.Filters{Day: 1, Month: 2, Year: 2024})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Birthday"}, names(e))
+	require.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), e[0].StartTime)
+	require.Equal(t, time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC), e[0].EndTime)
 
+	err = bl.AddEvent(tokyo, types.Event{Name: "Holiday", StartTime: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2023, 5, 1, 23, 0, 0, 0, time.UTC), AllDay: true})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest, "the end date should be after the start date")
+
+	allDay, timed := true, false
+
+	err = bl.UpdateEvent(tokyo, types.EventUpdate{AllDay: &allDay}, 3)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest, "start and end dates are required")
+
+	err = bl.UpdateEvent(tokyo, types.EventUpdate{Event: types.Event{StartTime: time.Date(2023, 7, 12, 0, 0, 0, 0,
+		time.UTC), EndTime: time.Date(2023, 7, 12, 0, 0, 0, 0, time.UTC)}}, 2)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	// Updates without the flag keep all-day events all-day, false turns them back into timed ones
+	err = bl.UpdateEvent(tokyo, types.EventUpdate{Event: types.Event{Name: "Holidays"}}, 2)
+	require.Nil(t, err)
+
+	vacation, err := bl.GetEvent(tokyo, 2)
+	require.Nil(t, err)
+	require.True(t, vacation.AllDay)
+
+	err = bl.UpdateEvent(tokyo, types.EventUpdate{AllDay: &timed}, 2)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest, "start and end times are required")
+
+	err = bl.UpdateEvent(tokyo, types.EventUpdate{Event: types.Event{StartTime: time.Date(2023, 7, 10, 9, 0, 0, 0,
+		time.UTC), EndTime: time.Date(2023, 7, 10, 17, 0, 0, 0, time.UTC)}, AllDay: &timed}, 2)
+	require.Nil(t, err)
+
+	vacation, err = bl.GetEvent(losAngeles, 2)
+	require.Nil(t, err)
+	require.False(t, vacation.AllDay)
+	require.Equal(t, time.Date(2023, 7, 9, 17, 0, 0, 0, loc), vacation.StartTime, "times are given in Tokyo")
+	require.Equal(t, time.Date(2023, 7, 10, 1, 0, 0, 0, loc), vacation.EndTime)
+}
+
+func TestEventValidation(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
 	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
 
-	event, err = bl.eventToUTC(ctx, event)
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, time.UTC)
+
+	fields := func(err error) []string {
+		var validation customErrors.ValidationError
+		require.ErrorAs(t, err, &validation)
+
+		var s []string
+		for _, f := range validation.Fields {
+			s = append(s, f.Field+":"+f.Code)
+		}
+		return s
+	}
+
+	err := bl.AddEvent(ctx, types.Event{Name: "Stand-up", StartTime: ti, EndTime: ti.Add(-time.Hour),
+		AlertTime: ti.Add(time.Minute), Description: strings.Repeat("a", 511)})
+	require.Equal(t, []string{"description:tooLong", "endTime:endBeforeStart", "alertTime:alertAfterStart"},
+		fields(err))
+
+	err = bl.AddEvent(ctx, types.Event{Name: "Stand-up", StartTime: ti, EndTime: ti.Add(15 * time.Minute),
+		AlertTime: ti.Add(-5 * time.Minute), Recurrence: "FREQ=DAILY"})
 	require.Nil(t, err)
 
-	require.Equal(t, event.StartTime.Hour(), 10, "Failed to convert timezone")
+	// Partial updates are validated together with the stored fields
+	err = bl.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{StartTime: ti.Add(time.Hour)}}, 1)
+	require.Equal(t, []string{"endTime:endBeforeStart"}, fields(err))
+
+	err = bl.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{AlertTime: ti.Add(time.Minute)}}, 1)
+	require.Equal(t, []string{"alertTime:alertAfterStart"}, fields(err))
+
+	err = bl.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{StartTime: ti.Add(time.Hour),
+		EndTime: ti.Add(2 * time.Hour), AlertTime: ti.Add(time.Hour)}}, 1)
+	require.Nil(t, err)
+
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	originalStart := time.Date(2023, 3, 21, 10, 0, 0, 0, loc)
+
+	err = bl.UpdateOccurrence(ctx, types.Event{EndTime: ti}, 1, originalStart, types.ScopeThis)
+	require.Equal(t, []string{"endTime:endBeforeStart"}, fields(err))
+
+	err = bl.UpdateOccurrence(ctx, types.Event{Description: strings.Repeat("a", 511)}, 1, originalStart,
+		types.ScopeFollowing)
+	require.Equal(t, []string{"description:tooLong"}, fields(err))
+}
+
+func TestEventsPagination(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Asia/Tokyo")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Asia/Tokyo")
+	names := func(e []types.Event) []string {
+		var s []string
+		for _, o := range e {
+			s = append(s, o.Name)
+		}
+		return s
+	}
+
+	for i, name := range []string{"Breakfast", "Lunch", "Dinner", "Supper", "Tea"} {
+		start := time.Date(2023, 1, 1+i, 8, 0, 0, 0, loc)
+		err := bl.AddEvent(ctx, types.Event{Name: name, StartTime: start, EndTime: start.Add(time.Hour)})
+		require.Nil(t, err)
+	}
+
+	page, err := bl.GetEventsPage(ctx, types.Filters{}, types.Page{Limit: 2})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Breakfast", "Lunch"}, names(page.Events))
+	require.Equal(t, loc, page.Events[0].StartTime.Location())
+	require.Empty(t, page.Prev)
+
+	page, err = bl.GetEventsPage(ctx, types.Filters{}, types.Page{Limit: 2, Cursor: page.Next})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Dinner", "Supper"}, names(page.Events))
+
+	last, err := bl.GetEventsPage(ctx, types.Filters{}, types.Page{Limit: 2, Cursor: page.Next})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Tea"}, names(last.Events))
+	require.Empty(t, last.Next)
+
+	// Going back from the last page returns the previous one in the same order
+	page, err = bl.GetEventsPage(ctx, types.Filters{}, types.Page{Limit: 2, Cursor: last.Prev})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Dinner", "Supper"}, names(page.Events))
+	require.NotEmpty(t, page.Next)
+
+	page, err = bl.GetEventsPage(ctx, types.Filters{}, types.Page{Limit: 2, Cursor: page.Prev})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Breakfast", "Lunch"}, names(page.Events))
+	require.Empty(t, page.Prev)
+
+	page, err = bl.GetEventsPage(ctx, types.Filters{Month: 1}, types.Page{Limit: 3, Sort: types.SortName, Desc: true})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Tea", "Supper", "Lunch"}, names(page.Events))
+
+	page, err = bl.GetEventsPage(ctx, types.Filters{Month: 1}, types.Page{Limit: 3, Cursor: page.Next})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Dinner", "Breakfast"}, names(page.Events))
+
+	for _, p := range []types.Page{
+		{Limit: -1},
+		{Limit: 501},
+		{Sort: "description"},
+		{Cursor: "not a cursor"},
+		{Sort: types.SortStartTime, Cursor: page.Prev},
+	} {
+		_, err = bl.GetEventsPage(ctx, types.Filters{}, p)
+		require.ErrorIs(t, err, customErrors.ErrBadRequest, "page: %+v", p)
+	}
+}
+
+func TestOccurrenceExceptions(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	day := func(d, hour int) time.Time { return time.Date(2023, 3, d, hour, 0, 0, 0, loc) }
+	starts := func(e []types.Event) []time.Time {
+		var s []time.Time
+		for _, o := range e {
+			s = append(s, o.StartTime)
+		}
+		return s
+	}
+
+	err := bl.AddEvent(ctx, types.Event{
+		Name:       "Stand-up",
+		StartTime:  day(20, 9),
+		EndTime:    day(20, 9).Add(15 * time.Minute),
+		Recurrence: "FREQ=DAILY;COUNT=5",
+	})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(ctx, types.Event{Name: "Single", StartTime: day(1, 9), EndTime: day(1, 10)})
+	require.Nil(t, err)
+
+	from, to := day(20, 0), day(27, 0)
+
+	// Tuesday's stand-up moves to Wednesday
+	err = bl.UpdateOccurrence(ctx, types.Event{StartTime: day(22, 10)}, 1, day(21, 9), types.ScopeThis)
+	require.Nil(t, err)
+
+	err = bl.DeleteOccurrence(ctx, 1, day(23, 9), types.ScopeThis)
+	require.Nil(t, err)
+
+	e, err := bl.GetEventsInRange(ctx, from, to)
+	require.Nil(t, err)
+	require.Equal(t, []time.Time{day(20, 9), day(22, 9), day(22, 10), day(24, 9)}, starts(e))
+	require.Equal(t, day(21, 9), *e[2].OriginalStart)
+	require.Equal(t, day(22, 10).Add(15*time.Minute), e[2].EndTime, "moved occurrence should keep its duration")
+
+	err = bl.DeleteOccurrence(ctx, 1, day(23, 9), types.ScopeThis)
+	require.ErrorIs(t, err, customErrors.ErrNotFound, "cancelled occurrence")
+
+	err = bl.DeleteOccurrence(ctx, 1, day(21, 8), types.ScopeThis)
+	require.ErrorIs(t, err, customErrors.ErrNotFound, "no occurrence starts at that time")
+
+	err = bl.DeleteOccurrence(ctx, 2, day(1, 9), types.ScopeThis)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest, "event is not recurring")
+
+	err = bl.DeleteOccurrence(ctx, 1, day(20, 9), "some")
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	// Friday's stand-up is renamed, the series is split
+	err = bl.UpdateOccurrence(ctx, types.Event{Name: "Retro"}, 1, day(24, 9), types.ScopeFollowing)
+	require.Nil(t, err)
+
+	series, err := bl.GetEvent(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, "FREQ=DAILY;COUNT=4", series.Recurrence)
+
+	following, err := bl.GetEvent(ctx, 3)
+	require.Nil(t, err)
+	require.Equal(t, "Retro", following.Name)
+	require.Equal(t, day(24, 9), following.StartTime)
+	require.Equal(t, "FREQ=DAILY;COUNT=1", following.Recurrence)
+
+	e, err = bl.GetEventsInRange(ctx, from, to)
+	require.Nil(t, err)
+	require.Equal(t, []time.Time{day(20, 9), day(22, 9), day(22, 10), day(24, 9)}, starts(e))
+
+	// Exceptions of the removed occurrences are dropped, the moved Tuesday's stand-up stays
+	err = bl.DeleteOccurrence(ctx, 1, day(22, 9), types.ScopeFollowing)
+	require.Nil(t, err)
+
+	e, err = bl.GetEventsInRange(ctx, from, to)
+	require.Nil(t, err)
+	require.Equal(t, []time.Time{day(20, 9), day(22, 10), day(24, 9)}, starts(e))
+
+	e, err = bl.GetEvents(ctx, types.Filters{Day: 22, Month: 3, Year: 2023})
+	require.Nil(t, err)
+	require.Equal(t, []time.Time{day(22, 10)}, starts(e))
+
+	err = bl.DeleteOccurrence(ctx, 3, day(24, 9), types.ScopeAll)
+	require.Nil(t, err)
+
+	e, err = bl.GetEventsInRange(ctx, from, to)
+	require.Nil(t, err)
+	require.Equal(t, []time.Time{day(20, 9), day(22, 10)}, starts(e))
+}
+
+func TestWebhookMessages(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	day := func(d, hour int) time.Time { return time.Date(2023, 3, d, hour, 0, 0, 0, loc) }
+
+	err := bl.AddEvent(ctx, types.Event{Name: "Single", StartTime: day(1, 9), EndTime: day(1, 10)})
+	require.Nil(t, err)
+
+	err = bl.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{Name: "Renamed"}}, 1)
+	require.Nil(t, err)
+
+	err = bl.AddEvent(ctx, types.Event{
+		Name:       "Stand-up",
+		StartTime:  day(20, 9),
+		EndTime:    day(20, 10),
+		Recurrence: "FREQ=DAILY;COUNT=5",
+	})
+	require.Nil(t, err)
+
+	err = bl.DeleteOccurrence(ctx, 2, day(21, 9), types.ScopeThis)
+	require.Nil(t, err)
+
+	err = bl.UpdateOccurrence(ctx, types.Event{Name: "Retro"}, 2, day(23, 9), types.ScopeFollowing)
+	require.Nil(t, err)
+
+	err = bl.DeleteEvent(ctx, 1)
+	require.Nil(t, err)
+
+	err = bl.DeleteEvent(ctx, 1)
+	require.ErrorIs(t, err, customErrors.ErrNotFound)
+
+	var messages []string
+	for _, m := range db.Outbox["hello"] {
+		messages = append(messages, string(m.Type)+" "+string(m.Data))
+	}
+
+	require.Len(t, messages, 7, "failed changes should not be notified")
+	require.Equal(t, `event.created {"id":1,"name":"Single","startTime":"2023-03-01T09:00:00+01:00",`+
+		`"endTime":"2023-03-01T10:00:00+01:00","alertTime":"0001-01-01T00:00:00Z","calendarId":1}`, messages[0])
+	require.Contains(t, messages[1], `event.updated {"id":1,"name":"Renamed"`)
+	require.Contains(t, messages[2], `event.created {"id":2,"name":"Stand-up"`)
+	require.Contains(t, messages[3], `event.updated {"id":2,"name":"Stand-up"`, "exceptions update the series")
+	require.Contains(t, messages[4], `"recurrence":"FREQ=DAILY;COUNT=3"`, "split series is truncated")
+	require.Contains(t, messages[5], `event.created {"id":3,"name":"Retro"`)
+	require.Equal(t, `event.deleted {"id":1}`, messages[6])
+}
+
+func TestCalendars(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	err := bl.AddDefaultCalendar(ctx, "hello")
+	require.Nil(t, err)
+
+	work, err := bl.AddCalendar(ctx, types.Calendar{Name: "Work", Color: "#1E90FF", Timezone: "Asia/Tokyo",
+		Default: true})
+	require.Nil(t, err)
+	require.Equal(t, types.Calendar{ID: 2, Name: "Work", Color: "#1E90FF", Timezone: "Asia/Tokyo",
+		Visibility: types.VisibilityFreeBusy, Owner: "hello"}, work,
+		"only the calendar created with the user is the default one")
+
+	_, err = bl.AddCalendar(ctx, types.Calendar{Name: "Broken", Color: "blue", Visibility: "public"})
+	var validationErr customErrors.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []string{"color", "visibility"}, []string{validationErr.Fields[0].Field,
+		validationErr.Fields[1].Field})
+
+	// Events are in the timezone of their calendar unless they have their own one
+	err = bl.AddEvent(ctx, types.Event{Name: "Stand-up", StartTime: time.Date(2023, 3, 1, 9, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC), CalendarID: work.ID})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(ctx, types.Event{Name: "Gym", StartTime: time.Date(2023, 3, 1, 18, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2023, 3, 1, 19, 0, 0, 0, time.UTC)})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(ctx, types.Event{Name: "Nowhere", StartTime: time.Now(), EndTime: time.Now(), CalendarID: 9})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	e, err := bl.GetEvent(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, "Asia/Tokyo", e.Timezone)
+	require.Equal(t, time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), e.StartTime.UTC())
+
+	e, err = bl.GetEvent(ctx, 2)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), e.CalendarID, "events are added to the default calendar")
+
+	events, err := bl.GetEvents(ctx, types.Filters{CalendarID: work.ID})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "Stand-up", events[0].Name)
+
+	events, err = bl.GetEvents(ctx, types.Filters{CalendarID: 1, Day: 1, Month: 3, Year: 2023})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "Gym", events[0].Name)
+
+	page, err := bl.GetEventsPage(ctx, types.Filters{CalendarID: work.ID}, types.Page{})
+	require.Nil(t, err)
+	require.Len(t, page.Events, 1)
+	require.Equal(t, "Stand-up", page.Events[0].Name)
+
+	_, err = bl.GetEvents(ctx, types.Filters{CalendarID: 9})
+	require.ErrorIs(t, err, customErrors.ErrNotFound)
+
+	err = bl.UpdateCalendar(ctx, types.Calendar{Name: "Office"}, work.ID)
+	require.Nil(t, err)
+
+	c, err := bl.GetCalendar(ctx, work.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Office", c.Name)
+	require.Equal(t, "#1E90FF", c.Color, "fields missing in the update should be kept")
+
+	err = bl.UpdateCalendar(ctx, types.Calendar{Timezone: "Mars/Olympus_Mons"}, work.ID)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.DeleteCalendar(ctx, 1, types.CalendarEventsMove, work.ID)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest, "the default calendar cannot be deleted")
+
+	err = bl.DeleteCalendar(ctx, work.ID, types.CalendarEventsMove, work.ID)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.DeleteCalendar(ctx, work.ID, "archive", 0)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.DeleteCalendar(ctx, work.ID, types.CalendarEventsMove, 0)
+	require.Nil(t, err)
+
+	e, err = bl.GetEvent(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), e.CalendarID, "events are moved to the default calendar")
+
+	trash, err := bl.AddCalendar(ctx, types.Calendar{Name: "Trash"})
+	require.Nil(t, err)
+
+	err = bl.UpdateEvent(ctx, types.EventUpdate{Event: types.Event{CalendarID: trash.ID}}, 1)
+	require.Nil(t, err)
+
+	err = bl.DeleteCalendar(ctx, trash.ID, types.CalendarEventsDelete, 0)
+	require.Nil(t, err)
+
+	_, err = bl.GetEvent(ctx, 1)
+	require.ErrorIs(t, err, customErrors.ErrNotFound, "events are deleted together with their calendar")
+
+	calendars, err := bl.GetCalendars(ctx)
+	require.Nil(t, err)
+	require.Len(t, calendars, 1)
+
+	var messages []string
+	for _, m := range db.Outbox["hello"] {
+		messages = append(messages, string(m.Type))
+	}
+	require.Equal(t, []string{"event.created", "event.created", "event.updated", "event.updated", "event.deleted"},
+		messages)
+}
+
+func TestCalendarShares(t *testing.T) {
+	ownerCtx := contextHelpers.WriteLoginToContext(context.Background(), "owner")
+	ownerCtx = contextHelpers.WriteTimezoneToContext(ownerCtx, "Europe/Warsaw")
+	guestCtx := contextHelpers.WriteLoginToContext(context.Background(), "guest")
+	guestCtx = contextHelpers.WriteTimezoneToContext(guestCtx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	require.Nil(t, bl.AddDefaultCalendar(ownerCtx, "owner"))
+	require.Nil(t, bl.AddDefaultCalendar(guestCtx, "guest"))
+
+	team, err := bl.AddCalendar(ownerCtx, types.Calendar{Name: "Team"})
+	require.Nil(t, err)
+
+	start := time.Date(2023, 3, 1, 9, 0, 0, 0, time.UTC)
+	err = bl.AddEvent(ownerCtx, types.Event{Name: "Planning", Description: "Q2 roadmap", StartTime: start,
+		EndTime: start.Add(time.Hour), CalendarID: team.ID})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(ownerCtx, types.Event{Name: "Dentist", StartTime: start.Add(3 * time.Hour),
+		EndTime: start.Add(4 * time.Hour)})
+	require.Nil(t, err)
+
+	// Calendars which are not shared are not found
+	_, err = bl.GetEvent(guestCtx, 1)
+	require.ErrorIs(t, err, customErrors.ErrNotFound)
+
+	_, err = bl.GetCalendar(guestCtx, team.ID)
+	require.ErrorIs(t, err, customErrors.ErrNotFound)
+
+	err = bl.SetShare(guestCtx, team.ID, types.Share{Login: "guest", Level: types.ShareManage})
+	require.ErrorIs(t, err, customErrors.ErrNotFound)
+
+	err = bl.SetShare(ownerCtx, team.ID, types.Share{Login: "owner", Level: types.ShareRead})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.SetShare(ownerCtx, team.ID, types.Share{Login: "guest", Level: "admin"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.SetShare(ownerCtx, team.ID, types.Share{Login: "guest", Level: types.ShareFreeBusy})
+	require.Nil(t, err)
+
+	// Free/busy shares show when events take place only
+	e, err := bl.GetEvent(guestCtx, 1)
+	require.Nil(t, err)
+	require.Equal(t, "Busy", e.Name)
+	require.Empty(t, e.Description)
+
+	events, err := bl.GetEvents(guestCtx, types.Filters{})
+	require.Nil(t, err)
+	require.Len(t, events, 1, "events of calendars which are not shared are not listed")
+	require.Equal(t, "Busy", events[0].Name)
+
+	err = bl.UpdateEvent(guestCtx, types.EventUpdate{Event: types.Event{Name: "Cancelled"}}, 1)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized)
+
+	_, err = bl.GetShares(guestCtx, team.ID)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized)
+
+	err = bl.SetShare(ownerCtx, team.ID, types.Share{Login: "guest", Level: types.ShareWrite})
+	require.Nil(t, err)
+
+	c, err := bl.GetCalendar(guestCtx, team.ID)
+	require.Nil(t, err)
+	require.Equal(t, "owner", c.Owner)
+	require.Equal(t, types.ShareWrite, c.Access)
+
+	events, err = bl.GetEvents(guestCtx, types.Filters{Day: 1, Month: 3, Year: 2023})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "Planning", events[0].Name)
+
+	// Events added to shared calendars belong to their owner
+	err = bl.AddEvent(guestCtx, types.Event{Name: "Retro", StartTime: start.Add(time.Hour),
+		EndTime: start.Add(2 * time.Hour), CalendarID: team.ID})
+	require.Nil(t, err)
+
+	err = bl.UpdateEvent(guestCtx, types.EventUpdate{Event: types.Event{Name: "Planning Q2"}}, 1)
+	require.Nil(t, err)
+
+	page, err := bl.GetEventsPage(ownerCtx, types.Filters{CalendarID: team.ID}, types.Page{})
+	require.Nil(t, err)
+	require.Equal(t, []string{"Planning Q2", "Retro"}, []string{page.Events[0].Name, page.Events[1].Name})
+
+	page, err = bl.GetEventsPage(guestCtx, types.Filters{}, types.Page{Limit: 1})
+	require.Nil(t, err)
+	require.Len(t, page.Events, 1)
+	require.Equal(t, "Planning Q2", page.Events[0].Name)
+	require.NotEmpty(t, page.Next)
+
+	err = bl.UpdateEvent(guestCtx, types.EventUpdate{Event: types.Event{CalendarID: 2}}, 1)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest, "events stay in calendars of their owner")
+
+	err = bl.UpdateCalendar(guestCtx, types.Calendar{Name: "Guests"}, team.ID)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized)
+
+	err = bl.SetShare(ownerCtx, team.ID, types.Share{Login: "guest", Level: types.ShareManage})
+	require.Nil(t, err)
+
+	err = bl.UpdateCalendar(guestCtx, types.Calendar{Name: "Guests"}, team.ID)
+	require.Nil(t, err)
+
+	shares, err := bl.GetShares(guestCtx, team.ID)
+	require.Nil(t, err)
+	require.Equal(t, []types.Share{{Login: "guest", Level: types.ShareManage}}, shares)
+
+	err = bl.DeleteCalendar(guestCtx, team.ID, types.CalendarEventsDelete, 0)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized, "only the owner deletes calendars")
+
+	require.Empty(t, db.Outbox["guest"])
+	require.Len(t, db.Outbox["owner"], 4)
+
+	// Users can give up calendars shared with them
+	err = bl.DeleteShare(guestCtx, team.ID, "guest")
+	require.Nil(t, err)
+
+	_, err = bl.GetCalendar(guestCtx, team.ID)
+	require.ErrorIs(t, err, customErrors.ErrNotFound)
+
+	events, err = bl.GetEvents(guestCtx, types.Filters{})
+	require.Nil(t, err)
+	require.Empty(t, events)
+}
+
+func TestSharedEventsPages(t *testing.T) {
+	ownerCtx := contextHelpers.WriteLoginToContext(context.Background(), "owner")
+	ownerCtx = contextHelpers.WriteTimezoneToContext(ownerCtx, "UTC")
+	guestCtx := contextHelpers.WriteLoginToContext(context.Background(), "guest")
+	guestCtx = contextHelpers.WriteTimezoneToContext(guestCtx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	require.Nil(t, bl.AddDefaultCalendar(ownerCtx, "owner"))
+	require.Nil(t, bl.AddDefaultCalendar(guestCtx, "guest"))
+
+	team, err := bl.AddCalendar(ownerCtx, types.Calendar{Name: "Team"})
+	require.Nil(t, err)
+
+	private, err := bl.AddCalendar(ownerCtx, types.Calendar{Name: "Private"})
+	require.Nil(t, err)
+
+	require.Nil(t, bl.SetShare(ownerCtx, team.ID, types.Share{Login: "guest", Level: types.ShareRead}))
+	require.Nil(t, bl.SetShare(ownerCtx, private.ID, types.Share{Login: "guest", Level: types.ShareFreeBusy}))
+
+	start := time.Date(2023, 3, 1, 9, 0, 0, 0, time.UTC)
+	add := func(ctx context.Context, name string, hours int, calendarID int64, attendees ...types.Attendee) {
+		err := bl.AddEvent(ctx, types.Event{Name: name, StartTime: start.Add(time.Duration(hours) * time.Hour),
+			EndTime: start.Add(time.Duration(hours+1) * time.Hour), CalendarID: calendarID, Attendees: attendees})
+		require.Nil(t, err)
+	}
+
+	guest := types.Attendee{Login: "guest"}
+
+	add(ownerCtx, "Planning", 5, team.ID)
+	add(ownerCtx, "Doctor", 1, private.ID)
+	add(ownerCtx, "Accountant", 8, private.ID)
+	add(ownerCtx, "Lunch", 3, private.ID, guest)
+	add(ownerCtx, "Kick-off", 2, 0, guest)
+	add(ownerCtx, "Hidden", 4, 0)
+	add(guestCtx, "Busy", 6, 0)
+	add(guestCtx, "Gym", 0, 0)
+	add(guestCtx, "Yoga", 7, 0)
+
+	require.Nil(t, bl.RSVP(guestCtx, 4, types.RSVPAccepted))
+	require.Nil(t, bl.RSVP(guestCtx, 5, types.RSVPAccepted))
+
+	all, err := bl.GetEvents(guestCtx, types.Filters{})
+	require.Nil(t, err)
+	require.Len(t, all, 8, "events of calendars which are not shared are not listed")
+
+	// Pages read from the database per owner make up the same listing as the events merged in memory
+	for _, sort := range []types.SortField{types.SortStartTime, types.SortName, types.SortID} {
+		for _, desc := range []bool{false, true} {
+			var exp, got []string
+			for _, e := range pagination.Select(all, types.PageQuery{Sort: sort, Desc: desc}) {
+				exp = append(exp, fmt.Sprintf("%d %s %s", e.ID, e.Name, e.Organizer))
+			}
+
+			p := types.Page{Limit: 3, Sort: sort, Desc: desc}
+			for {
+				page, err := bl.GetEventsPage(guestCtx, types.Filters{}, p)
+				require.Nil(t, err)
+
+				for _, e := range page.Events {
+					got = append(got, fmt.Sprintf("%d %s %s", e.ID, e.Name, e.Organizer))
+				}
+
+				if page.Next == "" {
+					break
+				}
+				p = types.Page{Limit: 3, Cursor: page.Next}
+			}
+
+			require.Equal(t, exp, got, "sort: %s, desc: %t", sort, desc)
+		}
+	}
+
+	page, err := bl.GetEventsPage(guestCtx, types.Filters{CalendarID: private.ID}, types.Page{Sort: types.SortName})
+	require.Nil(t, err)
+	require.Len(t, page.Events, 3)
+	require.Equal(t, []string{"Busy", "Busy", "Lunch"},
+		[]string{page.Events[0].Name, page.Events[1].Name, page.Events[2].Name})
+}
+
+func TestAttendees(t *testing.T) {
+	orgCtx := contextHelpers.WriteLoginToContext(context.Background(), "org")
+	orgCtx = contextHelpers.WriteTimezoneToContext(orgCtx, "UTC")
+	guestCtx := contextHelpers.WriteLoginToContext(context.Background(), "guest")
+	guestCtx = contextHelpers.WriteTimezoneToContext(guestCtx, "Asia/Tokyo")
+	strangerCtx := contextHelpers.WriteLoginToContext(context.Background(), "stranger")
+	strangerCtx = contextHelpers.WriteTimezoneToContext(strangerCtx, "UTC")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	start := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	meeting := types.Event{Name: "Kick-off", Timezone: "UTC", StartTime: start, EndTime: start.Add(time.Hour)}
+
+	invalid := meeting
+	invalid.Attendees = []types.Attendee{{Login: "guest", Email: "guest@example.com"}, {Email: "not an email"},
+		{Login: "org"}, {Login: "guest"}, {Login: "guest"}}
+	err := bl.AddEvent(orgCtx, invalid)
+	var validationErr customErrors.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []string{"attendees[0]", "attendees[1].email", "attendees[2].login", "attendees[4]"},
+		[]string{validationErr.Fields[0].Field, validationErr.Fields[1].Field, validationErr.Fields[2].Field,
+			validationErr.Fields[3].Field})
+
+	meeting.Attendees = []types.Attendee{{Login: "guest", Status: types.RSVPAccepted}, {Email: "Ext@Example.com"}}
+	err = bl.AddEvent(orgCtx, meeting)
+	require.Nil(t, err)
+
+	e, err := bl.GetEvent(orgCtx, 1)
+	require.Nil(t, err)
+	require.Equal(t, []types.Attendee{{Login: "guest", Status: types.RSVPNeedsAction},
+		{Email: "ext@example.com", Status: types.RSVPNeedsAction}}, e.Attendees, "attendees have not answered yet")
+
+	invitations, err := bl.GetInvitations(guestCtx, "")
+	require.Nil(t, err)
+	require.Len(t, invitations, 1)
+	require.Equal(t, types.RSVPNeedsAction, invitations[0].Status)
+	require.Equal(t, "org", invitations[0].Event.Organizer)
+	require.Equal(t, 19, invitations[0].Event.StartTime.Hour(), "invitations are shown in the attendee's timezone")
+
+	// Attendees see the events they are invited to, but only accepted ones are listed with their own events
+	e, err = bl.GetEvent(guestCtx, 1)
+	require.Nil(t, err)
+	require.Equal(t, "Kick-off", e.Name)
+
+	events, err := bl.GetEvents(guestCtx, types.Filters{})
+	require.Nil(t, err)
+	require.Empty(t, events)
+
+	_, err = bl.GetEvent(strangerCtx, 1)
+	require.ErrorIs(t, err, customErrors.ErrNotFound)
+
+	err = bl.RSVP(strangerCtx, 1, types.RSVPAccepted)
+	require.ErrorIs(t, err, customErrors.ErrNotFound)
+
+	err = bl.RSVP(guestCtx, 1, types.RSVPNeedsAction)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.UpdateEvent(guestCtx, types.EventUpdate{Event: types.Event{Name: "Hijacked"}}, 1)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized)
+
+	err = bl.RSVP(guestCtx, 1, types.RSVPAccepted)
+	require.Nil(t, err)
+
+	events, err = bl.GetEvents(guestCtx, types.Filters{Day: 1, Month: 3, Year: 2023})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "org", events[0].Organizer)
+	require.Equal(t, "Asia/Tokyo", events[0].StartTime.Location().String())
+
+	page, err := bl.GetEventsPage(guestCtx, types.Filters{}, types.Page{})
+	require.Nil(t, err)
+	require.Len(t, page.Events, 1)
+
+	// Answers are kept unless the time of the event changes
+	err = bl.UpdateEvent(orgCtx, types.EventUpdate{Event: types.Event{Name: "Kick-off meeting"}}, 1)
+	require.Nil(t, err)
+
+	invitations, err = bl.GetInvitations(guestCtx, types.RSVPAccepted)
+	require.Nil(t, err)
+	require.Len(t, invitations, 1)
+
+	err = bl.UpdateEvent(orgCtx, types.EventUpdate{Event: types.Event{
+		StartTime: time.Date(2023, 3, 1, 11, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)}}, 1)
+	require.Nil(t, err)
+
+	invitations, err = bl.GetInvitations(guestCtx, types.RSVPNeedsAction)
+	require.Nil(t, err)
+	require.Len(t, invitations, 1)
+
+	events, err = bl.GetEvents(guestCtx, types.Filters{})
+	require.Nil(t, err)
+	require.Empty(t, events)
+
+	err = bl.UpdateEvent(orgCtx,
+		types.EventUpdate{Event: types.Event{Attendees: []types.Attendee{{Email: "ext@example.com"}}}}, 1)
+	require.Nil(t, err)
+
+	invitations, err = bl.GetInvitations(guestCtx, "")
+	require.Nil(t, err)
+	require.Empty(t, invitations, "attendees missing in the update are not invited anymore")
+
+	// The following occurrences of a series keep its attendees
+	series := types.Event{Name: "Stand-up", Timezone: "UTC", StartTime: start, EndTime: start.Add(15 * time.Minute),
+		Recurrence: "FREQ=DAILY;COUNT=5", Attendees: []types.Attendee{{Login: "guest"}}}
+	err = bl.AddEvent(orgCtx, series)
+	require.Nil(t, err)
+
+	err = bl.RSVP(guestCtx, 2, types.RSVPAccepted)
+	require.Nil(t, err)
+
+	err = bl.UpdateOccurrence(orgCtx, types.Event{Name: "Daily"}, 2, start.Add(48*time.Hour), types.ScopeFollowing)
+	require.Nil(t, err)
+	require.Equal(t, []types.Attendee{{Login: "guest", Status: types.RSVPAccepted}}, db.Attendees[3])
+
+	err = bl.UpdateOccurrence(orgCtx, types.Event{Attendees: []types.Attendee{}}, 2, start, types.ScopeThis)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	var messages []string
+	for _, m := range db.Outbox["org"] {
+		messages = append(messages, string(m.Type))
+	}
+	require.Contains(t, messages, "event.updated", "organizers are notified about answers")
+	require.Empty(t, db.Outbox["guest"])
+}
+
+func TestFreeBusy(t *testing.T) {
+	userCtx := func(login, timezone string) context.Context {
+		ctx := contextHelpers.WriteLoginToContext(context.Background(), login)
+		return contextHelpers.WriteTimezoneToContext(ctx, timezone)
+	}
+
+	aliceCtx := userCtx("alice", "Europe/Warsaw")
+	bobCtx := userCtx("bob", "America/New_York")
+	carolCtx := userCtx("carol", "UTC")
+
+	db := memoryStorage.InitDatabase()
+	db.Timezones["bob"] = "America/New_York"
+	bl := InitBusinessLogic(db)
+
+	// Times are wall-clock times of the users adding the events
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2023, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	events := []struct {
+		ctx context.Context
+		e   types.Event
+	}{
+		{bobCtx, types.Event{Name: "Sync", StartTime: at(6, 9, 0), EndTime: at(6, 10, 0)}},
+		{bobCtx, types.Event{Name: "Review", StartTime: at(6, 9, 30), EndTime: at(6, 11, 0)}},
+		{bobCtx, types.Event{Name: "Stand-up", StartTime: at(1, 8, 0), EndTime: at(1, 8, 15),
+			Recurrence: "FREQ=DAILY;COUNT=10"}},
+		{bobCtx, types.Event{Name: "Day off", StartTime: at(5, 0, 0), EndTime: at(6, 0, 0), AllDay: true}},
+		{carolCtx, types.Event{Name: "Interview", StartTime: at(7, 10, 0), EndTime: at(7, 11, 0),
+			Attendees: []types.Attendee{{Login: "bob"}}}},
+	}
+
+	for _, tc := range events {
+		err := bl.AddEvent(tc.ctx, tc.e)
+		require.Nil(t, err)
+	}
+
+	private, err := bl.AddCalendar(bobCtx, types.Calendar{Name: "Health", Visibility: types.VisibilityPrivate})
+	require.Nil(t, err)
+
+	err = bl.AddEvent(bobCtx, types.Event{Name: "Doctor", StartTime: at(6, 13, 0), EndTime: at(6, 14, 0),
+		CalendarID: private.ID})
+	require.Nil(t, err)
+
+	err = bl.DeleteOccurrence(bobCtx, 3, at(7, 8, 0), types.ScopeThis)
+	require.Nil(t, err)
+
+	err = bl.RSVP(bobCtx, 5, types.RSVPAccepted)
+	require.Nil(t, err)
+
+	busy := func(ctx context.Context, logins ...string) [][]string {
+		s, err := bl.GetFreeBusy(ctx, types.FreeBusyQuery{Logins: logins, From: at(6, 0, 0), To: at(8, 0, 0)})
+		require.Nil(t, err)
+		require.Len(t, s, len(logins))
+
+		intervals := make([][]string, len(s))
+		for i, fb := range s {
+			require.Equal(t, logins[i], fb.Login)
+
+			intervals[i] = []string{}
+			for _, b := range fb.Busy {
+				intervals[i] = append(intervals[i], b.Start.Format(time.RFC3339)+"/"+b.End.Format(time.RFC3339))
+			}
+		}
+		return intervals
+	}
+
+	require.Equal(t, [][]string{{
+		"2023-03-06T00:00:00+01:00/2023-03-06T06:00:00+01:00", // the day off ends at midnight in New York
+		"2023-03-06T14:00:00+01:00/2023-03-06T14:15:00+01:00", // the stand-up is cancelled on the next day
+		"2023-03-06T15:00:00+01:00/2023-03-06T17:00:00+01:00",
+		"2023-03-07T11:00:00+01:00/2023-03-07T12:00:00+01:00", // the accepted interview
+	}, {}}, busy(aliceCtx, "bob", "nobody"), "events of private calendars are not shown")
+
+	err = bl.SetShare(bobCtx, private.ID, types.Share{Login: "alice", Level: types.ShareFreeBusy})
+	require.Nil(t, err)
+
+	require.Contains(t, busy(aliceCtx, "bob")[0], "2023-03-06T19:00:00+01:00/2023-03-06T20:00:00+01:00",
+		"calendars shared with the user are shown")
+	require.Contains(t, busy(bobCtx, "bob")[0], "2023-03-06T13:00:00-05:00/2023-03-06T14:00:00-05:00",
+		"users see every calendar of their own")
+
+	testCases := []struct {
+		testName string
+		q        types.FreeBusyQuery
+		expField string
+	}{
+		{"NoLogins", types.FreeBusyQuery{From: at(6, 0, 0), To: at(7, 0, 0)}, "logins"},
+		{"EmptyLogin", types.FreeBusyQuery{Logins: []string{"bob", ""}, From: at(6, 0, 0), To: at(7, 0, 0)},
+			"logins[1]"},
+		{"ToBeforeFrom", types.FreeBusyQuery{Logins: []string{"bob"}, From: at(7, 0, 0), To: at(6, 0, 0)}, "to"},
+		{"LongWindow", types.FreeBusyQuery{Logins: []string{"bob"}, From: at(1, 0, 0),
+			To: at(1, 0, 0).AddDate(0, 4, 0)}, "to"},
+		{"MissingFrom", types.FreeBusyQuery{Logins: []string{"bob"}, To: at(7, 0, 0)}, "from"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := bl.GetFreeBusy(aliceCtx, tc.q)
+
+			var validationErr customErrors.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			require.Equal(t, tc.expField, validationErr.Fields[0].Field)
+		})
+	}
+
+	logins := make([]string, maxFreeBusyUsers+1)
+	for i := range logins {
+		logins[i] = fmt.Sprintf("user%d", i)
+	}
+
+	_, err = bl.GetFreeBusy(aliceCtx, types.FreeBusyQuery{Logins: logins, From: at(6, 0, 0), To: at(7, 0, 0)})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+}
+
+func TestSuggestSlots(t *testing.T) {
+	aliceCtx := contextHelpers.WriteLoginToContext(context.Background(), "alice")
+	aliceCtx = contextHelpers.WriteTimezoneToContext(aliceCtx, "Europe/Warsaw")
+	bobCtx := contextHelpers.WriteLoginToContext(context.Background(), "bob")
+	bobCtx = contextHelpers.WriteTimezoneToContext(bobCtx, "America/New_York")
+
+	db := memoryStorage.InitDatabase()
+	db.Timezones["alice"], db.Timezones["bob"] = "Europe/Warsaw", "America/New_York"
+	bl := InitBusinessLogic(db)
+
+	// Bob is busy from 15:00 to 16:00 in Warsaw on Monday
+	err := bl.AddEvent(bobCtx, types.Event{Name: "Sync", StartTime: time.Date(2023, 3, 6, 9, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2023, 3, 6, 10, 0, 0, 0, time.UTC)})
+	require.Nil(t, err)
+
+	// Monday and Tuesday, working hours of both overlap from 15:00 to 17:00 in Warsaw
+	query := func(c types.SlotConstraints, limit int) types.SuggestQuery {
+		return types.SuggestQuery{Attendees: []string{"bob"}, Duration: 60, Constraints: c, Limit: limit,
+			From: time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 3, 8, 0, 0, 0, 0, time.UTC)}
+	}
+
+	suggest := func(q types.SuggestQuery) []string {
+		slots, err := bl.SuggestSlots(aliceCtx, q)
+		require.Nil(t, err)
+
+		s := []string{}
+		for _, slot := range slots {
+			require.Equal(t, time.Hour, slot.End.Sub(slot.Start))
+			s = append(s, strings.TrimSpace(slot.Start.Format("Mon 15:04 ")+strings.Join(slot.Busy, " ")))
+		}
+		return s
+	}
+
+	testCases := []struct {
+		testName string
+		q        types.SuggestQuery
+		exp      []string
+	}{
+		{"WorkingHours", query(types.SlotConstraints{}, 0),
+			[]string{"Mon 16:00", "Tue 15:00", "Tue 15:30", "Tue 16:00", "Mon 15:00 bob", "Mon 15:30 bob"}},
+		{"Buffer", query(types.SlotConstraints{Buffer: 15}, 4),
+			[]string{"Tue 15:00", "Tue 15:30", "Tue 16:00", "Mon 15:00 bob"}},
+		{"EarliestLatest", query(types.SlotConstraints{Earliest: "15:30", Latest: "16:30"}, 0),
+			[]string{"Tue 15:30", "Mon 15:30 bob"}},
+		{"CustomWorkingHours", query(types.SlotConstraints{WorkingHours: &types.WorkingHours{Start: "08:00",
+			End: "18:00", Days: []string{"tuesday"}}}, 3), []string{"Tue 14:00", "Tue 14:30", "Tue 15:00"}},
+		{"AttendeeWorkingHours", query(types.SlotConstraints{AttendeeWorkingHours: map[string]types.WorkingHours{
+			"bob": {Start: "06:00", End: "10:00"}}}, 0), []string{"Mon 12:00", "Mon 12:30", "Mon 13:00", "Mon 13:30",
+			"Mon 14:00", "Tue 12:00", "Tue 12:30", "Tue 13:00", "Tue 13:30", "Tue 14:00"}},
+		{"AttendeesWorkingHours", query(types.SlotConstraints{AttendeeWorkingHours: map[string]types.WorkingHours{
+			"alice": {Start: "16:00", End: "20:00"},
+			"bob":   {Start: "12:00", End: "13:30", Days: []string{"monday"}}}}, 0),
+			[]string{"Mon 18:00", "Mon 18:30"}},
+		{"Limit", query(types.SlotConstraints{}, 1), []string{"Mon 16:00"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			require.Equal(t, tc.exp, suggest(tc.q))
+		})
+	}
+
+	// Slots the user is busy at are not suggested
+	err = bl.AddEvent(aliceCtx, types.Event{Name: "Lunch", StartTime: time.Date(2023, 3, 7, 15, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2023, 3, 7, 15, 30, 0, 0, time.UTC)})
+	require.Nil(t, err)
+
+	require.Equal(t, []string{"Mon 16:00", "Tue 15:30", "Tue 16:00", "Mon 15:00 bob", "Mon 15:30 bob"},
+		suggest(query(types.SlotConstraints{}, 0)))
+
+	invalidCases := []struct {
+		testName  string
+		q         types.SuggestQuery
+		expFields []string
+	}{
+		{"MissingDuration", types.SuggestQuery{From: time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC),
+			To: time.Date(2023, 3, 7, 0, 0, 0, 0, time.UTC)}, []string{"duration"}},
+		{"InvalidConstraints", query(types.SlotConstraints{Buffer: -5, Earliest: "25:00", Latest: "9am"}, 51),
+			[]string{"constraints.buffer", "constraints.earliest", "constraints.latest", "limit"}},
+		{"LatestBeforeEarliest", query(types.SlotConstraints{Earliest: "16:00", Latest: "09:00"}, 0),
+			[]string{"constraints.latest"}},
+		{"InvalidWorkingHours", query(types.SlotConstraints{WorkingHours: &types.WorkingHours{End: "17:00",
+			Days: []string{"monday", "someday"}}}, 0),
+			[]string{"constraints.workingHours.start", "constraints.workingHours.days[1]"}},
+		{"InvalidAttendeeWorkingHours", query(types.SlotConstraints{AttendeeWorkingHours: map[string]types.WorkingHours{
+			"carol": {Start: "09:00", End: "17:00"}, "bob": {Start: "17:00", End: "09:00"}}}, 0),
+			[]string{"constraints.attendeeWorkingHours.bob.end", "constraints.attendeeWorkingHours.carol"}},
+		{"EmptyAttendee", types.SuggestQuery{Attendees: []string{""}, Duration: 30,
+			From: time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 3, 7, 0, 0, 0, 0, time.UTC)},
+			[]string{"attendees[0]"}},
+	}
+
+	for _, tc := range invalidCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := bl.SuggestSlots(aliceCtx, tc.q)
+
+			var validationErr customErrors.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+
+			var fields []string
+			for _, f := range validationErr.Fields {
+				fields = append(fields, f.Field)
+			}
+			require.Equal(t, tc.expFields, fields)
+		})
+	}
+}
+
+func TestExportEvents(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, loc)
+
+	err := bl.AddEvent(ctx, types.Event{Name: "Stand-up", StartTime: ti, EndTime: ti.Add(15 * time.Minute),
+		Recurrence: "FREQ=DAILY;COUNT=3"})
+	require.Nil(t, err)
+
+	err = bl.DeleteOccurrence(ctx, 1, ti.AddDate(0, 0, 1), types.ScopeThis)
+	require.Nil(t, err)
+
+	calendar, err := bl.ExportEvents(ctx, types.Filters{})
+	require.Nil(t, err)
+	require.Contains(t, string(calendar), "RRULE:FREQ=DAILY;COUNT=3\r\nEXDATE;TZID=Europe/Warsaw:20230321T090000\r\n")
+
+	calendar, err = bl.ExportEvents(ctx, types.Filters{Day: 22, Month: 3, Year: 2023})
+	require.Nil(t, err)
+	require.Contains(t, string(calendar), "RECURRENCE-ID;TZID=Europe/Warsaw:20230322T090000\r\n")
+	require.NotContains(t, string(calendar), "RRULE")
+
+	_, err = bl.ExportEvents(ctx, types.Filters{Month: 13})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+}
+
+func TestImportEvents(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:moved@example.com",
+		"RECURRENCE-ID;TZID=America/New_York:20230322T090000",
+		"DTSTART;TZID=America/New_York:20230322T110000",
+		"DTEND;TZID=America/New_York:20230322T113000",
+		"SUMMARY:Stand-up moved",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:moved@example.com",
+		"DTSTART;TZID=America/New_York:20230320T090000",
+		"DTEND;TZID=America/New_York:20230320T091500",
+		"SUMMARY:Stand-up",
+		"RRULE:FREQ=DAILY;COUNT=4",
+		"EXDATE;TZID=America/New_York:20230321T090000",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT5M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:holiday@example.com",
+		"DTSTART;VALUE=DATE:20230501",
+		"SUMMARY:Holiday",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:untitled@example.com",
+		"DTSTART:20230320T090000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:orphan@example.com",
+		"RECURRENCE-ID:20230320T090000Z",
+		"DTSTART:20230320T100000Z",
+		"SUMMARY:Orphan",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	report, err := bl.ImportEvents(ctx, strings.NewReader(calendar))
+	require.Nil(t, err)
+	require.Equal(t, 3, report.Created)
+	require.Equal(t, 0, report.Skipped)
+	require.Equal(t, 2, report.Rejected)
+
+	statuses := []types.ImportStatus{types.ImportCreated, types.ImportCreated, types.ImportCreated,
+		types.ImportRejected, types.ImportRejected}
+	for i, item := range report.Items {
+		require.Equal(t, statuses[i], item.Status, item.UID)
+	}
+	require.Equal(t, "the server cannot process the request: name: is required", report.Items[3].Reason)
+
+	events, err := bl.GetEventsInRange(ctx, time.Date(2023, 3, 20, 0, 0, 0, 0, loc), time.Date(2023, 5, 3, 0, 0, 0, 0, loc))
+	require.Nil(t, err)
+	require.Len(t, events, 4)
+
+	require.Equal(t, "Stand-up", events[0].Name)
+	require.Equal(t, "America/New_York", events[0].Timezone)
+	require.Equal(t, time.Date(2023, 3, 20, 14, 0, 0, 0, loc), events[0].StartTime)
+	require.Equal(t, time.Date(2023, 3, 20, 13, 55, 0, 0, loc), events[0].AlertTime)
+	require.Equal(t, "Stand-up moved", events[1].Name)
+	require.Equal(t, time.Date(2023, 3, 22, 16, 0, 0, 0, loc), events[1].StartTime)
+	require.Equal(t, time.Date(2023, 3, 23, 14, 0, 0, 0, loc), events[2].StartTime)
+	require.Equal(t, "Holiday", events[3].Name)
+	require.True(t, events[3].AllDay)
+	require.Equal(t, time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC), events[3].StartTime)
+	require.Equal(t, time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC), events[3].EndTime)
+
+	report, err = bl.ImportEvents(ctx, strings.NewReader(calendar))
+	require.Nil(t, err)
+	require.Equal(t, 0, report.Created)
+	require.Equal(t, 3, report.Skipped)
+
+	calendarExport, err := bl.ExportEvents(ctx, types.Filters{})
+	require.Nil(t, err)
+	require.Contains(t, string(calendarExport), "UID:moved@example.com")
+
+	_, err = bl.ImportEvents(ctx, strings.NewReader("BEGIN:VCARD\r\nEND:VCARD\r\n"))
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.AddEvent(ctx, types.Event{Name: "Duplicate", StartTime: time.Now(), EndTime: time.Now(),
+		UID: "holiday@example.com"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+}
+
+func TestExportImportTimezone(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+	otherCtx := contextHelpers.WriteLoginToContext(context.Background(), "other")
+	otherCtx = contextHelpers.WriteTimezoneToContext(otherCtx, "Asia/Tokyo")
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	newYork, _ := time.LoadLocation("America/New_York")
+	ti := time.Date(2023, 10, 30, 9, 0, 0, 0, newYork)
+
+	err := bl.AddEvent(ctx, types.Event{Name: "Stand-up", StartTime: ti, EndTime: ti.Add(15 * time.Minute),
+		Recurrence: "FREQ=WEEKLY;COUNT=2", Timezone: "America/New_York"})
+	require.Nil(t, err)
+
+	calendar, err := bl.ExportEvents(ctx, types.Filters{})
+	require.Nil(t, err)
+	require.Contains(t, string(calendar), "DTSTART;TZID=America/New_York:20231030T090000\r\n")
+
+	report, err := bl.ImportEvents(otherCtx, strings.NewReader(string(calendar)))
+	require.Nil(t, err)
+	require.Equal(t, 1, report.Created)
+
+	// The imported series follows the offsets of New York, not the ones of the importing user
+	events, err := bl.GetEventsInRange(otherCtx, ti, ti.AddDate(0, 0, 8))
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "America/New_York", events[0].Timezone)
+	require.True(t, events[0].StartTime.Equal(ti))
+	require.True(t, events[1].StartTime.Equal(ti.AddDate(0, 0, 7)))
+}
+
+func TestValidateEvent(t *testing.T) {
+	ti := time.Date(2020, 5, 15, 20, 30, 0, 0, time.UTC)
+
+	valid := types.Event{
+		ID:          300,
+		Name:        "Daily meeting",
+		StartTime:   ti,
+		EndTime:     ti,
+		Description: "A daily meeting for backend team",
+		AlertTime:   ti,
+	}
+
+	invalid := func(fields ...customErrors.FieldError) error {
+		return customErrors.ValidationError{Fields: fields}
+	}
+	tooLong := func(field string, length int) customErrors.FieldError {
+		return customErrors.FieldError{Field: field, Code: customErrors.CodeTooLong,
+			Message: fmt.Sprintf("should be at most %d characters", length)}
+	}
+
+	testCases := []struct {
+		testName string
+		update   types.Event
+		allDay   bool
+		expError error
+	}{
+		{testName: "Valid"},
+		{testName: "Emoji", update: types.Event{Name: "😂"}},
+		{
+			// len 288, rune 96
+			testName: "NameRunes",
+			update: types.Event{Name: "嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複" +
+				"嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複嘉定屠城紀略》影印本重複"},
+		},
+		{testName: "NameMaxLength", update: types.Event{Name: strings.Repeat("😂", 255)}},
+		{
+			testName: "NameTooLong",
+			update:   types.Event{Name: strings.Repeat("嘉", 256)},
+			expError: invalid(tooLong("name", 255)),
+		},
+		{
+			testName: "DescriptionTooLong",
+			update:   types.Event{Description: strings.Repeat("😂", 511)},
+			expError: invalid(tooLong("description", 510)),
+		},
+		{
+			testName: "EndBeforeStart",
+			update:   types.Event{EndTime: ti.Add(-time.Minute)},
+			expError: invalid(customErrors.FieldError{Field: "endTime", Code: customErrors.CodeEndBeforeStart,
+				Message: "should not be before startTime"}),
+		},
+		{
+			testName: "AlertAfterStart",
+			update:   types.Event{AlertTime: ti.Add(time.Minute)},
+			expError: invalid(customErrors.FieldError{Field: "alertTime", Code: customErrors.CodeAlertAfterStart,
+				Message: "should not be after startTime"}),
+		},
+		{
+			testName: "AllDayEndDateExclusive",
+			allDay:   true,
+			expError: invalid(customErrors.FieldError{Field: "endTime", Code: customErrors.CodeEndBeforeStart,
+				Message: "should be after startTime, the end date of an all-day event is exclusive"}),
+		},
+		{
+			testName: "AllDayAlert",
+			allDay:   true,
+			update:   types.Event{EndTime: ti.AddDate(0, 0, 1), AlertTime: ti.Add(time.Hour)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			e := updatedEvent(valid, tc.update)
+			e.AllDay = tc.allDay
+
+			err := validateEvent(e)
+			require.Equal(t, tc.expError, err)
+		})
+	}
+
+	err := validateEvent(types.Event{ID: 400, Name: "", Description: "A daily meeting for backend team"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+	require.Equal(t, invalid(
+		customErrors.FieldError{Field: "name", Code: customErrors.CodeRequired, Message: "is required"},
+		customErrors.FieldError{Field: "startTime", Code: customErrors.CodeRequired, Message: "is required"},
+		customErrors.FieldError{Field: "endTime", Code: customErrors.CodeRequired, Message: "is required"},
+	), err, "every invalid field should be reported")
+}
+
+func TestEventToUserTime(t *testing.T) {
+	ctx := context.Background()
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	// UTC time, in Europe/Warsaw should be 12
+	ti := time.Date(2020, 5, 15, 10, 0, 0, 0, time.UTC)
+
+	event := types.Event{
+		ID:        300,
+		Name:      "Daily meeting",
+		StartTime: ti,
+		EndTime:   ti,
+	}
+
+	_, err := bl.eventToUserTime(ctx, event.StartTime)
+	require.NotNilf(t, err, "Should return an error: %v", timezoneFetchErr)
+	require.Equal(t, err, timezoneFetchErr)
+
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	event.StartTime, err = bl.eventToUserTime(ctx, event.StartTime)
+	require.Nil(t, err)
+
+	require.Equal(t, event.StartTime.Hour(), 12, "Failed to convert timezone")
+
+}
+
+func TestEventToUTC(t *testing.T) {
+	ctx := context.Background()
+
+	db := memoryStorage.InitDatabase()
+	bl := InitBusinessLogic(db)
+
+	loc, _ := time.LoadLocation("Europe/Warsaw")
+
+	// Should convert to 10 UTC
+	ti := time.Date(2020, 5, 15, 12, 0, 0, 0, loc)
+	event := types.Event{
+		ID:        300,
+		Name:      "Daily meeting",
+		StartTime: ti,
+		EndTime:   ti,
+	}
+
+	_, err := bl.eventToUTC(ctx, event)
+	require.NotNilf(t, err, "Should return an error: %v", timezoneFetchErr)
+	require.Equal(t, err, timezoneFetchErr)
+
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/Warsaw")
+
+	event, err = bl.eventToUTC(ctx, event)
+	require.Nil(t, err)
+
+	require.Equal(t, event.StartTime.Hour(), 10, "Failed to convert timezone")
+}
+
+func TestPlanAlertsSkipsBrokenRecurrence(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockDB := mocks.NewMockDatabaseRepository(mockCtrl)
+	bl := InitBusinessLogic(mockDB)
+
+	ti := time.Date(2023, 3, 20, 9, 0, 0, 0, time.UTC)
+	broken := types.Event{ID: 1, Name: "Broken", StartTime: ti, EndTime: ti.Add(time.Hour),
+		AlertTime: ti.Add(-5 * time.Minute), Recurrence: "FREQ=SOMETIMES"}
+	daily := types.Event{ID: 2, Name: "Stand-up", StartTime: ti, EndTime: ti.Add(15 * time.Minute),
+		AlertTime: ti.Add(-5 * time.Minute), Recurrence: "FREQ=DAILY"}
+
+	// Exceptions come with the alerting events, they are not queried for every event
+	mockDB.EXPECT().GetAlertingEvents(ctx, ti.Add(-time.Hour), ti).Return([]types.AlertingEvent{
+		{Event: broken, Login: "hello", Timezone: "UTC"}, {Event: daily, Login: "hello", Timezone: "UTC"}}, nil)
+	mockDB.EXPECT().ScheduleAlerts(ctx, []types.Alert{{EventID: 2, OccurrenceStart: ti,
+		AlertTime: ti.Add(-5 * time.Minute)}}).Return(nil)
+
+	err := bl.PlanAlerts(ctx, ti.Add(-time.Hour), ti)
+	require.Nil(t, err)
 }
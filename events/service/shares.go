@@ -0,0 +1,497 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/events/pagination"
+	"github.com/bubo-py/McK/types"
+)
+
+// busyName replaces names of events the user can only see as busy time
+const busyName = "Busy"
+
+// shareRanks orders share levels, owners of calendars have every right
+var shareRanks = map[types.ShareLevel]int{
+	types.ShareFreeBusy: 1,
+	types.ShareRead:     2,
+	types.ShareWrite:    3,
+	types.ShareManage:   4,
+}
+
+// GetShares returns users the calendar is shared with, which is shown to the ones who can manage it
+func (bl BusinessLogic) GetShares(ctx context.Context, id int64) ([]types.Share, error) {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := bl.calendarAccess(ctx, id, login, types.ShareManage)
+	if err != nil {
+		return nil, err
+	}
+
+	return bl.db.GetShares(ctx, id, c.Owner)
+}
+
+// SetShare shares the calendar with the user or changes the level of the existing share
+func (bl BusinessLogic) SetShare(ctx context.Context, id int64, s types.Share) error {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = validateShare(s)
+	if err != nil {
+		return err
+	}
+
+	c, err := bl.calendarAccess(ctx, id, login, types.ShareManage)
+	if err != nil {
+		return err
+	}
+
+	if s.Login == c.Owner {
+		return fmt.Errorf("%w: the calendar cannot be shared with its owner", customErrors.ErrBadRequest)
+	}
+
+	return bl.db.SetShare(ctx, id, s, c.Owner)
+}
+
+// DeleteShare revokes the access of the user to the calendar, users can give up calendars shared with them
+func (bl BusinessLogic) DeleteShare(ctx context.Context, id int64, grantee string) error {
+	login, err := retrieveLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	level := types.ShareManage
+	if grantee == login {
+		level = types.ShareFreeBusy
+	}
+
+	c, err := bl.calendarAccess(ctx, id, login, level)
+	if err != nil {
+		return err
+	}
+
+	return bl.db.DeleteShare(ctx, id, grantee, c.Owner)
+}
+
+// calendarAccess returns the calendar if the user has at least the level of access to it. Calendars the user
+// cannot see are not found, the ones the user sees without the access are unauthorized.
+func (bl BusinessLogic) calendarAccess(ctx context.Context, id int64, login string,
+	level types.ShareLevel) (types.Calendar, error) {
+	c, err := bl.db.GetCalendar(ctx, id, login)
+	if err != nil {
+		return c, err
+	}
+
+	return c, authorize(c, login, level)
+}
+
+// eventAccess returns the calendar of the event, whose owner repositories are queried as,
+// if the user has at least the level of access to it. Attendees see events they are invited to, but only
+// the ones who can write to their calendars change them.
+func (bl BusinessLogic) eventAccess(ctx context.Context, id int64, login string,
+	level types.ShareLevel) (types.Calendar, error) {
+	c, err := bl.db.GetEventCalendar(ctx, id, login)
+	if errors.Is(err, customErrors.ErrNotFound) && shareRanks[level] > shareRanks[types.ShareRead] {
+		_, invitationErr := bl.db.GetInvitation(ctx, id, login)
+		if invitationErr == nil {
+			return c, fmt.Errorf("%w: only the organizer can change event %d", customErrors.ErrUnauthorized, id)
+		}
+	}
+
+	if err != nil {
+		return c, err
+	}
+
+	return c, authorize(c, login, level)
+}
+
+// moveTarget checks the calendar an event of the given one is moved to, events stay with their owner
+func (bl BusinessLogic) moveTarget(ctx context.Context, from types.Calendar, id int64, login string) error {
+	if id == from.ID {
+		return nil
+	}
+
+	to, err := bl.eventCalendar(ctx, id, login)
+	if err != nil {
+		return err
+	}
+
+	if to.Owner != from.Owner {
+		return fmt.Errorf("%w: events cannot be moved to calendars of another user", customErrors.ErrBadRequest)
+	}
+
+	return authorize(to, login, types.ShareWrite)
+}
+
+// visiblePage returns a page of the events visibleEvents lists, a non-zero calendar ID lists a calendar the user
+// can see only. Every owner is queried for a page of the events the user sees, the user for the own events and other
+// users for events of the calendars they share with the user and events the user accepted invitations to, so that
+// the database reads no more than a page of each owner.
+func (bl BusinessLogic) visiblePage(ctx context.Context, q types.PageQuery, calendarID int64,
+	login string) ([]types.Event, error) {
+	loc, err := bl.userLocation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := bl.sharedCalendars(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, err := bl.acceptedInvitations(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	own := true
+	if calendarID != 0 {
+		c, err := bl.db.GetCalendar(ctx, calendarID, login)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only the owner of the calendar has events in it
+		own = c.Owner == login
+		q.CalendarID = c.ID
+		shared = map[string]map[int64]types.Calendar{c.Owner: {c.ID: c}}
+		accepted = map[string]map[int64]bool{c.Owner: accepted[c.Owner]}
+	}
+
+	var s []types.Event
+
+	if own {
+		events, err := bl.db.GetEventsPage(ctx, q, login)
+		if err != nil {
+			return nil, err
+		}
+
+		events, err = bl.withAttendees(ctx, events, login)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range events {
+			s = append(s, eventInLocation(e, loc))
+		}
+	}
+
+	owners := make(map[string]bool)
+	for owner := range shared {
+		owners[owner] = true
+	}
+	for owner := range accepted {
+		owners[owner] = true
+	}
+	delete(owners, login)
+
+	for owner := range owners {
+		events, err := bl.sharedPage(ctx, q, shared[owner], accepted[owner], owner, login)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range events {
+			s = append(s, eventInLocation(e, loc))
+		}
+	}
+
+	return pagination.Select(s, q), nil
+}
+
+// sharedPage returns a page of the events of the owner the user sees through the calendars shared with the user
+// and the accepted invitations. Events the user sees as busy time only are queried apart, because their names
+// are not the ones they are sorted by in the database.
+func (bl BusinessLogic) sharedPage(ctx context.Context, q types.PageQuery, calendars map[int64]types.Calendar,
+	accepted map[int64]bool, owner, login string) ([]types.Event, error) {
+	var s []types.Event
+
+	rq, bq := q, q
+	for id, c := range calendars {
+		if allows(c, login, types.ShareRead) {
+			rq.CalendarIDs = append(rq.CalendarIDs, id)
+		} else {
+			bq.CalendarIDs = append(bq.CalendarIDs, id)
+		}
+	}
+	for id := range accepted {
+		rq.EventIDs = append(rq.EventIDs, id)
+	}
+
+	if len(rq.CalendarIDs) > 0 || len(rq.EventIDs) > 0 {
+		events, err := bl.db.GetEventsPage(ctx, rq, owner)
+		if err != nil {
+			return nil, err
+		}
+
+		events, err = bl.withAttendees(ctx, events, owner)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range events {
+			if accepted[e.ID] {
+				e.Organizer = owner
+			}
+
+			s = append(s, e)
+		}
+	}
+
+	bq, ok := busyQuery(bq)
+	if len(bq.CalendarIDs) == 0 || !ok {
+		return s, nil
+	}
+
+	// Accepted events are listed with their names above, the page is extended by the ones skipped here
+	bq.Limit += len(accepted)
+
+	events, err := bl.db.GetEventsPage(ctx, bq, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		if !accepted[e.ID] {
+			s = append(s, busy(e))
+		}
+	}
+
+	return s, nil
+}
+
+// busyQuery returns the query of a page of events reduced to busy time. Their names are all the same, so sorted
+// by name they follow the key of the page in the order of their IDs. It returns false when none of them follows it.
+func busyQuery(q types.PageQuery) (types.PageQuery, bool) {
+	if q.Sort != types.SortName {
+		return q, true
+	}
+
+	q.Sort = types.SortID
+	if q.After == nil {
+		return q, true
+	}
+
+	c := strings.Compare(busyName, q.After.Name)
+	if q.Desc {
+		c = -c
+	}
+
+	switch {
+	case c > 0:
+		q.After = nil
+	case c == 0:
+		q.After = &types.PageKey{ID: q.After.ID}
+	default:
+		return q, false
+	}
+
+	return q, true
+}
+
+// visibleEvents returns events listed by list for the user together with the ones listed for owners of calendars
+// shared with the user and for organizers of events the user accepted invitations to. Events of calendars shared
+// for free/busy time only are reduced to the time they take, unless the user attends them.
+func (bl BusinessLogic) visibleEvents(ctx context.Context, login string,
+	list func(owner string) ([]types.Event, error)) ([]types.Event, error) {
+	s, err := bl.listWithAttendees(ctx, list, login)
+	if err != nil {
+		return s, err
+	}
+
+	shared, err := bl.sharedCalendars(ctx, login)
+	if err != nil {
+		return s, err
+	}
+
+	accepted, err := bl.acceptedInvitations(ctx, login)
+	if err != nil || len(shared) == 0 && len(accepted) == 0 {
+		return s, err
+	}
+
+	var owners []string
+	for owner := range shared {
+		owners = append(owners, owner)
+	}
+	for owner := range accepted {
+		if _, ok := shared[owner]; !ok {
+			owners = append(owners, owner)
+		}
+	}
+	sort.Strings(owners)
+
+	for _, owner := range owners {
+		events, err := bl.listWithAttendees(ctx, list, owner)
+		if err != nil {
+			return s, err
+		}
+
+		for _, e := range events {
+			if accepted[owner][e.ID] {
+				e.Organizer = owner
+				s = append(s, e)
+				continue
+			}
+
+			c, ok := shared[owner][e.CalendarID]
+			if !ok {
+				continue
+			}
+
+			if !allows(c, login, types.ShareRead) {
+				e = busy(e)
+			}
+
+			s = append(s, e)
+		}
+	}
+
+	sortByStartTime(s)
+
+	return s, nil
+}
+
+// listWithAttendees returns events listed for the owner with their attendees
+func (bl BusinessLogic) listWithAttendees(ctx context.Context, list func(owner string) ([]types.Event, error),
+	owner string) ([]types.Event, error) {
+	events, err := list(owner)
+	if err != nil {
+		return events, err
+	}
+
+	return bl.withAttendees(ctx, events, owner)
+}
+
+// sharedCalendars returns calendars shared with the user grouped by their owners and IDs
+func (bl BusinessLogic) sharedCalendars(ctx context.Context,
+	login string) (map[string]map[int64]types.Calendar, error) {
+	calendars, err := bl.db.GetCalendars(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := make(map[string]map[int64]types.Calendar)
+	for _, c := range calendars {
+		if c.Owner == login {
+			continue
+		}
+
+		if shared[c.Owner] == nil {
+			shared[c.Owner] = make(map[int64]types.Calendar)
+		}
+		shared[c.Owner][c.ID] = c
+	}
+
+	return shared, nil
+}
+
+// visibleExceptions fetches exceptions of the recurring events among the given ones, which may come from calendars
+// of other users or their events the user attends. Exceptions of calendars shared for free/busy time only
+// are reduced to the time they take.
+func (bl BusinessLogic) visibleExceptions(ctx context.Context, events []types.Event,
+	login string) (map[int64][]types.EventException, error) {
+	shared, err := bl.sharedCalendars(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars := make(map[int64]types.Calendar)
+	for _, owned := range shared {
+		for id, c := range owned {
+			calendars[id] = c
+		}
+	}
+
+	byOwner := make(map[string][]types.Event)
+	for _, e := range events {
+		owner := login
+		if c, ok := calendars[e.CalendarID]; ok {
+			owner = c.Owner
+		}
+		if e.Organizer != "" {
+			owner = e.Organizer
+		}
+
+		byOwner[owner] = append(byOwner[owner], e)
+	}
+
+	exceptions := make(map[int64][]types.EventException)
+	for owner, owned := range byOwner {
+		ownerExceptions, err := bl.seriesExceptions(ctx, owned, owner)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range owned {
+			for _, ex := range ownerExceptions[e.ID] {
+				c, ok := calendars[e.CalendarID]
+				if ok && e.Organizer == "" && !allows(c, login, types.ShareRead) {
+					ex.Name, ex.Description, ex.AlertTime = busyName, "", time.Time{}
+				}
+
+				exceptions[e.ID] = append(exceptions[e.ID], ex)
+			}
+		}
+	}
+
+	return exceptions, nil
+}
+
+// allows tells whether the user has at least the level of access to the calendar
+func allows(c types.Calendar, login string, level types.ShareLevel) bool {
+	return c.Owner == login || shareRanks[c.Access] >= shareRanks[level]
+}
+
+// authorize returns ErrUnauthorized unless the user has at least the level of access to the calendar
+func authorize(c types.Calendar, login string, level types.ShareLevel) error {
+	if !allows(c, login, level) {
+		return fmt.Errorf("%w: %s access to calendar %d is required", customErrors.ErrUnauthorized, level, c.ID)
+	}
+
+	return nil
+}
+
+// busy returns the event reduced to the time it takes, the way users see events they can see as busy time only
+func busy(e types.Event) types.Event {
+	return types.Event{
+		ID:            e.ID,
+		Name:          busyName,
+		StartTime:     e.StartTime,
+		EndTime:       e.EndTime,
+		Recurrence:    e.Recurrence,
+		Timezone:      e.Timezone,
+		AllDay:        e.AllDay,
+		CalendarID:    e.CalendarID,
+		OriginalStart: e.OriginalStart,
+	}
+}
+
+func validateShare(s types.Share) error {
+	var fields []customErrors.FieldError
+
+	if s.Login == "" {
+		fields = append(fields, customErrors.FieldError{Field: "login", Code: customErrors.CodeRequired,
+			Message: "is required"})
+	}
+
+	if _, ok := shareRanks[s.Level]; !ok {
+		fields = append(fields, customErrors.FieldError{Field: "level", Code: customErrors.CodeInvalid,
+			Message: "should be one of freeBusy, read, write, manage"})
+	}
+
+	if len(fields) > 0 {
+		return customErrors.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+)
+
+// Lengths of the name and description columns, in characters
+const (
+	maxNameLength        = 255
+	maxDescriptionLength = 510
+)
+
+// validateEvent checks the complete state of an event, with times in UTC, and reports every invalid field
+// of it in a customErrors.ValidationError
+func validateEvent(e types.Event) error {
+	var fields []customErrors.FieldError
+
+	invalid := func(field, code, message string) {
+		fields = append(fields, customErrors.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	switch {
+	case e.Name == "":
+		invalid("name", customErrors.CodeRequired, "is required")
+	case len([]rune(e.Name)) > maxNameLength:
+		invalid("name", customErrors.CodeTooLong, fmt.Sprintf("should be at most %d characters", maxNameLength))
+	}
+
+	if len([]rune(e.Description)) > maxDescriptionLength {
+		invalid("description", customErrors.CodeTooLong,
+			fmt.Sprintf("should be at most %d characters", maxDescriptionLength))
+	}
+
+	if e.StartTime.IsZero() {
+		invalid("startTime", customErrors.CodeRequired, "is required")
+	}
+
+	if e.EndTime.IsZero() {
+		invalid("endTime", customErrors.CodeRequired, "is required")
+	}
+
+	if !e.StartTime.IsZero() && !e.EndTime.IsZero() {
+		switch {
+		case e.AllDay && !e.EndTime.After(e.StartTime):
+			invalid("endTime", customErrors.CodeEndBeforeStart,
+				"should be after startTime, the end date of an all-day event is exclusive")
+		case e.EndTime.Before(e.StartTime):
+			invalid("endTime", customErrors.CodeEndBeforeStart, "should not be before startTime")
+		}
+	}
+
+	// The start of an all-day event depends on the timezone it is viewed in, so its alert is not checked
+	if !e.AllDay && !e.StartTime.IsZero() && e.AlertTime.After(e.StartTime) {
+		invalid("alertTime", customErrors.CodeAlertAfterStart, "should not be after startTime")
+	}
+
+	if len(fields) > 0 {
+		return customErrors.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+// updatedEvent returns the stored event with the fields set in the update, the way repositories apply updates.
+// AllDay of the update replaces the stored one, it is resolved against the stored event before.
+func updatedEvent(stored, update types.Event) types.Event {
+	if update.Name != "" {
+		stored.Name = update.Name
+	}
+
+	if !update.StartTime.IsZero() {
+		stored.StartTime = update.StartTime
+	}
+
+	if !update.EndTime.IsZero() {
+		stored.EndTime = update.EndTime
+	}
+
+	if update.Description != "" {
+		stored.Description = update.Description
+	}
+
+	if !update.AlertTime.IsZero() {
+		stored.AlertTime = update.AlertTime
+	}
+
+	if update.Recurrence != "" {
+		stored.Recurrence = update.Recurrence
+	}
+
+	if update.Timezone != "" {
+		stored.Timezone = update.Timezone
+	}
+
+	if update.CalendarID != 0 {
+		stored.CalendarID = update.CalendarID
+	}
+
+	stored.AllDay = update.AllDay
+
+	return stored
+}
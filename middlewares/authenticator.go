@@ -2,11 +2,17 @@ package middlewares
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/bubo-py/McK/contextHelpers"
 	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/users/lockout"
 	"github.com/bubo-py/McK/users/service"
 )
 
@@ -15,30 +21,47 @@ var unauthenticatedReturn = customErrors.ReturnError{
 	ErrorMessage: customErrors.ErrUnauthenticated.Error(),
 }
 
+var otpRequiredReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrOTPRequired.ErrorType,
+	ErrorMessage: customErrors.ErrOTPRequired.Error(),
+}
+
+var tooManyRequestsReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrTooManyRequests.ErrorType,
+	ErrorMessage: customErrors.ErrTooManyRequests.Error(),
+}
+
+// Authenticate accepts access tokens issued on login and API tokens in the Bearer scheme, Basic auth credentials
+// are still accepted, but they are checked against the password hash on every request. Users with two-factor
+// authentication send a one-time password in the X-OTP header along with Basic auth, the same code is accepted
+// by every request within its time step.
+// Requests authenticated with API tokens are limited to the scopes of the token, see RequireScope.
 func Authenticate(bl service.BusinessLogicInterface) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			login, pwd, ok := r.BasicAuth()
-			if !ok {
-				w.WriteHeader(http.StatusUnauthorized)
-				err := json.NewEncoder(w).Encode(unauthenticatedReturn)
+			user, scopes, err := authenticate(bl, r)
+
+			// Basic auth credentials are counted as logins, so they are locked out after failures as well
+			var locked lockout.LockedError
+			if errors.As(err, &locked) {
+				w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+				w.WriteHeader(http.StatusTooManyRequests)
+				err = json.NewEncoder(w).Encode(tooManyRequestsReturn)
 				if err != nil {
 					log.Println(err)
 				}
 				return
 			}
 
-			err := bl.LoginUser(r.Context(), login, pwd)
-			if err != nil {
+			if errors.Is(err, customErrors.ErrOTPRequired) {
 				w.WriteHeader(http.StatusUnauthorized)
-				err = json.NewEncoder(w).Encode(unauthenticatedReturn)
+				err = json.NewEncoder(w).Encode(otpRequiredReturn)
 				if err != nil {
 					log.Println(err)
 				}
 				return
 			}
 
-			user, err := bl.GetUserByLogin(r.Context(), login)
 			if err != nil {
 				w.WriteHeader(http.StatusUnauthorized)
 				err = json.NewEncoder(w).Encode(unauthenticatedReturn)
@@ -50,8 +73,44 @@ func Authenticate(bl service.BusinessLogicInterface) func(next http.Handler) htt
 
 			r = r.WithContext(contextHelpers.WriteLoginToContext(r.Context(), user.Login))
 			r = r.WithContext(contextHelpers.WriteTimezoneToContext(r.Context(), user.Timezone))
+			r = r.WithContext(contextHelpers.WriteRoleToContext(r.Context(), user.Role))
+
+			if scopes != nil {
+				r = r.WithContext(contextHelpers.WriteScopesToContext(r.Context(), scopes))
+			}
 
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// authenticate returns the user of the request and the scopes it is limited to, which are nil for full access
+func authenticate(bl service.BusinessLogicInterface, r *http.Request) (types.User, []types.TokenScope, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if strings.EqualFold(scheme, "Bearer") {
+		if strings.HasPrefix(token, types.APITokenPrefix) {
+			user, scopes, err := bl.AuthenticateAPIToken(r.Context(), token)
+			if scopes == nil {
+				scopes = []types.TokenScope{}
+			}
+
+			return user, scopes, err
+		}
+
+		user, err := bl.AuthenticateToken(r.Context(), token)
+		return user, nil, err
+	}
+
+	login, pwd, ok := r.BasicAuth()
+	if !ok {
+		return types.User{}, nil, fmt.Errorf("%w: missing credentials", customErrors.ErrUnauthenticated)
+	}
+
+	err := bl.LoginUser(r.Context(), login, pwd, r.Header.Get("X-OTP"))
+	if err != nil {
+		return types.User{}, nil, err
+	}
+
+	user, err := bl.GetUserByLogin(r.Context(), login)
+	return user, nil, err
+}
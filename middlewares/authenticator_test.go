@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/users"
+	"github.com/bubo-py/McK/users/lockout"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticateBasic(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		password      string
+		otp           string
+		loginErr      error
+		expStatusCode int
+		expRetryAfter string
+	}{
+		{
+			testName:      "Basic_positive",
+			password:      "hello",
+			expStatusCode: 200,
+		},
+		{
+			testName:      "Basic_Unauthenticated",
+			password:      "wrong",
+			loginErr:      customErrors.ErrUnauthenticated,
+			expStatusCode: 401,
+		},
+		{
+			testName:      "Basic_lockedOut",
+			password:      "hello",
+			loginErr:      lockout.LockedError{RetryAfter: 1500 * time.Millisecond},
+			expStatusCode: 429,
+			expRetryAfter: "2",
+		},
+		{
+			testName:      "Basic_OTPRequired",
+			password:      "hello",
+			loginErr:      customErrors.ErrOTPRequired,
+			expStatusCode: 401,
+		},
+		{
+			testName:      "Basic_OTP",
+			password:      "hello",
+			otp:           "123456",
+			expStatusCode: 200,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := users.NewMockBusinessLogicInterface(mockCtrl)
+
+			// Failed logins are counted for the address of the client
+			mockBL.EXPECT().LoginUser(gomock.Any(), "hello", tc.password, tc.otp).DoAndReturn(
+				func(ctx context.Context, login, password, otp string) error {
+					ip, _ := contextHelpers.RetrieveClientIPFromContext(ctx)
+					require.Equal(t, "192.0.2.1", ip)
+					return tc.loginErr
+				})
+
+			if tc.loginErr == nil {
+				mockBL.EXPECT().GetUserByLogin(gomock.Any(), "hello").Return(types.User{ID: 1, Login: "hello"}, nil)
+			}
+
+			handler := ClientIP(Authenticate(mockBL)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+			req := httptest.NewRequest("GET", "/api/events", nil)
+			req.SetBasicAuth("hello", tc.password)
+			if tc.otp != "" {
+				req.Header.Set("X-OTP", tc.otp)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expStatusCode, w.Result().StatusCode, "Wrong status code returned")
+			require.Equal(t, tc.expRetryAfter, w.Result().Header.Get("Retry-After"))
+		})
+	}
+}
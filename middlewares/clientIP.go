@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/bubo-py/McK/contextHelpers"
+)
+
+// ClientIP writes the address of the client to the context. Forwarding headers are not trusted, behind a proxy
+// the address has to be restored before this middleware, e.g. by chi's RealIP.
+func ClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		next.ServeHTTP(w, r.WithContext(contextHelpers.WriteClientIPToContext(r.Context(), ip)))
+	})
+}
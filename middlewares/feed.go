@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/users/service"
+	"github.com/go-chi/chi"
+)
+
+// AuthenticateFeed authenticates read-only calendar subscriptions by the token in their URL,
+// calendar clients usually cannot be configured to send Basic auth credentials.
+func AuthenticateFeed(bl service.BusinessLogicInterface) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := bl.GetUserByFeedToken(r.Context(), chi.URLParam(r, "token"))
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				err = json.NewEncoder(w).Encode(unauthenticatedReturn)
+				if err != nil {
+					log.Println(err)
+				}
+				return
+			}
+
+			r = r.WithContext(contextHelpers.WriteLoginToContext(r.Context(), user.Login))
+			r = r.WithContext(contextHelpers.WriteTimezoneToContext(r.Context(), user.Timezone))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
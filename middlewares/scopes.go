@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+)
+
+var unauthorizedReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrUnauthorized.ErrorType,
+	ErrorMessage: customErrors.ErrUnauthorized.Error(),
+}
+
+// RequireScope limits requests authenticated with API tokens to tokens with the read scope for safe methods
+// and the write scope for the others. Requests authenticated with passwords or access tokens are not limited.
+func RequireScope(read, write types.TokenScope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope := write
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				scope = read
+			}
+
+			if !hasScope(r, scope) {
+				forbidden(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DenyAPITokens rejects requests authenticated with API tokens, e.g. the ones managing the account,
+// so that a leaked token cannot be turned into a password or another token
+func DenyAPITokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, limited := contextHelpers.RetrieveScopesFromContext(r.Context())
+		if limited {
+			forbidden(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasScope(r *http.Request, scope types.TokenScope) bool {
+	scopes, limited := contextHelpers.RetrieveScopesFromContext(r.Context())
+	if !limited {
+		return true
+	}
+
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func forbidden(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+
+	err := json.NewEncoder(w).Encode(unauthorizedReturn)
+	if err != nil {
+		log.Println(err)
+	}
+}
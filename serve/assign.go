@@ -0,0 +1,28 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// AssignUnownedEvents hands events created before ownership was introduced over to the user with the login
+func AssignUnownedEvents(ctx context.Context, w io.Writer, login string) error {
+	eventsDb, usersDb, err := initDatabases(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = usersDb.GetUserByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	n, err := eventsDb.AssignUnownedEvents(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d events assigned to user %q\n", n, login)
+	return nil
+}
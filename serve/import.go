@@ -0,0 +1,63 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	eventsService "github.com/bubo-py/McK/events/service"
+	"github.com/bubo-py/McK/types"
+)
+
+// Import adds events from an iCalendar file to the calendar of the user with the given login
+// and prints a line for every VEVENT of the file to w.
+func Import(ctx context.Context, w io.Writer, login, path string) error {
+	eventsDb, usersDb, err := initDatabases(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := usersDb.GetUserByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx = contextHelpers.WriteLoginToContext(ctx, user.Login)
+	ctx = contextHelpers.WriteTimezoneToContext(ctx, user.Timezone)
+
+	report, err := eventsService.InitBusinessLogic(eventsDb).ImportEvents(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range report.Items {
+		fmt.Fprintf(w, "%-8s %s", item.Status, item.UID)
+
+		if item.RecurrenceID != nil {
+			fmt.Fprintf(w, " (occurrence %s)", item.RecurrenceID.Format(time.RFC3339))
+		}
+
+		if item.Name != "" {
+			fmt.Fprintf(w, " %q", item.Name)
+		}
+
+		if item.Status != types.ImportCreated {
+			fmt.Fprintf(w, ": %s", item.Reason)
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "%d created, %d skipped, %d rejected\n", report.Created, report.Skipped, report.Rejected)
+
+	return nil
+}
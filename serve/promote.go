@@ -0,0 +1,26 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/bubo-py/McK/types"
+)
+
+// PromoteUser grants the administrator role to the user with the given login, it bootstraps the first
+// administrator, who can then manage the other users through the API
+func PromoteUser(ctx context.Context, w io.Writer, login string) error {
+	_, usersDb, err := initDatabases(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = usersDb.SetUserRole(ctx, login, types.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "user %q is now an administrator\n", login)
+	return nil
+}
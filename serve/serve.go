@@ -2,68 +2,343 @@ package serve
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/bubo-py/McK/events/alerts"
 	eventsHandlers "github.com/bubo-py/McK/events/handlers"
 	eventsPostgres "github.com/bubo-py/McK/events/repositories/postgres"
 	eventsService "github.com/bubo-py/McK/events/service"
 	"github.com/bubo-py/McK/middlewares"
+	"github.com/bubo-py/McK/types"
 	usersHandlers "github.com/bubo-py/McK/users/handlers"
+	"github.com/bubo-py/McK/users/lockout"
+	"github.com/bubo-py/McK/users/mail"
+	"github.com/bubo-py/McK/users/passwords"
 	usersPostgres "github.com/bubo-py/McK/users/repositories/postgres"
 	usersService "github.com/bubo-py/McK/users/service"
+	"github.com/bubo-py/McK/users/tokens"
+	"github.com/bubo-py/McK/users/totp"
+	webhooksHandlers "github.com/bubo-py/McK/webhooks/handlers"
+	"github.com/bubo-py/McK/webhooks/relay"
+	webhooksPostgres "github.com/bubo-py/McK/webhooks/repositories/postgres"
+	webhooksService "github.com/bubo-py/McK/webhooks/service"
 	"github.com/go-chi/chi"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func Serve(ctx context.Context) {
-	// Database setup
-	connString := os.Getenv("PGURL")
+	eventsDb, usersDb, err := initDatabases(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	eventsDb, err := eventsPostgres.Init(ctx, connString)
+	webhooksDb, err := initWebhooksDatabase(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	usersDb, err := usersPostgres.Init(ctx, connString)
+	signer, err := tokenSigner()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = eventsPostgres.RunMigration(ctx, eventsDb)
+	cipher, err := totpCipher()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = usersPostgres.RunMigration(ctx, usersDb)
+	hasher, err := passwordHasher()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	policy, err := passwordPolicy()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// Business logic setup
 	eventsBl := eventsService.InitBusinessLogic(eventsDb)
-	usersBl := usersService.InitBusinessLogic(usersDb)
+	usersBl := usersService.InitBusinessLogic(usersDb, signer, lockout.InitGuard(usersDb, lockoutConfig()), mailer(),
+		cipher, hasher, policy, eventsBl)
+	webhooksBl := webhooksService.InitBusinessLogic(webhooksDb, net.DefaultResolver)
+
+	// Alerts and webhooks are delivered in the background for as long as the server runs
+	go alerts.InitDispatcher(eventsBl, alertNotifier(), alertsConfig()).Run(ctx)
+	go relay.InitRelay(webhooksBl, relayConfig()).Run(ctx)
 
 	// Router setup
 	r := chi.NewRouter()
+	r.Use(middlewares.ClientIP)
 
 	eventsHandler := eventsHandlers.InitHandler(eventsBl)
 	r.Group(func(r chi.Router) {
 		r.Use(middlewares.Authenticate(usersBl))
+		r.Use(middlewares.RequireScope(types.ScopeEventsRead, types.ScopeEventsWrite))
 		r.Mount("/api/events", eventsHandler.Mux)
+		r.Get("/api/events.ics", eventsHandler.ExportEventsHandler)
+		r.Mount("/api/calendars", eventsHandler.CalendarsMux)
+		r.Get("/api/invitations", eventsHandler.GetInvitationsHandler)
 	})
 
+	// Free/busy and scheduling queries are posted, but they only read events
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Authenticate(usersBl))
+		r.Use(middlewares.RequireScope(types.ScopeEventsRead, types.ScopeEventsRead))
+		r.Post("/api/freebusy", eventsHandler.FreeBusyHandler)
+		r.Post("/api/scheduling/suggest", eventsHandler.SuggestSlotsHandler)
+	})
+
+	// Calendar subscriptions are authenticated by the token in their URL
+	r.With(middlewares.AuthenticateFeed(usersBl)).Get("/api/feeds/{token}.ics", eventsHandler.ExportEventsHandler)
+
 	usersHandler := usersHandlers.InitHandler(usersBl)
 	r.Group(func(r chi.Router) {
 		r.Use(middlewares.Authenticate(usersBl))
+		r.Use(middlewares.DenyAPITokens)
 		r.Mount("/api/users", usersHandler.Mux)
 	})
 
-	// Unprotected route
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Authenticate(usersBl))
+		r.Use(middlewares.DenyAPITokens)
+		r.Mount("/api/admin/users", usersHandler.AdminMux)
+	})
+
+	webhooksHandler := webhooksHandlers.InitHandler(webhooksBl)
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Authenticate(usersBl))
+		r.Use(middlewares.RequireScope(types.ScopeWebhooksRead, types.ScopeWebhooksWrite))
+		r.Mount("/api/webhooks", webhooksHandler.Mux)
+	})
+
+	// Unprotected routes
 	r.Post("/api/users", usersHandler.AddUserHandler)
+	r.Post("/api/auth/login", usersHandler.LoginHandler)
+	r.Post("/api/auth/refresh", usersHandler.RefreshHandler)
+	r.Post("/api/auth/logout", usersHandler.LogoutHandler)
+	r.Post("/api/auth/verify-email", usersHandler.VerifyEmailHandler)
+	r.Post("/api/auth/password-reset", usersHandler.RequestPasswordResetHandler)
+	r.Post("/api/auth/password-reset/confirm", usersHandler.ConfirmPasswordResetHandler)
 
 	port := os.Getenv("LISTEN_AND_SERVE_PORT")
 	log.Printf("Starting an HTTP server on port %v", port)
 	log.Fatal(http.ListenAndServe(port, r))
 
 }
+
+// initDatabases connects to the database given by PGURL and runs migrations of both domains
+func initDatabases(ctx context.Context) (eventsPostgres.Db, usersPostgres.Db, error) {
+	connString := os.Getenv("PGURL")
+
+	eventsDb, err := eventsPostgres.Init(ctx, connString)
+	if err != nil {
+		return eventsDb, usersPostgres.Db{}, err
+	}
+
+	usersDb, err := usersPostgres.Init(ctx, connString)
+	if err != nil {
+		return eventsDb, usersDb, err
+	}
+
+	// Users have to be migrated first, events reference them as their owners
+	err = usersPostgres.RunMigration(ctx, usersDb)
+	if err != nil {
+		return eventsDb, usersDb, err
+	}
+
+	err = eventsPostgres.RunMigration(ctx, eventsDb)
+	if err != nil {
+		return eventsDb, usersDb, err
+	}
+
+	return eventsDb, usersDb, nil
+}
+
+// initWebhooksDatabase runs migrations of the webhooks domain, it has to be called after initDatabases,
+// since webhooks read the outbox of the events domain
+func initWebhooksDatabase(ctx context.Context) (webhooksPostgres.Db, error) {
+	webhooksDb, err := webhooksPostgres.Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		return webhooksDb, err
+	}
+
+	err = webhooksPostgres.RunMigration(ctx, webhooksDb)
+	if err != nil {
+		return webhooksDb, err
+	}
+
+	return webhooksDb, nil
+}
+
+// tokenSigner signs access tokens with the AUTH_SIGNING_KEY_ID key of AUTH_SIGNING_KEYS, the other keys are
+// only used to verify tokens signed before a rotation. Without keys tokens are signed with a random key,
+// so they are invalidated whenever the server restarts.
+func tokenSigner() (tokens.Signer, error) {
+	keysEnv := os.Getenv("AUTH_SIGNING_KEYS")
+	if keysEnv == "" {
+		log.Println("AUTH_SIGNING_KEYS is not set, access tokens are signed with a temporary key")
+
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return tokens.Signer{}, err
+		}
+
+		return tokens.InitSigner("temporary", []tokens.Key{{ID: "temporary", Secret: secret}})
+	}
+
+	keys, err := tokens.ParseKeys(keysEnv)
+	if err != nil {
+		return tokens.Signer{}, err
+	}
+
+	return tokens.InitSigner(os.Getenv("AUTH_SIGNING_KEY_ID"), keys)
+}
+
+// totpCipher encrypts secrets of two-factor authentication with the base64 encoded TOTP_ENCRYPTION_KEY.
+// Unlike signing keys it cannot be temporary, as stored secrets would be lost on restart, so without it
+// users cannot enroll in two-factor authentication.
+func totpCipher() (totp.Cipher, error) {
+	keyEnv := os.Getenv("TOTP_ENCRYPTION_KEY")
+	if keyEnv == "" {
+		log.Println("TOTP_ENCRYPTION_KEY is not set, two-factor authentication is unavailable")
+		return totp.Cipher{}, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(keyEnv)
+	if err != nil {
+		return totp.Cipher{}, fmt.Errorf("TOTP_ENCRYPTION_KEY should be base64 encoded: %v", err)
+	}
+
+	return totp.InitCipher(key)
+}
+
+// passwordHasher hashes new passwords with PASSWORD_HASH_ALGORITHM, argon2id by default or bcrypt with BCRYPT_COST.
+// Hashes of both algorithms are verified, and the outdated ones are replaced when their users sign in.
+func passwordHasher() (passwords.Hasher, error) {
+	bcryptAlgorithm := passwords.Bcrypt{Cost: bcrypt.DefaultCost}
+
+	costEnv := os.Getenv("BCRYPT_COST")
+	if costEnv != "" {
+		cost, err := strconv.Atoi(costEnv)
+		if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return passwords.Hasher{}, fmt.Errorf("BCRYPT_COST should be between %d and %d", bcrypt.MinCost,
+				bcrypt.MaxCost)
+		}
+
+		bcryptAlgorithm.Cost = cost
+	}
+
+	switch os.Getenv("PASSWORD_HASH_ALGORITHM") {
+	case "", "argon2id":
+		return passwords.InitHasher(passwords.DefaultArgon2id, bcryptAlgorithm), nil
+	case "bcrypt":
+		return passwords.InitHasher(bcryptAlgorithm, passwords.DefaultArgon2id), nil
+	default:
+		return passwords.Hasher{}, fmt.Errorf("PASSWORD_HASH_ALGORITHM should be argon2id or bcrypt")
+	}
+}
+
+// passwordPolicy rejects passwords shorter than PASSWORD_MIN_LENGTH, equal to the login unless
+// PASSWORD_DISALLOW_LOGIN is false, and those listed in the PASSWORD_BREACHED_LIST file
+func passwordPolicy() (passwords.Policy, error) {
+	policy := passwords.DefaultPolicy
+
+	minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+	if err == nil && minLength > 0 {
+		policy.MinLength = minLength
+	}
+
+	disallowLogin, err := strconv.ParseBool(os.Getenv("PASSWORD_DISALLOW_LOGIN"))
+	if err == nil {
+		policy.DisallowLogin = disallowLogin
+	}
+
+	path := os.Getenv("PASSWORD_BREACHED_LIST")
+	if path != "" {
+		policy.Breached, err = passwords.LoadBreached(path)
+		if err != nil {
+			return policy, fmt.Errorf("failed to load PASSWORD_BREACHED_LIST: %v", err)
+		}
+	}
+
+	return policy, nil
+}
+
+// mailer sends mail through SMTP_ADDR when it is set, otherwise messages are written to files of MAIL_DIR
+func mailer() mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "McK <noreply@localhost>"
+	}
+
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+
+		log.Printf("SMTP_ADDR is not set, mail is written to %s", dir)
+		return mail.FileMailer{Dir: dir, From: from}
+	}
+
+	return mail.InitSMTPMailer(addr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+}
+
+// alertNotifier posts alerts to ALERTS_WEBHOOK_URL when it is set, otherwise they are only logged
+func alertNotifier() alerts.Notifier {
+	url := os.Getenv("ALERTS_WEBHOOK_URL")
+	if url == "" {
+		return alerts.LogNotifier{}
+	}
+
+	return alerts.InitWebhookNotifier(url)
+}
+
+func alertsConfig() alerts.Config {
+	config := alerts.DefaultConfig
+
+	interval, err := time.ParseDuration(os.Getenv("ALERTS_POLL_INTERVAL"))
+	if err == nil && interval > 0 {
+		config.Interval = interval
+	}
+
+	return config
+}
+
+func relayConfig() relay.Config {
+	config := relay.DefaultConfig
+
+	interval, err := time.ParseDuration(os.Getenv("WEBHOOKS_POLL_INTERVAL"))
+	if err == nil && interval > 0 {
+		config.Interval = interval
+	}
+
+	return config
+}
+
+func lockoutConfig() lockout.Config {
+	config := lockout.DefaultConfig
+
+	maxFailures, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES"))
+	if err == nil && maxFailures > 0 {
+		config.MaxFailures = maxFailures
+	}
+
+	duration, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION"))
+	if err == nil && duration > 0 {
+		config.LockoutDuration = duration
+	}
+
+	return config
+}
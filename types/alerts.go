@@ -0,0 +1,47 @@
+package types
+
+import "time"
+
+// AlertStatus tells whether an alert was delivered by the dispatcher
+type AlertStatus string
+
+const (
+	AlertPending   AlertStatus = "pending"
+	AlertDelivered AlertStatus = "delivered"
+	AlertFailed    AlertStatus = "failed"
+
+	// AlertCancelled marks alerts of occurrences which were moved, cancelled or lost their alert since planned
+	AlertCancelled AlertStatus = "cancelled"
+)
+
+// Alert is a reminder of a single occurrence of an event, delivered at its alert time
+type Alert struct {
+	ID              int64     `json:"id"`
+	EventID         int64     `json:"eventId"`
+	OccurrenceStart time.Time `json:"occurrenceStart"`
+	AlertTime       time.Time `json:"alertTime"`
+
+	Login    string `json:"login"`
+	Timezone string `json:"timezone"`
+
+	// Details of the occurrence at the time of the delivery
+	Name        string    `json:"name"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	Description string    `json:"description,omitempty"`
+
+	Status      AlertStatus `json:"status"`
+	Attempts    int         `json:"attempts"`
+	LastError   string      `json:"lastError,omitempty"`
+	NextAttempt time.Time   `json:"-"`
+	DeliveredAt *time.Time  `json:"deliveredAt,omitempty"`
+}
+
+// AlertingEvent is an event with an alert together with its owner, alerts are planned for events of all users.
+// Recurring events come with their exceptions.
+type AlertingEvent struct {
+	Event
+	Login      string
+	Timezone   string
+	Exceptions []EventException
+}
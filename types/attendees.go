@@ -0,0 +1,31 @@
+package types
+
+// RSVPStatus is the answer of an attendee to the invitation to an event
+type RSVPStatus string
+
+const (
+	// RSVPNeedsAction is the status of attendees who have not answered yet, including the ones
+	// whose answer was reset by a change of the time of the event
+	RSVPNeedsAction RSVPStatus = "needsAction"
+	RSVPAccepted    RSVPStatus = "accepted"
+	RSVPDeclined    RSVPStatus = "declined"
+	RSVPTentative   RSVPStatus = "tentative"
+)
+
+// Attendee is invited to an event by its organizer, either a user by login or an external guest by email address
+type Attendee struct {
+	Login  string     `json:"login,omitempty"`
+	Email  string     `json:"email,omitempty"`
+	Status RSVPStatus `json:"status,omitempty"`
+}
+
+// Invitation is an event of another user the user attends, with the answer of the user
+type Invitation struct {
+	Event  Event      `json:"event"`
+	Status RSVPStatus `json:"status"`
+}
+
+// RSVP is the answer of the user to an invitation
+type RSVP struct {
+	Status RSVPStatus `json:"status"`
+}
@@ -0,0 +1,59 @@
+package types
+
+// CalendarVisibility tells what other users can learn about events of a calendar
+type CalendarVisibility string
+
+const (
+	// VisibilityFreeBusy lets other users see when the owner is busy, but not the events themselves
+	VisibilityFreeBusy CalendarVisibility = "freeBusy"
+	VisibilityPrivate  CalendarVisibility = "private"
+)
+
+// DefaultCalendarName is the name of the calendar every user has, it keeps events added without a calendar
+const DefaultCalendarName = "Personal"
+
+type Calendar struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"` // #RRGGBB
+
+	// Timezone is given to events added to the calendar without their own one
+	Timezone   string             `json:"timezone,omitempty"`
+	Visibility CalendarVisibility `json:"visibility"`
+
+	// Default is the calendar of events added without one, it cannot be deleted
+	Default bool `json:"default"`
+
+	// Owner is the login of the user the calendar belongs to
+	Owner string `json:"owner"`
+
+	// Access is the level the calendar is shared with the user at, it is empty for calendars of the user
+	Access ShareLevel `json:"access,omitempty"`
+}
+
+// CalendarEvents tells what happens to events of a deleted calendar
+type CalendarEvents string
+
+const (
+	CalendarEventsMove   CalendarEvents = "move"
+	CalendarEventsDelete CalendarEvents = "delete"
+)
+
+// ShareLevel is the access to a calendar granted to another user, every level includes the lower ones
+type ShareLevel string
+
+const (
+	// ShareFreeBusy shows when events of the calendar take place, but not what they are
+	ShareFreeBusy ShareLevel = "freeBusy"
+	ShareRead     ShareLevel = "read"
+	ShareWrite    ShareLevel = "write"
+
+	// ShareManage allows changing the calendar and its shares as well, only the owner can delete it
+	ShareManage ShareLevel = "manage"
+)
+
+// Share grants the user with the login access to a calendar
+type Share struct {
+	Login string     `json:"login"`
+	Level ShareLevel `json:"level"`
+}
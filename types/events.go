@@ -9,4 +9,83 @@ type Event struct {
 	EndTime     time.Time `json:"endTime"`   // RFC 3339, section 5.6
 	Description string    `json:"description,omitempty"`
 	AlertTime   time.Time `json:"alertTime,omitempty"`
+	Recurrence  string    `json:"recurrence,omitempty"` // RFC 5545 RRULE, e.g. FREQ=WEEKLY;BYDAY=MO,WE
+
+	// Timezone is the IANA zone the times of the event are given in, e.g. of a flight departing in Tokyo.
+	// It overrides the timezone of the user, whose local times are still used in responses.
+	Timezone string `json:"timezone,omitempty"`
+
+	// AllDay events span whole days, their start and end times are dates at midnight UTC, which are not shifted
+	// to any timezone. The end date is exclusive like DTEND in iCalendar, a single day ends on the next one.
+	AllDay bool `json:"allDay,omitempty"`
+
+	// CalendarID is the calendar of the event, the default calendar of the user when it is added without one
+	CalendarID int64 `json:"calendarId,omitempty"`
+
+	// UID is the iCalendar identifier of an imported event, unique among events of its owner
+	UID string `json:"uid,omitempty"`
+
+	// OriginalStart identifies an occurrence of a recurring event, it is set only on expanded occurrences
+	OriginalStart *time.Time `json:"originalStart,omitempty"`
+
+	// Attendees are invited by the owner of the event. In updates a missing list keeps the stored one.
+	Attendees []Attendee `json:"attendees,omitempty"`
+
+	// Organizer is the login of the owner of an event the user attends, it is empty on other events
+	Organizer string `json:"organizer,omitempty"`
+}
+
+// EventUpdate changes the fields of an event given in it and keeps the other ones. AllDay is a pointer,
+// so that false turns an all-day event back into a timed one, which requires new start and end times.
+type EventUpdate struct {
+	Event
+	AllDay *bool `json:"allDay,omitempty"`
+}
+
+// EventException cancels or overrides a single occurrence of a recurring event,
+// like EXDATE and RECURRENCE-ID in iCalendar. The occurrence is identified
+// by the start time it has according to the recurrence rule.
+type EventException struct {
+	EventID       int64     `json:"eventId"`
+	OriginalStart time.Time `json:"originalStart"`
+	Cancelled     bool      `json:"cancelled"`
+	Name          string    `json:"name,omitempty"`
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+	Description   string    `json:"description,omitempty"`
+	AlertTime     time.Time `json:"alertTime,omitempty"`
+}
+
+// OccurrenceScope tells which occurrences of a recurring event are affected by a change
+type OccurrenceScope string
+
+const (
+	ScopeThis      OccurrenceScope = "this"
+	ScopeFollowing OccurrenceScope = "following"
+	ScopeAll       OccurrenceScope = "all"
+)
+
+// ImportStatus tells what happened to a single VEVENT of an imported calendar
+type ImportStatus string
+
+const (
+	ImportCreated  ImportStatus = "created"
+	ImportSkipped  ImportStatus = "skipped"
+	ImportRejected ImportStatus = "rejected"
+)
+
+type ImportItem struct {
+	UID          string       `json:"uid,omitempty"`
+	Name         string       `json:"name,omitempty"`
+	RecurrenceID *time.Time   `json:"recurrenceId,omitempty"`
+	Status       ImportStatus `json:"status"`
+	Reason       string       `json:"reason,omitempty"`
+}
+
+// ImportReport lists the VEVENTs of an imported calendar in their original order
+type ImportReport struct {
+	Created  int          `json:"created"`
+	Skipped  int          `json:"skipped"`
+	Rejected int          `json:"rejected"`
+	Items    []ImportItem `json:"items"`
 }
@@ -1,7 +1,17 @@
 package types
 
+import "time"
+
 type Filters struct {
 	Day   int
 	Month int
 	Year  int
+
+	// From and To select events overlapping the [From, To) interval,
+	// they are wall-clock times in the timezone of the user like any other time of the API
+	From time.Time
+	To   time.Time
+
+	// CalendarID selects events of a single calendar
+	CalendarID int64
 }
@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+// FreeBusyQuery asks when the users with the logins are busy in the [from, to) window
+type FreeBusyQuery struct {
+	Logins []string  `json:"logins"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+}
+
+// FreeBusy tells when the user with the login is busy, without telling what the events are
+type FreeBusy struct {
+	Login string         `json:"login"`
+	Busy  []BusyInterval `json:"busy"`
+}
+
+// BusyInterval is a time the user is busy, overlapping and adjacent events are merged into one interval
+type BusyInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// BusyEvent is an event which makes the user with the login busy. Recurring events come with their exceptions
+// and are expanded in the timezone of their owner.
+type BusyEvent struct {
+	Event
+	Login      string
+	Timezone   string
+	Exceptions []EventException
+}
@@ -0,0 +1,51 @@
+package types
+
+import "time"
+
+// SortField is a field listings of events can be sorted by
+type SortField string
+
+const (
+	SortStartTime SortField = "startTime"
+	SortName      SortField = "name"
+	SortID        SortField = "id"
+)
+
+// Page selects a page of a listing. Cursor is returned with the previous page and carries its sort order.
+type Page struct {
+	Limit  int
+	Sort   SortField
+	Desc   bool
+	Cursor string
+}
+
+// PageKey is the position of an event in a sorted listing, events with the same value of the sorted field
+// are ordered by their ID and then by their start time, which tells apart occurrences of recurring events
+type PageKey struct {
+	Name      string    `json:"name,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	ID        int64     `json:"id"`
+}
+
+// PageQuery selects up to Limit events following the After key in the sort order, from the start when it is nil
+type PageQuery struct {
+	Sort  SortField
+	Desc  bool
+	After *PageKey
+	Limit int
+
+	// CalendarID selects events of a single calendar, events of all calendars are selected when it is zero
+	CalendarID int64
+
+	// CalendarIDs and EventIDs narrow the selection to events of the calendars or with the IDs, e.g. to calendars
+	// shared with a user and to events the user attends. Events matching either of them are selected.
+	CalendarIDs []int64
+	EventIDs    []int64
+}
+
+// EventPage is a page of a listing with cursors of the neighbouring pages, they are empty at the ends of the listing
+type EventPage struct {
+	Events []Event
+	Next   string
+	Prev   string
+}
@@ -0,0 +1,45 @@
+package types
+
+import "time"
+
+// SuggestQuery asks for times the attendees can meet for the duration in the [from, to) window,
+// the user who asks attends the meeting as well
+type SuggestQuery struct {
+	Attendees   []string        `json:"attendees"`
+	Duration    int             `json:"duration"` // minutes
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	Constraints SlotConstraints `json:"constraints"`
+	Limit       int             `json:"limit,omitempty"`
+}
+
+// SlotConstraints narrow down the times suggested for a meeting
+type SlotConstraints struct {
+	// WorkingHours apply in the timezone of every attendee, 09:00-17:00 on weekdays when left out
+	WorkingHours *WorkingHours `json:"workingHours,omitempty"`
+
+	// AttendeeWorkingHours are working hours of single attendees by their logins, the user's included,
+	// they apply instead of WorkingHours
+	AttendeeWorkingHours map[string]WorkingHours `json:"attendeeWorkingHours,omitempty"`
+
+	// Buffer is the time in minutes attendees should be free before and after the meeting
+	Buffer int `json:"buffer,omitempty"`
+
+	// Earliest and Latest bound the time of day of the meeting in the user's timezone, as HH:MM
+	Earliest string `json:"earliest,omitempty"`
+	Latest   string `json:"latest,omitempty"`
+}
+
+// WorkingHours are the hours of the days attendees can meet at, as HH:MM in their own timezones
+type WorkingHours struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"` // monday to sunday, weekdays when left out
+}
+
+// Slot is a suggested time of a meeting with the attendees who are busy at it
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Busy  []string  `json:"busy,omitempty"`
+}
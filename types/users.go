@@ -1,10 +1,245 @@
 package types
 
+import "time"
+
 type User struct {
-	ID       int64  `json:"id"`
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+
+	// Password is the hash of the password, users are decoded from NewUser and UserUpdate and returned as Profile
+	Password string `json:"-"`
+	Timezone string `json:"timezone"` // E.g. Africa/Abidjan, Europe/London, Asia/Tokyo
+
+	// Email is optional, it has to be verified before passwords can be reset through it
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"-"`
+
+	// Role, Disabled and PasswordResetRequired are managed by administrators
+	Role                  Role `json:"role,omitempty"`
+	Disabled              bool `json:"disabled,omitempty"`
+	PasswordResetRequired bool `json:"passwordResetRequired,omitempty"`
+
+	// TOTPSecret is encrypted, it is set on enrollment and TOTPEnabled once the enrollment is verified.
+	// TOTPLastStep is the time step of the last accepted code, codes cannot be used twice.
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `json:"-"`
+	TOTPLastStep int64  `json:"-"`
+
+	// Preferences are left unchanged on updates when nil
+	Preferences *Preferences `json:"-"`
+	CreatedAt   time.Time    `json:"-"`
+	UpdatedAt   time.Time    `json:"-"`
+}
+
+// NewUser signs a user up
+type NewUser struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
-	Timezone string `json:"timezone"` // E.g. Africa/Abidjan, Europe/London, Asia/Tokyo
+	Timezone string `json:"timezone"`
+	Email    string `json:"email"`
+}
+
+// UserUpdate changes the login and the timezone of a user. Passwords are changed with PasswordChange only,
+// so a given password is rejected.
+type UserUpdate struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Timezone string `json:"timezone"`
+}
+
+// Preferences tell clients how to present the calendar of a user, empty values leave the choice to the client
+type Preferences struct {
+	WeekStart  string `json:"weekStart,omitempty"`  // monday or sunday
+	TimeFormat string `json:"timeFormat,omitempty"` // 12h or 24h
+}
+
+// Profile is how users are returned by the API, it never carries the password hash
+type Profile struct {
+	ID                    int64       `json:"id"`
+	Login                 string      `json:"login"`
+	Timezone              string      `json:"timezone"`
+	Email                 string      `json:"email,omitempty"`
+	EmailVerified         bool        `json:"emailVerified"`
+	Role                  Role        `json:"role"`
+	Disabled              bool        `json:"disabled,omitempty"`
+	PasswordResetRequired bool        `json:"passwordResetRequired,omitempty"`
+	TOTPEnabled           bool        `json:"totpEnabled"`
+	Preferences           Preferences `json:"preferences"`
+	CreatedAt             time.Time   `json:"createdAt"`
+	UpdatedAt             time.Time   `json:"updatedAt"`
+}
+
+// ProfileUpdate changes the profile of the current user, empty fields are left unchanged
+// and given preferences replace the previous ones
+type ProfileUpdate struct {
+	Login       string       `json:"login"`
+	Timezone    string       `json:"timezone"`
+	Preferences *Preferences `json:"preferences"`
+}
+
+// PasswordResetRequest asks for a password reset token to be mailed to the verified email address of a user
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// EmailConfirmation carries a token mailed to a user, NewPassword is required to confirm a password reset
+type EmailConfirmation struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword,omitempty"`
+}
+
+type EmailTokenPurpose string
+
+const (
+	EmailVerification  EmailTokenPurpose = "verification"
+	EmailPasswordReset EmailTokenPurpose = "password-reset"
+)
+
+// EmailToken is a stored single-use token mailed to a user, it is kept by its hash like refresh tokens
+type EmailToken struct {
+	ID        int64
+	UserID    int64
+	Login     string
+	Email     string
+	TokenHash string
+	Purpose   EmailTokenPurpose
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// PasswordChange replaces the password of the current user, who has to confirm it with the current password
+type PasswordChange struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// Role tells what a user is allowed to do besides managing their own events
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// Credentials sign a user in, NewPassword replaces the password when an administrator forced its reset.
+// OTP is a code of the authenticator app or a recovery code, it is required from users with two-factor authentication.
+type Credentials struct {
+	Login       string `json:"login"`
+	Password    string `json:"password"`
+	NewPassword string `json:"newPassword,omitempty"`
+	OTP         string `json:"otp,omitempty"`
+}
+
+// TOTPEnrollment carries a new secret of two-factor authentication, URI is shown as a QR code to authenticator apps
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// OTP confirms an action with a code of the authenticator app or a recovery code
+type OTP struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodes sign in instead of codes of a lost authenticator app, each of them once.
+// They are shown only once when two-factor authentication is enabled.
+type RecoveryCodes struct {
+	Codes []string `json:"codes"`
+}
+
+// TOTPDisable turns two-factor authentication off, it takes both factors
+type TOTPDisable struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// PasswordReset carries the temporary password of a user whose password reset was forced by an administrator
+type PasswordReset struct {
+	TemporaryPassword string `json:"temporaryPassword"`
+}
+
+// Feed is a read-only calendar subscription of a user, its token is shown only once when the feed is created
+type Feed struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
 }
 
 // time.LoadLocation("EST")
+
+// Tokens are issued when a user logs in, the access token authenticates requests until it expires
+// and the refresh token is exchanged for new tokens
+type Tokens struct {
+	AccessToken  string `json:"accessToken,omitempty"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType,omitempty"`
+	ExpiresIn    int    `json:"expiresIn,omitempty"` // seconds
+}
+
+// RefreshToken is a stored refresh token, tokens rotated from the same login share their family
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	Login     string
+	TokenHash string
+	Family    string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// TokenScope is a part of the API an API token can be used for
+type TokenScope string
+
+const (
+	ScopeEventsRead    TokenScope = "events:read"
+	ScopeEventsWrite   TokenScope = "events:write"
+	ScopeWebhooksRead  TokenScope = "webhooks:read"
+	ScopeWebhooksWrite TokenScope = "webhooks:write"
+)
+
+// TokenScopes are all the scopes an API token can be granted
+var TokenScopes = []TokenScope{ScopeEventsRead, ScopeEventsWrite, ScopeWebhooksRead, ScopeWebhooksWrite}
+
+// APITokenPrefix starts every API token, it tells them apart from access tokens
+const APITokenPrefix = "mck_"
+
+// APIToken authenticates scripts and integrations of a user, the token is shown only once when it is created
+// and is recognized by its prefix afterwards
+type APIToken struct {
+	ID         int64        `json:"id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []TokenScope `json:"scopes"`
+	Token      string       `json:"token,omitempty"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	LastUsedAt *time.Time   `json:"lastUsedAt,omitempty"`
+	ExpiresAt  *time.Time   `json:"expiresAt,omitempty"`
+
+	UserID int64  `json:"-"`
+	Login  string `json:"-"`
+}
+
+// LoginAttempts are failed logins of a login or an IP address, their Key is made by lockout.LoginKey or lockout.IPKey
+type LoginAttempts struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil *time.Time
+}
+
+type LockoutAction string
+
+const (
+	LockoutLocked   LockoutAction = "locked"
+	LockoutUnlocked LockoutAction = "unlocked"
+)
+
+// LockoutEvent audits locks of logins and IP addresses, Actor is the administrator who unlocked a login
+type LockoutEvent struct {
+	ID          int64
+	Key         string
+	Action      LockoutAction
+	Failures    int
+	LockedUntil *time.Time
+	Actor       string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,67 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEventType names a change webhooks are notified about
+type WebhookEventType string
+
+const (
+	EventCreated WebhookEventType = "event.created"
+	EventUpdated WebhookEventType = "event.updated"
+	EventDeleted WebhookEventType = "event.deleted"
+)
+
+// WebhookEventTypes are all the types a webhook can subscribe to
+var WebhookEventTypes = []WebhookEventType{EventCreated, EventUpdated, EventDeleted}
+
+type Webhook struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url"`
+
+	// Events the webhook is subscribed to, all of them when empty
+	Events []WebhookEventType `json:"events,omitempty"`
+
+	// Secret signs the deliveries, it is returned only when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OutboxMessage is a change written together with the change itself, it is delivered to webhooks afterwards
+type OutboxMessage struct {
+	Type      WebhookEventType
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+// DeliveryStatus tells whether a message was delivered to a webhook
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+
+	// DeliveryDead marks deliveries which failed too many times, they are kept in the dead-letter list
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is a message of the outbox addressed to a single webhook
+type WebhookDelivery struct {
+	ID          int64            `json:"id"`
+	WebhookID   int64            `json:"webhookId"`
+	MessageID   int64            `json:"messageId"`
+	Type        WebhookEventType `json:"type"`
+	Data        json.RawMessage  `json:"data"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	Status      DeliveryStatus   `json:"status"`
+	Attempts    int              `json:"attempts"`
+	LastError   string           `json:"lastError,omitempty"`
+	NextAttempt time.Time        `json:"-"`
+	DeliveredAt *time.Time       `json:"deliveredAt,omitempty"`
+
+	// Endpoint the delivery is sent to, filled when it is claimed by the relay
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
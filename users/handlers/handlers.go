@@ -9,6 +9,7 @@ import (
 
 	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/users/lockout"
 	"github.com/bubo-py/McK/users/service"
 	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
@@ -29,11 +30,21 @@ var unauthenticatedReturn = customErrors.ReturnError{
 	ErrorMessage: customErrors.ErrUnauthenticated.Error(),
 }
 
+var otpRequiredReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrOTPRequired.ErrorType,
+	ErrorMessage: customErrors.ErrOTPRequired.Error(),
+}
+
 var unauthorizedReturn = customErrors.ReturnError{
 	ErrorType:    customErrors.ErrUnauthorized.ErrorType,
 	ErrorMessage: customErrors.ErrUnauthorized.Error(),
 }
 
+var tooManyRequestsReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrTooManyRequests.ErrorType,
+	ErrorMessage: customErrors.ErrTooManyRequests.Error(),
+}
+
 var unexpectedReturn = customErrors.ReturnError{
 	ErrorType:    customErrors.ErrUnexpected.ErrorType,
 	ErrorMessage: customErrors.ErrUnexpected.Error(),
@@ -42,6 +53,9 @@ var unexpectedReturn = customErrors.ReturnError{
 type Handler struct {
 	bl  service.BusinessLogicInterface
 	Mux *chi.Mux
+
+	// AdminMux serves the administration of all the users
+	AdminMux *chi.Mux
 }
 
 func InitHandler(bl service.BusinessLogicInterface) Handler {
@@ -49,11 +63,34 @@ func InitHandler(bl service.BusinessLogicInterface) Handler {
 
 	r := chi.NewRouter()
 
+	r.Get("/me", h.GetCurrentUserHandler)
+	r.Patch("/me", h.UpdateCurrentUserHandler)
+	r.Post("/me/password", h.ChangePasswordHandler)
+	r.Post("/me/email/verification", h.SendEmailVerificationHandler)
+	r.Post("/me/totp", h.EnrollTOTPHandler)
+	r.Post("/me/totp/verify", h.EnableTOTPHandler)
+	r.Delete("/me/totp", h.DisableTOTPHandler)
+	r.Post("/me/totp/recovery-codes", h.RegenerateRecoveryCodesHandler)
+	r.Post("/me/feed", h.CreateFeedHandler)
+	r.Get("/me/tokens", h.GetAPITokensHandler)
+	r.Post("/me/tokens", h.CreateAPITokenHandler)
+	r.Delete("/me/tokens/{id}", h.RevokeAPITokenHandler)
 	r.Put("/{id}", h.UpdateUserHandler)
 	r.Delete("/{id}", h.DeleteUserHandler)
 
 	h.Mux = r
 
+	admin := chi.NewRouter()
+
+	admin.Get("/", h.GetUsersHandler)
+	admin.Delete("/{id}", h.DeleteUserHandler)
+	admin.Post("/{id}/disable", h.DisableUserHandler)
+	admin.Post("/{id}/enable", h.EnableUserHandler)
+	admin.Post("/{id}/password-reset", h.ForcePasswordResetHandler)
+	admin.Post("/{id}/unlock", h.UnlockUserHandler)
+
+	h.AdminMux = admin
+
 	h.bl = bl
 	return h
 }
@@ -61,8 +98,8 @@ func InitHandler(bl service.BusinessLogicInterface) Handler {
 func (h *Handler) AddUserHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	var u types.User
-	err := json.NewDecoder(r.Body).Decode(&u)
+	var nu types.NewUser
+	err := json.NewDecoder(r.Body).Decode(&nu)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		err = json.NewEncoder(w).Encode(badRequestReturn)
@@ -72,6 +109,8 @@ func (h *Handler) AddUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	u := types.User{Login: nu.Login, Password: nu.Password, Timezone: nu.Timezone, Email: nu.Email}
+
 	u, err = h.bl.AddUser(r.Context(), u)
 	if err != nil {
 		errBasedReturn(w, err)
@@ -116,8 +155,8 @@ func (h *Handler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var u types.User
-	err = json.NewDecoder(r.Body).Decode(&u)
+	var uu types.UserUpdate
+	err = json.NewDecoder(r.Body).Decode(&uu)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		err = json.NewEncoder(w).Encode(badRequestReturn)
@@ -126,7 +165,8 @@ func (h *Handler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	u.ID = id
+
+	u := types.User{ID: id, Login: uu.Login, Password: uu.Password, Timezone: uu.Timezone}
 
 	u, err = h.bl.UpdateUser(r.Context(), u, id)
 	if err != nil {
@@ -140,6 +180,506 @@ func (h *Handler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *Handler) GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	u, err := h.bl.GetCurrentUser(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(toProfile(u))
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) UpdateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var p types.ProfileUpdate
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	u, err := h.bl.UpdateCurrentUser(r.Context(), p)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(toProfile(u))
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var c types.PasswordChange
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.ChangePassword(r.Context(), c)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EnrollTOTPHandler returns a new secret of two-factor authentication, it is enabled by EnableTOTPHandler
+func (h *Handler) EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enrollment, err := h.bl.EnrollTOTP(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(enrollment)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) EnableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var otp types.OTP
+	err := json.NewDecoder(r.Body).Decode(&otp)
+	if err != nil || otp.Code == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	codes, err := h.bl.EnableTOTP(r.Context(), otp.Code)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(codes)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var d types.TOTPDisable
+	err := json.NewDecoder(r.Body).Decode(&d)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.DisableTOTP(r.Context(), d)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var otp types.OTP
+	err := json.NewDecoder(r.Body).Decode(&otp)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	codes, err := h.bl.RegenerateRecoveryCodes(r.Context(), otp.Code)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(codes)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// CreateFeedHandler creates a read-only calendar subscription URL, which does not require Basic auth
+func (h *Handler) CreateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	feed, err := h.bl.CreateFeed(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(feed)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) GetAPITokensHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	apiTokens, err := h.bl.GetAPITokens(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(apiTokens)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// CreateAPITokenHandler creates an API token for scripts and integrations, it is returned only once
+func (h *Handler) CreateAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var t types.APIToken
+	err := json.NewDecoder(r.Body).Decode(&t)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	t, err = h.bl.CreateAPIToken(r.Context(), t)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(t)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) RevokeAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.RevokeAPIToken(r.Context(), id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	users, err := h.bl.GetUsers(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	profiles := make([]types.Profile, 0, len(users))
+	for _, u := range users {
+		profiles = append(profiles, toProfile(u))
+	}
+
+	err = json.NewEncoder(w).Encode(profiles)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) DisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+func (h *Handler) EnableUserHandler(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+func (h *Handler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.SetUserDisabled(r.Context(), id, disabled)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockUserHandler lets a user locked out by failed logins sign in again
+func (h *Handler) UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.UnlockUser(r.Context(), id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForcePasswordResetHandler replaces the password of a user with a temporary one, which is returned only once
+func (h *Handler) ForcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	reset, err := h.bl.ForcePasswordReset(r.Context(), id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(reset)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// LoginHandler exchanges the login and password of a user for an access token and a refresh token
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var c types.Credentials
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	t, err := h.bl.IssueTokens(r.Context(), c)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(t)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// RefreshHandler exchanges a refresh token for new tokens, the refresh token cannot be used again
+func (h *Handler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var t types.Tokens
+	err := json.NewDecoder(r.Body).Decode(&t)
+	if err != nil || t.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	t, err = h.bl.RefreshTokens(r.Context(), t.RefreshToken)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(t)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// LogoutHandler revokes the refresh token and the tokens it was rotated from or into
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var t types.Tokens
+	err := json.NewDecoder(r.Body).Decode(&t)
+	if err != nil || t.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.RevokeTokens(r.Context(), t.RefreshToken)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SendEmailVerificationHandler mails a new verification token, e.g. when the one sent on signup was lost
+func (h *Handler) SendEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	err := h.bl.SendEmailVerification(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var c types.EmailConfirmation
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil || c.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.VerifyEmail(r.Context(), c.Token)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestPasswordResetHandler accepts unknown emails as well, so that it does not tell which emails have accounts
+func (h *Handler) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req types.PasswordResetRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.RequestPasswordReset(r.Context(), req.Email)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) ConfirmPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var c types.EmailConfirmation
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil || c.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.ConfirmPasswordReset(r.Context(), c)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// toProfile leaves out the password hash and other internals of the user
+func toProfile(u types.User) types.Profile {
+	p := types.Profile{
+		ID:                    u.ID,
+		Login:                 u.Login,
+		Timezone:              u.Timezone,
+		Email:                 u.Email,
+		EmailVerified:         u.EmailVerified,
+		Role:                  u.Role,
+		Disabled:              u.Disabled,
+		PasswordResetRequired: u.PasswordResetRequired,
+		TOTPEnabled:           u.TOTPEnabled,
+		CreatedAt:             u.CreatedAt,
+		UpdatedAt:             u.UpdatedAt,
+	}
+
+	if u.Preferences != nil {
+		p.Preferences = *u.Preferences
+	}
+
+	return p
+}
+
 func errBasedReturn(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, customErrors.ErrBadRequest):
@@ -160,12 +700,29 @@ func errBasedReturn(w http.ResponseWriter, err error) {
 		if err != nil {
 			log.Println(err)
 		}
+	case errors.Is(err, customErrors.ErrOTPRequired):
+		w.WriteHeader(http.StatusUnauthorized)
+		err = json.NewEncoder(w).Encode(otpRequiredReturn)
+		if err != nil {
+			log.Println(err)
+		}
 	case errors.Is(err, customErrors.ErrUnauthorized):
 		w.WriteHeader(http.StatusForbidden)
 		err = json.NewEncoder(w).Encode(unauthorizedReturn)
 		if err != nil {
 			log.Println(err)
 		}
+	case errors.Is(err, customErrors.ErrTooManyRequests):
+		var locked lockout.LockedError
+		if errors.As(err, &locked) {
+			w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+		}
+
+		w.WriteHeader(http.StatusTooManyRequests)
+		err = json.NewEncoder(w).Encode(tooManyRequestsReturn)
+		if err != nil {
+			log.Println(err)
+		}
 	default:
 		err = json.NewEncoder(w).Encode(unexpectedReturn)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/types"
 	"github.com/bubo-py/McK/users"
+	"github.com/bubo-py/McK/users/lockout"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 )
@@ -20,6 +24,7 @@ func TestAddUserHandler(t *testing.T) {
 		decodeErrPresent bool
 		jsonStr          string
 		userToMock       types.User
+		mockIDReturn     int64
 		mockErrReturn    error
 		expJSONReturn    string
 		expIDReturn      string
@@ -29,11 +34,11 @@ func TestAddUserHandler(t *testing.T) {
 			testName: "AddUser_positive_return",
 			jsonStr:  `{"id":1,"login":"hello","password":"hello","timezone":"Europe/London"}`,
 			userToMock: types.User{
-				ID:       1,
 				Login:    "hello",
 				Password: "hello",
 				Timezone: "Europe/London",
 			},
+			mockIDReturn:  1,
 			expIDReturn:   "1\n",
 			expStatusCode: 200,
 		},
@@ -41,7 +46,6 @@ func TestAddUserHandler(t *testing.T) {
 			testName: "AddUser_BadRequest",
 			jsonStr:  `{"id":2,"login":"TooLongLogin","password":"hello","timezone":"Europe/London"}`,
 			userToMock: types.User{
-				ID:       2,
 				Login:    "TooLongLogin",
 				Password: "hello",
 				Timezone: "Europe/London",
@@ -86,7 +90,9 @@ func TestAddUserHandler(t *testing.T) {
 			mockBL := users.NewMockBusinessLogicInterface(mockCtrl)
 
 			if !tc.decodeErrPresent {
-				mockBL.EXPECT().AddUser(r.Context(), tc.userToMock).Return(tc.userToMock, tc.mockErrReturn)
+				created := tc.userToMock
+				created.ID = tc.mockIDReturn
+				mockBL.EXPECT().AddUser(r.Context(), tc.userToMock).Return(created, tc.mockErrReturn)
 			}
 
 			// create handler with mocks
@@ -189,6 +195,14 @@ func TestDeleteUserHandler(t *testing.T) {
 	}
 }
 
+func TestUserSerialization(t *testing.T) {
+	// Users are never returned as they are, but a password hash should not leak if one is encoded by mistake
+	b, err := json.Marshal(types.User{ID: 1, Login: "hello", Password: "$2a$10$hash"})
+	require.Nil(t, err)
+	require.NotContains(t, string(b), "password")
+	require.NotContains(t, string(b), "$2a$10$hash")
+}
+
 func TestUpdateUserHandler(t *testing.T) {
 	testCases := []struct {
 		testName         string
@@ -204,12 +218,11 @@ func TestUpdateUserHandler(t *testing.T) {
 	}{
 		{
 			testName: "UpdateUser_positive_return",
-			r:        httptest.NewRequest("PUT", "/1", bytes.NewBuffer([]byte(`{"id":1,"login":"hello","password":"hello","timezone":"Europe/London"}`))),
+			r:        httptest.NewRequest("PUT", "/1", bytes.NewBuffer([]byte(`{"id":1,"login":"hello","timezone":"Europe/London"}`))),
 			w:        httptest.NewRecorder(),
 			userToMock: types.User{
 				ID:       1,
 				Login:    "hello",
-				Password: "hello",
 				Timezone: "Europe/London",
 			},
 			expID:         1,
@@ -315,3 +328,610 @@ func TestUpdateUserHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateFeedHandler(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		mockReturn    types.Feed
+		mockErrReturn error
+		expJSONReturn string
+		expStatusCode int
+	}{
+		{
+			testName:      "CreateFeed_positive",
+			mockReturn:    types.Feed{Token: "abc", URL: "/api/feeds/abc.ics"},
+			expJSONReturn: `{"token":"abc","url":"/api/feeds/abc.ics"}`,
+			expStatusCode: 201,
+		},
+		{
+			testName:      "CreateFeed_Unauthenticated",
+			mockErrReturn: customErrors.ErrUnauthenticated,
+			expJSONReturn: `{"ErrorType":"Unauthenticated","ErrorMessage":"failed to authenticate current user"}`,
+			expStatusCode: 401,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := users.NewMockBusinessLogicInterface(mockCtrl)
+			mockBL.EXPECT().CreateFeed(gomock.Any()).Return(tc.mockReturn, tc.mockErrReturn)
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			w := httptest.NewRecorder()
+			handler.Mux.ServeHTTP(w, httptest.NewRequest("POST", "/me/feed", nil))
+
+			resp := w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestTokensHandlers(t *testing.T) {
+	tokens := types.Tokens{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}
+
+	testCases := []struct {
+		testName      string
+		path          string
+		jsonStr       string
+		mock          func(mockBL *users.MockBusinessLogicInterface)
+		expJSONReturn string
+		expRetryAfter string
+		expStatusCode int
+	}{
+		{
+			testName: "Login_positive",
+			path:     "/api/auth/login",
+			jsonStr:  `{"login":"hello","password":"hello"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().IssueTokens(gomock.Any(), types.Credentials{Login: "hello", Password: "hello"}).Return(tokens, nil)
+			},
+			expJSONReturn: `{"accessToken":"access","refreshToken":"refresh","tokenType":"Bearer","expiresIn":900}`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "Login_Unauthenticated",
+			path:     "/api/auth/login",
+			jsonStr:  `{"login":"hello","password":"wrong"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().IssueTokens(gomock.Any(), types.Credentials{Login: "hello", Password: "wrong"}).Return(types.Tokens{}, customErrors.ErrUnauthenticated)
+			},
+			expJSONReturn: `{"ErrorType":"Unauthenticated","ErrorMessage":"failed to authenticate current user"}`,
+			expStatusCode: 401,
+		},
+		{
+			testName: "Login_OTPRequired",
+			path:     "/api/auth/login",
+			jsonStr:  `{"login":"hello","password":"hello"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().IssueTokens(gomock.Any(), types.Credentials{Login: "hello", Password: "hello"}).
+					Return(types.Tokens{}, customErrors.ErrOTPRequired)
+			},
+			expJSONReturn: `{"ErrorType":"OTPRequired","ErrorMessage":"a one-time password is required"}`,
+			expStatusCode: 401,
+		},
+		{
+			testName: "Login_OTP",
+			path:     "/api/auth/login",
+			jsonStr:  `{"login":"hello","password":"hello","otp":"123456"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().IssueTokens(gomock.Any(), types.Credentials{Login: "hello", Password: "hello",
+					OTP: "123456"}).Return(tokens, nil)
+			},
+			expJSONReturn: `{"accessToken":"access","refreshToken":"refresh","tokenType":"Bearer","expiresIn":900}`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "Login_lockedOut",
+			path:     "/api/auth/login",
+			jsonStr:  `{"login":"hello","password":"hello"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().IssueTokens(gomock.Any(), types.Credentials{Login: "hello", Password: "hello"}).
+					Return(types.Tokens{}, fmt.Errorf("login: %w", lockout.LockedError{RetryAfter: 15 * time.Minute}))
+			},
+			expJSONReturn: `{"ErrorType":"TooManyRequests","ErrorMessage":"too many requests, try again later"}`,
+			expRetryAfter: "900",
+			expStatusCode: 429,
+		},
+		{
+			testName: "Refresh_positive",
+			path:     "/api/auth/refresh",
+			jsonStr:  `{"refreshToken":"old"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().RefreshTokens(gomock.Any(), "old").Return(tokens, nil)
+			},
+			expJSONReturn: `{"accessToken":"access","refreshToken":"refresh","tokenType":"Bearer","expiresIn":900}`,
+			expStatusCode: 200,
+		},
+		{
+			testName:      "Refresh_missingToken",
+			path:          "/api/auth/refresh",
+			jsonStr:       `{}`,
+			mock:          func(mockBL *users.MockBusinessLogicInterface) {},
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName: "Logout_positive",
+			path:     "/api/auth/logout",
+			jsonStr:  `{"refreshToken":"refresh"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().RevokeTokens(gomock.Any(), "refresh").Return(nil)
+			},
+			expStatusCode: 204,
+		},
+		{
+			testName: "VerifyEmail_positive",
+			path:     "/api/auth/verify-email",
+			jsonStr:  `{"token":"token"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().VerifyEmail(gomock.Any(), "token").Return(nil)
+			},
+			expStatusCode: 204,
+		},
+		{
+			testName:      "VerifyEmail_missingToken",
+			path:          "/api/auth/verify-email",
+			jsonStr:       `{}`,
+			mock:          func(mockBL *users.MockBusinessLogicInterface) {},
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName: "RequestPasswordReset_positive",
+			path:     "/api/auth/password-reset",
+			jsonStr:  `{"email":"hello@example.com"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().RequestPasswordReset(gomock.Any(), "hello@example.com").Return(nil)
+			},
+			expStatusCode: 202,
+		},
+		{
+			testName: "ConfirmPasswordReset_positive",
+			path:     "/api/auth/password-reset/confirm",
+			jsonStr:  `{"token":"token","newPassword":"hello2"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().ConfirmPasswordReset(gomock.Any(), types.EmailConfirmation{Token: "token",
+					NewPassword: "hello2"}).Return(nil)
+			},
+			expStatusCode: 204,
+		},
+		{
+			testName: "ConfirmPasswordReset_invalidToken",
+			path:     "/api/auth/password-reset/confirm",
+			jsonStr:  `{"token":"used","newPassword":"hello2"}`,
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().ConfirmPasswordReset(gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("%w: invalid or expired token", customErrors.ErrBadRequest))
+			},
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := users.NewMockBusinessLogicInterface(mockCtrl)
+			tc.mock(mockBL)
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			handlers := map[string]http.HandlerFunc{
+				"/api/auth/login":                  handler.LoginHandler,
+				"/api/auth/refresh":                handler.RefreshHandler,
+				"/api/auth/logout":                 handler.LogoutHandler,
+				"/api/auth/verify-email":           handler.VerifyEmailHandler,
+				"/api/auth/password-reset":         handler.RequestPasswordResetHandler,
+				"/api/auth/password-reset/confirm": handler.ConfirmPasswordResetHandler,
+			}
+
+			w := httptest.NewRecorder()
+			handlers[tc.path](w, httptest.NewRequest("POST", tc.path, bytes.NewBufferString(tc.jsonStr)))
+
+			resp := w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.expJSONReturn != "" {
+				require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			}
+
+			require.Equal(t, tc.expRetryAfter, resp.Header.Get("Retry-After"))
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestAPITokensHandlers(t *testing.T) {
+	created := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	apiToken := types.APIToken{ID: 1, Name: "CI", Prefix: "mck_abcdefgh", Scopes: []types.TokenScope{types.ScopeEventsRead},
+		CreatedAt: created}
+
+	testCases := []struct {
+		testName      string
+		r             *http.Request
+		mock          func(mockBL *users.MockBusinessLogicInterface)
+		expJSONReturn string
+		expStatusCode int
+	}{
+		{
+			testName: "CreateAPIToken_positive",
+			r:        httptest.NewRequest("POST", "/me/tokens", bytes.NewBufferString(`{"name":"CI","scopes":["events:read"]}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				withToken := apiToken
+				withToken.Token = "mck_abcdefghijkl"
+				mockBL.EXPECT().CreateAPIToken(gomock.Any(), types.APIToken{Name: "CI", Scopes: apiToken.Scopes}).
+					Return(withToken, nil)
+			},
+			expJSONReturn: `{"id":1,"name":"CI","prefix":"mck_abcdefgh","scopes":["events:read"],"token":"mck_abcdefghijkl",
+				"createdAt":"2023-01-01T12:00:00Z"}`,
+			expStatusCode: 201,
+		},
+		{
+			testName: "CreateAPIToken_BadRequest",
+			r:        httptest.NewRequest("POST", "/me/tokens", bytes.NewBufferString(`{"name":"CI"}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().CreateAPIToken(gomock.Any(), types.APIToken{Name: "CI"}).
+					Return(types.APIToken{}, customErrors.ErrBadRequest)
+			},
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName: "GetAPITokens_positive",
+			r:        httptest.NewRequest("GET", "/me/tokens", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().GetAPITokens(gomock.Any()).Return([]types.APIToken{apiToken}, nil)
+			},
+			expJSONReturn: `[{"id":1,"name":"CI","prefix":"mck_abcdefgh","scopes":["events:read"],"createdAt":"2023-01-01T12:00:00Z"}]`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "RevokeAPIToken_positive",
+			r:        httptest.NewRequest("DELETE", "/me/tokens/1", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().RevokeAPIToken(gomock.Any(), int64(1)).Return(nil)
+			},
+			expStatusCode: 204,
+		},
+		{
+			testName: "RevokeAPIToken_NotFound",
+			r:        httptest.NewRequest("DELETE", "/me/tokens/2", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().RevokeAPIToken(gomock.Any(), int64(2)).Return(customErrors.ErrNotFound)
+			},
+			expJSONReturn: `{"ErrorType":"NotFound","ErrorMessage":"the server cannot find the requested resource"}`,
+			expStatusCode: 404,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := users.NewMockBusinessLogicInterface(mockCtrl)
+			tc.mock(mockBL)
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			w := httptest.NewRecorder()
+			handler.Mux.ServeHTTP(w, tc.r)
+
+			resp := w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.expJSONReturn != "" {
+				require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			}
+
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestAdminHandlers(t *testing.T) {
+	created := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		testName      string
+		r             *http.Request
+		mock          func(mockBL *users.MockBusinessLogicInterface)
+		expJSONReturn string
+		expStatusCode int
+	}{
+		{
+			testName: "GetUsers_positive",
+			r:        httptest.NewRequest("GET", "/", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().GetUsers(gomock.Any()).Return([]types.User{
+					{ID: 1, Login: "admin", Password: "hash", Timezone: "Europe/London", Role: types.RoleAdmin,
+						CreatedAt: created, UpdatedAt: created},
+					{ID: 2, Login: "hello", Password: "hash", Timezone: "Europe/London", Role: types.RoleUser,
+						Disabled: true, CreatedAt: created, UpdatedAt: created},
+				}, nil)
+			},
+			expJSONReturn: `[{"id":1,"login":"admin","timezone":"Europe/London","emailVerified":false,"role":"admin",
+				"totpEnabled":false,"preferences":{},"createdAt":"2023-01-01T12:00:00Z",
+				"updatedAt":"2023-01-01T12:00:00Z"},
+				{"id":2,"login":"hello","timezone":"Europe/London","emailVerified":false,"role":"user","disabled":true,
+				"totpEnabled":false,"preferences":{},"createdAt":"2023-01-01T12:00:00Z",
+				"updatedAt":"2023-01-01T12:00:00Z"}]`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "GetUsers_Unauthorized",
+			r:        httptest.NewRequest("GET", "/", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().GetUsers(gomock.Any()).Return(nil, customErrors.ErrUnauthorized)
+			},
+			expJSONReturn: `{"ErrorType":"Unauthorized","ErrorMessage":"the server cannot process the request due to lack of client's access rights"}`,
+			expStatusCode: 403,
+		},
+		{
+			testName: "DisableUser_positive",
+			r:        httptest.NewRequest("POST", "/2/disable", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().SetUserDisabled(gomock.Any(), int64(2), true).Return(nil)
+			},
+			expStatusCode: 204,
+		},
+		{
+			testName: "EnableUser_NotFound",
+			r:        httptest.NewRequest("POST", "/3/enable", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().SetUserDisabled(gomock.Any(), int64(3), false).Return(customErrors.ErrNotFound)
+			},
+			expJSONReturn: `{"ErrorType":"NotFound","ErrorMessage":"the server cannot find the requested resource"}`,
+			expStatusCode: 404,
+		},
+		{
+			testName:      "DisableUser_BadRequest",
+			r:             httptest.NewRequest("POST", "/abc/disable", nil),
+			mock:          func(mockBL *users.MockBusinessLogicInterface) {},
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName: "ForcePasswordReset_positive",
+			r:        httptest.NewRequest("POST", "/2/password-reset", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().ForcePasswordReset(gomock.Any(), int64(2)).
+					Return(types.PasswordReset{TemporaryPassword: "temporary"}, nil)
+			},
+			expJSONReturn: `{"temporaryPassword":"temporary"}`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "UnlockUser_positive",
+			r:        httptest.NewRequest("POST", "/2/unlock", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().UnlockUser(gomock.Any(), int64(2)).Return(nil)
+			},
+			expStatusCode: 204,
+		},
+		{
+			testName: "DeleteUser_positive",
+			r:        httptest.NewRequest("DELETE", "/2", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().DeleteUser(gomock.Any(), int64(2)).Return(nil)
+			},
+			expStatusCode: 204,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := users.NewMockBusinessLogicInterface(mockCtrl)
+			tc.mock(mockBL)
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			w := httptest.NewRecorder()
+			handler.AdminMux.ServeHTTP(w, tc.r)
+
+			resp := w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.expJSONReturn != "" {
+				require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			}
+
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestCurrentUserHandlers(t *testing.T) {
+	created := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	user := types.User{ID: 1, Login: "hello", Password: "hash", Timezone: "Europe/London", Role: types.RoleUser,
+		CreatedAt: created, UpdatedAt: created}
+	updated := user
+	updated.Timezone = "Asia/Tokyo"
+	updated.Preferences = &types.Preferences{WeekStart: "monday"}
+	updated.UpdatedAt = created.Add(time.Hour)
+
+	testCases := []struct {
+		testName      string
+		r             *http.Request
+		mock          func(mockBL *users.MockBusinessLogicInterface)
+		expJSONReturn string
+		expStatusCode int
+	}{
+		{
+			testName: "GetCurrentUser_positive",
+			r:        httptest.NewRequest("GET", "/me", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().GetCurrentUser(gomock.Any()).Return(user, nil)
+			},
+			expJSONReturn: `{"id":1,"login":"hello","timezone":"Europe/London","emailVerified":false,"role":"user",
+				"totpEnabled":false,"preferences":{},"createdAt":"2023-01-01T12:00:00Z","updatedAt":"2023-01-01T12:00:00Z"}`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "UpdateCurrentUser_positive",
+			r: httptest.NewRequest("PATCH", "/me",
+				bytes.NewBufferString(`{"timezone":"Asia/Tokyo","preferences":{"weekStart":"monday"}}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().UpdateCurrentUser(gomock.Any(), types.ProfileUpdate{Timezone: "Asia/Tokyo",
+					Preferences: &types.Preferences{WeekStart: "monday"}}).Return(updated, nil)
+			},
+			expJSONReturn: `{"id":1,"login":"hello","timezone":"Asia/Tokyo","emailVerified":false,"role":"user",
+				"totpEnabled":false,"preferences":{"weekStart":"monday"},"createdAt":"2023-01-01T12:00:00Z",
+				"updatedAt":"2023-01-01T13:00:00Z"}`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "UpdateCurrentUser_BadRequest",
+			r:        httptest.NewRequest("PATCH", "/me", bytes.NewBufferString(`{"preferences":{"weekStart":"friday"}}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().UpdateCurrentUser(gomock.Any(), gomock.Any()).Return(types.User{}, customErrors.ErrBadRequest)
+			},
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName: "ChangePassword_positive",
+			r:        httptest.NewRequest("POST", "/me/password", bytes.NewBufferString(`{"currentPassword":"hello","newPassword":"hello2"}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().ChangePassword(gomock.Any(), types.PasswordChange{CurrentPassword: "hello",
+					NewPassword: "hello2"}).Return(nil)
+			},
+			expStatusCode: 204,
+		},
+		{
+			testName: "ChangePassword_Unauthenticated",
+			r:        httptest.NewRequest("POST", "/me/password", bytes.NewBufferString(`{"currentPassword":"wrong","newPassword":"hello2"}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().ChangePassword(gomock.Any(), gomock.Any()).Return(customErrors.ErrUnauthenticated)
+			},
+			expJSONReturn: `{"ErrorType":"Unauthenticated","ErrorMessage":"failed to authenticate current user"}`,
+			expStatusCode: 401,
+		},
+		{
+			testName: "EnrollTOTP_positive",
+			r:        httptest.NewRequest("POST", "/me/totp", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().EnrollTOTP(gomock.Any()).Return(types.TOTPEnrollment{Secret: "SECRET",
+					URI: "otpauth://totp/McK:hello?secret=SECRET"}, nil)
+			},
+			expJSONReturn: `{"secret":"SECRET","uri":"otpauth://totp/McK:hello?secret=SECRET"}`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "EnableTOTP_positive",
+			r:        httptest.NewRequest("POST", "/me/totp/verify", bytes.NewBufferString(`{"code":"123456"}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().EnableTOTP(gomock.Any(), "123456").
+					Return(types.RecoveryCodes{Codes: []string{"abcde-fghij"}}, nil)
+			},
+			expJSONReturn: `{"codes":["abcde-fghij"]}`,
+			expStatusCode: 200,
+		},
+		{
+			testName:      "EnableTOTP_missingCode",
+			r:             httptest.NewRequest("POST", "/me/totp/verify", bytes.NewBufferString(`{}`)),
+			mock:          func(mockBL *users.MockBusinessLogicInterface) {},
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName: "DisableTOTP_positive",
+			r: httptest.NewRequest("DELETE", "/me/totp",
+				bytes.NewBufferString(`{"password":"hello","code":"123456"}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().DisableTOTP(gomock.Any(), types.TOTPDisable{Password: "hello", Code: "123456"}).
+					Return(nil)
+			},
+			expStatusCode: 204,
+		},
+		{
+			testName: "DisableTOTP_OTPRequired",
+			r:        httptest.NewRequest("DELETE", "/me/totp", bytes.NewBufferString(`{"password":"hello"}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().DisableTOTP(gomock.Any(), gomock.Any()).Return(customErrors.ErrOTPRequired)
+			},
+			expJSONReturn: `{"ErrorType":"OTPRequired","ErrorMessage":"a one-time password is required"}`,
+			expStatusCode: 401,
+		},
+		{
+			testName: "RegenerateRecoveryCodes_positive",
+			r: httptest.NewRequest("POST", "/me/totp/recovery-codes",
+				bytes.NewBufferString(`{"code":"123456"}`)),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().RegenerateRecoveryCodes(gomock.Any(), "123456").
+					Return(types.RecoveryCodes{Codes: []string{"abcde-fghij"}}, nil)
+			},
+			expJSONReturn: `{"codes":["abcde-fghij"]}`,
+			expStatusCode: 200,
+		},
+		{
+			testName: "SendEmailVerification_positive",
+			r:        httptest.NewRequest("POST", "/me/email/verification", nil),
+			mock: func(mockBL *users.MockBusinessLogicInterface) {
+				mockBL.EXPECT().SendEmailVerification(gomock.Any()).Return(nil)
+			},
+			expStatusCode: 202,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := users.NewMockBusinessLogicInterface(mockCtrl)
+			tc.mock(mockBL)
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			w := httptest.NewRecorder()
+			handler.Mux.ServeHTTP(w, tc.r)
+
+			resp := w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.expJSONReturn != "" {
+				require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			}
+
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package lockout protects logins from brute-force attacks. Failed attempts are counted for the login and for
+// the IP address they came from: every failure of a login delays its next attempt, and logins and addresses
+// with too many failures are locked for a while. Rejected attempts never reach the password check,
+// so hammering a login does not cost any hashing.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+)
+
+// Store keeps the failed attempts, it is implemented by the users database and by MemoryStore
+type Store interface {
+	// GetAttempts returns the attempts of those keys which have any
+	GetAttempts(ctx context.Context, keys []string) ([]types.LoginAttempts, error)
+
+	// RecordFailure counts a failure at now and returns the attempts of the key, failures before since are forgotten
+	RecordFailure(ctx context.Context, key string, now, since time.Time) (types.LoginAttempts, error)
+
+	LockAttempts(ctx context.Context, key string, until time.Time) error
+	ClearAttempts(ctx context.Context, key string) error
+	AddLockoutEvent(ctx context.Context, e types.LockoutEvent) error
+}
+
+type Config struct {
+	// MaxFailures of a login before it is locked, zero disables the lockout
+	MaxFailures int
+
+	// MaxIPFailures of an IP address before it is locked, it is higher as users behind a NAT share their address
+	MaxIPFailures int
+
+	// LockoutDuration is the time logins and IP addresses stay locked
+	LockoutDuration time.Duration
+
+	// Window after which failures are forgotten
+	Window time.Duration
+
+	// BaseDelay is the wait after the first failure of a login, it doubles with every failure up to MaxDelay,
+	// zero disables the delays
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultConfig = Config{
+	MaxFailures:     5,
+	MaxIPFailures:   50,
+	LockoutDuration: 15 * time.Minute,
+	Window:          15 * time.Minute,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+}
+
+// LockedError rejects an attempt until RetryAfter passes, it wraps customErrors.ErrTooManyRequests
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e LockedError) Error() string {
+	return fmt.Sprintf("%v: retry after %v", customErrors.ErrTooManyRequests, e.RetryAfter.Round(time.Second))
+}
+
+func (e LockedError) Unwrap() error {
+	return customErrors.ErrTooManyRequests
+}
+
+// RetryAfterSeconds rounds the wait up to whole seconds of the Retry-After header
+func (e LockedError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+type Guard struct {
+	store  Store
+	config Config
+}
+
+func InitGuard(store Store, config Config) Guard {
+	var g Guard
+	g.store = store
+	g.config = config
+	return g
+}
+
+func LoginKey(login string) string {
+	return "login:" + login
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check rejects attempts of locked logins and IP addresses and attempts made too soon after a failure of the login.
+// It tells whether the login has failures, which have to be cleared by Succeed after a successful attempt.
+func (g Guard) Check(ctx context.Context, login, ip string, now time.Time) (bool, error) {
+	keys := []string{LoginKey(login)}
+	if ip != "" {
+		keys = append(keys, IPKey(ip))
+	}
+
+	attempts, err := g.store.GetAttempts(ctx, keys)
+	if err != nil {
+		return false, err
+	}
+
+	var failed bool
+	var wait time.Duration
+
+	for _, a := range attempts {
+		if a.LockedUntil != nil && now.Before(*a.LockedUntil) && a.LockedUntil.Sub(now) > wait {
+			wait = a.LockedUntil.Sub(now)
+		}
+
+		if a.Key != LoginKey(login) || a.Failures == 0 {
+			continue
+		}
+
+		failed = true
+
+		next := a.LastFailure.Add(g.delay(a.Failures))
+		if now.Before(next) && next.Sub(now) > wait {
+			wait = next.Sub(now)
+		}
+	}
+
+	if wait > 0 {
+		return failed, LockedError{RetryAfter: wait}
+	}
+
+	return failed, nil
+}
+
+// Fail counts a failed attempt of the login from the IP address and locks them when they reach their limits
+func (g Guard) Fail(ctx context.Context, login, ip string, now time.Time) error {
+	err := g.fail(ctx, LoginKey(login), g.config.MaxFailures, now)
+	if err != nil {
+		return err
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	return g.fail(ctx, IPKey(ip), g.config.MaxIPFailures, now)
+}
+
+// Succeed clears failures of the login. Failures of the IP address are kept, otherwise a single valid account
+// would let an attacker try other logins from the same address indefinitely.
+func (g Guard) Succeed(ctx context.Context, login string) error {
+	return g.store.ClearAttempts(ctx, LoginKey(login))
+}
+
+// Unlock clears failures and the lock of a login on behalf of the administrator actor
+func (g Guard) Unlock(ctx context.Context, login, actor string, now time.Time) error {
+	err := g.store.ClearAttempts(ctx, LoginKey(login))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Login %q unlocked by %q", login, actor)
+
+	return g.store.AddLockoutEvent(ctx, types.LockoutEvent{Key: LoginKey(login), Action: types.LockoutUnlocked,
+		Actor: actor, CreatedAt: now})
+}
+
+func (g Guard) fail(ctx context.Context, key string, maxFailures int, now time.Time) error {
+	a, err := g.store.RecordFailure(ctx, key, now, now.Add(-g.config.Window))
+	if err != nil {
+		return err
+	}
+
+	if maxFailures <= 0 || a.Failures < maxFailures {
+		return nil
+	}
+
+	until := now.Add(g.config.LockoutDuration)
+
+	err = g.store.LockAttempts(ctx, key, until)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Locked %q after %d failed logins until %v", key, a.Failures, until.Format(time.RFC3339))
+
+	return g.store.AddLockoutEvent(ctx, types.LockoutEvent{Key: key, Action: types.LockoutLocked,
+		Failures: a.Failures, LockedUntil: &until, CreatedAt: now})
+}
+
+// delay returns the wait after the given number of failures of a login
+func (g Guard) delay(failures int) time.Duration {
+	if g.config.BaseDelay <= 0 {
+		return 0
+	}
+
+	d := g.config.BaseDelay
+	for i := 1; i < failures && d < g.config.MaxDelay; i++ {
+		d *= 2
+	}
+
+	if d > g.config.MaxDelay {
+		d = g.config.MaxDelay
+	}
+
+	return d
+}
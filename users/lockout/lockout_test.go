@@ -0,0 +1,117 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	MaxFailures:     3,
+	MaxIPFailures:   5,
+	LockoutDuration: 10 * time.Minute,
+	Window:          time.Hour,
+	BaseDelay:       time.Second,
+	MaxDelay:        4 * time.Second,
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	store := InitMemoryStore()
+	g := InitGuard(store, testConfig)
+
+	failed, err := g.Check(ctx, "hello", "10.0.0.1", now)
+	require.Nil(t, err)
+	require.False(t, failed)
+
+	// Every failure doubles the wait before the next attempt of the login
+	for i, delay := range []time.Duration{time.Second, 2 * time.Second} {
+		require.Nil(t, g.Fail(ctx, "hello", "10.0.0.1", now))
+
+		failed, err = g.Check(ctx, "hello", "10.0.0.1", now)
+		require.True(t, failed)
+
+		var locked LockedError
+		require.True(t, errors.As(err, &locked), "failure %d", i)
+		require.Equal(t, delay, locked.RetryAfter)
+		require.ErrorIs(t, err, customErrors.ErrTooManyRequests)
+
+		now = now.Add(delay)
+		_, err = g.Check(ctx, "hello", "10.0.0.1", now)
+		require.Nil(t, err)
+	}
+
+	// Other logins from the same address are not delayed
+	_, err = g.Check(ctx, "other", "10.0.0.1", now)
+	require.Nil(t, err)
+
+	require.Nil(t, g.Fail(ctx, "hello", "10.0.0.1", now))
+
+	_, err = g.Check(ctx, "hello", "10.0.0.2", now.Add(9*time.Minute))
+	require.Equal(t, LockedError{RetryAfter: time.Minute}, err)
+	require.Equal(t, 60, err.(LockedError).RetryAfterSeconds())
+
+	require.Len(t, store.Events, 1)
+	require.Equal(t, types.LockoutLocked, store.Events[0].Action)
+	require.Equal(t, LoginKey("hello"), store.Events[0].Key)
+	require.Equal(t, 3, store.Events[0].Failures)
+
+	require.Nil(t, g.Unlock(ctx, "hello", "admin", now))
+
+	_, err = g.Check(ctx, "hello", "10.0.0.1", now)
+	require.Nil(t, err)
+	require.Equal(t, types.LockoutUnlocked, store.Events[1].Action)
+	require.Equal(t, "admin", store.Events[1].Actor)
+
+	// The address is locked after failures of different logins
+	_ = g.Fail(ctx, "a", "10.0.0.1", now)
+	_ = g.Fail(ctx, "b", "10.0.0.1", now)
+
+	_, err = g.Check(ctx, "c", "10.0.0.1", now)
+	require.ErrorIs(t, err, customErrors.ErrTooManyRequests)
+
+	_, err = g.Check(ctx, "c", "10.0.0.2", now)
+	require.Nil(t, err)
+}
+
+func TestGuardSucceed(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	store := InitMemoryStore()
+	g := InitGuard(store, testConfig)
+
+	_ = g.Fail(ctx, "hello", "10.0.0.1", now)
+	_ = g.Fail(ctx, "hello", "10.0.0.1", now.Add(time.Minute))
+
+	require.Nil(t, g.Succeed(ctx, "hello"))
+	require.NotContains(t, store.Attempts, LoginKey("hello"))
+	require.Equal(t, 2, store.Attempts[IPKey("10.0.0.1")].Failures)
+
+	// Failures are forgotten after the window
+	_ = g.Fail(ctx, "hello", "10.0.0.1", now)
+	_ = g.Fail(ctx, "hello", "10.0.0.1", now.Add(2*time.Hour))
+	require.Equal(t, 1, store.Attempts[LoginKey("hello")].Failures)
+}
+
+func TestGuardDisabled(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	g := InitGuard(InitMemoryStore(), Config{})
+
+	for i := 0; i < 10; i++ {
+		require.Nil(t, g.Fail(ctx, "hello", "10.0.0.1", now))
+	}
+
+	failed, err := g.Check(ctx, "hello", "10.0.0.1", now)
+	require.Nil(t, err)
+	require.True(t, failed)
+}
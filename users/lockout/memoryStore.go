@@ -0,0 +1,81 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+)
+
+// MemoryStore keeps attempts in memory, it suits tests and single-instance deployments
+type MemoryStore struct {
+	mu       sync.Mutex
+	Attempts map[string]types.LoginAttempts // key -> failed attempts
+	Events   []types.LockoutEvent
+}
+
+func InitMemoryStore() *MemoryStore {
+	return &MemoryStore{Attempts: make(map[string]types.LoginAttempts)}
+}
+
+func (s *MemoryStore) GetAttempts(ctx context.Context, keys []string) ([]types.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var attempts []types.LoginAttempts
+	for _, key := range keys {
+		a, ok := s.Attempts[key]
+		if ok {
+			attempts = append(attempts, a)
+		}
+	}
+
+	return attempts, nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, now, since time.Time) (types.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.Attempts[key]
+	if !ok || a.LastFailure.Before(since) {
+		a.Key = key
+		a.Failures = 0
+	}
+
+	a.Failures++
+	a.LastFailure = now
+	s.Attempts[key] = a
+
+	return a, nil
+}
+
+func (s *MemoryStore) LockAttempts(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.Attempts[key]
+	a.Key = key
+	a.LockedUntil = &until
+	s.Attempts[key] = a
+
+	return nil
+}
+
+func (s *MemoryStore) ClearAttempts(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Attempts, key)
+	return nil
+}
+
+func (s *MemoryStore) AddLockoutEvent(ctx context.Context, e types.LockoutEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = int64(len(s.Events) + 1)
+	s.Events = append(s.Events, e)
+	return nil
+}
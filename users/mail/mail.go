@@ -0,0 +1,127 @@
+// Package mail delivers emails to users, e.g. password reset tokens. Mail goes out through SMTP in production,
+// FileMailer and MemoryMailer keep it locally for development and tests.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// SMTPMailer sends mail through an SMTP server, it authenticates only when a username is given
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func InitSMTPMailer(addr, from, username, password string) SMTPMailer {
+	m := SMTPMailer{Addr: addr, From: from}
+
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		m.Auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m
+}
+
+func (s SMTPMailer) Send(ctx context.Context, m Message) error {
+	msg, err := format(s.From, m, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{m.To}, msg)
+}
+
+// FileMailer writes every message to a file of Dir, so that mail can be read without a server during development
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (f FileMailer) Send(ctx context.Context, m Message) error {
+	now := time.Now()
+
+	msg, err := format(f.From, m, now)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(f.Dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), strings.ReplaceAll(m.To, "/", "_"))
+
+	// Messages carry tokens, so they are readable by the owner only
+	return os.WriteFile(filepath.Join(f.Dir, name), msg, 0o600)
+}
+
+// MemoryMailer keeps sent messages for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	Messages []Message
+}
+
+func (mm *MemoryMailer) Send(ctx context.Context, m Message) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.Messages = append(mm.Messages, m)
+	return nil
+}
+
+// Last returns the latest message sent to the address
+func (mm *MemoryMailer) Last(to string) (Message, bool) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	for i := len(mm.Messages) - 1; i >= 0; i-- {
+		if mm.Messages[i].To == to {
+			return mm.Messages[i], true
+		}
+	}
+
+	return Message{}, false
+}
+
+// format builds a plain text message, line breaks in the headers are rejected as they would inject other headers
+func format(from string, m Message, now time.Time) ([]byte, error) {
+	for _, header := range []string{from, m.To, m.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, fmt.Errorf("mail header %q should not contain line breaks", header)
+		}
+	}
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+
+	return b.Bytes(), nil
+}
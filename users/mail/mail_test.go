@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var message = Message{To: "hello@example.com", Subject: "Reset your password", Body: "Token:\nabc"}
+
+func TestSMTPMailer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+
+	received := make(chan []string, 1)
+	go serveSMTP(l, received)
+
+	err = InitSMTPMailer(l.Addr().String(), "noreply@example.com", "", "").Send(context.Background(), message)
+	require.Nil(t, err)
+
+	lines := <-received
+	require.Contains(t, lines, "MAIL FROM:<noreply@example.com>")
+	require.Contains(t, lines, "RCPT TO:<hello@example.com>")
+	require.Contains(t, lines, "Subject: Reset your password")
+	require.Contains(t, lines, "abc")
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	err := FileMailer{Dir: dir, From: "noreply@example.com"}.Send(context.Background(), message)
+	require.Nil(t, err)
+
+	files, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	require.Len(t, files, 1)
+
+	b, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.Nil(t, err)
+	require.Contains(t, string(b), "To: hello@example.com\r\n")
+	require.True(t, strings.HasSuffix(string(b), "\r\n\r\nToken:\r\nabc"))
+}
+
+func TestMemoryMailer(t *testing.T) {
+	var m MemoryMailer
+
+	require.Nil(t, m.Send(context.Background(), message))
+
+	last, ok := m.Last("hello@example.com")
+	require.True(t, ok)
+	require.Equal(t, message, last)
+
+	_, ok = m.Last("other@example.com")
+	require.False(t, ok)
+}
+
+func TestHeaderInjection(t *testing.T) {
+	injected := message
+	injected.Subject = "Hello\r\nBcc: victim@example.com"
+
+	err := FileMailer{Dir: t.TempDir()}.Send(context.Background(), injected)
+	require.NotNil(t, err)
+}
+
+// serveSMTP accepts a single message and sends the lines of the session
+func serveSMTP(l net.Listener, received chan<- []string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	r := bufio.NewReader(conn)
+	var lines []string
+
+	_ = tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+
+		switch {
+		case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+			_ = tp.PrintfLine("250 localhost")
+		case line == "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+
+			for {
+				line, err = r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				line = strings.TrimRight(line, "\r\n")
+				if line == "." {
+					break
+				}
+				lines = append(lines, line)
+			}
+
+			_ = tp.PrintfLine("250 queued")
+		case line == "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			received <- lines
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
@@ -50,6 +50,95 @@ func (mr *MockBusinessLogicInterfaceMockRecorder) AddUser(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockBusinessLogicInterface)(nil).AddUser), arg0, arg1)
 }
 
+// AuthenticateAPIToken mocks base method.
+func (m *MockBusinessLogicInterface) AuthenticateAPIToken(arg0 context.Context, arg1 string) (types.User, []types.TokenScope, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateAPIToken", arg0, arg1)
+	ret0, _ := ret[0].(types.User)
+	ret1, _ := ret[1].([]types.TokenScope)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AuthenticateAPIToken indicates an expected call of AuthenticateAPIToken.
+func (mr *MockBusinessLogicInterfaceMockRecorder) AuthenticateAPIToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateAPIToken", reflect.TypeOf((*MockBusinessLogicInterface)(nil).AuthenticateAPIToken), arg0, arg1)
+}
+
+// AuthenticateToken mocks base method.
+func (m *MockBusinessLogicInterface) AuthenticateToken(arg0 context.Context, arg1 string) (types.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateToken", arg0, arg1)
+	ret0, _ := ret[0].(types.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateToken indicates an expected call of AuthenticateToken.
+func (mr *MockBusinessLogicInterfaceMockRecorder) AuthenticateToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateToken", reflect.TypeOf((*MockBusinessLogicInterface)(nil).AuthenticateToken), arg0, arg1)
+}
+
+// ChangePassword mocks base method.
+func (m *MockBusinessLogicInterface) ChangePassword(arg0 context.Context, arg1 types.PasswordChange) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockBusinessLogicInterfaceMockRecorder) ChangePassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockBusinessLogicInterface)(nil).ChangePassword), arg0, arg1)
+}
+
+// ConfirmPasswordReset mocks base method.
+func (m *MockBusinessLogicInterface) ConfirmPasswordReset(arg0 context.Context, arg1 types.EmailConfirmation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmPasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmPasswordReset indicates an expected call of ConfirmPasswordReset.
+func (mr *MockBusinessLogicInterfaceMockRecorder) ConfirmPasswordReset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmPasswordReset", reflect.TypeOf((*MockBusinessLogicInterface)(nil).ConfirmPasswordReset), arg0, arg1)
+}
+
+// CreateAPIToken mocks base method.
+func (m *MockBusinessLogicInterface) CreateAPIToken(arg0 context.Context, arg1 types.APIToken) (types.APIToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIToken", arg0, arg1)
+	ret0, _ := ret[0].(types.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIToken indicates an expected call of CreateAPIToken.
+func (mr *MockBusinessLogicInterfaceMockRecorder) CreateAPIToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIToken", reflect.TypeOf((*MockBusinessLogicInterface)(nil).CreateAPIToken), arg0, arg1)
+}
+
+// CreateFeed mocks base method.
+func (m *MockBusinessLogicInterface) CreateFeed(arg0 context.Context) (types.Feed, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateFeed", arg0)
+	ret0, _ := ret[0].(types.Feed)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateFeed indicates an expected call of CreateFeed.
+func (mr *MockBusinessLogicInterfaceMockRecorder) CreateFeed(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFeed", reflect.TypeOf((*MockBusinessLogicInterface)(nil).CreateFeed), arg0)
+}
+
 // DeleteUser mocks base method.
 func (m *MockBusinessLogicInterface) DeleteUser(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
@@ -64,6 +153,110 @@ func (mr *MockBusinessLogicInterfaceMockRecorder) DeleteUser(arg0, arg1 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockBusinessLogicInterface)(nil).DeleteUser), arg0, arg1)
 }
 
+// DisableTOTP mocks base method.
+func (m *MockBusinessLogicInterface) DisableTOTP(arg0 context.Context, arg1 types.TOTPDisable) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTP", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTP indicates an expected call of DisableTOTP.
+func (mr *MockBusinessLogicInterfaceMockRecorder) DisableTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTP", reflect.TypeOf((*MockBusinessLogicInterface)(nil).DisableTOTP), arg0, arg1)
+}
+
+// EnableTOTP mocks base method.
+func (m *MockBusinessLogicInterface) EnableTOTP(arg0 context.Context, arg1 string) (types.RecoveryCodes, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTP", arg0, arg1)
+	ret0, _ := ret[0].(types.RecoveryCodes)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockBusinessLogicInterfaceMockRecorder) EnableTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockBusinessLogicInterface)(nil).EnableTOTP), arg0, arg1)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockBusinessLogicInterface) EnrollTOTP(arg0 context.Context) (types.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", arg0)
+	ret0, _ := ret[0].(types.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockBusinessLogicInterfaceMockRecorder) EnrollTOTP(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockBusinessLogicInterface)(nil).EnrollTOTP), arg0)
+}
+
+// ForcePasswordReset mocks base method.
+func (m *MockBusinessLogicInterface) ForcePasswordReset(arg0 context.Context, arg1 int64) (types.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForcePasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(types.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ForcePasswordReset indicates an expected call of ForcePasswordReset.
+func (mr *MockBusinessLogicInterfaceMockRecorder) ForcePasswordReset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForcePasswordReset", reflect.TypeOf((*MockBusinessLogicInterface)(nil).ForcePasswordReset), arg0, arg1)
+}
+
+// GetAPITokens mocks base method.
+func (m *MockBusinessLogicInterface) GetAPITokens(arg0 context.Context) ([]types.APIToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPITokens", arg0)
+	ret0, _ := ret[0].([]types.APIToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPITokens indicates an expected call of GetAPITokens.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetAPITokens(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPITokens", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetAPITokens), arg0)
+}
+
+// GetCurrentUser mocks base method.
+func (m *MockBusinessLogicInterface) GetCurrentUser(arg0 context.Context) (types.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCurrentUser", arg0)
+	ret0, _ := ret[0].(types.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCurrentUser indicates an expected call of GetCurrentUser.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetCurrentUser(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrentUser", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetCurrentUser), arg0)
+}
+
+// GetUserByFeedToken mocks base method.
+func (m *MockBusinessLogicInterface) GetUserByFeedToken(arg0 context.Context, arg1 string) (types.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByFeedToken", arg0, arg1)
+	ret0, _ := ret[0].(types.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByFeedToken indicates an expected call of GetUserByFeedToken.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetUserByFeedToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByFeedToken", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetUserByFeedToken), arg0, arg1)
+}
+
 // GetUserByLogin mocks base method.
 func (m *MockBusinessLogicInterface) GetUserByLogin(arg0 context.Context, arg1 string) (types.User, error) {
 	m.ctrl.T.Helper()
@@ -79,18 +272,177 @@ func (mr *MockBusinessLogicInterfaceMockRecorder) GetUserByLogin(arg0, arg1 inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLogin", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetUserByLogin), arg0, arg1)
 }
 
+// GetUsers mocks base method.
+func (m *MockBusinessLogicInterface) GetUsers(arg0 context.Context) ([]types.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsers", arg0)
+	ret0, _ := ret[0].([]types.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsers indicates an expected call of GetUsers.
+func (mr *MockBusinessLogicInterfaceMockRecorder) GetUsers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockBusinessLogicInterface)(nil).GetUsers), arg0)
+}
+
+// IssueTokens mocks base method.
+func (m *MockBusinessLogicInterface) IssueTokens(arg0 context.Context, arg1 types.Credentials) (types.Tokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueTokens", arg0, arg1)
+	ret0, _ := ret[0].(types.Tokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueTokens indicates an expected call of IssueTokens.
+func (mr *MockBusinessLogicInterfaceMockRecorder) IssueTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueTokens", reflect.TypeOf((*MockBusinessLogicInterface)(nil).IssueTokens), arg0, arg1)
+}
+
 // LoginUser mocks base method.
-func (m *MockBusinessLogicInterface) LoginUser(arg0 context.Context, arg1, arg2 string) error {
+func (m *MockBusinessLogicInterface) LoginUser(arg0 context.Context, arg1, arg2, arg3 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoginUser", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "LoginUser", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // LoginUser indicates an expected call of LoginUser.
-func (mr *MockBusinessLogicInterfaceMockRecorder) LoginUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockBusinessLogicInterfaceMockRecorder) LoginUser(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginUser", reflect.TypeOf((*MockBusinessLogicInterface)(nil).LoginUser), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginUser", reflect.TypeOf((*MockBusinessLogicInterface)(nil).LoginUser), arg0, arg1, arg2, arg3)
+}
+
+// RefreshTokens mocks base method.
+func (m *MockBusinessLogicInterface) RefreshTokens(arg0 context.Context, arg1 string) (types.Tokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokens", arg0, arg1)
+	ret0, _ := ret[0].(types.Tokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokens indicates an expected call of RefreshTokens.
+func (mr *MockBusinessLogicInterfaceMockRecorder) RefreshTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokens", reflect.TypeOf((*MockBusinessLogicInterface)(nil).RefreshTokens), arg0, arg1)
+}
+
+// RegenerateRecoveryCodes mocks base method.
+func (m *MockBusinessLogicInterface) RegenerateRecoveryCodes(arg0 context.Context, arg1 string) (types.RecoveryCodes, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegenerateRecoveryCodes", arg0, arg1)
+	ret0, _ := ret[0].(types.RecoveryCodes)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegenerateRecoveryCodes indicates an expected call of RegenerateRecoveryCodes.
+func (mr *MockBusinessLogicInterfaceMockRecorder) RegenerateRecoveryCodes(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegenerateRecoveryCodes", reflect.TypeOf((*MockBusinessLogicInterface)(nil).RegenerateRecoveryCodes), arg0, arg1)
+}
+
+// RequestPasswordReset mocks base method.
+func (m *MockBusinessLogicInterface) RequestPasswordReset(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestPasswordReset indicates an expected call of RequestPasswordReset.
+func (mr *MockBusinessLogicInterfaceMockRecorder) RequestPasswordReset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPasswordReset", reflect.TypeOf((*MockBusinessLogicInterface)(nil).RequestPasswordReset), arg0, arg1)
+}
+
+// RevokeAPIToken mocks base method.
+func (m *MockBusinessLogicInterface) RevokeAPIToken(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIToken indicates an expected call of RevokeAPIToken.
+func (mr *MockBusinessLogicInterfaceMockRecorder) RevokeAPIToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIToken", reflect.TypeOf((*MockBusinessLogicInterface)(nil).RevokeAPIToken), arg0, arg1)
+}
+
+// RevokeTokens mocks base method.
+func (m *MockBusinessLogicInterface) RevokeTokens(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeTokens", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeTokens indicates an expected call of RevokeTokens.
+func (mr *MockBusinessLogicInterfaceMockRecorder) RevokeTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeTokens", reflect.TypeOf((*MockBusinessLogicInterface)(nil).RevokeTokens), arg0, arg1)
+}
+
+// SendEmailVerification mocks base method.
+func (m *MockBusinessLogicInterface) SendEmailVerification(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendEmailVerification", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendEmailVerification indicates an expected call of SendEmailVerification.
+func (mr *MockBusinessLogicInterfaceMockRecorder) SendEmailVerification(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendEmailVerification", reflect.TypeOf((*MockBusinessLogicInterface)(nil).SendEmailVerification), arg0)
+}
+
+// SetUserDisabled mocks base method.
+func (m *MockBusinessLogicInterface) SetUserDisabled(arg0 context.Context, arg1 int64, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserDisabled", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserDisabled indicates an expected call of SetUserDisabled.
+func (mr *MockBusinessLogicInterfaceMockRecorder) SetUserDisabled(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserDisabled", reflect.TypeOf((*MockBusinessLogicInterface)(nil).SetUserDisabled), arg0, arg1, arg2)
+}
+
+// UnlockUser mocks base method.
+func (m *MockBusinessLogicInterface) UnlockUser(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockUser indicates an expected call of UnlockUser.
+func (mr *MockBusinessLogicInterfaceMockRecorder) UnlockUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockUser", reflect.TypeOf((*MockBusinessLogicInterface)(nil).UnlockUser), arg0, arg1)
+}
+
+// UpdateCurrentUser mocks base method.
+func (m *MockBusinessLogicInterface) UpdateCurrentUser(arg0 context.Context, arg1 types.ProfileUpdate) (types.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCurrentUser", arg0, arg1)
+	ret0, _ := ret[0].(types.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCurrentUser indicates an expected call of UpdateCurrentUser.
+func (mr *MockBusinessLogicInterfaceMockRecorder) UpdateCurrentUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCurrentUser", reflect.TypeOf((*MockBusinessLogicInterface)(nil).UpdateCurrentUser), arg0, arg1)
 }
 
 // UpdateUser mocks base method.
@@ -107,3 +459,17 @@ func (mr *MockBusinessLogicInterfaceMockRecorder) UpdateUser(arg0, arg1, arg2 in
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockBusinessLogicInterface)(nil).UpdateUser), arg0, arg1, arg2)
 }
+
+// VerifyEmail mocks base method.
+func (m *MockBusinessLogicInterface) VerifyEmail(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockBusinessLogicInterfaceMockRecorder) VerifyEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockBusinessLogicInterface)(nil).VerifyEmail), arg0, arg1)
+}
@@ -0,0 +1,82 @@
+// Package passwords hashes and verifies passwords and checks them against the password policy.
+// Hashes are stored as PHC strings, e.g. "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>", which name
+// the algorithm and its parameters, so the algorithm can be changed while old hashes are still verified
+// and upgraded when their users sign in.
+package passwords
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrMismatch         = errors.New("password does not match the hash")
+	ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+)
+
+// Algorithm hashes passwords with its parameters
+type Algorithm interface {
+	// ID is the PHC identifier of the algorithm, e.g. "argon2id"
+	ID() string
+
+	Hash(password string) (string, error)
+
+	// Verify returns ErrMismatch when the password does not match the hash
+	Verify(hash, password string) error
+
+	// Outdated tells whether the hash was made with other parameters than the current ones
+	Outdated(hash string) bool
+}
+
+// Hasher hashes passwords with the current algorithm and verifies hashes of any of the known algorithms
+type Hasher struct {
+	current    Algorithm
+	algorithms map[string]Algorithm
+}
+
+func InitHasher(current Algorithm, known ...Algorithm) Hasher {
+	h := Hasher{current: current, algorithms: make(map[string]Algorithm)}
+
+	for _, a := range append(known, current) {
+		h.algorithms[a.ID()] = a
+	}
+
+	return h
+}
+
+func (h Hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify checks the password against the hash and tells whether the hash should be replaced by a new one,
+// because it was made by another algorithm or with outdated parameters
+func (h Hasher) Verify(hash, password string) (bool, error) {
+	id := algorithmID(hash)
+
+	a, ok := h.algorithms[id]
+	if !ok {
+		return false, ErrUnknownAlgorithm
+	}
+
+	err := a.Verify(hash, password)
+	if err != nil {
+		return false, err
+	}
+
+	return id != h.current.ID() || a.Outdated(hash), nil
+}
+
+// algorithmID returns the identifier of the PHC string, bcrypt hashes of all versions are identified as bcrypt
+func algorithmID(hash string) string {
+	parts := strings.SplitN(hash, "$", 3)
+	if len(parts) < 3 || parts[0] != "" {
+		return ""
+	}
+
+	switch parts[1] {
+	case "2a", "2b", "2y":
+		return bcryptID
+	}
+
+	return parts[1]
+}
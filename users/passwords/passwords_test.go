@@ -0,0 +1,122 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id keeps the tests fast, production parameters are far higher
+var testArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestHasher(t *testing.T) {
+	testCases := []struct {
+		testName  string
+		current   Algorithm
+		stored    Algorithm
+		expRehash bool
+	}{
+		{
+			testName: "argon2id",
+			current:  testArgon2id,
+			stored:   testArgon2id,
+		},
+		{
+			testName: "bcrypt",
+			current:  Bcrypt{Cost: bcrypt.MinCost},
+			stored:   Bcrypt{Cost: bcrypt.MinCost},
+		},
+		{
+			testName:  "bcrypt_upgrade",
+			current:   testArgon2id,
+			stored:    Bcrypt{Cost: bcrypt.MinCost},
+			expRehash: true,
+		},
+		{
+			testName:  "bcrypt_cost",
+			current:   Bcrypt{Cost: bcrypt.MinCost + 1},
+			stored:    Bcrypt{Cost: bcrypt.MinCost},
+			expRehash: true,
+		},
+		{
+			testName:  "argon2id_parameters",
+			current:   Argon2id{Memory: 128, Iterations: 1, Parallelism: 1},
+			stored:    testArgon2id,
+			expRehash: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			h := InitHasher(tc.current, Bcrypt{Cost: bcrypt.MinCost}, testArgon2id)
+
+			hash, err := tc.stored.Hash("Hello")
+			require.Nil(t, err)
+
+			rehash, err := h.Verify(hash, "Hello")
+			require.Nil(t, err)
+			require.Equal(t, tc.expRehash, rehash)
+
+			_, err = h.Verify(hash, "hello")
+			require.ErrorIs(t, err, ErrMismatch)
+		})
+	}
+}
+
+func TestArgon2idFormat(t *testing.T) {
+	hash, err := testArgon2id.Hash("Hello")
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+
+	other, _ := testArgon2id.Hash("Hello")
+	require.NotEqual(t, hash, other, "hashes should be salted")
+
+	h := InitHasher(testArgon2id)
+
+	malformed := []string{"", "Hello", "$argon2id$v=19$m=64,t=1,p=1$salt", "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5", "$5$rounds=5000$salt$hash"}
+
+	for _, m := range malformed {
+		_, err = h.Verify(m, "Hello")
+		require.NotNil(t, err, "hash: %q", m)
+	}
+
+	// Hashes of algorithms which are not known are not accepted
+	bcryptHash, _ := Bcrypt{Cost: bcrypt.MinCost}.Hash("Hello")
+	_, err = h.Verify(bcryptHash, "Hello")
+	require.ErrorIs(t, err, ErrUnknownAlgorithm)
+}
+
+func TestPolicy(t *testing.T) {
+	breached, err := ReadBreached(strings.NewReader("password\n\n  Qwerty123 \n"))
+	require.Nil(t, err)
+
+	p := Policy{MinLength: 8, DisallowLogin: true, Breached: breached}
+
+	testCases := []struct {
+		login    string
+		password string
+		expError bool
+	}{
+		{login: "hello", password: "correct horse", expError: false},
+		{login: "hello", password: "short", expError: true},
+		{login: "hello", password: strings.Repeat("a", maxLength+1), expError: true},
+		{login: "HelloWorld", password: "helloworld", expError: true},
+		{login: "", password: "helloworld", expError: false},
+		{login: "hello", password: "PASSWORD", expError: true},
+		{login: "hello", password: "qwerty123", expError: true},
+	}
+	for _, tc := range testCases {
+		err := p.Check(tc.login, tc.password)
+		if tc.expError {
+			require.ErrorIs(t, err, customErrors.ErrBadRequest, "password: %q", tc.password)
+		} else {
+			require.Nil(t, err, "password: %q", tc.password)
+		}
+	}
+
+	// The login is allowed when the policy does not forbid it
+	require.Nil(t, Policy{MinLength: 5}.Check("Hello", "Hello"))
+}
@@ -0,0 +1,77 @@
+package passwords
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bubo-py/McK/customErrors"
+)
+
+// maxLength bounds the work of hashing a password, it is far above any password people type
+const maxLength = 1024
+
+// Policy tells which passwords users may choose
+type Policy struct {
+	MinLength int
+
+	// DisallowLogin rejects passwords equal to the login regardless of the case
+	DisallowLogin bool
+
+	// Breached are lower-cased passwords known from leaks, see LoadBreached
+	Breached map[string]struct{}
+}
+
+var DefaultPolicy = Policy{MinLength: 5, DisallowLogin: true}
+
+// Check returns a bad request error describing why the password is rejected, the login can be empty when
+// it is not known yet
+func (p Policy) Check(login, password string) error {
+	length := len([]rune(password))
+
+	if length < p.MinLength {
+		return fmt.Errorf("%w: password should be at least %d characters", customErrors.ErrBadRequest, p.MinLength)
+	}
+
+	if length > maxLength {
+		return fmt.Errorf("%w: password should contain up to %d characters", customErrors.ErrBadRequest, maxLength)
+	}
+
+	if p.DisallowLogin && login != "" && strings.EqualFold(login, password) {
+		return fmt.Errorf("%w: password should not be the login", customErrors.ErrBadRequest)
+	}
+
+	if _, ok := p.Breached[strings.ToLower(password)]; ok {
+		return fmt.Errorf("%w: password is known from data breaches, choose another one", customErrors.ErrBadRequest)
+	}
+
+	return nil
+}
+
+// LoadBreached reads a file of breached passwords, one per line
+func LoadBreached(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadBreached(f)
+}
+
+// ReadBreached reads breached passwords, one per line, empty lines are skipped
+func ReadBreached(r io.Reader) (map[string]struct{}, error) {
+	breached := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			breached[strings.ToLower(line)] = struct{}{}
+		}
+	}
+
+	return breached, scanner.Err()
+}
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN feed_token VARCHAR(64) UNIQUE;
+
+---- create above / drop below ----
+
+ALTER TABLE users DROP COLUMN feed_token;
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN preferences JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE users ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+---- create above / drop below ----
+
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_at;
+ALTER TABLE users DROP COLUMN preferences;
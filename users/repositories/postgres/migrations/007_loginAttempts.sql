@@ -0,0 +1,23 @@
+CREATE TABLE login_attempts (
+    key VARCHAR(300) PRIMARY KEY,
+    failures INT NOT NULL,
+    last_failure TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE TABLE lockout_events (
+    id BIGSERIAL PRIMARY KEY,
+    key VARCHAR(300) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    failures INT NOT NULL,
+    locked_until TIMESTAMPTZ,
+    actor VARCHAR(30) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX lockout_events_key_idx ON lockout_events (key);
+
+---- create above / drop below ----
+
+DROP TABLE lockout_events;
+DROP TABLE login_attempts;
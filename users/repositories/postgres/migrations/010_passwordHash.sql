@@ -0,0 +1,5 @@
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
+
+---- create above / drop below ----
+
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(60);
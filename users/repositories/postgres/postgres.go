@@ -3,13 +3,17 @@ package postgres
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/types"
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jackc/tern/migrate"
 )
@@ -21,6 +25,27 @@ type Db struct {
 	pool *pgxpool.Pool
 }
 
+var userColumns = []string{"id", "login", "password", "timezone", "email", "email_verified", "role", "disabled",
+	"password_reset_required", "totp_secret", "totp_enabled", "totp_last_step", "preferences", "created_at",
+	"updated_at"}
+
+type apiTokenDb struct {
+	ID         int64      `db:"id"`
+	UserID     int64      `db:"user_id"`
+	Login      string     `db:"login"`
+	Name       string     `db:"name"`
+	Prefix     string     `db:"prefix"`
+	Scopes     []string   `db:"scopes"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+}
+
+var loginAttemptsColumns = []string{"key", "failures", "last_failure", "locked_until"}
+
+var apiTokenColumns = []string{"t.id", "t.user_id", "u.login", "t.name", "t.prefix", "t.scopes", "t.created_at",
+	"t.last_used_at", "t.expires_at"}
+
 func Init(ctx context.Context, connString string) (Db, error) {
 	var pg Db
 
@@ -79,11 +104,16 @@ func RunMigration(ctx context.Context, db Db) error {
 func (pg Db) AddUser(ctx context.Context, u types.User) (types.User, error) {
 	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
 
+	role := u.Role
+	if role == "" {
+		role = types.RoleUser
+	}
+
 	ib.InsertInto("users")
-	ib.Cols("login", "password", "timezone")
-	ib.Values(u.Login, u.Password, u.Timezone)
+	ib.Cols("login", "password", "timezone", "email", "role")
+	ib.Values(u.Login, u.Password, u.Timezone, u.Email, role)
 
-	ib.SQL("RETURNING *")
+	ib.SQL("RETURNING " + strings.Join(userColumns, ", "))
 
 	q, args := ib.Build()
 
@@ -95,43 +125,41 @@ func (pg Db) AddUser(ctx context.Context, u types.User) (types.User, error) {
 	return u, nil
 }
 
+// UpdateUser changes the non-empty fields of u and returns the updated user, passwords are changed by ResetPassword
 func (pg Db) UpdateUser(ctx context.Context, u types.User, id int64) (types.User, error) {
 	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
-
-	exists, err := pg.exists(ctx, id)
-	if err != nil {
-		return u, err
-	}
-
-	if !exists {
-		return u, customErrors.ErrNotFound
-	}
+	var updated types.User
 
 	ub.Update("users")
+	ub.Set("updated_at = now()")
 
 	if u.Login != "" {
 		ub.SetMore(ub.Assign("login", u.Login))
 	}
 
-	if u.Password != "" {
-		ub.SetMore(ub.Assign("password", u.Password))
-	}
-
 	if u.Timezone != "" {
 		ub.SetMore(ub.Assign("timezone", u.Timezone))
 	}
 
+	if u.Preferences != nil {
+		ub.SetMore(ub.Assign("preferences", *u.Preferences))
+	}
+
 	ub.Where(ub.Equal("id", id))
-	ub.SQL("RETURNING *")
+	ub.SQL("RETURNING " + strings.Join(userColumns, ", "))
 
 	q, args := ub.Build()
 
-	_, err = pg.pool.Exec(ctx, q, args...)
+	err := pgxscan.Get(ctx, pg.pool, &updated, q, args...)
+	if pgxscan.NotFound(err) {
+		return u, customErrors.ErrNotFound
+	}
+
 	if err != nil {
 		return u, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
 	}
 
-	return u, nil
+	return updated, nil
 }
 
 func (pg Db) DeleteUser(ctx context.Context, id int64) error {
@@ -163,7 +191,7 @@ func (pg Db) GetUserByLogin(ctx context.Context, login string) (types.User, erro
 	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
 	var u types.User
 
-	sb.Select("id", "login", "password", "timezone")
+	sb.Select(userColumns...)
 	sb.From("users")
 	sb.Where(sb.Equal("login", login))
 
@@ -177,6 +205,532 @@ func (pg Db) GetUserByLogin(ctx context.Context, login string) (types.User, erro
 	return u, nil
 }
 
+func (pg Db) GetUserByID(ctx context.Context, id int64) (types.User, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var u types.User
+
+	sb.Select(userColumns...)
+	sb.From("users")
+	sb.Where(sb.Equal("id", id))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Get(ctx, pg.pool, &u, q, args...)
+	if pgxscan.NotFound(err) {
+		return u, customErrors.ErrNotFound
+	}
+
+	if err != nil {
+		return u, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return u, nil
+}
+
+// GetUserByEmail finds a user by the email address regardless of its case
+func (pg Db) GetUserByEmail(ctx context.Context, email string) (types.User, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var u types.User
+
+	sb.Select(userColumns...)
+	sb.From("users")
+	sb.Where(fmt.Sprintf("lower(email) = lower(%s)", sb.Var(email)), sb.NotEqual("email", ""))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Get(ctx, pg.pool, &u, q, args...)
+	if pgxscan.NotFound(err) {
+		return u, customErrors.ErrNotFound
+	}
+
+	if err != nil {
+		return u, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return u, nil
+}
+
+func (pg Db) GetUserByFeedToken(ctx context.Context, tokenHash string) (types.User, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var u types.User
+
+	sb.Select(userColumns...)
+	sb.From("users")
+	sb.Where(sb.Equal("feed_token", tokenHash))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Get(ctx, pg.pool, &u, q, args...)
+	if err != nil {
+		return u, fmt.Errorf("%w: feed with provided token not found", customErrors.ErrUnauthenticated)
+	}
+
+	return u, nil
+}
+
+func (pg Db) SetFeedToken(ctx context.Context, id int64, tokenHash string) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	exists, err := pg.exists(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return customErrors.ErrNotFound
+	}
+
+	ub.Update("users")
+	ub.Set(ub.Assign("feed_token", tokenHash))
+	ub.Where(ub.Equal("id", id))
+
+	q, args := ub.Build()
+
+	_, err = pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+func (pg Db) GetUsers(ctx context.Context) ([]types.User, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var s []types.User
+
+	sb.Select(userColumns...)
+	sb.From("users")
+	sb.OrderBy("id")
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.pool, &s, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return s, nil
+}
+
+func (pg Db) SetUserRole(ctx context.Context, login string, role types.Role) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("users")
+	ub.Set(ub.Assign("role", role))
+	ub.Where(ub.Equal("login", login))
+
+	return pg.updateUser(ctx, ub)
+}
+
+func (pg Db) SetUserDisabled(ctx context.Context, id int64, disabled bool) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("users")
+	ub.Set(ub.Assign("disabled", disabled))
+	ub.Where(ub.Equal("id", id))
+
+	return pg.updateUser(ctx, ub)
+}
+
+func (pg Db) SetEmailVerified(ctx context.Context, id int64, verified bool) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("users")
+	ub.Set(ub.Assign("email_verified", verified), "updated_at = now()")
+	ub.Where(ub.Equal("id", id))
+
+	return pg.updateUser(ctx, ub)
+}
+
+func (pg Db) ResetPassword(ctx context.Context, id int64, passwordHash string, resetRequired bool) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("users")
+	ub.Set(ub.Assign("password", passwordHash), ub.Assign("password_reset_required", resetRequired), "updated_at = now()")
+	ub.Where(ub.Equal("id", id))
+
+	return pg.updateUser(ctx, ub)
+}
+
+// RehashPassword replaces the hash of an unchanged password, it returns ErrNotFound when the password was changed
+// meanwhile, so that a concurrent change is not overwritten
+func (pg Db) RehashPassword(ctx context.Context, id int64, oldHash, newHash string) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("users")
+	ub.Set(ub.Assign("password", newHash))
+	ub.Where(ub.Equal("id", id), ub.Equal("password", oldHash))
+
+	return pg.updateUser(ctx, ub)
+}
+
+// SetTOTP stores the encrypted secret of two-factor authentication, an empty secret turns it off
+func (pg Db) SetTOTP(ctx context.Context, id int64, secret string, enabled bool) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("users")
+	ub.Set(ub.Assign("totp_secret", secret), ub.Assign("totp_enabled", enabled), "updated_at = now()")
+	ub.Where(ub.Equal("id", id))
+
+	return pg.updateUser(ctx, ub)
+}
+
+// UseTOTPStep records the time step of an accepted code, it fails unless the step is newer than the last one,
+// so that a code cannot be used twice by concurrent requests
+func (pg Db) UseTOTPStep(ctx context.Context, id int64, step int64) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("users")
+	ub.Set(ub.Assign("totp_last_step", step))
+	ub.Where(ub.Equal("id", id), ub.LessThan("totp_last_step", step))
+
+	err := pg.updateUser(ctx, ub)
+	if errors.Is(err, customErrors.ErrNotFound) {
+		return fmt.Errorf("%w: one-time password already used", customErrors.ErrUnauthenticated)
+	}
+
+	return err
+}
+
+// SetRecoveryCodes replaces the recovery codes of the user with the given hashes
+func (pg Db) SetRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+
+	db.DeleteFrom("recovery_codes")
+	db.Where(db.Equal("user_id", userID))
+
+	dq, dArgs := db.Build()
+
+	err := pg.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, dq, dArgs...)
+		if err != nil {
+			return err
+		}
+
+		if len(codeHashes) == 0 {
+			return nil
+		}
+
+		ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+		ib.InsertInto("recovery_codes")
+		ib.Cols("user_id", "code_hash")
+		for _, hash := range codeHashes {
+			ib.Values(userID, hash)
+		}
+
+		iq, iArgs := ib.Build()
+
+		_, err = tx.Exec(ctx, iq, iArgs...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used, each code signs in once
+func (pg Db) UseRecoveryCode(ctx context.Context, userID int64, codeHash string, now time.Time) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("recovery_codes")
+	ub.Set(ub.Assign("used_at", now))
+	ub.Where(ub.Equal("user_id", userID), ub.Equal("code_hash", codeHash), ub.IsNull("used_at"))
+
+	q, args := ub.Build()
+
+	tag, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: unknown or used recovery code", customErrors.ErrUnauthenticated)
+	}
+
+	return nil
+}
+
+// updateUser runs an update of a single user, which is not found when no rows are updated
+func (pg Db) updateUser(ctx context.Context, ub *sqlbuilder.UpdateBuilder) error {
+	q, args := ub.Build()
+
+	tag, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (pg Db) AddRefreshToken(ctx context.Context, t types.RefreshToken) error {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	ib.InsertInto("refresh_tokens")
+	ib.Cols("user_id", "token_hash", "family", "expires_at")
+	ib.Values(t.UserID, t.TokenHash, t.Family, t.ExpiresAt)
+
+	q, args := ib.Build()
+
+	_, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+func (pg Db) GetRefreshToken(ctx context.Context, tokenHash string) (types.RefreshToken, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var t types.RefreshToken
+
+	sb.Select("t.id", "t.user_id", "u.login", "t.token_hash", "t.family", "t.expires_at", "t.revoked_at")
+	sb.From("refresh_tokens t")
+	sb.Join("users u", "u.id = t.user_id")
+	sb.Where(sb.Equal("t.token_hash", tokenHash))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Get(ctx, pg.pool, &t, q, args...)
+	if err != nil {
+		return t, fmt.Errorf("%w: refresh token not found", customErrors.ErrUnauthenticated)
+	}
+
+	return t, nil
+}
+
+// RevokeRefreshToken revokes an active token, it fails when the token was revoked in the meantime,
+// so that a refresh token cannot be exchanged twice by concurrent requests
+func (pg Db) RevokeRefreshToken(ctx context.Context, tokenHash string, now time.Time) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("refresh_tokens")
+	ub.Set(ub.Assign("revoked_at", now))
+	ub.Where(ub.Equal("token_hash", tokenHash), ub.IsNull("revoked_at"))
+
+	q, args := ub.Build()
+
+	tag, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: refresh token already revoked", customErrors.ErrUnauthenticated)
+	}
+
+	return nil
+}
+
+func (pg Db) RevokeTokenFamily(ctx context.Context, family string, now time.Time) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("refresh_tokens")
+	ub.Set(ub.Assign("revoked_at", now))
+	ub.Where(ub.Equal("family", family), ub.IsNull("revoked_at"))
+
+	q, args := ub.Build()
+
+	_, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+func (pg Db) RevokeUserTokens(ctx context.Context, userID int64, now time.Time) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("refresh_tokens")
+	ub.Set(ub.Assign("revoked_at", now))
+	ub.Where(ub.Equal("user_id", userID), ub.IsNull("revoked_at"))
+
+	q, args := ub.Build()
+
+	_, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+func (pg Db) AddEmailToken(ctx context.Context, t types.EmailToken) error {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	ib.InsertInto("email_tokens")
+	ib.Cols("user_id", "email", "token_hash", "purpose", "expires_at")
+	ib.Values(t.UserID, t.Email, t.TokenHash, t.Purpose, t.ExpiresAt)
+
+	q, args := ib.Build()
+
+	_, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+// UseEmailToken marks an unused and unexpired token as used and returns it, it is done in a single statement,
+// so that a token cannot be used twice by concurrent requests
+func (pg Db) UseEmailToken(ctx context.Context, tokenHash string, purpose types.EmailTokenPurpose,
+	now time.Time) (types.EmailToken, error) {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+	var t types.EmailToken
+
+	ub.Update("email_tokens t")
+	ub.Set(ub.Assign("used_at", now))
+	ub.SQL("FROM users u")
+	ub.Where("u.id = t.user_id", ub.Equal("t.token_hash", tokenHash), ub.Equal("t.purpose", purpose),
+		ub.IsNull("t.used_at"), ub.GreaterThan("t.expires_at", now))
+	ub.SQL("RETURNING t.id, t.user_id, u.login, t.email, t.token_hash, t.purpose, t.expires_at, t.used_at")
+
+	q, args := ub.Build()
+
+	err := pgxscan.Get(ctx, pg.pool, &t, q, args...)
+	if pgxscan.NotFound(err) {
+		return t, fmt.Errorf("%w: invalid or expired token", customErrors.ErrBadRequest)
+	}
+
+	if err != nil {
+		return t, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return t, nil
+}
+
+func (pg Db) AddAPIToken(ctx context.Context, t types.APIToken, tokenHash string) (types.APIToken, error) {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	scopes := make([]string, 0, len(t.Scopes))
+	for _, scope := range t.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	ib.InsertInto("api_tokens")
+	ib.Cols("user_id", "name", "prefix", "token_hash", "scopes", "created_at", "expires_at")
+	ib.Values(t.UserID, t.Name, t.Prefix, tokenHash, scopes, t.CreatedAt.UTC(), t.ExpiresAt)
+
+	ib.SQL("RETURNING id")
+
+	q, args := ib.Build()
+
+	err := pg.pool.QueryRow(ctx, q, args...).Scan(&t.ID)
+	if err != nil {
+		return t, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return t, nil
+}
+
+func (pg Db) GetAPITokens(ctx context.Context, userID int64) ([]types.APIToken, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var s []types.APIToken
+	var apiTokens []*apiTokenDb
+
+	sb.Select(apiTokenColumns...)
+	sb.From("api_tokens t")
+	sb.Join("users u", "u.id = t.user_id")
+	sb.Where(sb.Equal("t.user_id", userID))
+	sb.OrderBy("t.id")
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.pool, &apiTokens, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, t := range apiTokens {
+		s = append(s, toAPIToken(*t))
+	}
+
+	return s, nil
+}
+
+func (pg Db) GetAPIToken(ctx context.Context, tokenHash string) (types.APIToken, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var t apiTokenDb
+
+	sb.Select(apiTokenColumns...)
+	sb.From("api_tokens t")
+	sb.Join("users u", "u.id = t.user_id")
+	sb.Where(sb.Equal("t.token_hash", tokenHash))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Get(ctx, pg.pool, &t, q, args...)
+	if err != nil {
+		return types.APIToken{}, fmt.Errorf("%w: API token not found", customErrors.ErrUnauthenticated)
+	}
+
+	return toAPIToken(t), nil
+}
+
+func (pg Db) DeleteAPIToken(ctx context.Context, id, userID int64) error {
+	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+
+	db.DeleteFrom("api_tokens")
+	db.Where(db.Equal("id", id), db.Equal("user_id", userID))
+
+	q, args := db.Build()
+
+	tag, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (pg Db) TouchAPIToken(ctx context.Context, id int64, now time.Time) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("api_tokens")
+	ub.Set(ub.Assign("last_used_at", now.UTC()))
+	ub.Where(ub.Equal("id", id))
+
+	q, args := ub.Build()
+
+	_, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+func toAPIToken(t apiTokenDb) types.APIToken {
+	apiToken := types.APIToken{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+		ExpiresAt:  t.ExpiresAt,
+		UserID:     t.UserID,
+		Login:      t.Login,
+	}
+
+	for _, scope := range t.Scopes {
+		apiToken.Scopes = append(apiToken.Scopes, types.TokenScope(scope))
+	}
+
+	return apiToken
+}
+
 func (pg Db) exists(ctx context.Context, id int64) (bool, error) {
 	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
 	var exists bool
@@ -203,3 +757,98 @@ func (pg Db) exists(ctx context.Context, id int64) (bool, error) {
 
 	return exists, nil
 }
+
+func (pg Db) GetAttempts(ctx context.Context, keys []string) ([]types.LoginAttempts, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var s []types.LoginAttempts
+
+	values := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, key)
+	}
+
+	sb.Select(loginAttemptsColumns...)
+	sb.From("login_attempts")
+	sb.Where(sb.In("key", values...))
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.pool, &s, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return s, nil
+}
+
+// RecordFailure counts the failure in a single statement, so that concurrent failures are not lost
+func (pg Db) RecordFailure(ctx context.Context, key string, now, since time.Time) (types.LoginAttempts, error) {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+	var a types.LoginAttempts
+
+	ib.InsertInto("login_attempts")
+	ib.Cols("key", "failures", "last_failure")
+	ib.Values(key, 1, now)
+	ib.SQL("ON CONFLICT (key) DO UPDATE SET failures = CASE WHEN login_attempts.last_failure < " + ib.Var(since) +
+		" THEN 1 ELSE login_attempts.failures + 1 END, last_failure = EXCLUDED.last_failure")
+	ib.SQL("RETURNING " + strings.Join(loginAttemptsColumns, ", "))
+
+	q, args := ib.Build()
+
+	err := pgxscan.Get(ctx, pg.pool, &a, q, args...)
+	if err != nil {
+		return a, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return a, nil
+}
+
+func (pg Db) LockAttempts(ctx context.Context, key string, until time.Time) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("login_attempts")
+	ub.Set(ub.Assign("locked_until", until))
+	ub.Where(ub.Equal("key", key))
+
+	q, args := ub.Build()
+
+	_, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+func (pg Db) ClearAttempts(ctx context.Context, key string) error {
+	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+
+	db.DeleteFrom("login_attempts")
+	db.Where(db.Equal("key", key))
+
+	q, args := db.Build()
+
+	_, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
+
+func (pg Db) AddLockoutEvent(ctx context.Context, e types.LockoutEvent) error {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+
+	ib.InsertInto("lockout_events")
+	ib.Cols("key", "action", "failures", "locked_until", "actor", "created_at")
+	ib.Values(e.Key, e.Action, e.Failures, e.LockedUntil, e.Actor, e.CreatedAt)
+
+	q, args := ib.Build()
+
+	_, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return nil
+}
@@ -6,8 +6,11 @@ import (
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/types"
 )
 
@@ -25,7 +28,13 @@ func TestMain(m *testing.M) {
 		log.Fatalf("Could not initialize database: %v", err)
 	}
 
-	_, _ = db.pool.Exec(ctx, "DROP TABLE users")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE refresh_tokens")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE api_tokens")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE login_attempts")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE lockout_events")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE email_tokens")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE recovery_codes")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE users CASCADE")
 	_, _ = db.pool.Exec(ctx, "DROP TABLE users_migration")
 	_ = RunMigration(ctx, db)
 
@@ -140,10 +149,22 @@ func TestUpdateUser(t *testing.T) {
 		t.Error(err)
 	}
 
-	if !reflect.DeepEqual(fullUserUpdate, u) {
+	// The updated row is returned, the ID of the update is ignored
+	if u.ID != 1 || u.Login != fullUserUpdate.Login || u.Timezone != fullUserUpdate.Timezone ||
+		u.Role != types.RoleUser || u.UpdatedAt.Before(u.CreatedAt) {
 		t.Errorf("Failed to update user: got: %v, expected: %v", u, fullUserUpdate)
 	}
 
+	u, err = db.UpdateUser(ctx, types.User{Preferences: &types.Preferences{WeekStart: "monday"}}, 1)
+	if err != nil || u.Login != fullUserUpdate.Login || u.Preferences == nil || u.Preferences.WeekStart != "monday" {
+		t.Errorf("Failed to update preferences: got: %v, error: %v", u, err)
+	}
+
+	_, err = db.UpdateUser(ctx, fullUserUpdate, 100)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return a not found error for a missing user: got: %v", err)
+	}
+
 	_, err = db.UpdateUser(ctx, partialUserUpdate, 2)
 	if err != nil {
 		t.Error(err)
@@ -154,6 +175,10 @@ func TestUpdateUser(t *testing.T) {
 	if u.Login != user2.Login {
 		t.Errorf("Failed to partialy update user: got: %v, expected: %v", u.Login, user2.Login)
 	}
+
+	if u.Password != user2.Password {
+		t.Errorf("Passwords should not be changed by updates: got: %v, expected: %v", u.Password, user2.Password)
+	}
 }
 
 func TestDeleteUser(t *testing.T) {
@@ -220,6 +245,7 @@ func TestGetUserByLogin(t *testing.T) {
 		Login:    "Check The Login",
 		Password: "Hello",
 		Timezone: "Europe/London",
+		Role:     types.RoleUser,
 	}
 
 	_, _ = db.AddUser(ctx, user)
@@ -230,6 +256,13 @@ func TestGetUserByLogin(t *testing.T) {
 		t.Error(err)
 	}
 
+	if u.CreatedAt.IsZero() || u.UpdatedAt.IsZero() {
+		t.Errorf("Failed to retrive timestamps of user: got: %v", u)
+	}
+
+	user2.Preferences = &types.Preferences{}
+	user2.CreatedAt, user2.UpdatedAt = u.CreatedAt, u.UpdatedAt
+
 	if !reflect.DeepEqual(user2, u) {
 		t.Errorf("Failed to retrive user by login: got: %v, expected: %v", u, user2)
 	}
@@ -245,10 +278,453 @@ func TestGetUserByLogin(t *testing.T) {
 }
 
 func deleteAllUsers(ctx context.Context, pg Db) {
-	query := "TRUNCATE users RESTART IDENTITY"
+	query := "TRUNCATE users RESTART IDENTITY CASCADE"
 
 	_, err := pg.pool.Exec(ctx, query)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+func TestFeedToken(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleteAllUsers(ctx, db)
+
+	u, err := db.AddUser(ctx, types.User{Login: "Subscriber", Password: "Hello", Timezone: "Europe/Warsaw"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.GetUserByFeedToken(ctx, "token-hash")
+	if err == nil {
+		t.Errorf("Should return an error for an unknown token")
+	}
+
+	err = db.SetFeedToken(ctx, u.ID, "token-hash")
+	if err != nil {
+		t.Error(err)
+	}
+
+	feedUser, err := db.GetUserByFeedToken(ctx, "token-hash")
+	if err != nil || feedUser.Login != u.Login {
+		t.Errorf("Failed to retrieve user by feed token: got: %v, error: %v", feedUser, err)
+	}
+
+	err = db.SetFeedToken(ctx, u.ID+100, "other-hash")
+	if err == nil {
+		t.Errorf("Should return an error for a missing user")
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleteAllUsers(ctx, db)
+
+	u, err := db.AddUser(ctx, types.User{Login: "Session", Password: "Hello", Timezone: "Europe/Warsaw"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, hash := range []string{"first-hash", "second-hash"} {
+		err = db.AddRefreshToken(ctx, types.RefreshToken{UserID: u.ID, TokenHash: hash, Family: "family", ExpiresAt: now})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	rt, err := db.GetRefreshToken(ctx, "first-hash")
+	if err != nil || rt.Login != u.Login || rt.Family != "family" || !rt.ExpiresAt.Equal(now) || rt.RevokedAt != nil {
+		t.Errorf("Failed to retrieve refresh token: got: %v, error: %v", rt, err)
+	}
+
+	_, err = db.GetRefreshToken(ctx, "unknown-hash")
+	if err == nil {
+		t.Errorf("Should return an error for an unknown token")
+	}
+
+	err = db.RevokeRefreshToken(ctx, "first-hash", now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.RevokeRefreshToken(ctx, "first-hash", now)
+	if err == nil {
+		t.Errorf("Should return an error for a revoked token")
+	}
+
+	err = db.RevokeTokenFamily(ctx, "family", now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	rt, _ = db.GetRefreshToken(ctx, "second-hash")
+	if rt.RevokedAt == nil {
+		t.Errorf("Tokens of the family should be revoked")
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleteAllUsers(ctx, db)
+
+	u, err := db.AddUser(ctx, types.User{Login: "Integration", Password: "Hello", Timezone: "Europe/Warsaw"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	added, err := db.AddAPIToken(ctx, types.APIToken{Name: "CI", Prefix: "mck_abcdefgh", UserID: u.ID, CreatedAt: now,
+		Scopes: []types.TokenScope{types.ScopeEventsRead, types.ScopeWebhooksWrite}}, "api-token-hash")
+	if err != nil || added.ID == 0 {
+		t.Errorf("Failed to add API token: got: %v, error: %v", added, err)
+	}
+
+	apiToken, err := db.GetAPIToken(ctx, "api-token-hash")
+	if err != nil || apiToken.Login != u.Login || !reflect.DeepEqual(added.Scopes, apiToken.Scopes) ||
+		apiToken.LastUsedAt != nil || apiToken.ExpiresAt != nil {
+		t.Errorf("Failed to retrieve API token: got: %v, error: %v", apiToken, err)
+	}
+
+	err = db.TouchAPIToken(ctx, added.ID, now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	apiTokens, err := db.GetAPITokens(ctx, u.ID)
+	if err != nil || len(apiTokens) != 1 || apiTokens[0].LastUsedAt == nil || !apiTokens[0].LastUsedAt.Equal(now) {
+		t.Errorf("Failed to list API tokens: got: %v, error: %v", apiTokens, err)
+	}
+
+	err = db.DeleteAPIToken(ctx, added.ID, u.ID+1)
+	if err == nil {
+		t.Errorf("Should not revoke another user's token")
+	}
+
+	err = db.DeleteAPIToken(ctx, added.ID, u.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.GetAPIToken(ctx, "api-token-hash")
+	if err == nil {
+		t.Errorf("Should return an error for a revoked token")
+	}
+}
+
+func TestAdministration(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleteAllUsers(ctx, db)
+
+	admin, _ := db.AddUser(ctx, types.User{Login: "Admin", Password: "Hello", Timezone: "Europe/Warsaw"})
+	u, _ := db.AddUser(ctx, types.User{Login: "Managed", Password: "Hello", Timezone: "Europe/Warsaw"})
+
+	err = db.SetUserRole(ctx, admin.Login, types.RoleAdmin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.SetUserRole(ctx, "Missing", types.RoleAdmin)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return a not found error for a missing user: got: %v", err)
+	}
+
+	err = db.SetUserDisabled(ctx, u.ID, true)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.ResetPassword(ctx, u.ID, "Temporary", true)
+	if err != nil {
+		t.Error(err)
+	}
+
+	users, err := db.GetUsers(ctx)
+	if err != nil || len(users) != 2 {
+		t.Fatalf("Failed to list users: got: %v, error: %v", users, err)
+	}
+
+	if users[0].Role != types.RoleAdmin || users[1].Role != types.RoleUser || !users[1].Disabled ||
+		!users[1].PasswordResetRequired || users[1].Password != "Temporary" {
+		t.Errorf("Failed to update users: got: %v", users)
+	}
+
+	_ = db.AddRefreshToken(ctx, types.RefreshToken{UserID: u.ID, TokenHash: "managed-hash", Family: "managed",
+		ExpiresAt: now.Add(time.Hour)})
+
+	err = db.RevokeUserTokens(ctx, u.ID, now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	rt, err := db.GetRefreshToken(ctx, "managed-hash")
+	if err != nil || rt.RevokedAt == nil {
+		t.Errorf("Failed to revoke tokens of the user: got: %v, error: %v", rt, err)
+	}
+}
+
+func TestLoginAttempts(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, _ = db.pool.Exec(ctx, "TRUNCATE login_attempts, lockout_events")
+
+	for i := 0; i < 3; i++ {
+		a, err := db.RecordFailure(ctx, "login:Hello", now, now.Add(-time.Hour))
+		if err != nil || a.Failures != i+1 {
+			t.Errorf("Failed to record failure: got: %v, error: %v", a, err)
+		}
+	}
+
+	// Failures before the window are forgotten
+	a, err := db.RecordFailure(ctx, "login:Hello", now.Add(2*time.Hour), now.Add(time.Hour))
+	if err != nil || a.Failures != 1 || !a.LastFailure.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("Failed to restart counting failures: got: %v, error: %v", a, err)
+	}
+
+	_, _ = db.RecordFailure(ctx, "ip:10.0.0.1", now, now.Add(-time.Hour))
+
+	err = db.LockAttempts(ctx, "ip:10.0.0.1", now.Add(time.Hour))
+	if err != nil {
+		t.Error(err)
+	}
+
+	attempts, err := db.GetAttempts(ctx, []string{"login:Hello", "ip:10.0.0.1", "login:Missing"})
+	if err != nil || len(attempts) != 2 {
+		t.Fatalf("Failed to get attempts: got: %v, error: %v", attempts, err)
+	}
+
+	for _, a := range attempts {
+		if (a.Key == "ip:10.0.0.1") != (a.LockedUntil != nil) {
+			t.Errorf("Failed to lock attempts: got: %v", a)
+		}
+	}
+
+	err = db.ClearAttempts(ctx, "login:Hello")
+	if err != nil {
+		t.Error(err)
+	}
+
+	attempts, _ = db.GetAttempts(ctx, []string{"login:Hello"})
+	if len(attempts) != 0 {
+		t.Errorf("Failed to clear attempts: got: %v", attempts)
+	}
+
+	until := now.Add(time.Hour)
+	err = db.AddLockoutEvent(ctx, types.LockoutEvent{Key: "ip:10.0.0.1", Action: types.LockoutLocked, Failures: 1,
+		LockedUntil: &until, CreatedAt: now})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmailTokens(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleteAllUsers(ctx, db)
+
+	u, err := db.AddUser(ctx, types.User{Login: "Hello", Password: "Hello", Timezone: "Europe/Warsaw",
+		Email: "Hello@Example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.GetUserByEmail(ctx, "hello@example.com")
+	if err != nil || got.ID != u.ID || got.EmailVerified {
+		t.Errorf("Failed to get user by email: got: %v, error: %v", got, err)
+	}
+
+	_, err = db.GetUserByEmail(ctx, "missing@example.com")
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return a not found error for a missing email: got: %v", err)
+	}
+
+	_, err = db.AddUser(ctx, types.User{Login: "Other", Password: "Hello", Timezone: "Europe/Warsaw",
+		Email: "hello@example.com"})
+	if err == nil {
+		t.Error("Should not add a user with an email of another user")
+	}
+
+	err = db.AddEmailToken(ctx, types.EmailToken{UserID: u.ID, Email: u.Email, TokenHash: "valid",
+		Purpose: types.EmailVerification, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.AddEmailToken(ctx, types.EmailToken{UserID: u.ID, Email: u.Email, TokenHash: "expired",
+		Purpose: types.EmailPasswordReset, ExpiresAt: now.Add(-time.Hour)})
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.UseEmailToken(ctx, "valid", types.EmailPasswordReset, now)
+	if !errors.Is(err, customErrors.ErrBadRequest) {
+		t.Errorf("Should not use a token of another purpose: got: %v", err)
+	}
+
+	token, err := db.UseEmailToken(ctx, "valid", types.EmailVerification, now)
+	if err != nil || token.UserID != u.ID || token.Login != u.Login || token.UsedAt == nil {
+		t.Errorf("Failed to use token: got: %v, error: %v", token, err)
+	}
+
+	_, err = db.UseEmailToken(ctx, "valid", types.EmailVerification, now)
+	if !errors.Is(err, customErrors.ErrBadRequest) {
+		t.Errorf("Should not use a token twice: got: %v", err)
+	}
+
+	_, err = db.UseEmailToken(ctx, "expired", types.EmailPasswordReset, now)
+	if !errors.Is(err, customErrors.ErrBadRequest) {
+		t.Errorf("Should not use an expired token: got: %v", err)
+	}
+
+	err = db.SetEmailVerified(ctx, u.ID, true)
+	if err != nil {
+		t.Error(err)
+	}
+
+	got, _ = db.GetUserByEmail(ctx, "hello@example.com")
+	if !got.EmailVerified {
+		t.Errorf("Failed to verify email: got: %v", got)
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleteAllUsers(ctx, db)
+
+	u, _ := db.AddUser(ctx, types.User{Login: "Hello", Password: "Hello", Timezone: "Europe/Warsaw"})
+
+	err = db.SetTOTP(ctx, u.ID, "encrypted", true)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.UseTOTPStep(ctx, u.ID, 100)
+	if err != nil {
+		t.Error(err)
+	}
+
+	got, _ := db.GetUserByID(ctx, u.ID)
+	if got.TOTPSecret != "encrypted" || !got.TOTPEnabled || got.TOTPLastStep != 100 {
+		t.Errorf("Failed to set TOTP: got: %v", got)
+	}
+
+	// Codes of the same or older time steps are replays
+	for _, step := range []int64{100, 99} {
+		err = db.UseTOTPStep(ctx, u.ID, step)
+		if !errors.Is(err, customErrors.ErrUnauthenticated) {
+			t.Errorf("Should not use step %d twice: got: %v", step, err)
+		}
+	}
+
+	err = db.SetRecoveryCodes(ctx, u.ID, []string{"first", "second"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.UseRecoveryCode(ctx, u.ID, "first", now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.UseRecoveryCode(ctx, u.ID, "first", now)
+	if !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("Should not use a recovery code twice: got: %v", err)
+	}
+
+	// New codes replace the previous ones
+	err = db.SetRecoveryCodes(ctx, u.ID, []string{"third"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.UseRecoveryCode(ctx, u.ID, "second", now)
+	if !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("Should not use a replaced recovery code: got: %v", err)
+	}
+
+	err = db.SetTOTP(ctx, u.ID, "", false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.SetTOTP(ctx, 0, "", false)
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should return a not found error for a missing user: got: %v", err)
+	}
+}
+
+func TestRehashPassword(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleteAllUsers(ctx, db)
+
+	u, _ := db.AddUser(ctx, types.User{Login: "Hello", Password: "old", Timezone: "Europe/Warsaw"})
+
+	// argon2id hashes are longer than bcrypt ones
+	newHash := "$argon2id$v=19$m=19456,t=2,p=1$" + strings.Repeat("s", 22) + "$" + strings.Repeat("k", 43)
+
+	err = db.RehashPassword(ctx, u.ID, "old", newHash)
+	if err != nil {
+		t.Error(err)
+	}
+
+	got, _ := db.GetUserByID(ctx, u.ID)
+	if got.Password != newHash {
+		t.Errorf("Failed to rehash password: got: %v", got.Password)
+	}
+
+	// A password changed meanwhile is not overwritten
+	err = db.RehashPassword(ctx, u.ID, "old", "other")
+	if !errors.Is(err, customErrors.ErrNotFound) {
+		t.Errorf("Should not rehash a changed password: got: %v", err)
+	}
+}
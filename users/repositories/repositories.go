@@ -2,13 +2,42 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/bubo-py/McK/types"
 )
 
+//go:generate mockgen --build_flags=--mod=mod -destination=mocks/mockDatabase.go -package=mocks github.com/bubo-py/McK/users/repositories UserRepository
+
 type UserRepository interface {
 	AddUser(ctx context.Context, u types.User) (types.User, error)
 	UpdateUser(ctx context.Context, u types.User, id int64) (types.User, error)
 	DeleteUser(ctx context.Context, id int64) error
 	GetUserByLogin(ctx context.Context, login string) (types.User, error)
+	GetUserByID(ctx context.Context, id int64) (types.User, error)
+	GetUserByEmail(ctx context.Context, email string) (types.User, error)
+	GetUserByFeedToken(ctx context.Context, tokenHash string) (types.User, error)
+	SetFeedToken(ctx context.Context, id int64, tokenHash string) error
+	AddRefreshToken(ctx context.Context, t types.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (types.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash string, now time.Time) error
+	RevokeTokenFamily(ctx context.Context, family string, now time.Time) error
+	AddAPIToken(ctx context.Context, t types.APIToken, tokenHash string) (types.APIToken, error)
+	GetAPITokens(ctx context.Context, userID int64) ([]types.APIToken, error)
+	GetAPIToken(ctx context.Context, tokenHash string) (types.APIToken, error)
+	DeleteAPIToken(ctx context.Context, id, userID int64) error
+	TouchAPIToken(ctx context.Context, id int64, now time.Time) error
+	GetUsers(ctx context.Context) ([]types.User, error)
+	SetUserRole(ctx context.Context, login string, role types.Role) error
+	SetUserDisabled(ctx context.Context, id int64, disabled bool) error
+	ResetPassword(ctx context.Context, id int64, passwordHash string, resetRequired bool) error
+	RehashPassword(ctx context.Context, id int64, oldHash, newHash string) error
+	RevokeUserTokens(ctx context.Context, userID int64, now time.Time) error
+	SetEmailVerified(ctx context.Context, id int64, verified bool) error
+	AddEmailToken(ctx context.Context, t types.EmailToken) error
+	UseEmailToken(ctx context.Context, tokenHash string, purpose types.EmailTokenPurpose, now time.Time) (types.EmailToken, error)
+	SetTOTP(ctx context.Context, id int64, secret string, enabled bool) error
+	UseTOTPStep(ctx context.Context, id int64, step int64) error
+	SetRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string, now time.Time) error
 }
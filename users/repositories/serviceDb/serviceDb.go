@@ -2,6 +2,7 @@ package serviceDb
 
 import (
 	"context"
+	"time"
 
 	"github.com/bubo-py/McK/types"
 )
@@ -24,3 +25,116 @@ func (db Db) GetUserByLogin(ctx context.Context, login string) (types.User, erro
 	var u types.User
 	return u, nil
 }
+
+func (db Db) GetUserByID(ctx context.Context, id int64) (types.User, error) {
+	var u types.User
+	return u, nil
+}
+
+func (db Db) GetUserByEmail(ctx context.Context, email string) (types.User, error) {
+	var u types.User
+	return u, nil
+}
+
+func (db Db) GetUserByFeedToken(ctx context.Context, tokenHash string) (types.User, error) {
+	var u types.User
+	return u, nil
+}
+
+func (db Db) SetFeedToken(ctx context.Context, id int64, tokenHash string) error {
+	return nil
+}
+
+func (db Db) AddRefreshToken(ctx context.Context, t types.RefreshToken) error {
+	return nil
+}
+
+func (db Db) GetRefreshToken(ctx context.Context, tokenHash string) (types.RefreshToken, error) {
+	var t types.RefreshToken
+	return t, nil
+}
+
+func (db Db) RevokeRefreshToken(ctx context.Context, tokenHash string, now time.Time) error {
+	return nil
+}
+
+func (db Db) RevokeTokenFamily(ctx context.Context, family string, now time.Time) error {
+	return nil
+}
+
+func (db Db) AddAPIToken(ctx context.Context, t types.APIToken, tokenHash string) (types.APIToken, error) {
+	return t, nil
+}
+
+func (db Db) GetAPITokens(ctx context.Context, userID int64) ([]types.APIToken, error) {
+	var s []types.APIToken
+	return s, nil
+}
+
+func (db Db) GetAPIToken(ctx context.Context, tokenHash string) (types.APIToken, error) {
+	var t types.APIToken
+	return t, nil
+}
+
+func (db Db) DeleteAPIToken(ctx context.Context, id, userID int64) error {
+	return nil
+}
+
+func (db Db) TouchAPIToken(ctx context.Context, id int64, now time.Time) error {
+	return nil
+}
+
+func (db Db) GetUsers(ctx context.Context) ([]types.User, error) {
+	var s []types.User
+	return s, nil
+}
+
+func (db Db) SetUserRole(ctx context.Context, login string, role types.Role) error {
+	return nil
+}
+
+func (db Db) SetUserDisabled(ctx context.Context, id int64, disabled bool) error {
+	return nil
+}
+
+func (db Db) ResetPassword(ctx context.Context, id int64, passwordHash string, resetRequired bool) error {
+	return nil
+}
+
+func (db Db) RehashPassword(ctx context.Context, id int64, oldHash, newHash string) error {
+	return nil
+}
+
+func (db Db) RevokeUserTokens(ctx context.Context, userID int64, now time.Time) error {
+	return nil
+}
+
+func (db Db) SetEmailVerified(ctx context.Context, id int64, verified bool) error {
+	return nil
+}
+
+func (db Db) AddEmailToken(ctx context.Context, t types.EmailToken) error {
+	return nil
+}
+
+func (db Db) UseEmailToken(ctx context.Context, tokenHash string, purpose types.EmailTokenPurpose,
+	now time.Time) (types.EmailToken, error) {
+	var t types.EmailToken
+	return t, nil
+}
+
+func (db Db) SetTOTP(ctx context.Context, id int64, secret string, enabled bool) error {
+	return nil
+}
+
+func (db Db) UseTOTPStep(ctx context.Context, id int64, step int64) error {
+	return nil
+}
+
+func (db Db) SetRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return nil
+}
+
+func (db Db) UseRecoveryCode(ctx context.Context, userID int64, codeHash string, now time.Time) error {
+	return nil
+}
@@ -2,13 +2,27 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	netMail "net/mail"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bubo-py/McK/contextHelpers"
 	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/users/lockout"
+	"github.com/bubo-py/McK/users/mail"
+	"github.com/bubo-py/McK/users/passwords"
 	"github.com/bubo-py/McK/users/repositories"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/bubo-py/McK/users/tokens"
+	"github.com/bubo-py/McK/users/totp"
 )
 
 //go:generate mockgen -destination=../mockService.go -package=users github.com/bubo-py/McK/users/service BusinessLogicInterface
@@ -17,17 +31,78 @@ type BusinessLogicInterface interface {
 	AddUser(ctx context.Context, u types.User) (types.User, error)
 	UpdateUser(ctx context.Context, u types.User, id int64) (types.User, error)
 	DeleteUser(ctx context.Context, id int64) error
-	LoginUser(ctx context.Context, login, password string) error
+	LoginUser(ctx context.Context, login, password, otp string) error
 	GetUserByLogin(ctx context.Context, login string) (types.User, error)
+	CreateFeed(ctx context.Context) (types.Feed, error)
+	GetUserByFeedToken(ctx context.Context, token string) (types.User, error)
+	IssueTokens(ctx context.Context, c types.Credentials) (types.Tokens, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (types.Tokens, error)
+	RevokeTokens(ctx context.Context, refreshToken string) error
+	AuthenticateToken(ctx context.Context, accessToken string) (types.User, error)
+	CreateAPIToken(ctx context.Context, t types.APIToken) (types.APIToken, error)
+	GetAPITokens(ctx context.Context) ([]types.APIToken, error)
+	RevokeAPIToken(ctx context.Context, id int64) error
+	AuthenticateAPIToken(ctx context.Context, token string) (types.User, []types.TokenScope, error)
+	GetUsers(ctx context.Context) ([]types.User, error)
+	GetCurrentUser(ctx context.Context) (types.User, error)
+	UpdateCurrentUser(ctx context.Context, p types.ProfileUpdate) (types.User, error)
+	ChangePassword(ctx context.Context, c types.PasswordChange) error
+	SetUserDisabled(ctx context.Context, id int64, disabled bool) error
+	ForcePasswordReset(ctx context.Context, id int64) (types.PasswordReset, error)
+	UnlockUser(ctx context.Context, id int64) error
+	SendEmailVerification(ctx context.Context) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ConfirmPasswordReset(ctx context.Context, c types.EmailConfirmation) error
+	EnrollTOTP(ctx context.Context) (types.TOTPEnrollment, error)
+	EnableTOTP(ctx context.Context, code string) (types.RecoveryCodes, error)
+	DisableTOTP(ctx context.Context, d types.TOTPDisable) error
+	RegenerateRecoveryCodes(ctx context.Context, code string) (types.RecoveryCodes, error)
+}
+
+// Access tokens cannot be revoked, so they are short-lived, sessions are kept alive by refresh tokens
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Tokens mailed to users are single-use, password reset tokens expire soon as they grant access to the account
+const (
+	verificationTokenTTL  = 48 * time.Hour
+	passwordResetTokenTTL = time.Hour
+)
+
+// lastUsedInterval is the precision of the last use of API tokens, so that they are not written on every request
+const lastUsedInterval = time.Minute
+
+// Calendars creates the default calendar of new users, events are added to it unless they name another one
+type Calendars interface {
+	AddDefaultCalendar(ctx context.Context, login string) error
 }
 
 type BusinessLogic struct {
-	db repositories.UserRepository
+	db        repositories.UserRepository
+	signer    tokens.Signer
+	guard     lockout.Guard
+	mailer    mail.Mailer
+	cipher    totp.Cipher
+	hasher    passwords.Hasher
+	policy    passwords.Policy
+	calendars Calendars
 }
 
-func InitBusinessLogic(db repositories.UserRepository) BusinessLogic {
+func InitBusinessLogic(db repositories.UserRepository, signer tokens.Signer, guard lockout.Guard,
+	mailer mail.Mailer, cipher totp.Cipher, hasher passwords.Hasher, policy passwords.Policy,
+	calendars Calendars) BusinessLogic {
 	var bl BusinessLogic
 	bl.db = db
+	bl.guard = guard
+	bl.mailer = mailer
+	bl.cipher = cipher
+	bl.hasher = hasher
+	bl.policy = policy
+	bl.signer = signer
+	bl.calendars = calendars
 	return bl
 }
 
@@ -37,15 +112,65 @@ func (bl BusinessLogic) AddUser(ctx context.Context, u types.User) (types.User,
 		return u, err
 	}
 
-	u.Password, err = hashPassword(u.Password)
+	err = validateTimezone(u.Timezone)
 	if err != nil {
 		return u, err
 	}
 
-	return bl.db.AddUser(ctx, u)
+	u.Email = strings.TrimSpace(u.Email)
+	if u.Email != "" {
+		err = validateEmail(u.Email)
+		if err != nil {
+			return u, err
+		}
+
+		_, err = bl.db.GetUserByEmail(ctx, u.Email)
+		if err == nil {
+			return u, fmt.Errorf("%w: email is already used by another account", customErrors.ErrBadRequest)
+		}
+	}
+
+	u.Password, err = bl.hashPassword(u.Login, u.Password)
+	if err != nil {
+		return u, err
+	}
+
+	// Roles and the state of the account are managed by administrators only
+	u.Role = types.RoleUser
+	u.Disabled = false
+	u.PasswordResetRequired = false
+	u.EmailVerified = false
+
+	u, err = bl.db.AddUser(ctx, u)
+	if err != nil {
+		return u, err
+	}
+
+	// Events repositories create the default calendar when it is missing, so a failure here is not fatal
+	err = bl.calendars.AddDefaultCalendar(ctx, u.Login)
+	if err != nil {
+		log.Printf("Failed to create the default calendar of %q: %v", u.Login, err)
+	}
+
+	// The account is usable before the email is verified, the verification can be sent again if it is lost
+	if u.Email != "" {
+		err = bl.sendEmailVerification(ctx, u)
+		if err != nil {
+			log.Printf("Failed to send email verification to %q: %v", u.Login, err)
+		}
+	}
+
+	return u, nil
 }
 
+// UpdateUser changes the login and the timezone of the current user. Passwords are changed by ChangePassword only,
+// which takes the current password and signs out other sessions.
 func (bl BusinessLogic) UpdateUser(ctx context.Context, u types.User, id int64) (types.User, error) {
+	if u.Password != "" {
+		return u, fmt.Errorf("%w: password cannot be updated, it is changed with the current password",
+			customErrors.ErrBadRequest)
+	}
+
 	if u.Login != "" {
 		err := validateLogin(u.Login)
 		if err != nil {
@@ -53,12 +178,9 @@ func (bl BusinessLogic) UpdateUser(ctx context.Context, u types.User, id int64)
 		}
 	}
 
-	if u.Password != "" {
-		hashedPwd, err := hashPassword(u.Password)
-		if err != nil {
-			return u, err
-		}
-		u.Password = hashedPwd
+	err := validateTimezone(u.Timezone)
+	if err != nil {
+		return u, err
 	}
 
 	currentUserLogin, ok := contextHelpers.RetrieveLoginFromContext(ctx)
@@ -84,49 +206,746 @@ func (bl BusinessLogic) DeleteUser(ctx context.Context, id int64) error {
 		return fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
 	}
 
+	// Administrators can delete any account, events of the user are deleted with it
 	currentUser, _ := bl.db.GetUserByLogin(ctx, currentUserLogin)
-	if currentUser.ID != id {
+	if currentUser.ID != id && requireAdmin(ctx) != nil {
 		return fmt.Errorf("%w: cannot modify another user's account", customErrors.ErrUnauthorized)
 	}
 
 	return bl.db.DeleteUser(ctx, id)
 }
 
+// GetUsers lists all the users for administrators, without their password hashes
+func (bl BusinessLogic) GetUsers(ctx context.Context) ([]types.User, error) {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := bl.db.GetUsers(ctx)
+	if err != nil {
+		return users, err
+	}
+
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	return users, nil
+}
+
+// SetUserDisabled disables or enables an account, sessions of a disabled user are revoked
+func (bl BusinessLogic) SetUserDisabled(ctx context.Context, id int64, disabled bool) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	currentUser, err := bl.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if currentUser.ID == id && disabled {
+		return fmt.Errorf("%w: cannot disable your own account", customErrors.ErrBadRequest)
+	}
+
+	err = bl.db.SetUserDisabled(ctx, id, disabled)
+	if err != nil {
+		return err
+	}
+
+	if disabled {
+		return bl.db.RevokeUserTokens(ctx, id, time.Now())
+	}
+
+	return nil
+}
+
+// ForcePasswordReset replaces the password of a user with a temporary one and revokes their sessions.
+// The user has to replace the temporary password when signing in with it.
+func (bl BusinessLogic) ForcePasswordReset(ctx context.Context, id int64) (types.PasswordReset, error) {
+	var reset types.PasswordReset
+
+	err := requireAdmin(ctx)
+	if err != nil {
+		return reset, err
+	}
+
+	reset.TemporaryPassword, err = randomToken()
+	if err != nil {
+		return reset, err
+	}
+
+	hash, err := bl.hashPassword("", reset.TemporaryPassword)
+	if err != nil {
+		return types.PasswordReset{}, err
+	}
+
+	err = bl.db.ResetPassword(ctx, id, hash, true)
+	if err != nil {
+		return types.PasswordReset{}, err
+	}
+
+	err = bl.db.RevokeUserTokens(ctx, id, time.Now())
+	if err != nil {
+		return types.PasswordReset{}, err
+	}
+
+	return reset, nil
+}
+
+// UnlockUser clears failed logins of a user locked out by them
+func (bl BusinessLogic) UnlockUser(ctx context.Context, id int64) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	u, err := bl.db.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	admin, _ := contextHelpers.RetrieveLoginFromContext(ctx)
+
+	return bl.guard.Unlock(ctx, u.Login, admin, time.Now())
+}
+
+// SendEmailVerification mails a new verification token to the current user
+func (bl BusinessLogic) SendEmailVerification(ctx context.Context) error {
+	u, err := bl.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if u.Email == "" {
+		return fmt.Errorf("%w: account has no email", customErrors.ErrBadRequest)
+	}
+
+	if u.EmailVerified {
+		return fmt.Errorf("%w: email is already verified", customErrors.ErrBadRequest)
+	}
+
+	return bl.sendEmailVerification(ctx, u)
+}
+
+func (bl BusinessLogic) VerifyEmail(ctx context.Context, token string) error {
+	t, err := bl.db.UseEmailToken(ctx, hashToken(token), types.EmailVerification, time.Now())
+	if err != nil {
+		return err
+	}
+
+	u, err := bl.db.GetUserByLogin(ctx, t.Login)
+	if err != nil {
+		return err
+	}
+
+	// The email could have changed since the token was sent
+	if !strings.EqualFold(u.Email, t.Email) {
+		return fmt.Errorf("%w: invalid or expired token", customErrors.ErrBadRequest)
+	}
+
+	return bl.db.SetEmailVerified(ctx, u.ID, true)
+}
+
+// RequestPasswordReset mails a password reset token to the user with the verified email. Unknown emails
+// are not reported, so that the request does not tell which emails have accounts.
+func (bl BusinessLogic) RequestPasswordReset(ctx context.Context, email string) error {
+	err := validateEmail(email)
+	if err != nil {
+		return err
+	}
+
+	u, err := bl.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, customErrors.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !u.EmailVerified || u.Disabled {
+		return nil
+	}
+
+	token, err := bl.addEmailToken(ctx, u, types.EmailPasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	// Failures are only logged for the same reason as unknown emails are not reported
+	err = bl.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your McK password",
+		Body: fmt.Sprintf("A password reset was requested for your account %q.\n\n"+
+			"Use the following token to choose a new password, it expires in an hour:\n\n%s\n\n"+
+			"If you did not request the reset, ignore this email.\n", u.Login, token),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset to %q: %v", u.Login, err)
+	}
+
+	return nil
+}
+
+// ConfirmPasswordReset replaces the password of the user the token was sent to and signs out their sessions
+func (bl BusinessLogic) ConfirmPasswordReset(ctx context.Context, c types.EmailConfirmation) error {
+	hash, err := bl.hashPassword("", c.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	t, err := bl.db.UseEmailToken(ctx, hashToken(c.Token), types.EmailPasswordReset, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// The login is known only once the token is used, so it is checked separately
+	err = bl.policy.Check(t.Login, c.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = bl.db.ResetPassword(ctx, t.UserID, hash, false)
+	if err != nil {
+		return err
+	}
+
+	err = bl.db.RevokeUserTokens(ctx, t.UserID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Whoever received the token owns the account, so failed logins of the attacker do not keep it locked
+	err = bl.guard.Succeed(ctx, t.Login)
+	if err != nil {
+		log.Printf("Failed to clear failed logins: %v", err)
+	}
+
+	return nil
+}
+
+func (bl BusinessLogic) sendEmailVerification(ctx context.Context, u types.User) error {
+	token, err := bl.addEmailToken(ctx, u, types.EmailVerification, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return bl.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Verify your McK email",
+		Body: fmt.Sprintf("Use the following token to verify the email of your account %q:\n\n%s\n", u.Login,
+			token),
+	})
+}
+
+// addEmailToken stores the hash of a new token and returns the token to be mailed
+func (bl BusinessLogic) addEmailToken(ctx context.Context, u types.User, purpose types.EmailTokenPurpose,
+	ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = bl.db.AddEmailToken(ctx, types.EmailToken{UserID: u.ID, Email: u.Email, TokenHash: hashToken(token),
+		Purpose: purpose, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (bl BusinessLogic) GetCurrentUser(ctx context.Context) (types.User, error) {
+	return bl.currentUser(ctx)
+}
+
+func (bl BusinessLogic) UpdateCurrentUser(ctx context.Context, p types.ProfileUpdate) (types.User, error) {
+	if p.Login != "" {
+		err := validateLogin(p.Login)
+		if err != nil {
+			return types.User{}, err
+		}
+	}
+
+	err := validateTimezone(p.Timezone)
+	if err != nil {
+		return types.User{}, err
+	}
+
+	if p.Preferences != nil {
+		err := validatePreferences(*p.Preferences)
+		if err != nil {
+			return types.User{}, err
+		}
+	}
+
+	currentUser, err := bl.currentUser(ctx)
+	if err != nil {
+		return types.User{}, err
+	}
+
+	return bl.db.UpdateUser(ctx, types.User{Login: p.Login, Timezone: p.Timezone, Preferences: p.Preferences},
+		currentUser.ID)
+}
+
+// ChangePassword replaces the password of the current user and signs out their other sessions
+func (bl BusinessLogic) ChangePassword(ctx context.Context, c types.PasswordChange) error {
+	currentUser, err := bl.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = bl.checkPassword(ctx, currentUser.Login, c.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	hash, err := bl.hashPassword(currentUser.Login, c.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = bl.db.ResetPassword(ctx, currentUser.ID, hash, false)
+	if err != nil {
+		return err
+	}
+
+	return bl.db.RevokeUserTokens(ctx, currentUser.ID, time.Now())
+}
+
 func (bl BusinessLogic) GetUserByLogin(ctx context.Context, login string) (types.User, error) {
 	return bl.db.GetUserByLogin(ctx, login)
 }
 
-func (bl BusinessLogic) LoginUser(ctx context.Context, login, password string) error {
-	return bl.checkPassword(ctx, login, password)
+// LoginUser checks the password and, for users with two-factor authentication, the one-time password.
+// Basic auth sends both with every request, so a code of the authenticator app stays valid for its whole time step.
+func (bl BusinessLogic) LoginUser(ctx context.Context, login, password, otp string) error {
+	u, err := bl.checkPassword(ctx, login, password)
+	if err != nil {
+		return err
+	}
+
+	err = bl.checkOTP(ctx, u, otp, true)
+	if err != nil {
+		return err
+	}
+
+	return checkActive(u)
 }
 
-func hashPassword(s string) (string, error) {
-	if len(s) < 5 {
-		return s, fmt.Errorf("%w: password should be at least 5 characters", customErrors.ErrBadRequest)
+// IssueTokens starts a new session of the user authenticated by the password and, for users with two-factor
+// authentication, the one-time password. When an administrator forced a reset of the password, the new password
+// has to be given as well and it replaces the temporary one.
+func (bl BusinessLogic) IssueTokens(ctx context.Context, c types.Credentials) (types.Tokens, error) {
+	u, err := bl.checkPassword(ctx, c.Login, c.Password)
+	if err != nil {
+		return types.Tokens{}, err
 	}
 
-	bytes, err := bcrypt.GenerateFromPassword([]byte(s), bcrypt.DefaultCost)
+	err = bl.checkOTP(ctx, u, c.OTP, false)
 	if err != nil {
-		return s, fmt.Errorf("%w: bcrypt error: %v", customErrors.ErrUnexpected, err)
+		return types.Tokens{}, err
 	}
 
-	s = string(bytes)
-	if len(s) > 60 {
-		return s, fmt.Errorf("%w: failed to hash password", customErrors.ErrUnexpected)
+	if u.PasswordResetRequired && c.NewPassword != "" && !u.Disabled {
+		hash, err := bl.hashPassword(u.Login, c.NewPassword)
+		if err != nil {
+			return types.Tokens{}, err
+		}
+
+		err = bl.db.ResetPassword(ctx, u.ID, hash, false)
+		if err != nil {
+			return types.Tokens{}, err
+		}
+
+		u.PasswordResetRequired = false
 	}
 
-	return s, nil
+	err = checkActive(u)
+	if err != nil {
+		return types.Tokens{}, err
+	}
+
+	family, err := randomToken()
+	if err != nil {
+		return types.Tokens{}, err
+	}
+
+	return bl.issueTokens(ctx, u, family)
 }
 
-func (bl BusinessLogic) checkPassword(ctx context.Context, login, password string) error {
-	u, err := bl.db.GetUserByLogin(ctx, login)
+// RefreshTokens exchanges a refresh token for new tokens, each refresh token can be exchanged only once.
+// A reused token was either stolen or its replacement was, so the whole session is revoked.
+func (bl BusinessLogic) RefreshTokens(ctx context.Context, refreshToken string) (types.Tokens, error) {
+	now := time.Now()
+
+	t, err := bl.db.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return types.Tokens{}, err
+	}
+
+	if t.RevokedAt != nil {
+		err = bl.db.RevokeTokenFamily(ctx, t.Family, now)
+		if err != nil {
+			return types.Tokens{}, err
+		}
+
+		return types.Tokens{}, fmt.Errorf("%w: refresh token reused", customErrors.ErrUnauthenticated)
+	}
+
+	if !now.Before(t.ExpiresAt) {
+		return types.Tokens{}, fmt.Errorf("%w: refresh token expired", customErrors.ErrUnauthenticated)
+	}
+
+	err = bl.db.RevokeRefreshToken(ctx, t.TokenHash, now)
+	if err != nil {
+		return types.Tokens{}, err
+	}
+
+	u, err := bl.db.GetUserByLogin(ctx, t.Login)
+	if err != nil {
+		return types.Tokens{}, err
+	}
+
+	err = checkActive(u)
+	if err != nil {
+		return types.Tokens{}, err
+	}
+
+	return bl.issueTokens(ctx, u, t.Family)
+}
+
+// RevokeTokens ends the session of the refresh token, access tokens issued before stay valid until they expire
+func (bl BusinessLogic) RevokeTokens(ctx context.Context, refreshToken string) error {
+	t, err := bl.db.GetRefreshToken(ctx, hashToken(refreshToken))
 	if err != nil {
 		return err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	return bl.db.RevokeTokenFamily(ctx, t.Family, time.Now())
+}
+
+// AuthenticateToken resolves the user of an access token by the ID in its subject,
+// logins can be changed and taken over by other users, IDs cannot
+func (bl BusinessLogic) AuthenticateToken(ctx context.Context, accessToken string) (types.User, error) {
+	c, err := bl.signer.Verify(accessToken, time.Now())
+	if err != nil {
+		return types.User{}, fmt.Errorf("%w: %v", customErrors.ErrUnauthenticated, err)
+	}
+
+	id, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil {
+		return types.User{}, fmt.Errorf("%w: invalid token subject", customErrors.ErrUnauthenticated)
+	}
+
+	u, err := bl.db.GetUserByID(ctx, id)
+	if err != nil {
+		return u, err
+	}
+
+	return u, checkActive(u)
+}
+
+// CreateAPIToken generates an API token of the current user limited to the scopes of t.
+// Only a hash of the token is stored, so it cannot be shown again.
+func (bl BusinessLogic) CreateAPIToken(ctx context.Context, t types.APIToken) (types.APIToken, error) {
+	now := time.Now()
+
+	err := validateAPIToken(t, now)
+	if err != nil {
+		return t, err
+	}
+
+	currentUser, err := bl.currentUser(ctx)
+	if err != nil {
+		return t, err
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return t, err
+	}
+
+	t.Token = types.APITokenPrefix + secret
+	t.Prefix = t.Token[:len(types.APITokenPrefix)+8]
+	t.UserID = currentUser.ID
+	t.CreatedAt = now.UTC()
+	t.LastUsedAt = nil
+
+	if t.ExpiresAt != nil {
+		expiresAt := t.ExpiresAt.UTC()
+		t.ExpiresAt = &expiresAt
+	}
+
+	added, err := bl.db.AddAPIToken(ctx, t, hashToken(t.Token))
+	if err != nil {
+		return types.APIToken{}, err
+	}
+
+	return added, nil
+}
+
+func (bl BusinessLogic) GetAPITokens(ctx context.Context) ([]types.APIToken, error) {
+	currentUser, err := bl.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return bl.db.GetAPITokens(ctx, currentUser.ID)
+}
+
+func (bl BusinessLogic) RevokeAPIToken(ctx context.Context, id int64) error {
+	currentUser, err := bl.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	return bl.db.DeleteAPIToken(ctx, id, currentUser.ID)
+}
+
+// AuthenticateAPIToken returns the user of an API token and the scopes the token is limited to
+func (bl BusinessLogic) AuthenticateAPIToken(ctx context.Context, token string) (types.User, []types.TokenScope, error) {
+	now := time.Now()
+
+	t, err := bl.db.GetAPIToken(ctx, hashToken(token))
+	if err != nil {
+		return types.User{}, nil, err
+	}
+
+	if t.ExpiresAt != nil && !now.Before(*t.ExpiresAt) {
+		return types.User{}, nil, fmt.Errorf("%w: API token expired", customErrors.ErrUnauthenticated)
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= lastUsedInterval {
+		err = bl.db.TouchAPIToken(ctx, t.ID, now)
+		if err != nil {
+			return types.User{}, nil, err
+		}
+	}
+
+	u, err := bl.db.GetUserByLogin(ctx, t.Login)
+	if err != nil {
+		return types.User{}, nil, err
+	}
+
+	err = checkActive(u)
+	if err != nil {
+		return types.User{}, nil, err
+	}
+
+	return u, t.Scopes, nil
+}
+
+// currentUser returns the user the request was authenticated as
+func (bl BusinessLogic) currentUser(ctx context.Context) (types.User, error) {
+	currentUserLogin, ok := contextHelpers.RetrieveLoginFromContext(ctx)
+	if !ok {
+		return types.User{}, fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+	}
+
+	return bl.db.GetUserByLogin(ctx, currentUserLogin)
+}
+
+func (bl BusinessLogic) issueTokens(ctx context.Context, u types.User, family string) (types.Tokens, error) {
+	var t types.Tokens
+	now := time.Now()
+
+	access, err := bl.signer.Sign(tokens.Claims{
+		Subject:   strconv.FormatInt(u.ID, 10),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(accessTokenTTL).Unix(),
+	})
+	if err != nil {
+		return t, fmt.Errorf("%w: failed to sign access token: %v", customErrors.ErrUnexpected, err)
+	}
+
+	refresh, err := randomToken()
+	if err != nil {
+		return t, err
+	}
+
+	err = bl.db.AddRefreshToken(ctx, types.RefreshToken{
+		UserID:    u.ID,
+		TokenHash: hashToken(refresh),
+		Family:    family,
+		ExpiresAt: now.Add(refreshTokenTTL).UTC(),
+	})
+	if err != nil {
+		return t, err
+	}
+
+	t.AccessToken = access
+	t.RefreshToken = refresh
+	t.TokenType = "Bearer"
+	t.ExpiresIn = int(accessTokenTTL.Seconds())
+
+	return t, nil
+}
+
+// CreateFeed generates a new calendar subscription token of the current user, replacing the previous one.
+// Only a hash of the token is stored, so it cannot be shown again.
+func (bl BusinessLogic) CreateFeed(ctx context.Context) (types.Feed, error) {
+	var feed types.Feed
+
+	currentUserLogin, ok := contextHelpers.RetrieveLoginFromContext(ctx)
+	if !ok {
+		return feed, fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+	}
+
+	currentUser, err := bl.db.GetUserByLogin(ctx, currentUserLogin)
+	if err != nil {
+		return feed, err
+	}
+
+	feed.Token, err = randomToken()
+	if err != nil {
+		return feed, err
+	}
+
+	feed.URL = fmt.Sprintf("/api/feeds/%s.ics", feed.Token)
+
+	err = bl.db.SetFeedToken(ctx, currentUser.ID, hashToken(feed.Token))
+	if err != nil {
+		return types.Feed{}, err
+	}
+
+	return feed, nil
+}
+
+func (bl BusinessLogic) GetUserByFeedToken(ctx context.Context, token string) (types.User, error) {
+	if token == "" {
+		return types.User{}, fmt.Errorf("%w: missing feed token", customErrors.ErrUnauthenticated)
+	}
+
+	u, err := bl.db.GetUserByFeedToken(ctx, hashToken(token))
+	if err != nil {
+		return u, err
+	}
+
+	if u.Disabled {
+		return types.User{}, fmt.Errorf("%w: account disabled", customErrors.ErrUnauthenticated)
+	}
+
+	return u, nil
+}
+
+// randomToken generates feed, refresh and email tokens
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to generate token: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the form feed, refresh and email tokens are stored in, they are random so a plain hash suffices
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashPassword checks the password against the policy and hashes it, the login can be empty when it is not known
+func (bl BusinessLogic) hashPassword(login, password string) (string, error) {
+	err := bl.policy.Check(login, password)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := bl.hasher.Hash(password)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to hash password: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return hash, nil
+}
+
+// checkPassword verifies the password unless the login or the address of the client are locked out
+// by failed attempts, unknown logins count as failures as well. Failures of users with two-factor authentication
+// are cleared by checkOTP, otherwise the password alone would let one guess codes indefinitely.
+func (bl BusinessLogic) checkPassword(ctx context.Context, login, password string) (types.User, error) {
+	ip, _ := contextHelpers.RetrieveClientIPFromContext(ctx)
+	now := time.Now()
+
+	failed, err := bl.guard.Check(ctx, login, ip, now)
+	if err != nil {
+		return types.User{}, err
+	}
+
+	var rehash bool
+
+	u, err := bl.db.GetUserByLogin(ctx, login)
+	if err == nil {
+		rehash, err = bl.hasher.Verify(u.Password, password)
+		if errors.Is(err, passwords.ErrMismatch) {
+			err = fmt.Errorf("%w: incorrect password", customErrors.ErrUnauthenticated)
+		} else if err != nil {
+			err = fmt.Errorf("%w: failed to verify password: %v", customErrors.ErrUnexpected, err)
+		}
+	}
+
+	if errors.Is(err, customErrors.ErrUnauthenticated) {
+		lockErr := bl.guard.Fail(ctx, login, ip, now)
+		if lockErr != nil {
+			log.Printf("Failed to count a failed login: %v", lockErr)
+		}
+	}
+
 	if err != nil {
-		return fmt.Errorf("%w: incorrect password: %v", customErrors.ErrUnauthenticated, err)
+		return types.User{}, err
+	}
+
+	if failed && !u.TOTPEnabled {
+		err = bl.guard.Succeed(ctx, login)
+		if err != nil {
+			log.Printf("Failed to clear failed logins: %v", err)
+		}
+	}
+
+	if rehash {
+		bl.rehashPassword(ctx, u, password)
+	}
+
+	return u, nil
+}
+
+// rehashPassword replaces a hash made by an outdated algorithm or with outdated parameters, it is done
+// while the password is known after a successful check. Failures are only logged, the old hash still works.
+func (bl BusinessLogic) rehashPassword(ctx context.Context, u types.User, password string) {
+	hash, err := bl.hasher.Hash(password)
+	if err != nil {
+		log.Printf("Failed to rehash password of %q: %v", u.Login, err)
+		return
+	}
+
+	err = bl.db.RehashPassword(ctx, u.ID, u.Password, hash)
+	if err != nil && !errors.Is(err, customErrors.ErrNotFound) {
+		log.Printf("Failed to rehash password of %q: %v", u.Login, err)
+	}
+}
+
+// checkActive rejects users who cannot sign in, disabled ones and ones who have to reset their password first
+func checkActive(u types.User) error {
+	if u.Disabled {
+		return fmt.Errorf("%w: account disabled", customErrors.ErrUnauthenticated)
+	}
+
+	if u.PasswordResetRequired {
+		return fmt.Errorf("%w: password reset required", customErrors.ErrUnauthenticated)
+	}
+
+	return nil
+}
+
+// requireAdmin rejects users without the administrator role
+func requireAdmin(ctx context.Context) error {
+	role, _ := contextHelpers.RetrieveRoleFromContext(ctx)
+	if role != types.RoleAdmin {
+		return fmt.Errorf("%w: administrator role required", customErrors.ErrUnauthorized)
 	}
 
 	return nil
@@ -139,3 +958,66 @@ func validateLogin(s string) error {
 
 	return nil
 }
+
+func validateEmail(s string) error {
+	a, err := netMail.ParseAddress(s)
+	if err != nil || a.Address != s || len(s) > 254 {
+		return fmt.Errorf("%w: invalid email", customErrors.ErrBadRequest)
+	}
+
+	return nil
+}
+
+// validateTimezone accepts IANA zones, e.g. Europe/London, the empty timezone is UTC.
+// "Local" is rejected, as it depends on the server.
+func validateTimezone(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	_, err := time.LoadLocation(s)
+	if err != nil || s == "Local" {
+		return fmt.Errorf("%w: unknown timezone %q", customErrors.ErrBadRequest, s)
+	}
+
+	return nil
+}
+
+func validatePreferences(p types.Preferences) error {
+	if p.WeekStart != "" && p.WeekStart != "monday" && p.WeekStart != "sunday" {
+		return fmt.Errorf("%w: week should start on monday or sunday", customErrors.ErrBadRequest)
+	}
+
+	if p.TimeFormat != "" && p.TimeFormat != "12h" && p.TimeFormat != "24h" {
+		return fmt.Errorf("%w: time format should be 12h or 24h", customErrors.ErrBadRequest)
+	}
+
+	return nil
+}
+
+func validateAPIToken(t types.APIToken, now time.Time) error {
+	if len([]rune(t.Name)) > 64 || len([]rune(t.Name)) < 1 {
+		return fmt.Errorf("%w: name should contain from 1 to 64 characters", customErrors.ErrBadRequest)
+	}
+
+	if len(t.Scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", customErrors.ErrBadRequest)
+	}
+
+	for _, scope := range t.Scopes {
+		valid := false
+		for _, s := range types.TokenScopes {
+			valid = valid || scope == s
+		}
+
+		if !valid {
+			return fmt.Errorf("%w: unknown scope %q", customErrors.ErrBadRequest, scope)
+		}
+	}
+
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expiresAt should be in the future", customErrors.ErrBadRequest)
+	}
+
+	return nil
+}
@@ -1,24 +1,66 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/customErrors"
 	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/users/lockout"
+	"github.com/bubo-py/McK/users/mail"
+	"github.com/bubo-py/McK/users/passwords"
+	"github.com/bubo-py/McK/users/repositories/mocks"
 	"github.com/bubo-py/McK/users/repositories/serviceDb"
+	"github.com/bubo-py/McK/users/tokens"
+	"github.com/bubo-py/McK/users/totp"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	loginErr    = errors.New("the server cannot process the request: login should be at least 3 and contain up to 30 characters")
 	passwordErr = errors.New("the server cannot process the request: password should be at least 5 characters")
 	authErr     = errors.New("the server cannot process the request due to lack of client's access rights: cannot modify another user's account")
+	updateErr   = errors.New("the server cannot process the request: password cannot be updated, it is changed with the current password")
+	timezoneErr = errors.New("the server cannot process the request: unknown timezone \"Mars/Olympus_Mons\"")
 )
 
 var db = serviceDb.Db{}
 
+var signer, _ = tokens.InitSigner("test", []tokens.Key{{ID: "test", Secret: bytes.Repeat([]byte("s"), 32)}})
+
+// guard counts failed logins without locking them out, see TestLockout
+var guard = lockout.InitGuard(lockout.InitMemoryStore(), lockout.Config{})
+
+var mailer = &mail.MemoryMailer{}
+
+var cipher, _ = totp.InitCipher(bytes.Repeat([]byte("k"), totp.KeySize))
+
+// hasher hashes passwords with the lowest cost, so that tests stay fast
+var hasher = passwords.InitHasher(passwords.Bcrypt{Cost: bcrypt.MinCost}, passwords.DefaultArgon2id)
+
+var policy = passwords.Policy{MinLength: 5}
+
+var calendars = &calendarsRecorder{}
+
+// calendarsRecorder records users whose default calendar is created
+type calendarsRecorder struct {
+	logins []string
+	err    error
+}
+
+func (c *calendarsRecorder) AddDefaultCalendar(_ context.Context, login string) error {
+	c.logins = append(c.logins, login)
+	return c.err
+}
+
 func TestAddUser(t *testing.T) {
 	testCases := []struct {
 		user     types.User
@@ -64,11 +106,27 @@ func TestAddUser(t *testing.T) {
 			},
 			expError: passwordErr,
 		},
+		{
+			user: types.User{
+				Login:    "Hello",
+				Password: "Hello",
+				Timezone: "Asia/Tokyo",
+			},
+			expError: nil,
+		},
+		{
+			user: types.User{
+				Login:    "Hello",
+				Password: "Hello",
+				Timezone: "Mars/Olympus_Mons",
+			},
+			expError: timezoneErr,
+		},
 	}
 	for i, tc := range testCases {
 		testName := fmt.Sprintf("Test %d", i+1)
 		t.Run(testName, func(t *testing.T) {
-			bl := InitBusinessLogic(db)
+			bl := InitBusinessLogic(db, signer, guard, mailer, cipher, hasher, policy, calendars)
 			ctx := context.Background()
 
 			_, err := bl.AddUser(ctx, tc.user)
@@ -81,6 +139,25 @@ func TestAddUser(t *testing.T) {
 	}
 }
 
+func TestAddUserDefaultCalendar(t *testing.T) {
+	ctx := context.Background()
+
+	recorder := &calendarsRecorder{}
+	bl := InitBusinessLogic(db, signer, guard, mailer, cipher, hasher, policy, recorder)
+
+	_, err := bl.AddUser(ctx, types.User{Login: "calendars", Password: "Hello"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"calendars"}, recorder.logins)
+
+	_, err = bl.AddUser(ctx, types.User{Login: "x", Password: "Hello"})
+	require.NotNil(t, err)
+	require.Len(t, recorder.logins, 1, "rejected users should not get a calendar")
+
+	recorder.err = customErrors.ErrUnexpected
+	_, err = bl.AddUser(ctx, types.User{Login: "noCalendar", Password: "Hello"})
+	require.Nil(t, err, "the calendar is created later when it fails now")
+}
+
 func TestUpdateUser(t *testing.T) {
 	// Init context values
 	ctx := context.Background()
@@ -94,7 +171,7 @@ func TestUpdateUser(t *testing.T) {
 		{
 			user: types.User{
 				Login:    "",
-				Password: "Hello",
+				Password: "",
 				Timezone: "Asia/Tokyo",
 			},
 			expError: authErr,
@@ -118,16 +195,16 @@ func TestUpdateUser(t *testing.T) {
 		{
 			user: types.User{
 				Login:    "",
-				Password: "up",
+				Password: "Hello12345",
 				Timezone: "",
 			},
-			expError: passwordErr,
+			expError: updateErr,
 		},
 	}
 	for i, tc := range testCases {
 		testName := fmt.Sprintf("Test %d", i+1)
 		t.Run(testName, func(t *testing.T) {
-			bl := InitBusinessLogic(db)
+			bl := InitBusinessLogic(db, signer, guard, mailer, cipher, hasher, policy, calendars)
 
 			_, err := bl.UpdateUser(ctx, tc.user, 1)
 			if err != nil {
@@ -145,7 +222,7 @@ func TestDeleteUser(t *testing.T) {
 	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
 	ctx = contextHelpers.WriteTimezoneToContext(ctx, "Europe/London")
 
-	bl := InitBusinessLogic(db)
+	bl := InitBusinessLogic(db, signer, guard, mailer, cipher, hasher, policy, calendars)
 
 	err := bl.DeleteUser(ctx, 1)
 	expErr := authErr
@@ -154,3 +231,733 @@ func TestDeleteUser(t *testing.T) {
 	}
 
 }
+
+func TestCreateFeed(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+
+	bl := InitBusinessLogic(db, signer, guard, mailer, cipher, hasher, policy, calendars)
+
+	feed, err := bl.CreateFeed(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create feed: %v", err)
+	}
+
+	if feed.Token == "" || feed.URL != "/api/feeds/"+feed.Token+".ics" {
+		t.Errorf("Failed to create feed: got: %v", feed)
+	}
+
+	another, _ := bl.CreateFeed(ctx)
+	if another.Token == feed.Token {
+		t.Errorf("Feed tokens should be random")
+	}
+
+	if hashToken(feed.Token) == feed.Token || len(hashToken(feed.Token)) != 64 {
+		t.Errorf("Feed tokens should be stored hashed")
+	}
+
+	_, err = bl.CreateFeed(context.Background())
+	if err == nil {
+		t.Errorf("Should return an error without login in context")
+	}
+
+	_, err = bl.GetUserByFeedToken(ctx, "")
+	if !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("Should return a different error: got %v, expected: %v", err, customErrors.ErrUnauthenticated)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Hello"), bcrypt.MinCost)
+	user := types.User{ID: 1, Login: "hello", Password: string(hash), Timezone: "Europe/London"}
+
+	// Refresh tokens are kept by their hashes like in the database
+	stored := make(map[string]types.RefreshToken)
+
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "hello").Return(user, nil).AnyTimes()
+	mockDB.EXPECT().GetUserByID(gomock.Any(), int64(1)).Return(user, nil)
+	mockDB.EXPECT().AddRefreshToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, rt types.RefreshToken) error {
+			rt.Login = user.Login
+			stored[rt.TokenHash] = rt
+			return nil
+		}).AnyTimes()
+	mockDB.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, tokenHash string) (types.RefreshToken, error) {
+			rt, ok := stored[tokenHash]
+			if !ok {
+				return rt, customErrors.ErrUnauthenticated
+			}
+			return rt, nil
+		}).AnyTimes()
+	mockDB.EXPECT().RevokeRefreshToken(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, tokenHash string, now time.Time) error {
+			rt := stored[tokenHash]
+			rt.RevokedAt = &now
+			stored[tokenHash] = rt
+			return nil
+		}).AnyTimes()
+	mockDB.EXPECT().RevokeTokenFamily(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, family string, now time.Time) error {
+			for h, rt := range stored {
+				if rt.Family == family && rt.RevokedAt == nil {
+					rt.RevokedAt = &now
+					stored[h] = rt
+				}
+			}
+			return nil
+		}).AnyTimes()
+
+	bl := InitBusinessLogic(mockDB, signer, guard, mailer, cipher, hasher, policy, calendars)
+
+	_, err := bl.IssueTokens(ctx, types.Credentials{Login: "hello", Password: "wrong"})
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	issued, err := bl.IssueTokens(ctx, types.Credentials{Login: "hello", Password: "Hello"})
+	require.Nil(t, err)
+	require.Equal(t, "Bearer", issued.TokenType)
+	require.Contains(t, stored, hashToken(issued.RefreshToken))
+
+	u, err := bl.AuthenticateToken(ctx, issued.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, user, u)
+
+	_, err = bl.AuthenticateToken(ctx, issued.RefreshToken)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	refreshed, err := bl.RefreshTokens(ctx, issued.RefreshToken)
+	require.Nil(t, err)
+	require.NotEqual(t, issued.RefreshToken, refreshed.RefreshToken)
+
+	// Reusing a rotated token revokes the tokens rotated from it as well
+	_, err = bl.RefreshTokens(ctx, issued.RefreshToken)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	_, err = bl.RefreshTokens(ctx, refreshed.RefreshToken)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	issued, _ = bl.IssueTokens(ctx, types.Credentials{Login: "hello", Password: "Hello"})
+	err = bl.RevokeTokens(ctx, issued.RefreshToken)
+	require.Nil(t, err)
+
+	_, err = bl.RefreshTokens(ctx, issued.RefreshToken)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	_, err = bl.RefreshTokens(ctx, "unknown")
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+}
+
+func TestTokensAfterRename(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Hello"), bcrypt.MinCost)
+	users := map[int64]types.User{1: {ID: 1, Login: "hello", Password: string(hash)}}
+
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, login string) (types.User, error) {
+			for _, u := range users {
+				if u.Login == login {
+					return u, nil
+				}
+			}
+			return types.User{}, customErrors.ErrNotFound
+		}).AnyTimes()
+	mockDB.EXPECT().GetUserByID(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id int64) (types.User, error) {
+			u, ok := users[id]
+			if !ok {
+				return u, customErrors.ErrNotFound
+			}
+			return u, nil
+		}).AnyTimes()
+	mockDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, u types.User, id int64) (types.User, error) {
+			renamed := users[id]
+			renamed.Login = u.Login
+			users[id] = renamed
+			return renamed, nil
+		})
+	mockDB.EXPECT().AddRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+
+	bl := InitBusinessLogic(mockDB, signer, guard, mailer, cipher, hasher, policy, calendars)
+
+	issued, err := bl.IssueTokens(ctx, types.Credentials{Login: "hello", Password: "Hello"})
+	require.Nil(t, err)
+
+	_, err = bl.UpdateCurrentUser(ctx, types.ProfileUpdate{Login: "renamed"})
+	require.Nil(t, err)
+
+	// Another user takes the freed login, the old token still belongs to the renamed user
+	users[2] = types.User{ID: 2, Login: "hello", Password: string(hash)}
+
+	u, err := bl.AuthenticateToken(ctx, issued.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), u.ID)
+	require.Equal(t, "renamed", u.Login)
+}
+
+func TestAdministration(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "admin")
+	adminCtx := contextHelpers.WriteRoleToContext(ctx, types.RoleAdmin)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	admin := types.User{ID: 1, Login: "admin", Role: types.RoleAdmin}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Hello"), bcrypt.MinCost)
+	user := types.User{ID: 2, Login: "hello", Password: string(hash), Role: types.RoleUser}
+
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "admin").Return(admin, nil).AnyTimes()
+	mockDB.EXPECT().GetUsers(gomock.Any()).Return([]types.User{admin, user}, nil)
+	mockDB.EXPECT().SetUserDisabled(gomock.Any(), int64(2), true).Return(nil)
+	mockDB.EXPECT().RevokeUserTokens(gomock.Any(), int64(2), gomock.Any()).Return(nil).Times(2)
+	mockDB.EXPECT().ResetPassword(gomock.Any(), int64(2), gomock.Any(), true).Return(nil)
+	mockDB.EXPECT().DeleteUser(gomock.Any(), int64(2)).Return(nil)
+
+	bl := InitBusinessLogic(mockDB, signer, guard, mailer, cipher, hasher, policy, calendars)
+
+	// Users without the administrator role cannot manage other accounts
+	_, err := bl.GetUsers(ctx)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized)
+
+	err = bl.SetUserDisabled(ctx, 2, true)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized)
+
+	_, err = bl.ForcePasswordReset(ctx, 2)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized)
+
+	users, err := bl.GetUsers(adminCtx)
+	require.Nil(t, err)
+	require.Len(t, users, 2)
+	require.Empty(t, users[1].Password)
+
+	err = bl.SetUserDisabled(adminCtx, 1, true)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.SetUserDisabled(adminCtx, 2, true)
+	require.Nil(t, err)
+
+	reset, err := bl.ForcePasswordReset(adminCtx, 2)
+	require.Nil(t, err)
+	require.NotEmpty(t, reset.TemporaryPassword)
+
+	err = bl.DeleteUser(adminCtx, 2)
+	require.Nil(t, err)
+}
+
+func TestInactiveUsers(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Hello"), bcrypt.MinCost)
+	disabled := types.User{ID: 1, Login: "disabled", Password: string(hash), Disabled: true}
+	reset := types.User{ID: 2, Login: "reset", Password: string(hash), PasswordResetRequired: true}
+
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "disabled").Return(disabled, nil).AnyTimes()
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "reset").Return(reset, nil).AnyTimes()
+	mockDB.EXPECT().GetUserByID(gomock.Any(), int64(1)).Return(disabled, nil)
+	mockDB.EXPECT().ResetPassword(gomock.Any(), int64(2), gomock.Any(), false).Return(nil)
+	mockDB.EXPECT().AddRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+
+	bl := InitBusinessLogic(mockDB, signer, guard, mailer, cipher, hasher, policy, calendars)
+
+	err := bl.LoginUser(ctx, "disabled", "Hello", "")
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	_, err = bl.IssueTokens(ctx, types.Credentials{Login: "disabled", Password: "Hello", NewPassword: "Hello2"})
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	// The temporary password is accepted only together with a new one
+	err = bl.LoginUser(ctx, "reset", "Hello", "")
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	_, err = bl.IssueTokens(ctx, types.Credentials{Login: "reset", Password: "Hello"})
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	_, err = bl.IssueTokens(ctx, types.Credentials{Login: "reset", Password: "Hello", NewPassword: "abc"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	issued, err := bl.IssueTokens(ctx, types.Credentials{Login: "reset", Password: "Hello", NewPassword: "Hello2"})
+	require.Nil(t, err)
+	require.NotEmpty(t, issued.AccessToken)
+
+	// Access tokens issued before the account was disabled are rejected
+	token, _ := signer.Sign(tokens.Claims{Subject: "1", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	_, err = bl.AuthenticateToken(ctx, token)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+}
+
+func TestCurrentUser(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Hello"), bcrypt.MinCost)
+	user := types.User{ID: 1, Login: "hello", Password: string(hash), Timezone: "Europe/London"}
+	prefs := &types.Preferences{WeekStart: "sunday", TimeFormat: "12h"}
+
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "hello").Return(user, nil).AnyTimes()
+	mockDB.EXPECT().UpdateUser(gomock.Any(), types.User{Timezone: "Asia/Tokyo", Preferences: prefs}, int64(1)).
+		Return(user, nil)
+	mockDB.EXPECT().ResetPassword(gomock.Any(), int64(1), gomock.Any(), false).Return(nil)
+	mockDB.EXPECT().RevokeUserTokens(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+
+	bl := InitBusinessLogic(mockDB, signer, guard, mailer, cipher, hasher, policy, calendars)
+
+	u, err := bl.GetCurrentUser(ctx)
+	require.Nil(t, err)
+	require.Equal(t, user, u)
+
+	_, err = bl.UpdateCurrentUser(ctx, types.ProfileUpdate{Timezone: "Asia/Tokyo", Preferences: prefs})
+	require.Nil(t, err)
+
+	_, err = bl.UpdateCurrentUser(ctx, types.ProfileUpdate{Preferences: &types.Preferences{WeekStart: "friday"}})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	_, err = bl.UpdateCurrentUser(ctx, types.ProfileUpdate{Timezone: "Local"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	_, err = bl.UpdateCurrentUser(ctx, types.ProfileUpdate{Login: "hi"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.ChangePassword(ctx, types.PasswordChange{CurrentPassword: "wrong", NewPassword: "Hello2"})
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	err = bl.ChangePassword(ctx, types.PasswordChange{CurrentPassword: "Hello", NewPassword: "abc"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.ChangePassword(ctx, types.PasswordChange{CurrentPassword: "Hello", NewPassword: "Hello2"})
+	require.Nil(t, err)
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteClientIPToContext(ctx, "10.0.0.1")
+	adminCtx := contextHelpers.WriteLoginToContext(ctx, "admin")
+	adminCtx = contextHelpers.WriteRoleToContext(adminCtx, types.RoleAdmin)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Hello"), bcrypt.MinCost)
+	user := types.User{ID: 1, Login: "hello", Password: string(hash)}
+
+	// Locked logins are rejected before their password is checked
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "hello").Return(user, nil).Times(4)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "unknown").Return(types.User{}, customErrors.ErrUnauthenticated)
+	mockDB.EXPECT().GetUserByID(gomock.Any(), int64(1)).Return(user, nil)
+
+	store := lockout.InitMemoryStore()
+	bl := InitBusinessLogic(mockDB, signer, lockout.InitGuard(store, lockout.Config{MaxFailures: 2, MaxIPFailures: 10,
+		LockoutDuration: time.Hour, Window: time.Hour}), mailer, cipher, hasher, policy, calendars)
+
+	err := bl.LoginUser(ctx, "hello", "wrong", "")
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	// A successful login clears the failures of the login
+	err = bl.LoginUser(ctx, "hello", "Hello", "")
+	require.Nil(t, err)
+	require.NotContains(t, store.Attempts, lockout.LoginKey("hello"))
+
+	_ = bl.LoginUser(ctx, "hello", "wrong", "")
+	_ = bl.LoginUser(ctx, "hello", "wrong", "")
+
+	err = bl.LoginUser(ctx, "hello", "Hello", "")
+	require.ErrorIs(t, err, customErrors.ErrTooManyRequests)
+
+	var locked lockout.LockedError
+	require.ErrorAs(t, err, &locked)
+	require.True(t, locked.RetryAfter > 59*time.Minute)
+
+	// Unknown logins are counted as well, so that they cannot be told apart from locked ones
+	_ = bl.LoginUser(ctx, "unknown", "wrong", "")
+	require.Equal(t, 1, store.Attempts[lockout.LoginKey("unknown")].Failures)
+	require.Equal(t, 4, store.Attempts[lockout.IPKey("10.0.0.1")].Failures)
+
+	err = bl.UnlockUser(ctx, 1)
+	require.ErrorIs(t, err, customErrors.ErrUnauthorized)
+
+	err = bl.UnlockUser(adminCtx, 1)
+	require.Nil(t, err)
+	require.NotContains(t, store.Attempts, lockout.LoginKey("hello"))
+	require.Equal(t, types.LockoutUnlocked, store.Events[len(store.Events)-1].Action)
+	require.Equal(t, "admin", store.Events[len(store.Events)-1].Actor)
+}
+
+func TestAPITokens(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	user := types.User{ID: 1, Login: "hello", Timezone: "Europe/London"}
+	past := time.Now().Add(-time.Hour)
+	var storedHash string
+
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "hello").Return(user, nil).AnyTimes()
+	mockDB.EXPECT().AddAPIToken(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, at types.APIToken, tokenHash string) (types.APIToken, error) {
+			storedHash = tokenHash
+			at.ID = 1
+			return at, nil
+		})
+
+	bl := InitBusinessLogic(mockDB, signer, guard, mailer, cipher, hasher, policy, calendars)
+
+	for _, at := range []types.APIToken{
+		{Name: "", Scopes: []types.TokenScope{types.ScopeEventsRead}},
+		{Name: "CI"},
+		{Name: "CI", Scopes: []types.TokenScope{"events:delete"}},
+		{Name: "CI", Scopes: []types.TokenScope{types.ScopeEventsRead}, ExpiresAt: &past},
+	} {
+		_, err := bl.CreateAPIToken(ctx, at)
+		require.ErrorIs(t, err, customErrors.ErrBadRequest, "token: %+v", at)
+	}
+
+	created, err := bl.CreateAPIToken(ctx, types.APIToken{Name: "CI", Scopes: []types.TokenScope{types.ScopeEventsRead}})
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(created.Prefix, types.APITokenPrefix))
+	require.True(t, strings.HasPrefix(created.Token, created.Prefix))
+	require.Equal(t, hashToken(created.Token), storedHash)
+	require.Equal(t, user.ID, created.UserID)
+
+	stored := types.APIToken{ID: 1, Login: "hello", Scopes: created.Scopes}
+	mockDB.EXPECT().GetAPIToken(gomock.Any(), storedHash).Return(stored, nil)
+	mockDB.EXPECT().TouchAPIToken(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+
+	u, scopes, err := bl.AuthenticateAPIToken(ctx, created.Token)
+	require.Nil(t, err)
+	require.Equal(t, user, u)
+	require.Equal(t, []types.TokenScope{types.ScopeEventsRead}, scopes)
+
+	// Recent uses are not written again
+	recent := time.Now().Add(-time.Second)
+	stored.LastUsedAt = &recent
+	mockDB.EXPECT().GetAPIToken(gomock.Any(), storedHash).Return(stored, nil)
+
+	_, _, err = bl.AuthenticateAPIToken(ctx, created.Token)
+	require.Nil(t, err)
+
+	stored.ExpiresAt = &past
+	mockDB.EXPECT().GetAPIToken(gomock.Any(), storedHash).Return(stored, nil)
+
+	_, _, err = bl.AuthenticateAPIToken(ctx, created.Token)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+}
+
+func TestEmailFlows(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	user := types.User{ID: 1, Login: "hello", Email: "hello@example.com"}
+	outbox := &mail.MemoryMailer{}
+
+	// stored keeps the stored hashes, so that the tokens found in the mail can be matched
+	stored := make(map[string]types.EmailToken)
+
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByEmail(gomock.Any(), "hello@example.com").Return(types.User{}, customErrors.ErrNotFound)
+	mockDB.EXPECT().AddUser(gomock.Any(), gomock.Any()).Return(user, nil)
+	mockDB.EXPECT().AddEmailToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, t types.EmailToken) error {
+			t.Login = user.Login
+			stored[t.TokenHash] = t
+			return nil
+		}).Times(2)
+	mockDB.EXPECT().UseEmailToken(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, hash string, purpose types.EmailTokenPurpose, now time.Time) (types.EmailToken, error) {
+			t, ok := stored[hash]
+			if !ok || t.Purpose != purpose || now.After(t.ExpiresAt) {
+				return types.EmailToken{}, customErrors.ErrBadRequest
+			}
+
+			delete(stored, hash)
+			return t, nil
+		}).Times(4)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "hello").Return(user, nil)
+	mockDB.EXPECT().SetEmailVerified(gomock.Any(), int64(1), true).Return(nil)
+
+	bl := InitBusinessLogic(mockDB, signer, guard, outbox, cipher, hasher, policy, calendars)
+
+	_, err := bl.AddUser(ctx, types.User{Login: "hello", Password: "Hello", Email: " hello@example.com "})
+	require.Nil(t, err)
+
+	m, ok := outbox.Last("hello@example.com")
+	require.True(t, ok)
+
+	token := lastLine(m.Body)
+
+	// The verification token cannot reset the password
+	err = bl.ConfirmPasswordReset(ctx, types.EmailConfirmation{Token: token, NewPassword: "Hello2"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.VerifyEmail(ctx, token)
+	require.Nil(t, err)
+
+	err = bl.VerifyEmail(ctx, token)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	// Resets are mailed to verified emails only
+	user.EmailVerified = true
+	mockDB.EXPECT().GetUserByEmail(gomock.Any(), "hello@example.com").Return(user, nil)
+	mockDB.EXPECT().GetUserByEmail(gomock.Any(), "unknown@example.com").Return(types.User{}, customErrors.ErrNotFound)
+	mockDB.EXPECT().ResetPassword(gomock.Any(), int64(1), gomock.Any(), false).Return(nil)
+	mockDB.EXPECT().RevokeUserTokens(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+
+	err = bl.RequestPasswordReset(ctx, "unknown@example.com")
+	require.Nil(t, err)
+	require.Len(t, outbox.Messages, 1)
+
+	err = bl.RequestPasswordReset(ctx, "hello@example.com")
+	require.Nil(t, err)
+	require.Len(t, outbox.Messages, 2)
+
+	m, _ = outbox.Last("hello@example.com")
+	token = lastLine(strings.TrimSuffix(m.Body, "\n\nIf you did not request the reset, ignore this email.\n"))
+
+	err = bl.ConfirmPasswordReset(ctx, types.EmailConfirmation{Token: token, NewPassword: "abc"})
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.ConfirmPasswordReset(ctx, types.EmailConfirmation{Token: token, NewPassword: "Hello2"})
+	require.Nil(t, err)
+
+	err = bl.RequestPasswordReset(ctx, "not an email")
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+func TestTOTP(t *testing.T) {
+	ctx := context.Background()
+	ctx = contextHelpers.WriteLoginToContext(ctx, "hello")
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Hello"), bcrypt.MinCost)
+	user := types.User{ID: 1, Login: "hello", Password: string(hash)}
+	recoveryCodes := make(map[string]bool)
+
+	// The mock keeps the state of two-factor authentication like the database does
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "hello").DoAndReturn(
+		func(ctx context.Context, login string) (types.User, error) {
+			return user, nil
+		}).AnyTimes()
+	mockDB.EXPECT().SetTOTP(gomock.Any(), int64(1), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id int64, secret string, enabled bool) error {
+			user.TOTPSecret, user.TOTPEnabled = secret, enabled
+			return nil
+		}).AnyTimes()
+	mockDB.EXPECT().UseTOTPStep(gomock.Any(), int64(1), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id int64, step int64) error {
+			if step <= user.TOTPLastStep {
+				return customErrors.ErrUnauthenticated
+			}
+
+			user.TOTPLastStep = step
+			return nil
+		}).AnyTimes()
+	mockDB.EXPECT().SetRecoveryCodes(gomock.Any(), int64(1), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id int64, hashes []string) error {
+			recoveryCodes = make(map[string]bool)
+			for _, h := range hashes {
+				recoveryCodes[h] = true
+			}
+			return nil
+		}).AnyTimes()
+	mockDB.EXPECT().UseRecoveryCode(gomock.Any(), int64(1), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id int64, hash string, now time.Time) error {
+			if !recoveryCodes[hash] {
+				return customErrors.ErrUnauthenticated
+			}
+
+			delete(recoveryCodes, hash)
+			return nil
+		}).AnyTimes()
+
+	store := lockout.InitMemoryStore()
+	config := lockout.Config{MaxFailures: 5, LockoutDuration: time.Hour, Window: time.Hour}
+	bl := InitBusinessLogic(mockDB, signer, lockout.InitGuard(store, config), mailer, cipher, hasher, policy,
+		calendars)
+
+	enrollment, err := bl.EnrollTOTP(ctx)
+	require.Nil(t, err)
+	require.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	require.NotContains(t, user.TOTPSecret, enrollment.Secret)
+	require.False(t, user.TOTPEnabled)
+
+	// Logins do not take a second factor until the enrollment is verified
+	err = bl.LoginUser(ctx, "hello", "Hello", "")
+	require.Nil(t, err)
+
+	// Wrong codes of the enrollment count as failed logins as well
+	_, err = bl.EnableTOTP(ctx, "000000")
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+	require.Equal(t, 1, store.Attempts[lockout.LoginKey("hello")].Failures)
+
+	now := time.Now()
+	previous, _ := totp.Code(enrollment.Secret, now.Add(-30*time.Second))
+	code := previous
+
+	codes, err := bl.EnableTOTP(ctx, code)
+	require.Nil(t, err)
+	require.Len(t, codes.Codes, 10)
+	require.True(t, user.TOTPEnabled)
+	require.NotContains(t, store.Attempts, lockout.LoginKey("hello"))
+
+	_, err = bl.EnrollTOTP(ctx)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+
+	err = bl.LoginUser(ctx, "hello", "Hello", "")
+	require.ErrorIs(t, err, customErrors.ErrOTPRequired)
+
+	// Codes cannot be replayed and wrong ones count as failed logins
+	_, err = bl.RegenerateRecoveryCodes(ctx, code)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+	require.Equal(t, 1, store.Attempts[lockout.LoginKey("hello")].Failures)
+
+	err = bl.LoginUser(ctx, "hello", "Hello", "000000")
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+	require.Equal(t, 2, store.Attempts[lockout.LoginKey("hello")].Failures)
+
+	code, _ = totp.Code(enrollment.Secret, now)
+	err = bl.LoginUser(ctx, "hello", "Hello", code)
+	require.Nil(t, err)
+	require.NotContains(t, store.Attempts, lockout.LoginKey("hello"))
+
+	// Basic auth repeats the code with every request within its time step, which neither fails nor locks the login
+	for i := 0; i <= config.MaxFailures; i++ {
+		err = bl.LoginUser(ctx, "hello", "Hello", code)
+		require.Nil(t, err)
+	}
+	require.NotContains(t, store.Attempts, lockout.LoginKey("hello"))
+
+	// Only the code used last is repeatable, older codes still within the window cannot be replayed
+	err = bl.LoginUser(ctx, "hello", "Hello", previous)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	_, err = bl.RegenerateRecoveryCodes(ctx, code)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	// Recovery codes are accepted once, typed in any case and without the dash
+	recovery := strings.ToUpper(strings.ReplaceAll(codes.Codes[0], "-", ""))
+	err = bl.LoginUser(ctx, "hello", "Hello", recovery)
+	require.Nil(t, err)
+
+	err = bl.LoginUser(ctx, "hello", "Hello", recovery)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	_, err = bl.RegenerateRecoveryCodes(ctx, recovery)
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	code, _ = totp.Code(enrollment.Secret, now.Add(30*time.Second))
+	codes, err = bl.RegenerateRecoveryCodes(ctx, code)
+	require.Nil(t, err)
+	require.Len(t, recoveryCodes, 10)
+
+	err = bl.DisableTOTP(ctx, types.TOTPDisable{Password: "wrong", Code: codes.Codes[0]})
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	err = bl.DisableTOTP(ctx, types.TOTPDisable{Password: "Hello", Code: codes.Codes[0]})
+	require.Nil(t, err)
+	require.False(t, user.TOTPEnabled)
+	require.Empty(t, user.TOTPSecret)
+	require.Empty(t, recoveryCodes)
+}
+
+func TestPasswordRehash(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Hello"), bcrypt.MinCost)
+	user := types.User{ID: 1, Login: "hello", Password: string(hash)}
+
+	mockDB := mocks.NewMockUserRepository(mockCtrl)
+	mockDB.EXPECT().GetUserByLogin(gomock.Any(), "hello").DoAndReturn(
+		func(ctx context.Context, login string) (types.User, error) {
+			return user, nil
+		}).Times(3)
+	mockDB.EXPECT().RehashPassword(gomock.Any(), int64(1), string(hash), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id int64, oldHash, newHash string) error {
+			user.Password = newHash
+			return nil
+		})
+
+	argon2id := passwords.Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1}
+	bl := InitBusinessLogic(mockDB, signer, guard, mailer, cipher, passwords.InitHasher(argon2id, passwords.Bcrypt{}),
+		policy, calendars)
+
+	// A failed login does not upgrade the hash
+	err := bl.LoginUser(ctx, "hello", "wrong", "")
+	require.ErrorIs(t, err, customErrors.ErrUnauthenticated)
+
+	err = bl.LoginUser(ctx, "hello", "Hello", "")
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(user.Password, "$argon2id$"))
+
+	// The upgraded hash is current, so it is not replaced again
+	err = bl.LoginUser(ctx, "hello", "Hello", "")
+	require.Nil(t, err)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	bl := InitBusinessLogic(db, signer, guard, mailer, cipher, hasher, passwords.Policy{MinLength: 8,
+		DisallowLogin: true, Breached: map[string]struct{}{"password1": {}}}, calendars)
+
+	testCases := []struct {
+		user     types.User
+		expected error
+	}{
+		{types.User{Login: "hello", Password: "short"}, customErrors.ErrBadRequest},
+		{types.User{Login: "longlogin", Password: "LongLogin"}, customErrors.ErrBadRequest},
+		{types.User{Login: "hello", Password: "Password1"}, customErrors.ErrBadRequest},
+		{types.User{Login: "hello", Password: "correct horse"}, nil},
+	}
+
+	for _, tc := range testCases {
+		tc.user.Timezone = "Europe/London"
+
+		_, err := bl.AddUser(context.Background(), tc.user)
+		if tc.expected == nil {
+			require.Nil(t, err)
+		} else {
+			require.ErrorIs(t, err, tc.expected)
+		}
+	}
+
+	// Updates leave the login out, so they cannot set passwords, which would be checked without it
+	ctx := contextHelpers.WriteLoginToContext(context.Background(), "longlogin")
+	_, err := bl.UpdateUser(ctx, types.User{Password: "LongLogin"}, 1)
+	require.ErrorIs(t, err, customErrors.ErrBadRequest)
+	require.Contains(t, err.Error(), "password cannot be updated")
+}
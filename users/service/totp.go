@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/users/totp"
+)
+
+// totpIssuer names the account in authenticator apps
+const totpIssuer = "McK"
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 10 // characters, shown in two groups of five
+)
+
+// EnrollTOTP starts the enrollment of two-factor authentication of the current user with a new secret,
+// it is enabled only once a code of the secret is verified by EnableTOTP
+func (bl BusinessLogic) EnrollTOTP(ctx context.Context) (types.TOTPEnrollment, error) {
+	u, err := bl.currentUser(ctx)
+	if err != nil {
+		return types.TOTPEnrollment{}, err
+	}
+
+	if u.TOTPEnabled {
+		return types.TOTPEnrollment{}, fmt.Errorf("%w: two-factor authentication is already enabled",
+			customErrors.ErrBadRequest)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return types.TOTPEnrollment{}, fmt.Errorf("%w: failed to generate secret: %v", customErrors.ErrUnexpected, err)
+	}
+
+	encrypted, err := bl.cipher.Encrypt(secret)
+	if err != nil {
+		return types.TOTPEnrollment{}, fmt.Errorf("%w: failed to encrypt secret: %v", customErrors.ErrUnexpected, err)
+	}
+
+	err = bl.db.SetTOTP(ctx, u.ID, encrypted, false)
+	if err != nil {
+		return types.TOTPEnrollment{}, err
+	}
+
+	return types.TOTPEnrollment{Secret: secret, URI: totp.ProvisioningURI(totpIssuer, u.Login, secret)}, nil
+}
+
+// EnableTOTP verifies a code of the enrolled secret, which proves the authenticator app was set up, and enables
+// two-factor authentication of the current user. The returned recovery codes are not shown again.
+func (bl BusinessLogic) EnableTOTP(ctx context.Context, code string) (types.RecoveryCodes, error) {
+	u, err := bl.currentUser(ctx)
+	if err != nil {
+		return types.RecoveryCodes{}, err
+	}
+
+	if u.TOTPEnabled {
+		return types.RecoveryCodes{}, fmt.Errorf("%w: two-factor authentication is already enabled",
+			customErrors.ErrBadRequest)
+	}
+
+	if u.TOTPSecret == "" {
+		return types.RecoveryCodes{}, fmt.Errorf("%w: two-factor authentication is not enrolled",
+			customErrors.ErrBadRequest)
+	}
+
+	secret, err := bl.cipher.Decrypt(u.TOTPSecret)
+	if err != nil {
+		return types.RecoveryCodes{}, fmt.Errorf("%w: failed to decrypt secret: %v", customErrors.ErrUnexpected, err)
+	}
+
+	// Wrong codes count as failed logins, otherwise a stolen session could guess codes of the enrolled secret
+	ip, _ := contextHelpers.RetrieveClientIPFromContext(ctx)
+	now := time.Now()
+
+	failed, err := bl.guard.Check(ctx, u.Login, ip, now)
+	if err != nil {
+		return types.RecoveryCodes{}, err
+	}
+
+	step, ok := totp.Validate(secret, code, now)
+	if !ok {
+		lockErr := bl.guard.Fail(ctx, u.Login, ip, now)
+		if lockErr != nil {
+			log.Printf("Failed to count a failed login: %v", lockErr)
+		}
+
+		return types.RecoveryCodes{}, fmt.Errorf("%w: invalid one-time password", customErrors.ErrBadRequest)
+	}
+
+	if failed {
+		err = bl.guard.Succeed(ctx, u.Login)
+		if err != nil {
+			log.Printf("Failed to clear failed logins: %v", err)
+		}
+	}
+
+	err = bl.db.UseTOTPStep(ctx, u.ID, step)
+	if err != nil {
+		return types.RecoveryCodes{}, err
+	}
+
+	codes, err := bl.resetRecoveryCodes(ctx, u.ID)
+	if err != nil {
+		return types.RecoveryCodes{}, err
+	}
+
+	err = bl.db.SetTOTP(ctx, u.ID, u.TOTPSecret, true)
+	if err != nil {
+		return types.RecoveryCodes{}, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication of the current user off, it takes the password and a one-time password
+func (bl BusinessLogic) DisableTOTP(ctx context.Context, d types.TOTPDisable) error {
+	currentUser, err := bl.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !currentUser.TOTPEnabled {
+		return fmt.Errorf("%w: two-factor authentication is not enabled", customErrors.ErrBadRequest)
+	}
+
+	u, err := bl.checkPassword(ctx, currentUser.Login, d.Password)
+	if err != nil {
+		return err
+	}
+
+	err = bl.checkOTP(ctx, u, d.Code, false)
+	if err != nil {
+		return err
+	}
+
+	err = bl.db.SetTOTP(ctx, u.ID, "", false)
+	if err != nil {
+		return err
+	}
+
+	return bl.db.SetRecoveryCodes(ctx, u.ID, nil)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the current user, e.g. when most of them were used
+func (bl BusinessLogic) RegenerateRecoveryCodes(ctx context.Context, code string) (types.RecoveryCodes, error) {
+	u, err := bl.currentUser(ctx)
+	if err != nil {
+		return types.RecoveryCodes{}, err
+	}
+
+	if !u.TOTPEnabled {
+		return types.RecoveryCodes{}, fmt.Errorf("%w: two-factor authentication is not enabled",
+			customErrors.ErrBadRequest)
+	}
+
+	err = bl.checkOTP(ctx, u, code, false)
+	if err != nil {
+		return types.RecoveryCodes{}, err
+	}
+
+	return bl.resetRecoveryCodes(ctx, u.ID)
+}
+
+// checkOTP verifies the second factor of users with two-factor authentication, either a code of the authenticator
+// app or a recovery code. Wrong ones count as failed logins, so that codes cannot be guessed. A repeatable code
+// of the authenticator app is accepted again only if it is the code used last, older codes cannot be replayed.
+func (bl BusinessLogic) checkOTP(ctx context.Context, u types.User, otp string, repeatable bool) error {
+	if !u.TOTPEnabled {
+		return nil
+	}
+
+	if otp == "" {
+		return customErrors.ErrOTPRequired
+	}
+
+	ip, _ := contextHelpers.RetrieveClientIPFromContext(ctx)
+	now := time.Now()
+
+	err := bl.verifyOTP(ctx, u, otp, now, repeatable)
+	if errors.Is(err, customErrors.ErrUnauthenticated) {
+		lockErr := bl.guard.Fail(ctx, u.Login, ip, now)
+		if lockErr != nil {
+			log.Printf("Failed to count a failed login: %v", lockErr)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// checkPassword keeps failures of users with two-factor authentication until both factors are verified
+	err = bl.guard.Succeed(ctx, u.Login)
+	if err != nil {
+		log.Printf("Failed to clear failed logins: %v", err)
+	}
+
+	return nil
+}
+
+func (bl BusinessLogic) verifyOTP(ctx context.Context, u types.User, otp string, now time.Time,
+	repeatable bool) error {
+	if !totp.IsCode(otp) {
+		return bl.db.UseRecoveryCode(ctx, u.ID, hashToken(normalizeRecoveryCode(otp)), now)
+	}
+
+	secret, err := bl.cipher.Decrypt(u.TOTPSecret)
+	if err != nil {
+		return fmt.Errorf("%w: failed to decrypt secret: %v", customErrors.ErrUnexpected, err)
+	}
+
+	step, ok := totp.Validate(secret, otp, now)
+	if !ok {
+		return fmt.Errorf("%w: invalid one-time password", customErrors.ErrUnauthenticated)
+	}
+
+	if repeatable && step == u.TOTPLastStep {
+		return nil
+	}
+
+	// Codes stay valid for their whole time step, so each of them is accepted only once
+	return bl.db.UseTOTPStep(ctx, u.ID, step)
+}
+
+// resetRecoveryCodes stores hashes of new recovery codes in place of the previous ones and returns the codes
+func (bl BusinessLogic) resetRecoveryCodes(ctx context.Context, userID int64) (types.RecoveryCodes, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return types.RecoveryCodes{}, err
+		}
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	err := bl.db.SetRecoveryCodes(ctx, userID, hashes)
+	if err != nil {
+		return types.RecoveryCodes{}, err
+	}
+
+	return types.RecoveryCodes{Codes: codes}, nil
+}
+
+// randomRecoveryCode generates a code in the form "abcde-fghij", which is easy to write down
+func randomRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to generate recovery code: %v", customErrors.ErrUnexpected, err)
+	}
+
+	s := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:recoveryCodeSize]
+
+	return s[:recoveryCodeSize/2] + "-" + s[recoveryCodeSize/2:], nil
+}
+
+// normalizeRecoveryCode accepts recovery codes typed without the dash or in upper case
+func normalizeRecoveryCode(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer("-", "", " ", "").Replace(s)
+}
@@ -0,0 +1,159 @@
+// Package tokens signs and verifies access tokens. Tokens are JWTs signed with HMAC-SHA256, their header names
+// the key they were signed with, so that keys can be rotated: new tokens are signed with the current key while
+// tokens signed with the previous keys are accepted until they expire.
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// minSecretSize is the size of the SHA-256 output, shorter secrets weaken the signatures
+const minSecretSize = 32
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Key is a signing secret with the ID it is referred to by in tokens
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Claims identify the user of an access token, the subject is the ID of the user
+type Claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+type Signer struct {
+	keys    map[string][]byte
+	current string
+}
+
+// InitSigner returns a signer signing with the current key and verifying with any of the keys
+func InitSigner(current string, keys []Key) (Signer, error) {
+	s := Signer{keys: make(map[string][]byte), current: current}
+
+	for _, k := range keys {
+		if k.ID == "" || len(k.Secret) < minSecretSize {
+			return s, fmt.Errorf("signing key %q should have an ID and at least %d bytes", k.ID, minSecretSize)
+		}
+
+		s.keys[k.ID] = k.Secret
+	}
+
+	if _, ok := s.keys[current]; !ok {
+		return s, fmt.Errorf("current signing key %q not found", current)
+	}
+
+	return s, nil
+}
+
+// ParseKeys reads keys in the form "id:secret,id2:secret2", where secrets are base64 encoded
+func ParseKeys(s string) ([]Key, error) {
+	var keys []Key
+
+	for _, pair := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return keys, fmt.Errorf("signing key should be given as id:secret")
+		}
+
+		b, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return keys, fmt.Errorf("secret of signing key %q should be base64 encoded: %v", id, err)
+		}
+
+		keys = append(keys, Key{ID: id, Secret: b})
+	}
+
+	return keys, nil
+}
+
+func (s Signer) Sign(c Claims) (string, error) {
+	h, err := json.Marshal(header{Algorithm: "HS256", Type: "JWT", KeyID: s.current})
+	if err != nil {
+		return "", err
+	}
+
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encode(h) + "." + encode(p)
+
+	return unsigned + "." + encode(sign(s.keys[s.current], unsigned)), nil
+}
+
+// Verify checks the signature and the expiry of the token and returns its claims
+func (s Signer) Verify(token string, now time.Time) (Claims, error) {
+	var c Claims
+	var h header
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return c, ErrInvalidToken
+	}
+
+	err := decode(parts[0], &h)
+	if err != nil || h.Algorithm != "HS256" {
+		return c, ErrInvalidToken
+	}
+
+	secret, ok := s.keys[h.KeyID]
+	if !ok {
+		return c, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(secret, parts[0]+"."+parts[1])) {
+		return c, ErrInvalidToken
+	}
+
+	err = decode(parts[1], &c)
+	if err != nil || c.Subject == "" {
+		return c, ErrInvalidToken
+	}
+
+	if !now.Before(time.Unix(c.ExpiresAt, 0)) {
+		return c, ErrExpiredToken
+	}
+
+	return c, nil
+}
+
+func sign(secret []byte, s string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,76 @@
+package tokens
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldKey = Key{ID: "2022", Secret: bytes.Repeat([]byte("a"), 32)}
+	newKey = Key{ID: "2023", Secret: bytes.Repeat([]byte("b"), 32)}
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	claims := Claims{Subject: "1", IssuedAt: now.Unix(), ExpiresAt: now.Add(15 * time.Minute).Unix()}
+
+	old, err := InitSigner(oldKey.ID, []Key{oldKey})
+	require.Nil(t, err)
+
+	signer, err := InitSigner(newKey.ID, []Key{newKey, oldKey})
+	require.Nil(t, err)
+
+	token, err := signer.Sign(claims)
+	require.Nil(t, err)
+
+	c, err := signer.Verify(token, now)
+	require.Nil(t, err)
+	require.Equal(t, claims, c)
+
+	// Tokens signed with the previous key are accepted after the rotation, but not the other way around
+	oldToken, _ := old.Sign(claims)
+	_, err = signer.Verify(oldToken, now)
+	require.Nil(t, err)
+
+	_, err = old.Verify(token, now)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = signer.Verify(token, now.Add(15*time.Minute))
+	require.ErrorIs(t, err, ErrExpiredToken)
+
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) +
+		"." + parts[2]
+
+	for _, s := range []string{"", "a.b", forged, token + "x"} {
+		_, err = signer.Verify(s, now)
+		require.ErrorIs(t, err, ErrInvalidToken, "token: %q", s)
+	}
+}
+
+func TestInitSigner(t *testing.T) {
+	_, err := InitSigner("missing", []Key{newKey})
+	require.NotNil(t, err)
+
+	_, err = InitSigner("short", []Key{{ID: "short", Secret: []byte("secret")}})
+	require.NotNil(t, err)
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString(newKey.Secret)
+
+	keys, err := ParseKeys("2023:" + secret + ", 2022:" + base64.StdEncoding.EncodeToString(oldKey.Secret))
+	require.Nil(t, err)
+	require.Equal(t, []Key{newKey, oldKey}, keys)
+
+	_, err = ParseKeys(secret)
+	require.NotNil(t, err)
+
+	_, err = ParseKeys("2023:not base64")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize of AES-256 keys
+const KeySize = 32
+
+var ErrNoKey = errors.New("encryption key is not configured")
+
+// Cipher encrypts secrets with AES-GCM before they are stored, a leaked database alone does not reveal them.
+// The zero Cipher has no key and fails to encrypt and decrypt.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+func InitCipher(key []byte) (Cipher, error) {
+	if len(key) != KeySize {
+		return Cipher{}, fmt.Errorf("encryption key should have %d bytes", KeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return Cipher{}, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return Cipher{}, err
+	}
+
+	return Cipher{aead: aead}, nil
+}
+
+// Encrypt returns the nonce followed by the sealed plaintext, encoded in base64
+func (c Cipher) Encrypt(plaintext string) (string, error) {
+	if c.aead == nil {
+		return "", ErrNoKey
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+func (c Cipher) Decrypt(ciphertext string) (string, error) {
+	if c.aead == nil {
+		return "", ErrNoKey
+	}
+
+	b, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(b) < c.aead.NonceSize() {
+		return "", errors.New("malformed ciphertext")
+	}
+
+	nonce, sealed := b[:c.aead.NonceSize()], b[c.aead.NonceSize():]
+
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %v", err)
+	}
+
+	return string(plaintext), nil
+}
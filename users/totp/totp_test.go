@@ -0,0 +1,103 @@
+package totp
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA-1 secret of the test vectors of RFC 6238, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// The last six digits of the eight digit codes of RFC 6238
+	testCases := []struct {
+		time    int64
+		expCode string
+	}{
+		{time: 59, expCode: "287082"},
+		{time: 1111111109, expCode: "081804"},
+		{time: 1111111111, expCode: "050471"},
+		{time: 1234567890, expCode: "005924"},
+		{time: 2000000000, expCode: "279037"},
+	}
+	for _, tc := range testCases {
+		c, err := Code(rfcSecret, time.Unix(tc.time, 0))
+		require.Nil(t, err)
+		require.Equal(t, tc.expCode, c, "time: %d", tc.time)
+	}
+
+	_, err := Code("not base32!", time.Unix(59, 0))
+	require.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.Nil(t, err)
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	c, _ := Code(secret, now)
+
+	step, ok := Validate(secret, c, now)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	// Codes of the neighbouring steps are accepted for clock drift
+	step, ok = Validate(secret, c, now.Add(period*time.Second))
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	_, ok = Validate(secret, c, now.Add(2*period*time.Second))
+	require.False(t, ok)
+
+	for _, invalid := range []string{"", "12345", "1234567", "abcdef"} {
+		_, ok = Validate(secret, invalid, now)
+		require.False(t, ok, "code: %q", invalid)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	u, err := url.Parse(ProvisioningURI("McK", "hello world", rfcSecret))
+	require.Nil(t, err)
+
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.Equal(t, "/McK:hello world", u.Path)
+	require.Equal(t, rfcSecret, u.Query().Get("secret"))
+	require.Equal(t, "McK", u.Query().Get("issuer"))
+}
+
+func TestIsCode(t *testing.T) {
+	require.True(t, IsCode("012345"))
+	require.False(t, IsCode("01234a"))
+	require.False(t, IsCode("abcde-fghij"))
+}
+
+func TestCipher(t *testing.T) {
+	c, err := InitCipher(bytes.Repeat([]byte("k"), KeySize))
+	require.Nil(t, err)
+
+	encrypted, err := c.Encrypt(rfcSecret)
+	require.Nil(t, err)
+	require.NotContains(t, encrypted, rfcSecret)
+
+	decrypted, err := c.Decrypt(encrypted)
+	require.Nil(t, err)
+	require.Equal(t, rfcSecret, decrypted)
+
+	other, _ := InitCipher(bytes.Repeat([]byte("o"), KeySize))
+	_, err = other.Decrypt(encrypted)
+	require.NotNil(t, err)
+
+	_, err = c.Decrypt("bm90IGVuY3J5cHRlZA==")
+	require.NotNil(t, err)
+
+	_, err = Cipher{}.Encrypt(rfcSecret)
+	require.ErrorIs(t, err, ErrNoKey)
+
+	_, err = InitCipher([]byte("short"))
+	require.NotNil(t, err)
+}
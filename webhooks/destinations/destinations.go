@@ -0,0 +1,90 @@
+// Package destinations keeps webhooks away from the internal network. Users choose the URLs webhooks are posted to,
+// so hosts are checked when a webhook is added and again for every connection, as DNS records may change
+// in the meantime.
+package destinations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrForbidden rejects loopback, link-local, private and unspecified addresses
+var ErrForbidden = errors.New("address is not allowed")
+
+// Resolver looks up addresses of hosts, it is implemented by *net.Resolver
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Allowed tells whether webhooks may be posted to the address
+func Allowed(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsUnspecified()
+}
+
+// Check resolves the host and fails unless every address of it is allowed
+func Check(ctx context.Context, resolver Resolver, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		return check(ip)
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	for _, a := range addrs {
+		err = check(a.IP)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Control checks the address a connection is made to after the host was resolved, it is used by net.Dialer
+func Control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s is not an IP address", ErrForbidden, host)
+	}
+
+	return check(ip)
+}
+
+// Client returns an HTTP client which connects only to allowed addresses. It does not use proxies, which would
+// make the connections on its behalf, and does not follow redirects, so that 3xx responses fail deliveries.
+func Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: Control}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func check(ip net.IP) error {
+	if !Allowed(ip) {
+		return fmt.Errorf("%w: %s", ErrForbidden, ip)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package destinations
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// resolver returns the addresses of the hosts without looking them up
+type resolver map[string][]string
+
+func (r resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	var s []net.IPAddr
+	for _, a := range addrs {
+		s = append(s, net.IPAddr{IP: net.ParseIP(a)})
+	}
+
+	return s, nil
+}
+
+func TestCheck(t *testing.T) {
+	r := resolver{
+		"example.com":  {"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"},
+		"localhost":    {"127.0.0.1", "::1"},
+		"intranet":     {"10.1.2.3"},
+		"rebinding.io": {"93.184.216.34", "192.168.0.1"},
+	}
+
+	testCases := []struct {
+		host    string
+		allowed bool
+	}{
+		{"example.com", true},
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"localhost", false},
+		{"intranet", false},
+		{"rebinding.io", false},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			err := Check(context.Background(), r, tc.host)
+			if tc.allowed {
+				require.Nil(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrForbidden)
+			}
+		})
+	}
+
+	err := Check(context.Background(), r, "unknown.example")
+	require.NotNil(t, err)
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// The test server listens on a loopback address, which the client refuses to connect to
+	_, err := Client(time.Second).Get(srv.URL)
+	require.ErrorIs(t, err, ErrForbidden)
+
+	err = Client(time.Second).CheckRedirect(httptest.NewRequest("GET", "http://10.0.0.1/", nil), nil)
+	require.ErrorIs(t, err, http.ErrUseLastResponse)
+}
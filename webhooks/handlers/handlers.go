@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/webhooks/service"
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+)
+
+var badRequestReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrBadRequest.ErrorType,
+	ErrorMessage: customErrors.ErrBadRequest.Error(),
+}
+
+var notFoundReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrNotFound.ErrorType,
+	ErrorMessage: customErrors.ErrNotFound.Error(),
+}
+
+var unexpectedReturn = customErrors.ReturnError{
+	ErrorType:    customErrors.ErrUnexpected.ErrorType,
+	ErrorMessage: customErrors.ErrUnexpected.Error(),
+}
+
+type Handler struct {
+	bl  service.BusinessLogicInterface
+	Mux *chi.Mux
+}
+
+func InitHandler(bl service.BusinessLogicInterface) Handler {
+	var h Handler
+
+	r := chi.NewRouter()
+
+	r.Get("/", h.GetWebhooksHandler)
+	r.Post("/", h.AddWebhookHandler)
+	r.Delete("/{id}", h.DeleteWebhookHandler)
+	r.Get("/dead-letters", h.GetDeadLettersHandler)
+	r.Post("/dead-letters/{id}/retry", h.RetryDeliveryHandler)
+
+	h.Mux = r
+
+	h.bl = bl
+	return h
+}
+
+func (h *Handler) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhooks, err := h.bl.GetWebhooks(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(webhooks)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) AddWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var webhook types.Webhook
+	err := json.NewDecoder(r.Body).Decode(&webhook)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	webhook, err = h.bl.AddWebhook(r.Context(), webhook)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(webhook)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (h *Handler) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeadLettersHandler lists deliveries which were given up after too many failed attempts
+func (h *Handler) GetDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	deliveries, err := h.bl.GetDeadLetters(r.Context())
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(deliveries)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// RetryDeliveryHandler queues a dead delivery again
+func (h *Handler) RetryDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	err = h.bl.RetryDelivery(r.Context(), id)
+	if err != nil {
+		errBasedReturn(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func errBasedReturn(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, customErrors.ErrBadRequest):
+		w.WriteHeader(http.StatusBadRequest)
+		err = json.NewEncoder(w).Encode(badRequestReturn)
+		if err != nil {
+			log.Println(err)
+		}
+	case errors.Is(err, customErrors.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		err = json.NewEncoder(w).Encode(notFoundReturn)
+		if err != nil {
+			log.Println(err)
+		}
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		err = json.NewEncoder(w).Encode(unexpectedReturn)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
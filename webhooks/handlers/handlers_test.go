@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/webhooks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddWebhookHandler(t *testing.T) {
+	ti := time.Date(2022, 9, 14, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		testName       string
+		r              *http.Request
+		w              *httptest.ResponseRecorder
+		mockCalled     bool
+		expWebhook     types.Webhook
+		mockDataReturn types.Webhook
+		mockErrReturn  error
+		expJSONReturn  string
+		expStatusCode  int
+	}{
+		{
+			testName:   "AddWebhook_with_events",
+			r:          httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"url":"https://example.com/hook","events":["event.created"]}`)),
+			w:          httptest.NewRecorder(),
+			mockCalled: true,
+			expWebhook: types.Webhook{URL: "https://example.com/hook", Events: []types.WebhookEventType{types.EventCreated}},
+			mockDataReturn: types.Webhook{ID: 1, URL: "https://example.com/hook",
+				Events: []types.WebhookEventType{types.EventCreated}, Secret: "abc", CreatedAt: ti},
+			expJSONReturn: `{"id":1,"url":"https://example.com/hook","events":["event.created"],"secret":"abc","createdAt":"2022-09-14T09:00:00Z"}`,
+			expStatusCode: 200,
+		},
+		{
+			testName:      "AddWebhook_BadRequest",
+			r:             httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"url":"ftp://example.com"}`)),
+			w:             httptest.NewRecorder(),
+			mockCalled:    true,
+			expWebhook:    types.Webhook{URL: "ftp://example.com"},
+			mockErrReturn: customErrors.ErrBadRequest,
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+		{
+			testName:      "AddWebhook_malformed_body",
+			r:             httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"url":`)),
+			w:             httptest.NewRecorder(),
+			expJSONReturn: `{"ErrorType":"BadRequest","ErrorMessage":"the server cannot process the request"}`,
+			expStatusCode: 400,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := webhooks.NewMockBusinessLogicInterface(mockCtrl)
+			if tc.mockCalled {
+				mockBL.EXPECT().AddWebhook(gomock.Any(), tc.expWebhook).Return(tc.mockDataReturn, tc.mockErrReturn)
+			}
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			handler.Mux.ServeHTTP(tc.w, tc.r)
+
+			resp := tc.w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestDeleteWebhookHandler(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		r             *http.Request
+		w             *httptest.ResponseRecorder
+		expID         int64
+		mockErrReturn error
+		expJSONReturn string
+		expStatusCode int
+	}{
+		{
+			testName:      "DeleteWebhook_positive_paramValue_5",
+			r:             httptest.NewRequest("DELETE", "/5", nil),
+			w:             httptest.NewRecorder(),
+			expID:         5,
+			expStatusCode: 204,
+		},
+		{
+			testName:      "DeleteWebhook_NotFound",
+			r:             httptest.NewRequest("DELETE", "/450", nil),
+			w:             httptest.NewRecorder(),
+			expID:         450,
+			mockErrReturn: customErrors.ErrNotFound,
+			expJSONReturn: `{"ErrorType":"NotFound","ErrorMessage":"the server cannot find the requested resource"}`,
+			expStatusCode: 404,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			// mock business logic
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockBL := webhooks.NewMockBusinessLogicInterface(mockCtrl)
+			mockBL.EXPECT().DeleteWebhook(gomock.Any(), tc.expID).Return(tc.mockErrReturn)
+
+			// create handler with mocks
+			handler := InitHandler(mockBL)
+			handler.Mux.ServeHTTP(tc.w, tc.r)
+
+			resp := tc.w.Result()
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.expJSONReturn != "" {
+				require.JSONEq(t, tc.expJSONReturn, string(data), "JSON data should to be equal")
+			}
+
+			require.Equal(t, tc.expStatusCode, resp.StatusCode, "Wrong status code returned")
+		})
+	}
+}
+
+func TestDeadLettersHandlers(t *testing.T) {
+	ti := time.Date(2022, 9, 14, 9, 0, 0, 0, time.UTC)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockBL := webhooks.NewMockBusinessLogicInterface(mockCtrl)
+	mockBL.EXPECT().GetDeadLetters(gomock.Any()).Return([]types.WebhookDelivery{{ID: 7, WebhookID: 1, MessageID: 3,
+		Type: types.EventDeleted, Data: []byte(`{"id":3}`), CreatedAt: ti, Status: types.DeliveryDead, Attempts: 8,
+		LastError: "webhook responded with 500 Internal Server Error", URL: "https://example.com", Secret: "abc"}}, nil)
+	mockBL.EXPECT().RetryDelivery(gomock.Any(), int64(7)).Return(nil)
+	mockBL.EXPECT().RetryDelivery(gomock.Any(), int64(8)).Return(customErrors.ErrNotFound)
+
+	handler := InitHandler(mockBL)
+
+	w := httptest.NewRecorder()
+	handler.Mux.ServeHTTP(w, httptest.NewRequest("GET", "/dead-letters", nil))
+
+	require.Equal(t, 200, w.Code)
+	require.JSONEq(t, `[{"id":7,"webhookId":1,"messageId":3,"type":"event.deleted","data":{"id":3},
+		"createdAt":"2022-09-14T09:00:00Z","status":"dead","attempts":8,
+		"lastError":"webhook responded with 500 Internal Server Error"}]`, w.Body.String(),
+		"secrets of the endpoints should not be returned")
+
+	w = httptest.NewRecorder()
+	handler.Mux.ServeHTTP(w, httptest.NewRequest("POST", "/dead-letters/7/retry", nil))
+	require.Equal(t, 202, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.Mux.ServeHTTP(w, httptest.NewRequest("POST", "/dead-letters/8/retry", nil))
+	require.Equal(t, 404, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.Mux.ServeHTTP(w, httptest.NewRequest("POST", "/dead-letters/x/retry", nil))
+	require.Equal(t, 400, w.Code)
+}
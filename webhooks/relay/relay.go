@@ -0,0 +1,173 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/webhooks/destinations"
+	"github.com/bubo-py/McK/webhooks/service"
+	"github.com/bubo-py/McK/worker"
+)
+
+// Config of the relay, the outbox is also fanned out in batches and messages which run out of attempts are moved
+// to the dead-letter list
+type Config struct {
+	worker.Config
+
+	// Timeout limits a single request to a webhook
+	Timeout time.Duration
+}
+
+var DefaultConfig = Config{
+	Config: worker.Config{
+		Interval:    10 * time.Second,
+		Lease:       time.Minute,
+		BatchSize:   100,
+		MaxAttempts: 8,
+	},
+	Timeout: 10 * time.Second,
+}
+
+// Headers of webhook requests, the signature is an HMAC-SHA256 of the timestamp and the body, see Sign
+const (
+	EventHeader     = "X-McK-Event"
+	DeliveryHeader  = "X-McK-Delivery"
+	TimestampHeader = "X-McK-Timestamp"
+	SignatureHeader = "X-McK-Signature"
+)
+
+// payload is the body of a webhook request
+type payload struct {
+	ID        int64                  `json:"id"`
+	Type      types.WebhookEventType `json:"type"`
+	CreatedAt time.Time              `json:"createdAt"`
+	Data      json.RawMessage        `json:"data"`
+}
+
+// Relay moves messages from the outbox to the webhooks subscribed to them. Deliveries are claimed in the database,
+// so any number of replicas can run a relay without sending a message twice at the same time.
+type Relay struct {
+	bl     service.RelayInterface
+	client *http.Client
+	config Config
+}
+
+func InitRelay(bl service.RelayInterface, config Config) Relay {
+	var r Relay
+	r.bl = bl
+	r.client = destinations.Client(config.Timeout)
+	r.config = config
+	return r
+}
+
+// Run polls the outbox and due deliveries until the context is cancelled
+func (r Relay) Run(ctx context.Context) {
+	worker.Run(ctx, "Webhook relay", r.config.Interval, r.Relay)
+}
+
+// Relay fans out the outbox and sends the deliveries due at now, it returns the number of delivered messages
+func (r Relay) Relay(ctx context.Context, now time.Time) (int, error) {
+	delivered := 0
+
+	for {
+		count, err := r.bl.FanOutMessages(ctx, r.config.BatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		if count < r.config.BatchSize {
+			break
+		}
+	}
+
+	for {
+		deliveries, err := r.bl.ClaimDeliveries(ctx, now, r.config.Lease, r.config.BatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		if len(deliveries) == 0 {
+			return delivered, nil
+		}
+
+		for _, d := range deliveries {
+			d = r.deliver(ctx, d, now)
+			if d.Status == types.DeliveryDelivered {
+				delivered++
+			}
+
+			err = r.bl.FinishDelivery(ctx, d)
+			if err != nil {
+				return delivered, err
+			}
+		}
+	}
+}
+
+// deliver sends the delivery and sets its state according to the outcome
+func (r Relay) deliver(ctx context.Context, d types.WebhookDelivery, now time.Time) types.WebhookDelivery {
+	err := r.send(ctx, d, now)
+	if err == nil {
+		deliveredAt := now.UTC()
+		d.Status = types.DeliveryDelivered
+		d.LastError = ""
+		d.DeliveredAt = &deliveredAt
+		return d
+	}
+
+	d.LastError = err.Error()
+
+	if d.Attempts >= r.config.MaxAttempts {
+		d.Status = types.DeliveryDead
+		return d
+	}
+
+	d.Status = types.DeliveryPending
+	d.NextAttempt = now.Add(worker.RetryDelay(r.config.Interval, d.Attempts))
+
+	return d
+}
+
+// send posts the signed message to the webhook, any response other than 2xx fails the delivery. Redirects are not
+// followed and connections to the internal network are refused, see destinations.Client.
+func (r Relay) send(ctx context.Context, d types.WebhookDelivery, now time.Time) error {
+	body, err := json.Marshal(payload{ID: d.ID, Type: d.Type, CreatedAt: d.CreatedAt, Data: d.Data})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := now.Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(d.Type))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(d.Secret, timestamp, body))
+
+	return worker.Send(r.client, req)
+}
+
+// Sign returns the signature of a webhook request, an HMAC-SHA256 with the secret of the webhook over
+// the timestamp and the body joined with a dot. Receivers should compare it in constant time and reject
+// old timestamps to prevent replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
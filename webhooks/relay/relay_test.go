@@ -0,0 +1,172 @@
+package relay
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+	"github.com/stretchr/testify/require"
+)
+
+// queue keeps deliveries in memory the way the database does for the relay
+type queue struct {
+	outbox     []types.WebhookDelivery
+	deliveries map[int64]types.WebhookDelivery
+	locked     map[int64]time.Time
+}
+
+func (q *queue) FanOutMessages(ctx context.Context, limit int) (int, error) {
+	n := 0
+	for len(q.outbox) > 0 && n < limit {
+		d := q.outbox[0]
+		q.outbox = q.outbox[1:]
+		d.Status = types.DeliveryPending
+		q.deliveries[d.ID] = d
+		n++
+	}
+
+	return n, nil
+}
+
+func (q *queue) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]types.WebhookDelivery, error) {
+	var s []types.WebhookDelivery
+
+	for id := int64(1); id <= int64(len(q.deliveries)) && len(s) < limit; id++ {
+		d := q.deliveries[id]
+		if d.Status != types.DeliveryPending || d.NextAttempt.After(now) || q.locked[id].After(now) {
+			continue
+		}
+
+		d.Attempts++
+		q.deliveries[id] = d
+		q.locked[id] = now.Add(lease)
+		s = append(s, d)
+	}
+
+	return s, nil
+}
+
+func (q *queue) FinishDelivery(ctx context.Context, d types.WebhookDelivery) error {
+	q.deliveries[d.ID] = d
+	delete(q.locked, d.ID)
+
+	return nil
+}
+
+type receiver struct {
+	mu      sync.Mutex
+	fail    bool
+	headers []http.Header
+	bodies  []string
+}
+
+func (h *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	h.headers = append(h.headers, r.Header)
+	h.bodies = append(h.bodies, string(body))
+
+	if h.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	ti := time.Date(2022, 9, 14, 9, 0, 0, 0, time.UTC)
+
+	hook := &receiver{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	q := &queue{deliveries: make(map[int64]types.WebhookDelivery), locked: make(map[int64]time.Time)}
+	for id := int64(1); id <= 2; id++ {
+		q.outbox = append(q.outbox, types.WebhookDelivery{ID: id, MessageID: 10 + id, Type: types.EventCreated,
+			Data: []byte(`{"id":` + strconv.FormatInt(id, 10) + `}`), CreatedAt: ti, URL: server.URL, Secret: "secret"})
+	}
+
+	config := DefaultConfig
+	config.Interval = time.Minute
+	config.BatchSize = 1
+	config.MaxAttempts = 3
+	r := InitRelay(q, config)
+
+	// The test server listens on a loopback address, which the client of the relay refuses to connect to
+	r.client = server.Client()
+
+	delivered, err := r.Relay(ctx, ti)
+	require.Nil(t, err)
+	require.Equal(t, 2, delivered)
+	require.Len(t, hook.bodies, 2)
+
+	require.Equal(t, `{"id":1,"type":"event.created","createdAt":"2022-09-14T09:00:00Z","data":{"id":1}}`,
+		hook.bodies[0])
+	require.Equal(t, "event.created", hook.headers[0].Get(EventHeader))
+	require.Equal(t, "1", hook.headers[0].Get(DeliveryHeader))
+	require.Equal(t, strconv.FormatInt(ti.Unix(), 10), hook.headers[0].Get(TimestampHeader))
+	require.Equal(t, Sign("secret", ti.Unix(), []byte(hook.bodies[0])), hook.headers[0].Get(SignatureHeader))
+	require.Equal(t, types.DeliveryDelivered, q.deliveries[1].Status)
+
+	// Failed deliveries are retried with a growing delay, and given up after the last attempt
+	hook.fail = true
+	q.outbox = append(q.outbox, types.WebhookDelivery{ID: 3, Type: types.EventDeleted, Data: []byte(`{"id":3}`),
+		CreatedAt: ti, URL: server.URL, Secret: "secret"})
+
+	delivered, err = r.Relay(ctx, ti)
+	require.Nil(t, err)
+	require.Equal(t, 0, delivered)
+	require.Equal(t, types.DeliveryPending, q.deliveries[3].Status)
+	require.Equal(t, ti.Add(time.Minute), q.deliveries[3].NextAttempt)
+	require.Equal(t, "webhook responded with 500 Internal Server Error", q.deliveries[3].LastError)
+
+	_, err = r.Relay(ctx, ti.Add(30*time.Second))
+	require.Nil(t, err)
+	require.Equal(t, 1, q.deliveries[3].Attempts, "deliveries should not be retried before their delay")
+
+	_, err = r.Relay(ctx, ti.Add(time.Minute))
+	require.Nil(t, err)
+	require.Equal(t, ti.Add(3*time.Minute), q.deliveries[3].NextAttempt)
+
+	_, err = r.Relay(ctx, ti.Add(3*time.Minute))
+	require.Nil(t, err)
+	require.Equal(t, types.DeliveryDead, q.deliveries[3].Status)
+	require.Equal(t, 3, q.deliveries[3].Attempts)
+	require.Len(t, hook.bodies, 5)
+}
+
+func TestRelayInternalAddress(t *testing.T) {
+	ctx := context.Background()
+	ti := time.Date(2022, 9, 14, 9, 0, 0, 0, time.UTC)
+
+	hook := &receiver{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	q := &queue{deliveries: make(map[int64]types.WebhookDelivery), locked: make(map[int64]time.Time)}
+	q.outbox = append(q.outbox, types.WebhookDelivery{ID: 1, Type: types.EventCreated, Data: []byte(`{"id":1}`),
+		CreatedAt: ti, URL: server.URL, Secret: "secret"})
+
+	r := InitRelay(q, DefaultConfig)
+
+	delivered, err := r.Relay(ctx, ti)
+	require.Nil(t, err)
+	require.Equal(t, 0, delivered)
+	require.Empty(t, hook.bodies)
+	require.Equal(t, types.DeliveryPending, q.deliveries[1].Status)
+	require.Contains(t, q.deliveries[1].LastError, "address is not allowed")
+}
+
+func TestSign(t *testing.T) {
+	// Computed with: printf '1663146000.{}' | openssl dgst -sha256 -hmac secret
+	require.Equal(t, "sha256=732b0fc046c125c2e5144e78ee8e90178eb6aafe9891f16400cfee8a773262c2",
+		Sign("secret", 1663146000, []byte("{}")))
+}
@@ -0,0 +1,356 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jackc/tern/migrate"
+)
+
+//go:embed migrations
+var f embed.FS
+
+type webhookDb struct {
+	ID        int64     `db:"id"`
+	URL       string    `db:"url"`
+	Events    []string  `db:"events"`
+	Secret    string    `db:"secret"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type deliveryDb struct {
+	ID          int64      `db:"id"`
+	WebhookID   int64      `db:"webhook_id"`
+	MessageID   int64      `db:"message_id"`
+	Type        string     `db:"type"`
+	Payload     string     `db:"payload"`
+	CreatedAt   time.Time  `db:"created_at"`
+	Status      string     `db:"status"`
+	Attempts    int        `db:"attempts"`
+	LastError   string     `db:"last_error"`
+	NextAttempt time.Time  `db:"next_attempt"`
+	DeliveredAt *time.Time `db:"delivered_at"`
+	URL         string     `db:"url"`
+	Secret      string     `db:"secret"`
+}
+
+var deliveryColumns = []string{"webhook_deliveries.id", "webhook_id", "message_id", "type", "payload::text AS payload",
+	"webhook_deliveries.created_at", "status", "attempts", "last_error", "next_attempt", "delivered_at"}
+
+type Db struct {
+	pool *pgxpool.Pool
+}
+
+func Init(ctx context.Context, connString string) (Db, error) {
+	var pg Db
+
+	dbPool, err := pgxpool.Connect(ctx, connString)
+	if err != nil {
+		return pg, fmt.Errorf("%w: database initialization error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	pg.pool = dbPool
+
+	return pg, nil
+}
+
+func (pg Db) migrate(ctx context.Context, mFS embed.FS, rootDir, table string) error {
+	c, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Release()
+
+	opts := &migrate.MigratorOptions{
+		MigratorFS: adapterFS{
+			FS:      mFS,
+			rootDir: rootDir,
+		},
+	}
+	migrator, err := migrate.NewMigratorEx(ctx, c.Conn(), table, opts)
+	if err != nil {
+		return err
+	}
+	if err := migrator.LoadMigrations(rootDir); err != nil {
+		return err
+	}
+	if err := migrator.Migrate(ctx); err != nil {
+		return err
+	}
+
+	_, err = migrator.GetCurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func RunMigration(ctx context.Context, db Db) error {
+	err := db.migrate(ctx, f, "migrations", "webhooks_migration")
+	if err != nil {
+		return fmt.Errorf("%w: database migration error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	log.Println("Migrations from webhooks domain run correctly")
+	return nil
+}
+
+// GetWebhooks returns webhooks of the user, their secrets are left out
+func (pg Db) GetWebhooks(ctx context.Context, login string) ([]types.Webhook, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var s []types.Webhook
+	var webhooks []*webhookDb
+
+	sb.Select("id", "url", "events", "created_at")
+	sb.From("webhooks")
+	sb.Where(sb.Equal("owner_id", ownerID(login)))
+	sb.OrderBy("id")
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.pool, &webhooks, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, w := range webhooks {
+		s = append(s, toWebhook(*w))
+	}
+
+	return s, nil
+}
+
+func (pg Db) AddWebhook(ctx context.Context, w types.Webhook, login string) (types.Webhook, error) {
+	ib := sqlbuilder.PostgreSQL.NewInsertBuilder()
+	var added webhookDb
+
+	events := make([]string, 0, len(w.Events))
+	for _, t := range w.Events {
+		events = append(events, string(t))
+	}
+
+	ib.InsertInto("webhooks")
+	ib.Cols("owner_id", "url", "events", "secret", "created_at")
+	ib.Values(ownerID(login), w.URL, events, w.Secret, w.CreatedAt.UTC())
+
+	ib.SQL("RETURNING id, url, events, secret, created_at")
+
+	q, args := ib.Build()
+
+	err := pgxscan.Get(ctx, pg.pool, &added, q, args...)
+	if err != nil {
+		return w, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return toWebhook(added), nil
+}
+
+func (pg Db) DeleteWebhook(ctx context.Context, id int64, login string) error {
+	db := sqlbuilder.PostgreSQL.NewDeleteBuilder()
+
+	db.DeleteFrom("webhooks")
+	db.Where(db.Equal("id", id), db.Equal("owner_id", ownerID(login)))
+
+	q, args := db.Build()
+
+	tag, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// GetDeadLetters returns deliveries to webhooks of the user which failed too many times, the latest first
+func (pg Db) GetDeadLetters(ctx context.Context, login string) ([]types.WebhookDelivery, error) {
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	var s []types.WebhookDelivery
+	var deliveries []*deliveryDb
+
+	sb.Select(deliveryColumns...)
+	sb.From("webhook_deliveries")
+	sb.Join("webhooks", "webhooks.id = webhook_deliveries.webhook_id")
+	sb.Where(sb.Equal("status", string(types.DeliveryDead)), sb.Equal("webhooks.owner_id", ownerID(login)))
+	sb.OrderBy("webhook_deliveries.id").Desc()
+
+	q, args := sb.Build()
+
+	err := pgxscan.Select(ctx, pg.pool, &deliveries, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, d := range deliveries {
+		s = append(s, toDelivery(*d))
+	}
+
+	return s, nil
+}
+
+// RetryDelivery puts a dead delivery back into the queue with a fresh budget of attempts
+func (pg Db) RetryDelivery(ctx context.Context, id int64, now time.Time, login string) error {
+	q, args := sqlbuilder.WithFlavor(sqlbuilder.Buildf(`UPDATE webhook_deliveries
+	SET status = %v, attempts = 0, last_error = '', next_attempt = %v, locked_until = NULL
+	WHERE id = %v AND status = %v
+	AND webhook_id IN (SELECT id FROM webhooks WHERE owner_id = %v)`,
+		string(types.DeliveryPending), now.UTC(), id, string(types.DeliveryDead), ownerID(login)),
+		sqlbuilder.PostgreSQL).Build()
+
+	tag, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// FanOutMessages takes the oldest messages from the outbox and queues a delivery for every webhook subscribed
+// to them in a single statement, so that messages are never lost nor queued twice. Messages locked by another
+// relay are skipped.
+func (pg Db) FanOutMessages(ctx context.Context, now time.Time, limit int) (int, error) {
+	var count int
+
+	q, args := sqlbuilder.WithFlavor(sqlbuilder.Buildf(`WITH messages AS (
+		DELETE FROM webhook_outbox
+		WHERE id IN (
+			SELECT id FROM webhook_outbox
+			ORDER BY id
+			LIMIT %v
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, owner_id, type, payload, created_at
+	), deliveries AS (
+		INSERT INTO webhook_deliveries (webhook_id, message_id, type, payload, created_at, next_attempt)
+		SELECT webhooks.id, messages.id, messages.type, messages.payload, messages.created_at, %v
+		FROM messages
+		JOIN webhooks ON webhooks.owner_id = messages.owner_id
+		WHERE cardinality(webhooks.events) = 0 OR messages.type = ANY(webhooks.events)
+	)
+	SELECT count(*) FROM messages`, limit, now.UTC()), sqlbuilder.PostgreSQL).Build()
+
+	err := pg.pool.QueryRow(ctx, q, args...).Scan(&count)
+	if err != nil {
+		return count, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	return count, nil
+}
+
+// ClaimDeliveries locks due deliveries until lockedUntil and counts the attempt, together with the endpoints
+// they are sent to. Deliveries whose relay crashed are claimed again once their lock expires.
+func (pg Db) ClaimDeliveries(ctx context.Context, now, lockedUntil time.Time,
+	limit int) ([]types.WebhookDelivery, error) {
+	var s []types.WebhookDelivery
+	var deliveries []*deliveryDb
+
+	q, args := sqlbuilder.WithFlavor(sqlbuilder.Buildf(`WITH claimed AS (
+		UPDATE webhook_deliveries SET attempts = attempts + 1, locked_until = %v
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = %v AND next_attempt <= %v AND (locked_until IS NULL OR locked_until <= %v)
+			ORDER BY next_attempt
+			LIMIT %v
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, webhook_id, message_id, type, payload::text AS payload, created_at, status, attempts,
+			last_error, next_attempt, delivered_at
+	)
+	SELECT claimed.*, webhooks.url, webhooks.secret FROM claimed
+	JOIN webhooks ON webhooks.id = claimed.webhook_id
+	ORDER BY claimed.next_attempt, claimed.id`,
+		lockedUntil.UTC(), string(types.DeliveryPending), now.UTC(), now.UTC(), limit), sqlbuilder.PostgreSQL).Build()
+
+	err := pgxscan.Select(ctx, pg.pool, &deliveries, q, args...)
+	if err != nil {
+		return s, fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	for _, d := range deliveries {
+		s = append(s, toDelivery(*d))
+	}
+
+	return s, nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt and releases the delivery
+func (pg Db) UpdateDelivery(ctx context.Context, d types.WebhookDelivery) error {
+	ub := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+
+	ub.Update("webhook_deliveries")
+	ub.Set(
+		ub.Assign("status", string(d.Status)),
+		ub.Assign("last_error", d.LastError),
+		ub.Assign("next_attempt", d.NextAttempt.UTC()),
+		ub.Assign("delivered_at", d.DeliveredAt),
+		ub.Assign("locked_until", nil),
+	)
+	ub.Where(ub.Equal("id", d.ID))
+
+	q, args := ub.Build()
+
+	tag, err := pg.pool.Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("%w: SQL query error: %v", customErrors.ErrUnexpected, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return customErrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func toWebhook(w webhookDb) types.Webhook {
+	webhook := types.Webhook{
+		ID:        w.ID,
+		URL:       w.URL,
+		Secret:    w.Secret,
+		CreatedAt: w.CreatedAt,
+	}
+
+	for _, t := range w.Events {
+		webhook.Events = append(webhook.Events, types.WebhookEventType(t))
+	}
+
+	return webhook
+}
+
+func toDelivery(d deliveryDb) types.WebhookDelivery {
+	return types.WebhookDelivery{
+		ID:          d.ID,
+		WebhookID:   d.WebhookID,
+		MessageID:   d.MessageID,
+		Type:        types.WebhookEventType(d.Type),
+		Data:        []byte(d.Payload),
+		CreatedAt:   d.CreatedAt,
+		Status:      types.DeliveryStatus(d.Status),
+		Attempts:    d.Attempts,
+		LastError:   d.LastError,
+		NextAttempt: d.NextAttempt,
+		DeliveredAt: d.DeliveredAt,
+		URL:         d.URL,
+		Secret:      d.Secret,
+	}
+}
+
+// ownerID resolves a login into the users.id it belongs to, so that webhooks are scoped to their owner
+func ownerID(login string) sqlbuilder.Builder {
+	return sqlbuilder.Buildf("(SELECT id FROM users WHERE login = %v)", login)
+}
@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bubo-py/McK/customErrors"
+	eventsPostgres "github.com/bubo-py/McK/events/repositories/postgres"
+	"github.com/bubo-py/McK/types"
+	usersPostgres "github.com/bubo-py/McK/users/repositories/postgres"
+)
+
+const (
+	login      = "webhooks-owner"
+	otherLogin = "webhooks-other"
+)
+
+func TestMain(m *testing.M) {
+	// Setup
+	ctx := context.Background()
+
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		log.Fatalf("Could not initialize database: %v", err)
+	}
+
+	// Webhooks reference their owners and read the outbox of the events domain, both have to be migrated first
+	usersDb, err := usersPostgres.Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		log.Fatalf("Could not initialize database: %v", err)
+	}
+	_ = usersPostgres.RunMigration(ctx, usersDb)
+
+	eventsDb, err := eventsPostgres.Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		log.Fatalf("Could not initialize database: %v", err)
+	}
+	_ = eventsPostgres.RunMigration(ctx, eventsDb)
+
+	_, _ = db.pool.Exec(ctx, "DROP TABLE webhook_deliveries")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE webhooks")
+	_, _ = db.pool.Exec(ctx, "DROP TABLE webhooks_migration")
+	_ = RunMigration(ctx, db)
+
+	for _, l := range []string{login, otherLogin} {
+		_, _ = db.pool.Exec(ctx, "INSERT INTO users (login, password, timezone) VALUES ($1, 'hash', 'UTC') ON CONFLICT DO NOTHING", l)
+	}
+
+	code := m.Run()
+
+	// Tear down
+
+	os.Exit(code)
+}
+
+func TestPostgresDb_Webhooks(t *testing.T) {
+	ti := time.Date(2022, 9, 14, 9, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	w, err := db.AddWebhook(ctx, types.Webhook{URL: "https://example.com/hook", Events: []types.WebhookEventType{
+		types.EventCreated}, Secret: "secret", CreatedAt: ti}, login)
+	if err != nil || w.ID == 0 || w.Secret != "secret" || len(w.Events) != 1 {
+		t.Fatalf("Failed to add a webhook: got: %v, error: %v", w, err)
+	}
+
+	webhooks, err := db.GetWebhooks(ctx, login)
+	if err != nil || len(webhooks) != 1 || webhooks[0].Secret != "" || webhooks[0].URL != w.URL {
+		t.Errorf("Failed to fetch webhooks without their secrets: got: %v, error: %v", webhooks, err)
+	}
+
+	webhooks, err = db.GetWebhooks(ctx, otherLogin)
+	if err != nil || len(webhooks) != 0 {
+		t.Errorf("Webhooks of another user should not be returned: got: %v, error: %v", webhooks, err)
+	}
+
+	err = db.DeleteWebhook(ctx, w.ID, otherLogin)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Webhooks of another user should not be deleted: got: %v", err)
+	}
+
+	err = db.DeleteWebhook(ctx, w.ID, login)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = db.DeleteWebhook(ctx, w.ID, login)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Deleting a webhook twice should fail: got: %v", err)
+	}
+}
+
+func TestPostgresDb_Deliveries(t *testing.T) {
+	ti := time.Date(2022, 9, 14, 9, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	db, err := Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	eventsDb, err := eventsPostgres.Init(ctx, os.Getenv("PGURL"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	all, err := db.AddWebhook(ctx, types.Webhook{URL: "https://example.com/all", Secret: "a", CreatedAt: ti}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = db.AddWebhook(ctx, types.Webhook{URL: "https://example.com/deleted", Secret: "d", CreatedAt: ti,
+		Events: []types.WebhookEventType{types.EventDeleted}}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = eventsDb.AddOutboxMessage(ctx, types.OutboxMessage{Type: types.EventCreated, Data: []byte(`{"id":1}`),
+		CreatedAt: ti}, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Only the webhook subscribed to all events receives the message
+	count, err := db.FanOutMessages(ctx, ti, 100)
+	if err != nil || count != 1 {
+		t.Errorf("Failed to fan out the outbox: got: %v, error: %v", count, err)
+	}
+
+	count, err = db.FanOutMessages(ctx, ti, 100)
+	if err != nil || count != 0 {
+		t.Errorf("Messages should be taken from the outbox once: got: %v, error: %v", count, err)
+	}
+
+	claimed, err := db.ClaimDeliveries(ctx, ti, ti.Add(time.Minute), 10)
+	if err != nil || len(claimed) != 1 || claimed[0].WebhookID != all.ID || claimed[0].Secret != "a" ||
+		claimed[0].Attempts != 1 || string(claimed[0].Data) != `{"id": 1}` {
+		t.Fatalf("Failed to claim a due delivery: got: %v, error: %v", claimed, err)
+	}
+
+	again, err := db.ClaimDeliveries(ctx, ti, ti.Add(time.Minute), 10)
+	if err != nil || len(again) != 0 {
+		t.Errorf("Claimed deliveries should be locked: got: %v, error: %v", again, err)
+	}
+
+	claimed[0].Status = types.DeliveryDead
+	claimed[0].LastError = "webhook responded with 500 Internal Server Error"
+
+	err = db.UpdateDelivery(ctx, claimed[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	dead, err := db.GetDeadLetters(ctx, otherLogin)
+	if err != nil || len(dead) != 1 || dead[0].LastError != claimed[0].LastError {
+		t.Errorf("Failed to fetch dead letters: got: %v, error: %v", dead, err)
+	}
+
+	err = db.RetryDelivery(ctx, claimed[0].ID, ti, login)
+	if err != customErrors.ErrNotFound {
+		t.Errorf("Deliveries of another user should not be retried: got: %v", err)
+	}
+
+	err = db.RetryDelivery(ctx, claimed[0].ID, ti, otherLogin)
+	if err != nil {
+		t.Error(err)
+	}
+
+	claimed, err = db.ClaimDeliveries(ctx, ti.Add(time.Hour), ti.Add(2*time.Hour), 10)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Errorf("Retried delivery should be claimed with a fresh budget of attempts: got: %v, error: %v", claimed, err)
+	}
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/bubo-py/McK/types"
+)
+
+//go:generate mockgen --build_flags=--mod=mod -destination=mocks/mockDatabase.go -package=mocks github.com/bubo-py/McK/webhooks/repositories WebhookRepository
+
+type WebhookRepository interface {
+	GetWebhooks(ctx context.Context, login string) ([]types.Webhook, error)
+	AddWebhook(ctx context.Context, w types.Webhook, login string) (types.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64, login string) error
+	GetDeadLetters(ctx context.Context, login string) ([]types.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, id int64, now time.Time, login string) error
+
+	// FanOutMessages moves messages from the outbox of the events domain to deliveries of the webhooks
+	// subscribed to them, it returns the number of messages taken from the outbox
+	FanOutMessages(ctx context.Context, now time.Time, limit int) (int, error)
+	ClaimDeliveries(ctx context.Context, now, lockedUntil time.Time, limit int) ([]types.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, d types.WebhookDelivery) error
+}
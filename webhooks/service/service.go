@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/webhooks/destinations"
+	"github.com/bubo-py/McK/webhooks/repositories"
+)
+
+//go:generate mockgen -destination=../mockService.go -package=webhooks github.com/bubo-py/McK/webhooks/service BusinessLogicInterface
+
+type BusinessLogicInterface interface {
+	GetWebhooks(ctx context.Context) ([]types.Webhook, error)
+	AddWebhook(ctx context.Context, w types.Webhook) (types.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	GetDeadLetters(ctx context.Context) ([]types.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, id int64) error
+}
+
+// RelayInterface is used by the webhook relay, unlike BusinessLogicInterface it works on webhooks of all users
+type RelayInterface interface {
+	FanOutMessages(ctx context.Context, limit int) (int, error)
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]types.WebhookDelivery, error)
+	FinishDelivery(ctx context.Context, d types.WebhookDelivery) error
+}
+
+// maxURLLength limits the length of a webhook URL
+const maxURLLength = 2048
+
+type BusinessLogic struct {
+	db       repositories.WebhookRepository
+	resolver destinations.Resolver
+}
+
+// InitBusinessLogic takes the resolver hosts of webhooks are looked up with, usually net.DefaultResolver
+func InitBusinessLogic(db repositories.WebhookRepository, resolver destinations.Resolver) BusinessLogic {
+	var bl BusinessLogic
+	bl.db = db
+	bl.resolver = resolver
+	return bl
+}
+
+func (bl BusinessLogic) GetWebhooks(ctx context.Context) ([]types.Webhook, error) {
+	login, ok := contextHelpers.RetrieveLoginFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+	}
+
+	return bl.db.GetWebhooks(ctx, login)
+}
+
+// AddWebhook subscribes the URL to changes of the user's events. The returned webhook carries the secret
+// its deliveries are signed with, it is not shown again.
+func (bl BusinessLogic) AddWebhook(ctx context.Context, w types.Webhook) (types.Webhook, error) {
+	login, ok := contextHelpers.RetrieveLoginFromContext(ctx)
+	if !ok {
+		return w, fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+	}
+
+	err := bl.validateURL(ctx, w.URL)
+	if err != nil {
+		return w, err
+	}
+
+	w.Events, err = validateEvents(w.Events)
+	if err != nil {
+		return w, err
+	}
+
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return w, fmt.Errorf("%w: failed to generate webhook secret: %v", customErrors.ErrUnexpected, err)
+	}
+
+	w.ID = 0
+	w.Secret = hex.EncodeToString(b)
+	w.CreatedAt = time.Now().UTC()
+
+	return bl.db.AddWebhook(ctx, w, login)
+}
+
+func (bl BusinessLogic) DeleteWebhook(ctx context.Context, id int64) error {
+	login, ok := contextHelpers.RetrieveLoginFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+	}
+
+	return bl.db.DeleteWebhook(ctx, id, login)
+}
+
+// GetDeadLetters returns deliveries to the user's webhooks which were given up after too many failed attempts
+func (bl BusinessLogic) GetDeadLetters(ctx context.Context) ([]types.WebhookDelivery, error) {
+	login, ok := contextHelpers.RetrieveLoginFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+	}
+
+	return bl.db.GetDeadLetters(ctx, login)
+}
+
+// RetryDelivery queues a dead delivery again, it is sent by the relay on its next poll
+func (bl BusinessLogic) RetryDelivery(ctx context.Context, id int64) error {
+	login, ok := contextHelpers.RetrieveLoginFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: failed to fetch login from context", customErrors.ErrUnexpected)
+	}
+
+	return bl.db.RetryDelivery(ctx, id, time.Now(), login)
+}
+
+// FanOutMessages queues deliveries of messages written to the outbox by the events domain
+func (bl BusinessLogic) FanOutMessages(ctx context.Context, limit int) (int, error) {
+	return bl.db.FanOutMessages(ctx, time.Now(), limit)
+}
+
+// ClaimDeliveries claims due deliveries for the lease, so that no other relay sends them in the meantime
+func (bl BusinessLogic) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]types.WebhookDelivery, error) {
+	return bl.db.ClaimDeliveries(ctx, now, now.Add(lease), limit)
+}
+
+// FinishDelivery records the outcome of a claimed delivery
+func (bl BusinessLogic) FinishDelivery(ctx context.Context, d types.WebhookDelivery) error {
+	return bl.db.UpdateDelivery(ctx, d)
+}
+
+// validateURL accepts http and https URLs of hosts outside of the internal network
+func (bl BusinessLogic) validateURL(ctx context.Context, s string) error {
+	if len(s) > maxURLLength {
+		return fmt.Errorf("%w: webhook URL should contain up to %d characters", customErrors.ErrBadRequest,
+			maxURLLength)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: webhook URL should be an absolute http or https URL", customErrors.ErrBadRequest)
+	}
+
+	err = destinations.Check(ctx, bl.resolver, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: webhook URL should point to a public host: %v", customErrors.ErrBadRequest, err)
+	}
+
+	return nil
+}
+
+// validateEvents checks the event types a webhook subscribes to and drops duplicates
+func validateEvents(events []types.WebhookEventType) ([]types.WebhookEventType, error) {
+	var s []types.WebhookEventType
+	seen := make(map[types.WebhookEventType]bool)
+
+	for _, t := range events {
+		if !isEventType(t) {
+			return nil, fmt.Errorf("%w: unknown webhook event type %q", customErrors.ErrBadRequest, t)
+		}
+
+		if !seen[t] {
+			seen[t] = true
+			s = append(s, t)
+		}
+	}
+
+	return s, nil
+}
+
+func isEventType(t types.WebhookEventType) bool {
+	for _, known := range types.WebhookEventTypes {
+		if t == known {
+			return true
+		}
+	}
+
+	return false
+}
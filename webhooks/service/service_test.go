@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/bubo-py/McK/contextHelpers"
+	"github.com/bubo-py/McK/customErrors"
+	"github.com/bubo-py/McK/types"
+	"github.com/bubo-py/McK/webhooks/repositories/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// resolver returns the addresses of the hosts without looking them up
+type resolver map[string]string
+
+func (r resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addr, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return []net.IPAddr{{IP: net.ParseIP(addr)}}, nil
+}
+
+var hosts = resolver{"example.com": "93.184.216.34", "localhost": "127.0.0.1", "intranet": "10.0.0.8"}
+
+func TestAddWebhook(t *testing.T) {
+	ctx := contextHelpers.WriteLoginToContext(context.Background(), "hello")
+
+	testCases := []struct {
+		testName  string
+		webhook   types.Webhook
+		expEvents []types.WebhookEventType
+		expError  error
+	}{
+		{
+			testName: "AddWebhookAllEvents",
+			webhook:  types.Webhook{URL: "https://example.com/hook"},
+		},
+		{
+			testName: "AddWebhookDuplicateEvents",
+			webhook: types.Webhook{URL: "http://example.com:8080/hook", Events: []types.WebhookEventType{
+				types.EventDeleted, types.EventCreated, types.EventDeleted}},
+			expEvents: []types.WebhookEventType{types.EventDeleted, types.EventCreated},
+		},
+		{
+			testName: "AddWebhookUnknownEvent",
+			webhook: types.Webhook{URL: "https://example.com/hook", Events: []types.WebhookEventType{
+				"event.moved"}},
+			expError: customErrors.ErrBadRequest,
+		},
+		{
+			testName: "AddWebhookRelativeURL",
+			webhook:  types.Webhook{URL: "/hook"},
+			expError: customErrors.ErrBadRequest,
+		},
+		{
+			testName: "AddWebhookNotHTTP",
+			webhook:  types.Webhook{URL: "ftp://example.com/hook"},
+			expError: customErrors.ErrBadRequest,
+		},
+		{
+			testName: "AddWebhookLoopback",
+			webhook:  types.Webhook{URL: "http://localhost:8080/hook"},
+			expError: customErrors.ErrBadRequest,
+		},
+		{
+			testName: "AddWebhookPrivateNetwork",
+			webhook:  types.Webhook{URL: "https://intranet/hook"},
+			expError: customErrors.ErrBadRequest,
+		},
+		{
+			testName: "AddWebhookMetadataAddress",
+			webhook:  types.Webhook{URL: "http://169.254.169.254/latest/meta-data"},
+			expError: customErrors.ErrBadRequest,
+		},
+		{
+			testName: "AddWebhookUnknownHost",
+			webhook:  types.Webhook{URL: "https://unknown.example/hook"},
+			expError: customErrors.ErrBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockDB := mocks.NewMockWebhookRepository(mockCtrl)
+			bl := InitBusinessLogic(mockDB, hosts)
+
+			if tc.expError == nil {
+				mockDB.EXPECT().AddWebhook(ctx, gomock.Any(), "hello").DoAndReturn(
+					func(ctx context.Context, w types.Webhook, login string) (types.Webhook, error) {
+						w.ID = 1
+						return w, nil
+					})
+			}
+
+			w, err := bl.AddWebhook(ctx, tc.webhook)
+			require.ErrorIs(t, err, tc.expError)
+
+			if tc.expError == nil {
+				require.Equal(t, tc.expEvents, w.Events)
+				require.Len(t, w.Secret, 64, "secret should be 32 random bytes in hex")
+				require.False(t, w.CreatedAt.IsZero())
+			}
+		})
+	}
+}
+
+func TestWebhooksWithoutLogin(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	bl := InitBusinessLogic(mocks.NewMockWebhookRepository(mockCtrl), hosts)
+
+	_, err := bl.GetWebhooks(ctx)
+	require.ErrorIs(t, err, customErrors.ErrUnexpected)
+
+	_, err = bl.AddWebhook(ctx, types.Webhook{URL: "https://example.com/hook"})
+	require.ErrorIs(t, err, customErrors.ErrUnexpected)
+
+	err = bl.DeleteWebhook(ctx, 1)
+	require.ErrorIs(t, err, customErrors.ErrUnexpected)
+
+	_, err = bl.GetDeadLetters(ctx)
+	require.ErrorIs(t, err, customErrors.ErrUnexpected)
+
+	err = bl.RetryDelivery(ctx, 1)
+	require.ErrorIs(t, err, customErrors.ErrUnexpected)
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Config is shared by the workers which claim due items in the database and deliver them,
+// like the alert dispatcher and the webhook relay
+type Config struct {
+	// Interval between polls for due items, it is also the delay of the first retry
+	Interval time.Duration
+
+	// Lease is the time a claimed item stays locked for the worker which claimed it
+	Lease time.Duration
+
+	// BatchSize is the number of items claimed at once
+	BatchSize int
+
+	// MaxAttempts limits deliveries of an item before it is given up
+	MaxAttempts int
+}
+
+// MaxRetryDelay caps the exponential backoff of failed deliveries
+const MaxRetryDelay = time.Hour
+
+// Run calls poll at every interval until the context is cancelled, errors are logged with the name of the worker
+func Run(ctx context.Context, name string, interval time.Duration,
+	poll func(ctx context.Context, now time.Time) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := poll(ctx, time.Now())
+		if err != nil {
+			log.Printf("%s failed: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RetryDelay doubles the delay after every failed attempt
+func RetryDelay(interval time.Duration, attempts int) time.Duration {
+	delay := interval
+	for i := 1; i < attempts && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > MaxRetryDelay {
+		return MaxRetryDelay
+	}
+
+	return delay
+}
+
+// Send sends a request to a webhook, any response other than 2xx fails the delivery
+func Send(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
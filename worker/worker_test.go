@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryDelay(t *testing.T) {
+	testCases := []struct {
+		attempts int
+		exp      time.Duration
+	}{
+		{attempts: 1, exp: 30 * time.Second},
+		{attempts: 2, exp: time.Minute},
+		{attempts: 4, exp: 4 * time.Minute},
+		{attempts: 8, exp: MaxRetryDelay},
+		{attempts: 100, exp: MaxRetryDelay},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.exp, RetryDelay(30*time.Second, tc.attempts), "attempts: %d", tc.attempts)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	polls := 0
+	Run(ctx, "Test worker", time.Millisecond, func(ctx context.Context, now time.Time) (int, error) {
+		polls++
+		if polls == 3 {
+			cancel()
+		}
+		return 0, nil
+	})
+
+	require.Equal(t, 3, polls)
+}
+
+func TestSend(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, nil)
+	require.Nil(t, Send(server.Client(), req))
+
+	status = http.StatusServiceUnavailable
+	req, _ = http.NewRequest(http.MethodPost, server.URL, nil)
+	require.EqualError(t, Send(server.Client(), req), "webhook responded with 503 Service Unavailable")
+}